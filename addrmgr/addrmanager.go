@@ -1071,6 +1071,19 @@ func (a *AddrManager) SetServices(addr *wire.NetAddress, services protocol.Servi
 	}
 }
 
+// GetServices returns the services last known to be offered by the passed
+// address, or 0 if the address is not known.
+func (a *AddrManager) GetServices(addr *wire.NetAddress) protocol.ServiceFlag {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(addr)
+	if ka == nil {
+		return 0
+	}
+	return ka.na.Services
+}
+
 // New returns a new bitcoin address manager.
 // Use Start to begin processing asynchronous address updates.
 func New(dataDir string, lookupFunc func(string) ([]net.IP, er.R)) *AddrManager {
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/connmgr"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// serializedAnchors is the format of the anchors file.
+type serializedAnchors struct {
+	Addresses []string `json:"addresses"`
+}
+
+// anchorsFile returns the path of the file the anchors are saved to.
+func anchorsFile() string {
+	return filepath.Join(cfg.DataDir, anchorsFilename)
+}
+
+// loadAnchors reads the block-relay-only peers saved by the previous run.
+// The file is removed once read so a node which keeps crashing does not keep
+// reconnecting to the same peers, which could be the reason for the crash.
+func loadAnchors() []net.Addr {
+	path := anchorsFile()
+	r, errr := os.Open(path)
+	if os.IsNotExist(errr) {
+		return nil
+	} else if errr != nil {
+		log.Warnf("Error opening anchors file %s: %v", path, errr)
+		return nil
+	}
+	var sa serializedAnchors
+	errr = jsoniter.NewDecoder(r).Decode(&sa)
+	r.Close()
+	if errr := os.Remove(path); errr != nil {
+		log.Warnf("Failed to remove anchors file %s: %v", path, errr)
+	}
+	if errr != nil {
+		log.Warnf("Failed to parse anchors file %s: %v", path, errr)
+		return nil
+	}
+
+	anchors := make([]net.Addr, 0, len(sa.Addresses))
+	for _, addr := range sa.Addresses {
+		netAddr, err := addrStringToNetAddr(addr)
+		if err != nil {
+			log.Debugf("Skipping anchor %s: %v", addr, err)
+			continue
+		}
+		anchors = append(anchors, netAddr)
+	}
+	log.Debugf("Loaded %d anchors from file '%s'", len(anchors), path)
+	return anchors
+}
+
+// saveAnchors writes the addresses of the connected block-relay-only peers to
+// the anchors file.  It is invoked from the peerHandler goroutine.
+func (s *server) saveAnchors(state *peerState) {
+	var sa serializedAnchors
+	state.forAllOutboundPeers(func(sp *serverPeer) {
+		if sp.connType() == connmgr.ConnBlockRelayOnly && sp.Connected() {
+			sa.Addresses = append(sa.Addresses, sp.connReq.Addr.String())
+		}
+	})
+	if len(sa.Addresses) == 0 {
+		return
+	}
+
+	path := anchorsFile()
+	if err := writeAnchors(path, &sa); err != nil {
+		log.Errorf("Failed to write anchors file %s: %v", path, err)
+		return
+	}
+	log.Debugf("Saved %d anchors to file '%s'", len(sa.Addresses), path)
+}
+
+// writeAnchors encodes the anchors to the file at path.
+func writeAnchors(path string, sa *serializedAnchors) er.R {
+	w, errr := os.Create(path)
+	if errr != nil {
+		return er.E(errr)
+	}
+	defer w.Close()
+	return er.E(jsoniter.NewEncoder(w).Encode(sa))
+}
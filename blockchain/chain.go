@@ -227,6 +227,17 @@ func (b *BlockChain) IsKnownOrphan(hash *chainhash.Hash) bool {
 	return exists
 }
 
+// OrphanCount returns the number of orphan blocks currently held.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) OrphanCount() int {
+	b.orphanLock.RLock()
+	count := len(b.orphans)
+	b.orphanLock.RUnlock()
+
+	return count
+}
+
 // GetOrphanRoot returns the head of the chain for the provided hash from the
 // map of orphan blocks.
 //
@@ -1318,6 +1329,63 @@ func (b *BlockChain) HeaderByHash(hash *chainhash.Hash) (wire.BlockHeader, er.R)
 	return node.Header(), nil
 }
 
+// BlockPath returns the headers of the blocks which are disconnected and then
+// connected to move the tip of a chain from the block with hash from to the
+// block with hash to.  The blocks need not be in the main chain, which allows
+// following a reorganization from a block which has since been orphaned.
+// Detached headers are ordered from the from block back towards the fork point
+// and attached headers from the fork point towards the to block.  The height
+// of the fork point is returned along with them.  An error is returned if
+// either block is unknown or if more than maxBlocks headers would be returned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockPath(from, to *chainhash.Hash, maxBlocks int) (
+	[]wire.BlockHeader, []wire.BlockHeader, int32, er.R) {
+
+	fromNode := b.index.LookupNode(from)
+	if fromNode == nil {
+		return nil, nil, 0, er.Errorf("block %s is not known", from)
+	}
+	toNode := b.index.LookupNode(to)
+	if toNode == nil {
+		return nil, nil, 0, er.Errorf("block %s is not known", to)
+	}
+
+	// Find the fork point by walking back from the higher of the two blocks
+	// to the height of the lower one and then from both in step.
+	forkFrom, forkTo := fromNode, toNode
+	if forkFrom.height > forkTo.height {
+		forkFrom = forkFrom.Ancestor(forkTo.height)
+	} else if forkTo.height > forkFrom.height {
+		forkTo = forkTo.Ancestor(forkFrom.height)
+	}
+	for forkFrom != forkTo {
+		forkFrom, forkTo = forkFrom.parent, forkTo.parent
+	}
+	if forkFrom == nil {
+		str := fmt.Sprintf("blocks %s and %s do not share an ancestor",
+			from, to)
+		return nil, nil, 0, er.New(str)
+	}
+	fork := forkFrom
+
+	count := (fromNode.height - fork.height) + (toNode.height - fork.height)
+	if int(count) > maxBlocks {
+		return nil, nil, 0, er.Errorf("path from block %s to %s is %d "+
+			"blocks long, the limit is %d", from, to, count, maxBlocks)
+	}
+
+	detach := make([]wire.BlockHeader, 0, fromNode.height-fork.height)
+	for n := fromNode; n != fork; n = n.parent {
+		detach = append(detach, n.Header())
+	}
+	attach := make([]wire.BlockHeader, toNode.height-fork.height)
+	for n := toNode; n != fork; n = n.parent {
+		attach[n.height-fork.height-1] = n.Header()
+	}
+	return detach, attach, fork.height, nil
+}
+
 // MainChainHasBlock returns whether or not the block with the given hash is in
 // the main chain.
 //
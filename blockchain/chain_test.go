@@ -894,6 +894,124 @@ func TestHeightToHashRange(t *testing.T) {
 	}
 }
 
+// TestBlockPath ensures the blocks detached and attached to move between two
+// blocks are found whether or not the blocks are in the main chain.
+func TestBlockPath(t *testing.T) {
+	// Construct a synthetic block chain with a block index consisting of
+	// the following structure.
+	// 	genesis -> 1 -> 2 -> ... -> 15 -> 16  -> 17  -> 18
+	// 	                              \-> 16a -> 17a -> 18a
+	chain := newFakeChain(&chaincfg.MainNetParams)
+	branch0Nodes := chainedNodes(chain.bestChain.Genesis(), 18)
+	branch1Nodes := chainedNodes(branch0Nodes[14], 3)
+	for _, node := range branch0Nodes {
+		chain.index.AddNode(node)
+	}
+	for _, node := range branch1Nodes {
+		chain.index.AddNode(node)
+	}
+	chain.bestChain.SetTip(tstTip(branch0Nodes))
+	unknown := chainedNodes(nil, 1)[0]
+
+	tests := []struct {
+		name        string
+		from        chainhash.Hash
+		to          chainhash.Hash
+		maxBlocks   int
+		detach      []wire.BlockHeader
+		attach      []wire.BlockHeader
+		forkHeight  int32
+		expectError bool
+	}{
+		{
+			name:       "same block",
+			from:       branch0Nodes[17].hash,
+			to:         branch0Nodes[17].hash,
+			maxBlocks:  10,
+			detach:     []wire.BlockHeader{},
+			attach:     []wire.BlockHeader{},
+			forkHeight: 18,
+		},
+		{
+			name:       "forward along main chain",
+			from:       branch0Nodes[13].hash,
+			to:         branch0Nodes[16].hash,
+			maxBlocks:  10,
+			detach:     []wire.BlockHeader{},
+			attach:     nodeHeaders(branch0Nodes, 14, 15, 16),
+			forkHeight: 14,
+		},
+		{
+			name:       "back along main chain",
+			from:       branch0Nodes[16].hash,
+			to:         branch0Nodes[14].hash,
+			maxBlocks:  10,
+			detach:     nodeHeaders(branch0Nodes, 16, 15),
+			attach:     []wire.BlockHeader{},
+			forkHeight: 15,
+		},
+		{
+			name:       "from orphaned block",
+			from:       branch1Nodes[2].hash,
+			to:         branch0Nodes[17].hash,
+			maxBlocks:  10,
+			detach:     nodeHeaders(branch1Nodes, 2, 1, 0),
+			attach:     nodeHeaders(branch0Nodes, 15, 16, 17),
+			forkHeight: 15,
+		},
+		{
+			name:       "to side chain",
+			from:       branch0Nodes[16].hash,
+			to:         branch1Nodes[2].hash,
+			maxBlocks:  10,
+			detach:     nodeHeaders(branch0Nodes, 16, 15),
+			attach:     nodeHeaders(branch1Nodes, 0, 1, 2),
+			forkHeight: 15,
+		},
+		{
+			name:        "too many blocks",
+			from:        branch1Nodes[2].hash,
+			to:          branch0Nodes[17].hash,
+			maxBlocks:   5,
+			expectError: true,
+		},
+		{
+			name:        "unknown block",
+			from:        unknown.hash,
+			to:          branch0Nodes[17].hash,
+			maxBlocks:   10,
+			expectError: true,
+		},
+	}
+	for _, test := range tests {
+		detach, attach, forkHeight, err := chain.BlockPath(&test.from,
+			&test.to, test.maxBlocks)
+		if err != nil {
+			if !test.expectError {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		if test.expectError {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+
+		if !reflect.DeepEqual(detach, test.detach) {
+			t.Errorf("%s: unexpected detached headers -- got %v, want %v",
+				test.name, detach, test.detach)
+		}
+		if !reflect.DeepEqual(attach, test.attach) {
+			t.Errorf("%s: unexpected attached headers -- got %v, want %v",
+				test.name, attach, test.attach)
+		}
+		if forkHeight != test.forkHeight {
+			t.Errorf("%s: unexpected fork height -- got %d, want %d",
+				test.name, forkHeight, test.forkHeight)
+		}
+	}
+}
+
 // TestIntervalBlockHashes ensures that fetching block hashes at specified
 // intervals by end hash works as expected.
 func TestIntervalBlockHashes(t *testing.T) {
@@ -0,0 +1,256 @@
+package btcec
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+)
+
+// EllSwiftPubKeyLen is the length of an ElligatorSwift encoded public key as
+// used by BIP0324.
+const EllSwiftPubKeyLen = 64
+
+var (
+	// ellSwiftC3 is a square root of -3 modulo P, computed the same way as
+	// the BIP0324 reference implementation so the decoding is compatible.
+	ellSwiftC3 *big.Int
+
+	// bigSeven is the constant B of the secp256k1 curve equation.
+	bigSeven = big.NewInt(7)
+)
+
+func init() {
+	p := S256().P
+	minus3 := new(big.Int).Sub(p, big.NewInt(3))
+	ellSwiftC3 = fieldSqrt(minus3)
+}
+
+// fieldSqrt returns a square root of a modulo the secp256k1 field prime, or
+// nil if a is not a quadratic residue.
+func fieldSqrt(a *big.Int) *big.Int {
+	p := S256().P
+	r := new(big.Int).Exp(a, S256().QPlus1Div4(), p)
+	check := new(big.Int).Mul(r, r)
+	check.Mod(check, p)
+	if check.Cmp(new(big.Int).Mod(a, p)) != 0 {
+		return nil
+	}
+	return r
+}
+
+// fieldInv returns the multiplicative inverse of a modulo the field prime.
+// The result is nil when a is zero.
+func fieldInv(a *big.Int) *big.Int {
+	p := S256().P
+	if new(big.Int).Mod(a, p).Sign() == 0 {
+		return nil
+	}
+	return new(big.Int).ModInverse(a, p)
+}
+
+// curveRHS returns x^3 + 7 modulo P.
+func curveRHS(x *big.Int) *big.Int {
+	p := S256().P
+	r := new(big.Int).Mul(x, x)
+	r.Mul(r, x)
+	r.Add(r, bigSeven)
+	return r.Mod(r, p)
+}
+
+// isValidX returns whether x is the X coordinate of a point on the curve.
+func isValidX(x *big.Int) bool {
+	return fieldSqrt(curveRHS(x)) != nil
+}
+
+// XSwiftEC decodes the field elements u and t of an ElligatorSwift encoding
+// to an X coordinate on the curve.  Every pair of field elements decodes to a
+// valid X coordinate.
+func XSwiftEC(u, t *big.Int) *big.Int {
+	p := S256().P
+	mod := func(v *big.Int) *big.Int { return v.Mod(v, p) }
+
+	u = mod(new(big.Int).Set(u))
+	t = mod(new(big.Int).Set(t))
+	if u.Sign() == 0 {
+		u.SetInt64(1)
+	}
+	if t.Sign() == 0 {
+		t.SetInt64(1)
+	}
+	u3p7 := curveRHS(u)
+	t2 := mod(new(big.Int).Mul(t, t))
+	if mod(new(big.Int).Add(u3p7, t2)).Sign() == 0 {
+		t = mod(t.Lsh(t, 1))
+		t2 = mod(new(big.Int).Mul(t, t))
+	}
+
+	// X = (u^3 + 7 - t^2) / (2 * t)
+	X := mod(new(big.Int).Sub(u3p7, t2))
+	X = mod(X.Mul(X, fieldInv(new(big.Int).Lsh(t, 1))))
+
+	// Y = (X + t) / (sqrt(-3) * u)
+	Y := mod(new(big.Int).Add(X, t))
+	Y = mod(Y.Mul(Y, fieldInv(mod(new(big.Int).Mul(ellSwiftC3, u)))))
+
+	// x1 = u + 4 * Y^2
+	x := mod(new(big.Int).Mul(Y, Y))
+	x = mod(x.Lsh(x, 2).Add(x, u))
+	if isValidX(x) {
+		return x
+	}
+
+	// x2 = (-X / Y - u) / 2 and x3 = (X / Y - u) / 2
+	xy := mod(new(big.Int).Mul(X, fieldInv(Y)))
+	half := fieldInv(big.NewInt(2))
+	x = mod(new(big.Int).Neg(xy))
+	x = mod(x.Sub(x, u).Mul(x, half))
+	if isValidX(x) {
+		return x
+	}
+	x = mod(new(big.Int).Sub(xy, u))
+	return mod(x.Mul(x, half))
+}
+
+// xSwiftECInv finds a t such that XSwiftEC(u, t) = x, or returns nil if there
+// is none for the selected case.  The case, in the range [0, 8), selects
+// between the up to eight preimages of x for a given u.
+func xSwiftECInv(x, u *big.Int, c int) *big.Int {
+	p := S256().P
+	mod := func(v *big.Int) *big.Int { return v.Mod(v, p) }
+
+	u3p7 := curveRHS(u)
+	var s, v *big.Int
+	if c&2 == 0 {
+		// x must be decoded as x2 or x3, which requires -x - u to not
+		// be on the curve.
+		other := mod(new(big.Int).Neg(x))
+		if isValidX(mod(other.Sub(other, u))) {
+			return nil
+		}
+		v = x
+		den := new(big.Int).Mul(u, u)
+		den.Add(den, new(big.Int).Mul(u, v))
+		den.Add(den, new(big.Int).Mul(v, v))
+		inv := fieldInv(mod(den))
+		if inv == nil {
+			return nil
+		}
+		s = mod(new(big.Int).Neg(u3p7))
+		s = mod(s.Mul(s, inv))
+	} else {
+		s = mod(new(big.Int).Sub(x, u))
+		if s.Sign() == 0 {
+			return nil
+		}
+		// r = sqrt(-s * (4 * (u^3 + 7) + 3 * s * u^2))
+		r := new(big.Int).Lsh(u3p7, 2)
+		r.Add(r, new(big.Int).Mul(big.NewInt(3), new(big.Int).Mul(s, new(big.Int).Mul(u, u))))
+		r = mod(r.Mul(r, new(big.Int).Neg(s)))
+		r = fieldSqrt(r)
+		if r == nil {
+			return nil
+		}
+		if c&1 != 0 && r.Sign() == 0 {
+			return nil
+		}
+		v = mod(new(big.Int).Mul(r, fieldInv(s)))
+		v = mod(v.Sub(v, u).Mul(v, fieldInv(big.NewInt(2))))
+	}
+	w := fieldSqrt(s)
+	if w == nil {
+		return nil
+	}
+
+	// t = +/- w * (u * (1 -/+ sqrt(-3)) / 2 + v)
+	k := big.NewInt(1)
+	if c&1 == 0 {
+		k.Sub(k, ellSwiftC3)
+	} else {
+		k.Add(k, ellSwiftC3)
+	}
+	k = mod(k.Mul(k, u).Mul(k, fieldInv(big.NewInt(2))))
+	t := mod(k.Add(k, v).Mul(k, w))
+	if c&5 == 0 || c&5 == 5 {
+		t = mod(t.Neg(t))
+	}
+	return t
+}
+
+// randFieldElement returns a uniformly random non-zero field element.
+func randFieldElement() (*big.Int, er.R) {
+	p := S256().P
+	for {
+		var buf [32]byte
+		if _, errr := rand.Read(buf[:]); errr != nil {
+			return nil, er.E(errr)
+		}
+		f := new(big.Int).SetBytes(buf[:])
+		if f.Sign() != 0 && f.Cmp(p) < 0 {
+			return f, nil
+		}
+	}
+}
+
+// EllSwiftEncode returns a randomized 64 byte ElligatorSwift encoding of the
+// public key.  The encoding is indistinguishable from uniformly random bytes
+// and only preserves the X coordinate of the key.
+func EllSwiftEncode(pubKey *PublicKey) ([EllSwiftPubKeyLen]byte, er.R) {
+	var out [EllSwiftPubKeyLen]byte
+	for {
+		u, err := randFieldElement()
+		if err != nil {
+			return out, err
+		}
+		var cb [1]byte
+		if _, errr := rand.Read(cb[:]); errr != nil {
+			return out, er.E(errr)
+		}
+		t := xSwiftECInv(pubKey.X, u, int(cb[0]&7))
+		if t == nil || XSwiftEC(u, t).Cmp(pubKey.X) != 0 {
+			continue
+		}
+		copy(out[32-len(u.Bytes()):32], u.Bytes())
+		copy(out[64-len(t.Bytes()):], t.Bytes())
+		return out, nil
+	}
+}
+
+// EllSwiftDecode decodes a 64 byte ElligatorSwift encoding into the public key
+// with the encoded X coordinate and an even Y coordinate.
+func EllSwiftDecode(enc [EllSwiftPubKeyLen]byte) *PublicKey {
+	u := new(big.Int).SetBytes(enc[:32])
+	t := new(big.Int).SetBytes(enc[32:])
+	x := XSwiftEC(u, t)
+	y := fieldSqrt(curveRHS(x))
+	if y.Bit(0) == 1 {
+		y.Sub(S256().P, y)
+	}
+	return &PublicKey{Curve: S256(), X: x, Y: y}
+}
+
+// EllSwiftCreate generates a new private key along with an ElligatorSwift
+// encoding of its public key.
+func EllSwiftCreate() (*PrivateKey, [EllSwiftPubKeyLen]byte, er.R) {
+	privKey, err := NewPrivateKey(S256())
+	if err != nil {
+		return nil, [EllSwiftPubKeyLen]byte{}, err
+	}
+	enc, err := EllSwiftEncode(privKey.PubKey())
+	if err != nil {
+		return nil, [EllSwiftPubKeyLen]byte{}, err
+	}
+	return privKey, enc, nil
+}
+
+// EllSwiftECDHXOnly performs an x-only Diffie-Hellman key exchange with the
+// ElligatorSwift encoded public key of the remote party and returns the
+// 32 byte X coordinate of the shared point.
+func EllSwiftECDHXOnly(theirs [EllSwiftPubKeyLen]byte, privKey *PrivateKey) [32]byte {
+	pub := EllSwiftDecode(theirs)
+	x, _ := S256().ScalarMult(pub.X, pub.Y, privKey.D.Bytes())
+	var out [32]byte
+	xb := x.Bytes()
+	copy(out[32-len(xb):], xb)
+	return out
+}
@@ -0,0 +1,80 @@
+package btcec
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// TestEllSwiftDecodeVector checks decoding against the BIP0324 test vector
+// for the all zero encoding.
+func TestEllSwiftDecodeVector(t *testing.T) {
+	var enc [EllSwiftPubKeyLen]byte
+	want, _ := hex.DecodeString("edd1fd3e327ce90cc7a3542614289aee9682003e9cf7dcc9cf2ca9743be5aa0c")
+	pub := EllSwiftDecode(enc)
+	if !bytes.Equal(pub.X.Bytes(), want) {
+		t.Fatalf("decode mismatch - got: %x, want: %x", pub.X.Bytes(), want)
+	}
+}
+
+// TestEllSwiftRoundTrip ensures encodings decode back to the original key.
+func TestEllSwiftRoundTrip(t *testing.T) {
+	for i := 0; i < 32; i++ {
+		privKey, enc, err := EllSwiftCreate()
+		if err != nil {
+			t.Fatalf("EllSwiftCreate: %v", err)
+		}
+		pub := EllSwiftDecode(enc)
+		if pub.X.Cmp(privKey.PubKey().X) != 0 {
+			t.Fatalf("round trip #%d mismatch - got: %x, want: %x",
+				i, pub.X, privKey.PubKey().X)
+		}
+	}
+}
+
+// TestEllSwiftInverse ensures every preimage found by the inverse decodes to
+// the requested X coordinate.
+func TestEllSwiftInverse(t *testing.T) {
+	privKey, err := NewPrivateKey(S256())
+	if err != nil {
+		t.Fatalf("private key generation error: %s", err)
+	}
+	x := privKey.PubKey().X
+	found := 0
+	for i := int64(1); i < 64; i++ {
+		u := new(big.Int).SetInt64(i * 7919)
+		for c := 0; c < 8; c++ {
+			tt := xSwiftECInv(x, u, c)
+			if tt == nil {
+				continue
+			}
+			found++
+			if got := XSwiftEC(u, tt); got.Cmp(x) != 0 {
+				t.Fatalf("u=%x case=%d decodes to %x, want %x",
+					u, c, got, x)
+			}
+		}
+	}
+	if found == 0 {
+		t.Fatalf("no preimages found")
+	}
+}
+
+// TestEllSwiftECDH ensures both sides of an x-only ECDH agree.
+func TestEllSwiftECDH(t *testing.T) {
+	priv1, enc1, err := EllSwiftCreate()
+	if err != nil {
+		t.Fatalf("EllSwiftCreate: %v", err)
+	}
+	priv2, enc2, err := EllSwiftCreate()
+	if err != nil {
+		t.Fatalf("EllSwiftCreate: %v", err)
+	}
+	s1 := EllSwiftECDHXOnly(enc2, priv1)
+	s2 := EllSwiftECDHXOnly(enc1, priv2)
+	if s1 != s2 {
+		t.Fatalf("ECDH failed, secrets mismatch - first: %x, second: %x",
+			s1, s2)
+	}
+}
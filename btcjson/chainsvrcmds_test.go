@@ -41,6 +41,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, er.R) {
@@ -432,6 +443,17 @@ func TestChainSvrCmds(t *testing.T) {
 				TxID: "txhash",
 			},
 		},
+		{
+			name: "getmempoolfeehistogram",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("getmempoolfeehistogram")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolFeeHistogramCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getmempoolfeehistogram","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolFeeHistogramCmd{},
+		},
 		{
 			name: "getmempoolinfo",
 			newCmd: func() (interface{}, er.R) {
@@ -465,6 +487,28 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getnetworkinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetNetworkInfoCmd{},
 		},
+		{
+			name: "getnetmsgstats",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("getnetmsgstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetNetMsgStatsCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getnetmsgstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetNetMsgStatsCmd{PeerID: nil},
+		},
+		{
+			name: "getnetmsgstats optional",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("getnetmsgstats", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetNetMsgStatsCmd(btcjson.Int32(3))
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getnetmsgstats","params":[3],"id":1}`,
+			unmarshalled: &btcjson.GetNetMsgStatsCmd{PeerID: btcjson.Int32(3)},
+		},
 		{
 			name: "getnettotals",
 			newCmd: func() (interface{}, er.R) {
@@ -535,11 +579,12 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("getrawmempool")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetRawMempoolCmd(nil)
+				return btcjson.NewGetRawMempoolCmd(nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getrawmempool","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetRawMempoolCmd{
-				Verbose: btcjson.Bool(false),
+				Verbose:         btcjson.Bool(false),
+				MempoolSequence: btcjson.Bool(false),
 			},
 		},
 		{
@@ -548,11 +593,26 @@ func TestChainSvrCmds(t *testing.T) {
 				return btcjson.NewCmd("getrawmempool", false)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewGetRawMempoolCmd(btcjson.Bool(false))
+				return btcjson.NewGetRawMempoolCmd(btcjson.Bool(false), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"getrawmempool","params":[false],"id":1}`,
 			unmarshalled: &btcjson.GetRawMempoolCmd{
-				Verbose: btcjson.Bool(false),
+				Verbose:         btcjson.Bool(false),
+				MempoolSequence: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getrawmempool sequence",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("getrawmempool", false, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRawMempoolCmd(btcjson.Bool(false), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getrawmempool","params":[false,true],"id":1}`,
+			unmarshalled: &btcjson.GetRawMempoolCmd{
+				Verbose:         btcjson.Bool(false),
+				MempoolSequence: btcjson.Bool(true),
 			},
 		},
 		{
@@ -719,6 +779,28 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
+		{
+			name: "loadmempool",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("loadmempool")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewLoadMempoolCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"loadmempool","params":[],"id":1}`,
+			unmarshalled: &btcjson.LoadMempoolCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, er.R) {
@@ -743,6 +825,20 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "0123",
 			},
 		},
+		{
+			name: "prioritisetransaction",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("prioritisetransaction", "0123", -1000)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewPrioritiseTransactionCmd("0123", -1000)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"prioritisetransaction","params":["0123",-1000],"id":1}`,
+			unmarshalled: &btcjson.PrioritiseTransactionCmd{
+				TxID:     "0123",
+				FeeDelta: -1000,
+			},
+		},
 		{
 			name: "reconsiderblock",
 			newCmd: func() (interface{}, er.R) {
@@ -756,6 +852,17 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "savemempool",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("savemempool")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSaveMempoolCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"savemempool","params":[],"id":1}`,
+			unmarshalled: &btcjson.SaveMempoolCmd{},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, er.R) {
@@ -923,6 +1030,39 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("setban", "10.0.0.1", btcjson.SBAdd, 3600, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.1", btcjson.SBAdd,
+					btcjson.Int64(3600), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.1","add",3600,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.1",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(3600),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, er.R) {
@@ -995,6 +1135,47 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "submitpackage",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("submitpackage", []string{"1122", "3344"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSubmitPackageCmd([]string{"1122", "3344"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"submitpackage","params":[["1122","3344"]],"id":1}`,
+			unmarshalled: &btcjson.SubmitPackageCmd{
+				RawTxs: []string{"1122", "3344"},
+			},
+		},
+		{
+			name: "testmempoolaccept",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122", "3344"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122", "3344"}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122","3344"]],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxs:     []string{"1122", "3344"},
+				MaxFeeRate: btcjson.Float64(0.1),
+			},
+		},
+		{
+			name: "testmempoolaccept optional",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("testmempoolaccept", []string{"1122"}, 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestMempoolAcceptCmd([]string{"1122"}, btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testmempoolaccept","params":[["1122"],0.5],"id":1}`,
+			unmarshalled: &btcjson.TestMempoolAcceptCmd{
+				RawTxs:     []string{"1122"},
+				MaxFeeRate: btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "uptime",
 			newCmd: func() (interface{}, er.R) {
@@ -200,8 +200,69 @@ type GetBlockTemplateResult struct {
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
 // command.
 type GetMempoolInfoResult struct {
-	Size  int64 `json:"size"`
-	Bytes int64 `json:"bytes"`
+	Size          int64   `json:"size"`
+	Bytes         int64   `json:"bytes"`
+	MempoolMinFee float64 `json:"mempoolminfee"`
+}
+
+// SaveMempoolResult models the data returned from the savemempool command.
+type SaveMempoolResult struct {
+	Filename string `json:"filename"`
+	Size     int    `json:"size"`
+}
+
+// LoadMempoolResult models the data returned from the loadmempool command.
+type LoadMempoolResult struct {
+	Filename string `json:"filename"`
+	Loaded   int    `json:"loaded"`
+	Failed   int    `json:"failed"`
+}
+
+// TestMempoolAcceptResult models the data returned for each transaction from
+// the testmempoolaccept command.
+type TestMempoolAcceptResult struct {
+	TxID         string                 `json:"txid"`
+	WTxID        string                 `json:"wtxid"`
+	Allowed      bool                   `json:"allowed"`
+	VSize        int64                  `json:"vsize,omitempty"`
+	Fees         *TestMempoolAcceptFees `json:"fees,omitempty"`
+	RejectReason string                 `json:"reject-reason,omitempty"`
+}
+
+// TestMempoolAcceptFees models the fees of a transaction which would be
+// accepted, as returned from the testmempoolaccept command.
+type TestMempoolAcceptFees struct {
+	Base float64 `json:"base"`
+}
+
+// SubmitPackageResult models the data returned from the submitpackage command.
+type SubmitPackageResult struct {
+	PackageMsg string                  `json:"package_msg"`
+	TxResults  []SubmitPackageTxResult `json:"tx-results"`
+}
+
+// SubmitPackageTxResult models the data returned for each transaction from the
+// submitpackage command.
+type SubmitPackageTxResult struct {
+	TxID  string                 `json:"txid"`
+	WTxID string                 `json:"wtxid"`
+	VSize int64                  `json:"vsize,omitempty"`
+	Fees  *TestMempoolAcceptFees `json:"fees,omitempty"`
+	Error string                 `json:"error,omitempty"`
+}
+
+// NetMsgStat models the traffic of a single message type returned from the
+// getnetmsgstats command.
+type NetMsgStat struct {
+	Bytes uint64 `json:"bytes"`
+	Count uint64 `json:"count"`
+}
+
+// GetNetMsgStatsResult models the data returned from the getnetmsgstats
+// command.
+type GetNetMsgStatsResult struct {
+	Sent map[string]NetMsgStat `json:"sent"`
+	Recv map[string]NetMsgStat `json:"recv"`
 }
 
 // GetNetworkStewardResult models the data returned from the getnetworksteward command.
@@ -234,6 +295,11 @@ type GetPeerInfoResult struct {
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+	TransportType  string  `json:"transport_protocol_type"`
+	SessionID      string  `json:"session_id"`
+
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
 }
 
 type GetNetworkInfoNetworks struct {
@@ -273,6 +339,8 @@ type GetRawMempoolVerboseResult struct {
 	Size             int32    `json:"size"`
 	Vsize            int32    `json:"vsize"`
 	Fee              float64  `json:"fee"`
+	ModifiedFee      float64  `json:"modifiedfee"`
+	FeeDelta         float64  `json:"feedelta"`
 	Time             int64    `json:"time"`
 	Height           int64    `json:"height"`
 	StartingPriority float64  `json:"startingpriority"`
@@ -280,6 +348,20 @@ type GetRawMempoolVerboseResult struct {
 	Depends          []string `json:"depends"`
 }
 
+// GetMempoolFeeHistogramResult models the data returned from the
+// getmempoolfeehistogram command.  Each entry is a pair of a fee rate in
+// satoshi per virtual byte and the total virtual size of the transactions
+// paying at least that fee rate and less than the fee rate of the previous
+// entry.
+type GetMempoolFeeHistogramResult [][2]float64
+
+// GetRawMempoolSequenceResult models the data returned from the getrawmempool
+// command when the mempool sequence number is requested.
+type GetRawMempoolSequenceResult struct {
+	TxIDs           []string `json:"txids"`
+	MempoolSequence uint64   `json:"mempool_sequence"`
+}
+
 // GetTxOutResult models the data from the gettxout command.
 type GetTxOutResult struct {
 	BestBlock     string  `json:"bestblock"`
@@ -291,11 +373,36 @@ type GetTxOutResult struct {
 	Coinbase      bool    `json:"coinbase"`
 }
 
+// GetNetTotalsUploadTargetResult models the uploadtarget section of the data
+// returned from the getnettotals command.
+type GetNetTotalsUploadTargetResult struct {
+	TimeFrame             int64  `json:"timeframe"`
+	Target                uint64 `json:"target"`
+	TargetReached         bool   `json:"target_reached"`
+	ServeHistoricalBlocks bool   `json:"serve_historical_blocks"`
+	BytesLeftInCycle      uint64 `json:"bytes_left_in_cycle"`
+	TimeLeftInCycle       int64  `json:"time_left_in_cycle"`
+	MaxUploadRate         uint64 `json:"max_upload_rate"`
+	MaxPeerUploadRate     uint64 `json:"max_peer_upload_rate"`
+}
+
 // GetNetTotalsResult models the data returned from the getnettotals command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv uint64                         `json:"totalbytesrecv"`
+	TotalBytesSent uint64                         `json:"totalbytessent"`
+	TimeMillis     int64                          `json:"timemillis"`
+	UploadTarget   GetNetTotalsUploadTargetResult `json:"uploadtarget"`
+}
+
+// ListBannedResult models a ban returned from the listbanned command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	Reason        string `json:"reason"`
+	BanScore      int32  `json:"banscore"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
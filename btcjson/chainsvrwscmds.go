@@ -41,6 +41,50 @@ func NewStopNotifyBlocksCmd() *StopNotifyBlocksCmd {
 	return &StopNotifyBlocksCmd{}
 }
 
+// NotifyChainEventsCmd defines the notifychainevents JSON-RPC command.
+type NotifyChainEventsCmd struct {
+	From *string
+}
+
+// NewNotifyChainEventsCmd returns a new instance which can be used to issue a
+// notifychainevents JSON-RPC command.
+//
+// From is either the sequence number of the last event the client received or
+// the hash of the tip it last saw, nil starts the stream at the next event.
+func NewNotifyChainEventsCmd(from *string) *NotifyChainEventsCmd {
+	return &NotifyChainEventsCmd{
+		From: from,
+	}
+}
+
+// StopNotifyChainEventsCmd defines the stopnotifychainevents JSON-RPC command.
+type StopNotifyChainEventsCmd struct{}
+
+// NewStopNotifyChainEventsCmd returns a new instance which can be used to issue
+// a stopnotifychainevents JSON-RPC command.
+func NewStopNotifyChainEventsCmd() *StopNotifyChainEventsCmd {
+	return &StopNotifyChainEventsCmd{}
+}
+
+// NotifyMempoolEventsCmd defines the notifymempoolevents JSON-RPC command.
+type NotifyMempoolEventsCmd struct{}
+
+// NewNotifyMempoolEventsCmd returns a new instance which can be used to issue
+// a notifymempoolevents JSON-RPC command.
+func NewNotifyMempoolEventsCmd() *NotifyMempoolEventsCmd {
+	return &NotifyMempoolEventsCmd{}
+}
+
+// StopNotifyMempoolEventsCmd defines the stopnotifymempoolevents JSON-RPC
+// command.
+type StopNotifyMempoolEventsCmd struct{}
+
+// NewStopNotifyMempoolEventsCmd returns a new instance which can be used to
+// issue a stopnotifymempoolevents JSON-RPC command.
+func NewStopNotifyMempoolEventsCmd() *StopNotifyMempoolEventsCmd {
+	return &StopNotifyMempoolEventsCmd{}
+}
+
 // NotifyNewTransactionsCmd defines the notifynewtransactions JSON-RPC command.
 type NotifyNewTransactionsCmd struct {
 	Verbose *bool `jsonrpcdefault:"false"`
@@ -222,11 +266,15 @@ func init() {
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("notifychainevents", (*NotifyChainEventsCmd)(nil), flags)
+	MustRegisterCmd("notifymempoolevents", (*NotifyMempoolEventsCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
+	MustRegisterCmd("stopnotifychainevents", (*StopNotifyChainEventsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifymempoolevents", (*StopNotifyMempoolEventsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
@@ -64,6 +64,76 @@ func TestChainSvrWsCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyblocks","params":[],"id":1}`,
 			unmarshalled: &btcjson.StopNotifyBlocksCmd{},
 		},
+		{
+			name: "notifychainevents",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("notifychainevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifychainevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{},
+		},
+		{
+			name: "notifychainevents seq",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("notifychainevents", "42")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(btcjson.String("42"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifychainevents","params":["42"],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{
+				From: btcjson.String("42"),
+			},
+		},
+		{
+			name: "notifychainevents hash",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("notifychainevents", "0bdc1712a46194e552cf417ab0439c2d4f456c35cf63a0a406964c6f93432d85")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyChainEventsCmd(btcjson.String("0bdc1712a46194e552cf417ab0439c2d4f456c35cf63a0a406964c6f93432d85"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"notifychainevents","params":["0bdc1712a46194e552cf417ab0439c2d4f456c35cf63a0a406964c6f93432d85"],"id":1}`,
+			unmarshalled: &btcjson.NotifyChainEventsCmd{
+				From: btcjson.String("0bdc1712a46194e552cf417ab0439c2d4f456c35cf63a0a406964c6f93432d85"),
+			},
+		},
+		{
+			name: "stopnotifychainevents",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("stopnotifychainevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyChainEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifychainevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyChainEventsCmd{},
+		},
+		{
+			name: "notifymempoolevents",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("notifymempoolevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyMempoolEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifymempoolevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyMempoolEventsCmd{},
+		},
+		{
+			name: "stopnotifymempoolevents",
+			newCmd: func() (interface{}, er.R) {
+				return btcjson.NewCmd("stopnotifymempoolevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyMempoolEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifymempoolevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyMempoolEventsCmd{},
+		},
 		{
 			name: "notifynewtransactions",
 			newCmd: func() (interface{}, er.R) {
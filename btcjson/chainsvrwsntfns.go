@@ -75,8 +75,43 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// ChainEventNtfnMethod is the method used for notifications from the
+	// chain server of an entry in the ordered log of blocks connected to
+	// and disconnected from the main chain.
+	ChainEventNtfnMethod = "chainevent"
+
+	// MempoolEventNtfnMethod is the method used for notifications from the
+	// chain server that a transaction was added to or removed from the
+	// mempool.
+	MempoolEventNtfnMethod = "mempoolevent"
+)
+
+// Chain event types.
+const (
+	// ChainEventBlockConnected is the type of a chain event for a block
+	// connected to the main chain.
+	ChainEventBlockConnected = "blockconnected"
+
+	// ChainEventBlockDisconnected is the type of a chain event for a block
+	// disconnected from the main chain.
+	ChainEventBlockDisconnected = "blockdisconnected"
 )
 
+// ChainEvent is an entry in the ordered log of blocks connected to and
+// disconnected from the main chain.  Seq increases by one with each event, it
+// is zero for events replayed from before the start of the retained log.
+// PrevHash is the hash of the block before this one, which is the tip of the
+// chain after a disconnect.
+type ChainEvent struct {
+	Seq      uint64 `json:"seq"`
+	Type     string `json:"type"`
+	Hash     string `json:"hash"`
+	Height   int32  `json:"height"`
+	PrevHash string `json:"prevhash"`
+	Time     int64  `json:"time"`
+}
+
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
 //
 // NOTE: Deprecated. Use FilteredBlockConnectedNtfn instead.
@@ -285,6 +320,51 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// Mempool event types.
+const (
+	// MempoolEventAdded is the type of a mempool event for a transaction
+	// added to the mempool.
+	MempoolEventAdded = "added"
+
+	// MempoolEventRemoved is the type of a mempool event for a transaction
+	// removed from the mempool.
+	MempoolEventRemoved = "removed"
+)
+
+// MempoolEvent is a transaction being added to or removed from the mempool.
+// Seq is the mempool sequence number after the event, the mempool sequence
+// number returned by getrawmempool is that of the last event it reflects.
+// Reason is why a removed transaction was removed: confirmed, replaced,
+// conflict, expiry or eviction.
+type MempoolEvent struct {
+	Seq    uint64 `json:"seq"`
+	Type   string `json:"type"`
+	TxID   string `json:"txid"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MempoolEventNtfn defines the mempoolevent JSON-RPC notification.
+type MempoolEventNtfn struct {
+	Event MempoolEvent
+}
+
+// NewMempoolEventNtfn returns a new instance which can be used to issue a
+// mempoolevent JSON-RPC notification.
+func NewMempoolEventNtfn(event MempoolEvent) *MempoolEventNtfn {
+	return &MempoolEventNtfn{Event: event}
+}
+
+// ChainEventNtfn defines the chainevent JSON-RPC notification.
+type ChainEventNtfn struct {
+	Event ChainEvent
+}
+
+// NewChainEventNtfn returns a new instance which can be used to issue a
+// chainevent JSON-RPC notification.
+func NewChainEventNtfn(event ChainEvent) *ChainEventNtfn {
+	return &ChainEventNtfn{Event: event}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +381,6 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(ChainEventNtfnMethod, (*ChainEventNtfn)(nil), flags)
+	MustRegisterCmd(MempoolEventNtfnMethod, (*MempoolEventNtfn)(nil), flags)
 }
@@ -226,6 +226,68 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "chainevent",
+			newNtfn: func() (interface{}, er.R) {
+				return btcjson.NewCmd("chainevent", btcjson.ChainEvent{
+					Seq:      7,
+					Type:     "blockdisconnected",
+					Hash:     "123",
+					Height:   100000,
+					PrevHash: "456",
+					Time:     123456789,
+				})
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewChainEventNtfn(btcjson.ChainEvent{
+					Seq:      7,
+					Type:     btcjson.ChainEventBlockDisconnected,
+					Hash:     "123",
+					Height:   100000,
+					PrevHash: "456",
+					Time:     123456789,
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"chainevent","params":[{"seq":7,"type":"blockdisconnected","hash":"123","height":100000,"prevhash":"456","time":123456789}],"id":null}`,
+			unmarshalled: &btcjson.ChainEventNtfn{
+				Event: btcjson.ChainEvent{
+					Seq:      7,
+					Type:     "blockdisconnected",
+					Hash:     "123",
+					Height:   100000,
+					PrevHash: "456",
+					Time:     123456789,
+				},
+			},
+		},
+		{
+			name: "mempoolevent",
+			newNtfn: func() (interface{}, er.R) {
+				return btcjson.NewCmd("mempoolevent", btcjson.MempoolEvent{
+					Seq:    12,
+					Type:   "removed",
+					TxID:   "123",
+					Reason: "replaced",
+				})
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewMempoolEventNtfn(btcjson.MempoolEvent{
+					Seq:    12,
+					Type:   btcjson.MempoolEventRemoved,
+					TxID:   "123",
+					Reason: "replaced",
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"mempoolevent","params":[{"seq":12,"type":"removed","txid":"123","reason":"replaced"}],"id":null}`,
+			unmarshalled: &btcjson.MempoolEventNtfn{
+				Event: btcjson.MempoolEvent{
+					Seq:    12,
+					Type:   "removed",
+					TxID:   "123",
+					Reason: "replaced",
+				},
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
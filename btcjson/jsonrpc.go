@@ -148,6 +148,58 @@ func NewResponse(id interface{}, marshalledResult []byte, rpcErr er.R) (*Respons
 	}, nil
 }
 
+// RPCErrV2 is a JSON-RPC 2.0 error object.  Unlike RPCErr, the code and
+// message are always present and the message is not prefixed by the version.
+type RPCErrV2 struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponseV2 is the form of a JSON-RPC 2.0 response.  Exactly one of Result
+// and Error is set, the other is left out of the serialized object.
+type ResponseV2 struct {
+	Jsonrpc string              `json:"jsonrpc"`
+	Result  jsoniter.RawMessage `json:"result,omitempty"`
+	Error   *RPCErrV2           `json:"error,omitempty"`
+	ID      *interface{}        `json:"id"`
+}
+
+// SerializeErrorV2 converts an error to a JSON-RPC 2.0 error object.
+func SerializeErrorV2(err er.R) *RPCErrV2 {
+	if err == nil {
+		return nil
+	}
+	codeNum := ErrRPCInternal.Number
+	if code := Err.Decode(err); code != nil {
+		codeNum = code.Number
+	}
+	return &RPCErrV2{
+		Code:    codeNum,
+		Message: err.Message(),
+	}
+}
+
+// NewResponseV2 returns a new JSON-RPC 2.0 response object given the provided
+// id, marshalled result, and RPC error.  The result is dropped if rpcErr is
+// not nil.
+func NewResponseV2(id interface{}, marshalledResult []byte, rpcErr er.R) (*ResponseV2, er.R) {
+	if !IsValidIDType(id) {
+		str := fmt.Sprintf("the id of type '%T' is invalid", id)
+		return nil, makeError(ErrInvalidType, str)
+	}
+
+	if rpcErr != nil {
+		marshalledResult = nil
+	}
+	pid := &id
+	return &ResponseV2{
+		Jsonrpc: "2.0",
+		Result:  marshalledResult,
+		Error:   SerializeErrorV2(rpcErr),
+		ID:      pid,
+	}, nil
+}
+
 // MarshalResponse marshals the passed id, result, and RPCError to a JSON-RPC
 // response byte slice that is suitable for transmission to a JSON-RPC client.
 func MarshalResponse(id interface{}, result interface{}, rpcErr er.R) ([]byte, er.R) {
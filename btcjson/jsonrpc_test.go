@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"testing"
 
+	jsoniter "github.com/json-iterator/go"
+
 	"github.com/pkt-cash/PKT-FullNode/btcjson"
 	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
 	"github.com/pkt-cash/PKT-FullNode/pktconfig/version"
@@ -97,6 +99,51 @@ func TestMarshalResponse(t *testing.T) {
 	}
 }
 
+// TestNewResponseV2 ensures JSON-RPC 2.0 responses carry exactly one of
+// result and error.
+func TestNewResponseV2(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   []byte
+		jsonErr  er.R
+		expected string
+	}{
+		{
+			name:     "result",
+			result:   []byte(`true`),
+			expected: `{"jsonrpc":"2.0","result":true,"id":1}`,
+		},
+		{
+			name:   "error",
+			result: []byte(`null`),
+			jsonErr: btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound,
+				"Method not found", nil),
+			expected: `{"jsonrpc":"2.0","error":{"code":-32601,"message":"` +
+				`ErrRPCMethodNotFound(-32601): Method not found"},"id":1}`,
+		},
+	}
+
+	for i, test := range tests {
+		resp, err := btcjson.NewResponseV2(1, test.result, test.jsonErr)
+		if err != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, err)
+			continue
+		}
+		marshalled, errr := jsoniter.Marshal(resp)
+		if errr != nil {
+			t.Errorf("Test #%d (%s) unexpected error: %v", i,
+				test.name, errr)
+			continue
+		}
+		if string(marshalled) != test.expected {
+			t.Errorf("Test #%d (%s) mismatched result - got %s, "+
+				"want %s", i, test.name, marshalled,
+				test.expected)
+		}
+	}
+}
+
 // TestMiscErrors tests a few error conditions not covered elsewhere.
 func TestMiscErrors(t *testing.T) {
 
@@ -72,4 +72,5 @@ var (
 var (
 	ErrRPCNoWallet      = Err.CodeWithNumber("ErrRPCNoWallet", -1)
 	ErrRPCUnimplemented = Err.CodeWithNumber("ErrRPCUnimplemented", -1)
+	ErrRPCRateLimited   = Err.CodeWithNumber("ErrRPCRateLimited", -32029)
 )
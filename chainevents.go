@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/pkt-cash/PKT-FullNode/blockchain"
+	"github.com/pkt-cash/PKT-FullNode/btcjson"
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+	"github.com/pkt-cash/PKT-FullNode/wire"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// chainEventsFilename is the file in the data directory the chain event
+	// log is kept in, one JSON event per line.
+	chainEventsFilename = "chainevents.log"
+
+	// chainEventsRetained is the number of events kept in the log, clients
+	// which are further behind than this must resume by block hash.
+	chainEventsRetained = 10000
+
+	// chainEventsMaxReplay is the most blocks which are replayed to bring a
+	// client resuming by block hash up to the start of the log.
+	chainEventsMaxReplay = 100000
+
+	// chainEventsBatch is the most events handed to a stream at once.
+	chainEventsBatch = 500
+)
+
+// chainEventLog is an ordered log of the blocks connected to and disconnected
+// from the main chain.  Each event has a sequence number one greater than the
+// event before it, the numbers carry on across restarts because the log is
+// kept on disk.  Clients stream the log and resume it after disconnecting by
+// the sequence number of the last event they received or by the hash of the
+// tip they last saw, which works even if that block has since been orphaned.
+type chainEventLog struct {
+	chain *blockchain.BlockChain
+	path  string
+
+	mtx        sync.Mutex
+	file       *os.File
+	fileEvents int
+	events     []btcjson.ChainEvent
+	nextSeq    uint64
+
+	// tip is the tip of the main chain after the last event.
+	tip chainhash.Hash
+
+	// added is closed and replaced when events are added to the log.
+	added chan struct{}
+}
+
+// newChainEventLog loads the chain event log from the data directory, adds
+// the events for any blocks connected or disconnected while it was not being
+// written and subscribes it to chain notifications.
+func newChainEventLog(chain *blockchain.BlockChain, dataDir string) (*chainEventLog, er.R) {
+	l := &chainEventLog{
+		chain:   chain,
+		path:    filepath.Join(dataDir, chainEventsFilename),
+		nextSeq: 1,
+		added:   make(chan struct{}),
+	}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	l.catchUp()
+	if err := l.rewrite(); err != nil {
+		return nil, err
+	}
+	chain.Subscribe(l.handleBlockchainNotification)
+	return l, nil
+}
+
+// load reads the events in the log file, a line which can not be read ends
+// the log since it was being written when pktd stopped.
+func (l *chainEventLog) load() er.R {
+	f, errr := os.Open(l.path)
+	if os.IsNotExist(errr) {
+		return nil
+	} else if errr != nil {
+		return er.E(errr)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var ev btcjson.ChainEvent
+		if errr := jsoniter.Unmarshal(scanner.Bytes(), &ev); errr != nil {
+			log.Warnf("Ignoring unreadable chain event in %s: %v",
+				l.path, errr)
+			break
+		}
+		l.events = append(l.events, ev)
+		l.nextSeq = ev.Seq + 1
+		l.tip = tipAfter(&ev)
+	}
+	if errr := scanner.Err(); errr != nil {
+		return er.E(errr)
+	}
+	if len(l.events) > chainEventsRetained {
+		l.events = l.events[len(l.events)-chainEventsRetained:]
+	}
+	return nil
+}
+
+// catchUp adds the events which take the log from its tip to the tip of the
+// main chain.  If that can not be done the retained events are dropped so no
+// client resumes across the gap by sequence number.
+func (l *chainEventLog) catchUp() {
+	best := l.chain.BestSnapshot()
+	if len(l.events) == 0 {
+		l.tip = best.Hash
+		return
+	}
+	if l.tip == best.Hash {
+		return
+	}
+	detach, attach, forkHeight, err := l.chain.BlockPath(&l.tip,
+		&best.Hash, chainEventsMaxReplay)
+	if err != nil {
+		log.Warnf("Dropping chain event log, unable to follow the chain "+
+			"from %s: %v", l.tip, err)
+		l.events = nil
+		l.tip = best.Hash
+		return
+	}
+	for _, ev := range pathEvents(detach, attach, forkHeight) {
+		l.add(ev)
+	}
+	log.Infof("Added %d missing events to the chain event log",
+		len(detach)+len(attach))
+}
+
+// rewrite writes the retained events to a new log file and opens it for
+// appending.
+func (l *chainEventLog) rewrite() er.R {
+	tmpPath := l.path + ".tmp"
+	f, errr := os.Create(tmpPath)
+	if errr != nil {
+		return er.E(errr)
+	}
+	w := bufio.NewWriter(f)
+	for i := range l.events {
+		b, errr := jsoniter.Marshal(&l.events[i])
+		if errr != nil {
+			f.Close()
+			return er.E(errr)
+		}
+		w.Write(b)
+		w.WriteByte('\n')
+	}
+	if errr := w.Flush(); errr != nil {
+		f.Close()
+		return er.E(errr)
+	}
+	if errr := f.Close(); errr != nil {
+		return er.E(errr)
+	}
+	if errr := os.Rename(tmpPath, l.path); errr != nil {
+		return er.E(errr)
+	}
+
+	if l.file != nil {
+		l.file.Close()
+	}
+	l.file, errr = os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if errr != nil {
+		l.file = nil
+		return er.E(errr)
+	}
+	l.fileEvents = len(l.events)
+	return nil
+}
+
+// Close stops writing the log.
+func (l *chainEventLog) Close() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
+
+// add gives the event the next sequence number and appends it to the log.
+// The file is rewritten with only the retained events once it has grown to
+// twice their number.
+//
+// This function MUST be called with the log lock held.
+func (l *chainEventLog) add(ev btcjson.ChainEvent) {
+	ev.Seq = l.nextSeq
+	l.nextSeq++
+	l.events = append(l.events, ev)
+	if len(l.events) > chainEventsRetained {
+		l.events = l.events[len(l.events)-chainEventsRetained:]
+	}
+	l.tip = tipAfter(&ev)
+
+	if l.file != nil {
+		b, errr := jsoniter.Marshal(&ev)
+		if errr == nil {
+			_, errr = l.file.Write(append(b, '\n'))
+		}
+		if errr != nil {
+			log.Errorf("Unable to write chain event log: %v", errr)
+		}
+		l.fileEvents++
+		if l.fileEvents > 2*chainEventsRetained {
+			if err := l.rewrite(); err != nil {
+				log.Errorf("Unable to rewrite chain event log: %v", err)
+			}
+		}
+	}
+
+	close(l.added)
+	l.added = make(chan struct{})
+}
+
+// handleBlockchainNotification adds an event to the log for each block
+// connected to or disconnected from the main chain.
+func (l *chainEventLog) handleBlockchainNotification(n *blockchain.Notification) {
+	block, ok := n.Data.(*btcutil.Block)
+	if !ok {
+		return
+	}
+	var typ string
+	switch n.Type {
+	case blockchain.NTBlockConnected:
+		typ = btcjson.ChainEventBlockConnected
+	case blockchain.NTBlockDisconnected:
+		typ = btcjson.ChainEventBlockDisconnected
+	default:
+		return
+	}
+	header := &block.MsgBlock().Header
+	ev := btcjson.ChainEvent{
+		Type:     typ,
+		Hash:     block.Hash().String(),
+		Height:   block.Height(),
+		PrevHash: header.PrevBlock.String(),
+		Time:     header.Timestamp.Unix(),
+	}
+
+	l.mtx.Lock()
+	l.add(ev)
+	l.mtx.Unlock()
+}
+
+// tipAfter returns the tip of the main chain after the event.
+func tipAfter(ev *btcjson.ChainEvent) chainhash.Hash {
+	hash := ev.Hash
+	if ev.Type == btcjson.ChainEventBlockDisconnected {
+		hash = ev.PrevHash
+	}
+	h, err := chainhash.NewHashFromStr(hash)
+	if err != nil {
+		return chainhash.Hash{}
+	}
+	return *h
+}
+
+// tipBefore returns the tip of the main chain before the event.
+func tipBefore(ev *btcjson.ChainEvent) chainhash.Hash {
+	hash := ev.PrevHash
+	if ev.Type == btcjson.ChainEventBlockDisconnected {
+		hash = ev.Hash
+	}
+	h, err := chainhash.NewHashFromStr(hash)
+	if err != nil {
+		return chainhash.Hash{}
+	}
+	return *h
+}
+
+// pathEvents returns the events, without sequence numbers, for the path
+// between two blocks returned by BlockPath.
+func pathEvents(detach, attach []wire.BlockHeader, forkHeight int32) []btcjson.ChainEvent {
+	events := make([]btcjson.ChainEvent, 0, len(detach)+len(attach))
+	for i := range detach {
+		events = append(events, headerEvent(&detach[i],
+			btcjson.ChainEventBlockDisconnected,
+			forkHeight+int32(len(detach)-i)))
+	}
+	for i := range attach {
+		events = append(events, headerEvent(&attach[i],
+			btcjson.ChainEventBlockConnected, forkHeight+int32(i)+1))
+	}
+	return events
+}
+
+// headerEvent returns an event of the given type for the block header.
+func headerEvent(header *wire.BlockHeader, typ string, height int32) btcjson.ChainEvent {
+	return btcjson.ChainEvent{
+		Type:     typ,
+		Hash:     header.BlockHash().String(),
+		Height:   height,
+		PrevHash: header.PrevBlock.String(),
+		Time:     header.Timestamp.Unix(),
+	}
+}
+
+// chainEventStream is a client's position in the chain event log.
+type chainEventStream struct {
+	log *chainEventLog
+
+	// replay are the events, without sequence numbers, which bring a
+	// client resuming by block hash up to the start of the log.
+	replay []btcjson.ChainEvent
+
+	// nextSeq is the sequence number of the next event to hand out.
+	nextSeq uint64
+}
+
+// subscribe returns a stream of the log.  With an empty from it starts with
+// the next event, otherwise from is either the sequence number of the last
+// event the client received or the hash of the tip it last saw and the stream
+// starts with the events the client missed.
+func (l *chainEventLog) subscribe(from string) (*chainEventStream, er.R) {
+	s := &chainEventStream{log: l}
+	if from == "" {
+		l.mtx.Lock()
+		s.nextSeq = l.nextSeq
+		l.mtx.Unlock()
+		return s, nil
+	}
+
+	if len(from) == chainhash.MaxHashStringSize {
+		hash, err := chainhash.NewHashFromStr(from)
+		if err != nil {
+			return nil, err
+		}
+		return s, l.resumeFromHash(s, hash)
+	}
+
+	seq, errr := strconv.ParseUint(from, 10, 64)
+	if errr != nil {
+		return nil, er.Errorf("%q is neither a sequence number nor a "+
+			"block hash", from)
+	}
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if seq >= l.nextSeq {
+		return nil, er.Errorf("sequence number %d has not been issued, "+
+			"the last is %d", seq, l.nextSeq-1)
+	}
+	if seq+1 < l.firstSeq() {
+		return nil, er.Errorf("events after sequence number %d are no "+
+			"longer retained, resume by block hash", seq)
+	}
+	s.nextSeq = seq + 1
+	return s, nil
+}
+
+// resumeFromHash positions the stream after the last event which left the
+// tip at hash.  If no retained event did, the blocks between hash and the tip
+// before the first retained event are replayed and the stream continues with
+// the whole log.
+func (l *chainEventLog) resumeFromHash(s *chainEventStream, hash *chainhash.Hash) er.R {
+	l.mtx.Lock()
+	if l.tip == *hash {
+		s.nextSeq = l.nextSeq
+		l.mtx.Unlock()
+		return nil
+	}
+	for i := len(l.events) - 1; i >= 0; i-- {
+		if tipAfter(&l.events[i]) == *hash {
+			s.nextSeq = l.events[i].Seq + 1
+			l.mtx.Unlock()
+			return nil
+		}
+	}
+	base := l.tip
+	if len(l.events) > 0 {
+		base = tipBefore(&l.events[0])
+	}
+	s.nextSeq = l.firstSeq()
+	l.mtx.Unlock()
+
+	// The blocks in the path are never removed from the block index, so it
+	// is safe to find it without the log lock.
+	detach, attach, forkHeight, err := l.chain.BlockPath(hash, &base,
+		chainEventsMaxReplay)
+	if err != nil {
+		return err
+	}
+	s.replay = pathEvents(detach, attach, forkHeight)
+	return nil
+}
+
+// firstSeq returns the sequence number of the first retained event.
+//
+// This function MUST be called with the log lock held.
+func (l *chainEventLog) firstSeq() uint64 {
+	if len(l.events) == 0 {
+		return l.nextSeq
+	}
+	return l.events[0].Seq
+}
+
+// next returns the next events in the stream, waiting for them to be added if
+// necessary.  It returns nothing once quit is closed and an error if the
+// events the client needs next are no longer retained.
+func (s *chainEventStream) next(quit <-chan struct{}) ([]btcjson.ChainEvent, er.R) {
+	if len(s.replay) > 0 {
+		n := len(s.replay)
+		if n > chainEventsBatch {
+			n = chainEventsBatch
+		}
+		events := s.replay[:n]
+		s.replay = s.replay[n:]
+		return events, nil
+	}
+
+	l := s.log
+	for {
+		l.mtx.Lock()
+		first := l.firstSeq()
+		if s.nextSeq < first {
+			l.mtx.Unlock()
+			return nil, er.Errorf("fell behind the chain event log, "+
+				"events after sequence number %d are no longer "+
+				"retained", s.nextSeq-1)
+		}
+		if s.nextSeq < l.nextSeq {
+			start := int(s.nextSeq - first)
+			end := len(l.events)
+			if end-start > chainEventsBatch {
+				end = start + chainEventsBatch
+			}
+			events := make([]btcjson.ChainEvent, end-start)
+			copy(events, l.events[start:end])
+			s.nextSeq += uint64(len(events))
+			l.mtx.Unlock()
+			return events, nil
+		}
+		added := l.added
+		l.mtx.Unlock()
+
+		select {
+		case <-added:
+		case <-quit:
+			return nil, nil
+		}
+	}
+}
+
+// handleChainEventsStream streams the chain event log to an HTTP client as
+// newline delimited JSON events.  The from query parameter resumes the stream
+// as for the notifychainevents websocket command.  If the stream can not be
+// continued it ends with an object holding the error.
+func (s *rpcServer) handleChainEventsStream(w http.ResponseWriter, r *http.Request) {
+	stream, err := s.cfg.ChainEvents.subscribe(r.URL.Query().Get("from"))
+	if err != nil {
+		http.Error(w, "400 Bad Request: "+err.Message(), http.StatusBadRequest)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	// Stop waiting for events when the client goes away or the server
+	// shuts down.
+	quit := make(chan struct{})
+	defer close(quit)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-r.Context().Done():
+		case <-s.quit:
+		case <-quit:
+		}
+		close(done)
+	}()
+
+	enc := jsoniter.NewEncoder(w)
+	for {
+		events, err := stream.next(done)
+		if err != nil {
+			enc.Encode(map[string]string{"error": err.Message()})
+			return
+		}
+		if events == nil {
+			return
+		}
+		for i := range events {
+			if errr := enc.Encode(&events[i]); errr != nil {
+				return
+			}
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
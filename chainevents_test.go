@@ -0,0 +1,174 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcjson"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+)
+
+// testChainEventLog returns a chain event log in a temporary directory holding
+// a reorg: a1 and a2 are connected, a2 is disconnected and b2 and b3 are
+// connected in its place.
+func testChainEventLog(t *testing.T) (*chainEventLog, map[string]string) {
+	hashes := make(map[string]string)
+	for _, name := range []string{"a0", "a1", "a2", "b2", "b3"} {
+		hashes[name] = chainhash.HashH([]byte(name)).String()
+	}
+	l := &chainEventLog{
+		path:    filepath.Join(t.TempDir(), chainEventsFilename),
+		nextSeq: 1,
+		added:   make(chan struct{}),
+	}
+	if err := l.rewrite(); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	add := func(typ, hash, prev string, height int32) {
+		l.mtx.Lock()
+		l.add(btcjson.ChainEvent{
+			Type:     typ,
+			Hash:     hashes[hash],
+			Height:   height,
+			PrevHash: hashes[prev],
+		})
+		l.mtx.Unlock()
+	}
+	add(btcjson.ChainEventBlockConnected, "a1", "a0", 1)
+	add(btcjson.ChainEventBlockConnected, "a2", "a1", 2)
+	add(btcjson.ChainEventBlockDisconnected, "a2", "a1", 2)
+	add(btcjson.ChainEventBlockConnected, "b2", "a1", 2)
+	add(btcjson.ChainEventBlockConnected, "b3", "b2", 3)
+	return l, hashes
+}
+
+// eventSeqs returns the sequence numbers of the events.
+func eventSeqs(events []btcjson.ChainEvent) []uint64 {
+	seqs := make([]uint64, len(events))
+	for i := range events {
+		seqs[i] = events[i].Seq
+	}
+	return seqs
+}
+
+// TestChainEventLogResume ensures a stream resumed by sequence number or block
+// hash starts with exactly the events the client missed.
+func TestChainEventLogResume(t *testing.T) {
+	l, hashes := testChainEventLog(t)
+	defer l.Close()
+
+	tests := []struct {
+		name  string
+		from  string
+		seqs  []uint64
+		valid bool
+	}{
+		{name: "from start", from: "0", seqs: []uint64{1, 2, 3, 4, 5}, valid: true},
+		{name: "after seq", from: "2", seqs: []uint64{3, 4, 5}, valid: true},
+		{name: "orphaned tip", from: hashes["a2"], seqs: []uint64{3, 4, 5}, valid: true},
+		{name: "fork point", from: hashes["a1"], seqs: []uint64{4, 5}, valid: true},
+		{name: "unissued seq", from: "6"},
+		{name: "not a seq", from: "x"},
+	}
+	for _, test := range tests {
+		stream, err := l.subscribe(test.from)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		events, err := stream.next(nil)
+		if err != nil {
+			t.Errorf("%s: next: %v", test.name, err)
+			continue
+		}
+		seqs := eventSeqs(events)
+		if len(seqs) != len(test.seqs) {
+			t.Errorf("%s: got seqs %v, want %v", test.name, seqs, test.seqs)
+			continue
+		}
+		for i := range seqs {
+			if seqs[i] != test.seqs[i] {
+				t.Errorf("%s: got seqs %v, want %v", test.name, seqs,
+					test.seqs)
+				break
+			}
+		}
+	}
+
+	// A client which is up to date waits for the next event and stops
+	// waiting when quit is closed.
+	stream, err := l.subscribe(hashes["b3"])
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	got := make(chan []btcjson.ChainEvent)
+	go func() {
+		events, _ := stream.next(nil)
+		got <- events
+	}()
+	l.mtx.Lock()
+	l.add(btcjson.ChainEvent{
+		Type:     btcjson.ChainEventBlockDisconnected,
+		Hash:     hashes["b3"],
+		Height:   3,
+		PrevHash: hashes["b2"],
+	})
+	l.mtx.Unlock()
+	select {
+	case events := <-got:
+		if len(events) != 1 || events[0].Seq != 6 {
+			t.Fatalf("unexpected events %v", events)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not wake for a new event")
+	}
+	quit := make(chan struct{})
+	close(quit)
+	if events, err := stream.next(quit); events != nil || err != nil {
+		t.Fatalf("unexpected events %v, err %v after quit", events, err)
+	}
+
+	// Events which are no longer retained can not be resumed by sequence
+	// number and a stream which falls behind them ends.
+	l.mtx.Lock()
+	l.events = l.events[2:]
+	l.mtx.Unlock()
+	if _, err := l.subscribe("1"); err == nil {
+		t.Fatal("resumed after an event which is no longer retained")
+	}
+	stream = &chainEventStream{log: l, nextSeq: 2}
+	if _, err := stream.next(nil); err == nil {
+		t.Fatal("stream did not fall behind")
+	}
+}
+
+// TestChainEventLogReload ensures the log and its sequence numbers carry on
+// after it is reloaded from disk.
+func TestChainEventLogReload(t *testing.T) {
+	l, hashes := testChainEventLog(t)
+	l.Close()
+
+	l2 := &chainEventLog{path: l.path, nextSeq: 1, added: make(chan struct{})}
+	if err := l2.load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if l2.nextSeq != 6 || len(l2.events) != 5 {
+		t.Fatalf("loaded %d events with next seq %d", len(l2.events),
+			l2.nextSeq)
+	}
+	if l2.tip.String() != hashes["b3"] {
+		t.Fatalf("loaded tip %v, want %v", l2.tip, hashes["b3"])
+	}
+	stream, err := l2.subscribe("3")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	events, err := stream.next(nil)
+	if err != nil || len(events) != 2 || events[0].Hash != hashes["b2"] {
+		t.Fatalf("unexpected events %v, err %v", events, err)
+	}
+}
@@ -100,6 +100,8 @@ type config struct {
 	RPCUser       string `short:"u" long:"rpcuser" description:"RPC username"`
 	RPCPassword   string `short:"P" long:"rpcpass" default-mask:"-" description:"RPC password"`
 	RPCServer     string `short:"s" long:"rpcserver" description:"RPC server to connect to"`
+	RPCUnix       string `long:"rpcunix" description:"Unix domain socket of the RPC server to connect to, taken from pktd.conf unless --rpcserver is given"`
+	RPCCookie     string `long:"rpccookie" description:"Cookie file of pktd to authenticate with when no rpcuser/rpcpass is configured (default: the one in the pktd data directory)"`
 	RPCCert       string `short:"c" long:"rpccert" description:"RPC server certificate chain for validation"`
 	NoTLS         bool   `long:"notls" description:"Disable TLS"`
 	TLS           bool   `long:"tls" description:"Enable TLS - default false except for wallet"`
@@ -161,6 +163,23 @@ func normalizeAddress(addr string,
 	return addr
 }
 
+// netDirName returns the name of the pktd data directory of the selected
+// network.
+func netDirName(cfg *config) string {
+	switch {
+	case cfg.TestNet3:
+		return "testnet"
+	case cfg.SimNet:
+		return "simnet"
+	case cfg.BtcMainNet:
+		return "mainnet"
+	case cfg.PktTest:
+		return "pkttest"
+	default:
+		return "pkt"
+	}
+}
+
 // cleanAndExpandPath expands environement variables and leading ~ in the
 // passed path, cleans the result, and returns it.
 func cleanAndExpandPath(path string) string {
@@ -254,6 +273,13 @@ func loadConfig() (*config, []string, er.R) {
 		cfg.RPCPassword = userpass[1]
 	}
 
+	// Connect to pktd over its Unix domain socket when it listens on one.
+	// A config file which can not be read was already warned about.
+	var serverUnix string
+	if !preCfg.Wallet {
+		serverUnix, _ = pktconfig.ReadRPCUnix(serverConfigPath)
+	}
+
 	// Load additional config from file.
 	parser := flags.NewParser(&cfg, flags.Default)
 	err = flags.NewIniParser(parser).ParseFile(preCfg.ConfigFile)
@@ -304,6 +330,33 @@ func loadConfig() (*config, []string, er.R) {
 	// Handle environment variable expansion in the RPC certificate path.
 	cfg.RPCCert = cleanAndExpandPath(cfg.RPCCert)
 
+	// Use the Unix domain socket from pktd.conf unless a server was
+	// chosen explicitly.
+	if cfg.RPCUnix == "" && cfg.RPCServer == defaultRPCServer {
+		cfg.RPCUnix = serverUnix
+	}
+	if cfg.RPCUnix != "" {
+		cfg.RPCUnix = cleanAndExpandPath(cfg.RPCUnix)
+		cfg.TLS = false
+	}
+
+	// Fall back to the cookie which pktd writes to its data directory when
+	// no credentials are configured.
+	if !cfg.Wallet && (cfg.RPCUser == "" || cfg.RPCPassword == "") {
+		if cfg.RPCCookie == "" {
+			cfg.RPCCookie = filepath.Join(pktdHomeDir, "data",
+				netDirName(&cfg), ".cookie")
+		}
+		cookie, err := pktconfig.ReadCookie(cleanAndExpandPath(cfg.RPCCookie))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: cannot read cookie [%s] [%s]\n",
+				cfg.RPCCookie, err.String())
+		} else if cookie != nil {
+			cfg.RPCUser = cookie[0]
+			cfg.RPCPassword = cookie[1]
+		}
+	}
+
 	// Add default port to RPC server based on --testnet and --wallet flags
 	// if needed.
 	cfg.RPCServer = normalizeAddress(cfg.RPCServer, cfg.TestNet3,
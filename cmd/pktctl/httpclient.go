@@ -21,6 +21,11 @@ import (
 // to the TLS settings in the associated connection configuration.
 func newHTTPClient(cfg *config) (*http.Client, er.R) {
 	var dial func(network, addr string) (net.Conn, error)
+	if cfg.RPCUnix != "" {
+		dial = func(network, addr string) (net.Conn, error) {
+			return net.Dial("unix", cfg.RPCUnix)
+		}
+	}
 
 	// Configure TLS if needed.
 	var tlsConfig *tls.Config
@@ -59,6 +64,10 @@ func sendPostRequest(marshalledJSON []byte, cfg *config) (*btcjson.Response, er.
 		protocol = "https"
 	}
 	url := protocol + "://" + cfg.RPCServer
+	if cfg.RPCUnix != "" {
+		// The host is not used to connect, the socket is.
+		url = "http://localhost"
+	}
 	bodyReader := bytes.NewReader(marshalledJSON)
 	httpRequest, errr := http.NewRequest("POST", url, bodyReader)
 	if errr != nil {
@@ -68,8 +77,12 @@ func sendPostRequest(marshalledJSON []byte, cfg *config) (*btcjson.Response, er.
 	httpRequest.Header.Set("Content-Type", "application/json")
 	httpRequest.Header.Set("X-Pkt-RPC-Version", fmt.Sprintf("%d", version.AppMajorVersion()))
 
-	// Configure basic access authorization.
-	httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	// Configure basic access authorization.  Without credentials, the
+	// header is left out so that a Unix domain socket connection is
+	// served as the admin user.
+	if cfg.RPCUser != "" || cfg.RPCPassword != "" {
+		httpRequest.SetBasicAuth(cfg.RPCUser, cfg.RPCPassword)
+	}
 
 	// Create the new HTTP client that is configured according to the user-
 	// specified options and submit the request.
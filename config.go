@@ -6,10 +6,7 @@
 package main
 
 import (
-	"encoding/hex"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
 	"net"
 	"os"
 	"path/filepath"
@@ -39,7 +36,13 @@ const (
 	defaultDataDirname           = "data"
 	defaultLogLevel              = "info"
 	defaultLogDirname            = "logs"
+	defaultLogFilename           = "pktd.log"
+	defaultLogFormat             = "text"
+	defaultLogMaxSize            = 50
+	defaultLogMaxAge             = time.Hour * 24
+	defaultLogMaxFiles           = 14
 	defaultMaxPeers              = 2048
+	defaultBlockRelayOnlyConns   = 2
 	defaultBanDuration           = time.Hour * 24
 	defaultBanThreshold          = 120
 	defaultConnectTimeout        = time.Second * 10
@@ -60,6 +63,12 @@ const (
 	defaultGenerate              = false
 	defaultMaxOrphanTransactions = 100
 	defaultMaxOrphanTxSize       = 100000
+	defaultMaxMempool            = 300
+	defaultMempoolExpiry         = 336
+	defaultLimitAncestorCount    = 25
+	defaultLimitAncestorSize     = 101
+	defaultLimitDescendantCount  = 25
+	defaultLimitDescendantSize   = 101
 	defaultSigCacheMaxSize       = 100000
 	defaultTxIndex               = false
 	defaultAddrIndex             = false
@@ -96,15 +105,26 @@ type config struct {
 	ConfigFile           string        `short:"C" long:"configfile" description:"Path to configuration file"`
 	DataDir              string        `short:"b" long:"datadir" description:"Directory to store data"`
 	LogDir               string        `long:"logdir" description:"Directory to log output."`
+	NoLogFile            bool          `long:"nologfile" description:"Do not write the log to a file in the log directory"`
+	LogFormat            string        `long:"logformat" description:"Format of the log output {text, json}"`
+	LogMaxSize           int64         `long:"logmaxsize" description:"Rotate the log file once it reaches the given size in MiB (0 = no limit)"`
+	LogMaxAge            time.Duration `long:"logmaxage" description:"Rotate the log file once it has been written to for the given duration (0 = no limit)"`
+	LogMaxFiles          int           `long:"logmaxfiles" description:"Number of rotated log files to keep (0 = keep all)"`
+	NoLogCompress        bool          `long:"nologcompress" description:"Do not compress rotated log files"`
 	AddPeers             []string      `short:"a" long:"addpeer" description:"Add a peer to connect with at startup"`
 	ConnectPeers         []string      `long:"connect" description:"Connect only to the specified peers at startup"`
 	DisableListen        bool          `long:"nolisten" description:"Disable listening for incoming connections -- NOTE: Listening is automatically disabled if the --connect option is used without also specifying listening interfaces via --listen"`
 	Listeners            []string      `long:"listen" description:"Add an interface/port to listen for connections (default all interfaces port: 8333, testnet: 18333)"`
 	MaxPeers             int           `long:"maxpeers" description:"Max number of inbound and outbound peers"`
+	BlockRelayOnlyConns  int           `long:"blockrelayonlyconns" description:"Number of outbound connections which only relay blocks, these are kept across restarts"`
 	DisableBanning       bool          `long:"nobanning" description:"Disable banning of misbehaving peers"`
 	BanDuration          time.Duration `long:"banduration" description:"How long to ban misbehaving peers.  Valid time units are {s, m, h}.  Minimum 1 second"`
 	BanThreshold         uint32        `long:"banthreshold" description:"Maximum allowed ban score before disconnecting and banning misbehaving peers."`
-	Whitelists           []string      `long:"whitelist" description:"Add an IP network or IP that will not be banned. (eg. 192.168.1.0/24 or ::1)"`
+	Whitelists           []string      `long:"whitelist" description:"Grant permissions to peers connecting from an IP network or IP, in the form [perm,...@]<network>. Permissions are noban, relay, forcerelay, mempool, download and all, the default is noban,relay,mempool,download (eg. 192.168.1.0/24, ::1 or noban,mempool@10.0.0.1)"`
+	Whitebinds           []string      `long:"whitebind" description:"Listen on an interface/port and grant permissions to peers connecting to it, in the form [perm,...@]<addr>. Permissions are the same as for --whitelist"`
+	MaxUploadTarget      uint64        `long:"maxuploadtarget" description:"Try to keep upload traffic under the given target in MiB per 24h, once it is reached historical blocks are only served to peers with the download permission (0 = no limit)"`
+	MaxUploadRate        uint64        `long:"maxuploadrate" description:"Limit the combined upload rate to peers without the download permission in KiB/s (0 = no limit)"`
+	MaxPeerUploadRate    uint64        `long:"maxpeeruploadrate" description:"Limit the upload rate to each peer without the download permission in KiB/s (0 = no limit)"`
 	AgentBlacklist       []string      `long:"agentblacklist" description:"A comma separated list of user-agent substrings which will cause pktd to reject any peers whose user-agent contains any of the blacklisted substrings."`
 	AgentWhitelist       []string      `long:"agentwhitelist" description:"A comma separated list of user-agent substrings which will cause pktd to require all peers' user-agents to contain one of the whitelisted substrings. The blacklist is applied before the blacklist, and an empty whitelist will allow all agents that do not fail the blacklist."`
 	HomeDir              string        `long:"homedir" description:"Creates this directory at startup"`
@@ -112,13 +132,26 @@ type config struct {
 	RPCPass              string        `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCLimitUser         string        `long:"rpclimituser" description:"Username for limited RPC connections"`
 	RPCLimitPass         string        `long:"rpclimitpass" default-mask:"-" description:"Password for limited RPC connections"`
+	RPCAuth              []string      `long:"rpcauth" description:"Add an RPC user with hashed credentials as <user>:<salt>$<hmac>, where <hmac> is the hex HMAC-SHA256 of the password keyed by the salt"`
+	RPCAllow             []string      `long:"rpcallow" description:"Only allow an RPC user to call the listed methods, as <user>:<method>[,<method>...]"`
+	RPCDeny              []string      `long:"rpcdeny" description:"Do not allow an RPC user to call the listed methods, as <user>:<method>[,<method>...]"`
+	RPCAllowNotify       []string      `long:"rpcallownotify" description:"Only send an RPC user the listed websocket notifications, as <user>:<notification>[,<notification>...]"`
+	RPCDenyNotify        []string      `long:"rpcdenynotify" description:"Do not send an RPC user the listed websocket notifications, as <user>:<notification>[,<notification>...]"`
 	RPCListeners         []string      `long:"rpclisten" description:"Add an interface/port to listen for RPC connections (default port: 8334, testnet: 18334)"`
+	RPCUnix              string        `long:"rpcunix" description:"Path of a Unix domain socket to listen for RPC connections on -- only the owner of the process may connect and requests without credentials are served as the admin user"`
 	RPCCert              string        `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey               string        `long:"rpckey" description:"File containing the certificate key"`
 	RPCMaxClients        int           `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	RPCMaxWebsockets     int           `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
 	RPCMaxConcurrentReqs int           `long:"rpcmaxconcurrentreqs" description:"Max number of concurrent RPC requests that may be processed concurrently"`
+	RPCUserRate          float64       `long:"rpcuserrate" description:"Cost of the RPC calls each user may make per second, most calls cost 1 (0 = no limit)"`
+	RPCUserBurst         float64       `long:"rpcuserburst" description:"Cost of the RPC calls each user may make at once (default: 10 seconds worth)"`
+	RPCIPRate            float64       `long:"rpciprate" description:"Cost of the RPC calls which may be made from each IP address per second (0 = no limit)"`
+	RPCIPBurst           float64       `long:"rpcipburst" description:"Cost of the RPC calls which may be made from each IP address at once (default: 10 seconds worth)"`
+	RPCUserLimit         []string      `long:"rpcuserlimit" description:"Set the rate limit of one RPC user as <user>:<rate>[:<burst>] (0 = no limit)"`
+	RPCMethodCost        []string      `long:"rpcmethodcost" description:"Set the cost of an RPC method as <method>:<cost>"`
 	RPCQuirks            bool          `long:"rpcquirks" description:"Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE: Discouraged unless interoperability issues need to be worked around"`
+	RPCStrictJSONRPC     bool          `long:"rpcstrictjsonrpc" description:"Serve HTTP RPC requests strictly as JSON-RPC 2.0: require \"jsonrpc\":\"2.0\", reply with 2.0 response objects and do not reply to notifications"`
 	DisableRPC           bool          `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
 	DisableTLS           bool          `long:"notls" description:"Nolonger used, see --tls" hidden:"true"`
 	EnableTLS            bool          `long:"tls" description:"Enable TLS for the RPC server -- default is disabled unless bound to non-localhost"`
@@ -136,6 +169,13 @@ type config struct {
 	StatsViz             string        `long:"statsviz" description:"Enable StatsViz runtime visualization on given port -- NOTE port must be between 1024 and 65535"`
 	Profile              string        `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65535"`
 	CPUProfile           string        `long:"cpuprofile" description:"Write CPU profile to the specified file"`
+	MetricsListen        string        `long:"metricslisten" description:"Serve Prometheus metrics on the given interface/port at /metrics"`
+	BlockNotify          string        `long:"blocknotify" description:"Execute a command when the best block changes (%s in the command is replaced by the block hash)"`
+	StewardNotify        string        `long:"stewardnotify" description:"Execute a command when the network steward changes (%s in the command is replaced by the hex steward script)"`
+	NotifyTimeout        time.Duration `long:"notifytimeout" description:"Kill blocknotify and stewardnotify commands which run for longer than this"`
+	Webhooks             []string      `long:"webhook" description:"POST chain and mempool events to a URL, in the form [event,...@]<url>. Events are blockconnected, blockdisconnected, reorg, steward and mempooltx, the default is all of them"`
+	WebhookSecret        string        `long:"webhooksecret" description:"Secret used to sign webhook payloads with HMAC-SHA256, required with --webhook"`
+	WebhookAddrs         []string      `long:"webhookaddr" description:"Send the mempooltx webhook event for transactions paying to this address"`
 	DebugLevel           string        `short:"d" long:"debuglevel" description:"Logging level for all subsystems {trace, debug, info, warn, error, critical} -- You may also specify <subsystem>=<level>,<subsystem2>=<level>,... to set the log level for individual subsystems -- Use show to list available subsystems"`
 	Upnp                 bool          `long:"upnp" description:"Use UPnP to map our listening port outside of NAT"`
 	MinRelayTxFee        float64       `long:"minrelaytxfee" description:"The minimum transaction fee in BTC/kB to be considered a non-zero fee."`
@@ -143,6 +183,13 @@ type config struct {
 	NoRelayPriority      bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
 	TrickleInterval      time.Duration `long:"trickleinterval" description:"Minimum time between attempts to send new inventory to a connected peer"`
 	MaxOrphanTxs         int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	NoPersistMempool     bool          `long:"nopersistmempool" description:"Do not save the mempool on shutdown and load it on startup"`
+	MaxMempool           int           `long:"maxmempool" description:"Max size of the memory pool in megabytes, the transactions paying the lowest fee rate are evicted when it is exceeded -- 0 for no limit"`
+	MempoolExpiry        int           `long:"mempoolexpiry" description:"Evict transactions and their descendants from the memory pool after this many hours -- 0 for no limit"`
+	LimitAncestorCount   int           `long:"limitancestorcount" description:"Do not accept transactions with more than this many unconfirmed ancestors in the memory pool, counting themselves -- 0 for no limit"`
+	LimitAncestorSize    int           `long:"limitancestorsize" description:"Do not accept transactions which together with their unconfirmed ancestors in the memory pool are larger than this many kilobytes -- 0 for no limit"`
+	LimitDescendantCount int           `long:"limitdescendantcount" description:"Do not accept transactions which would give an unconfirmed transaction in the memory pool more than this many descendants, counting itself -- 0 for no limit"`
+	LimitDescendantSize  int           `long:"limitdescendantsize" description:"Do not accept transactions which would make an unconfirmed transaction in the memory pool and its descendants larger than this many kilobytes -- 0 for no limit"`
 	Generate             bool          `long:"generate" hidden:"true" description:"Generate (mine) bitcoins using the CPU - doesn't work for PacketCrypt"`
 	Coinbase             string        `long:"coinbase" description:"Include this message in generated coinbase"`
 	MiningAddrs          []string      `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
@@ -154,6 +201,7 @@ type config struct {
 	UserAgentComments    []string      `long:"uacomment" description:"Comment to add to the user agent -- See BIP 14 for more information."`
 	NoPeerBloomFilters   bool          `long:"nopeerbloomfilters" description:"Disable bloom filtering support"`
 	NoCFilters           bool          `long:"nocfilters" description:"Disable committed filtering (CF) support"`
+	NoV2Transport        bool          `long:"nov2transport" description:"Disable the BIP0324 encrypted v2 peer transport"`
 	DropCfIndex          bool          `long:"dropcfindex" description:"Deletes the index used for committed filtering (CF) support from the database on start up and then exits."`
 	SigCacheMaxSize      uint          `long:"sigcachemaxsize" description:"The maximum number of entries in the signature verification cache"`
 	BlocksOnly           bool          `long:"blocksonly" description:"Do not accept transactions from remote peers."`
@@ -170,7 +218,10 @@ type config struct {
 	addCheckpoints       []chaincfg.Checkpoint
 	miningAddrs          map[btcutil.Address]float64
 	minRelayTxFee        btcutil.Amount
-	whitelists           []*net.IPNet
+	whitelists           []whitelistEntry
+	whitebinds           []whitebindEntry
+	webhooks             []webhookEntry
+	webhookAddrs         map[string]struct{}
 }
 
 // serviceOptions defines the configuration options for the daemon as a service on
@@ -342,6 +393,7 @@ func loadConfig() (*config, []string, er.R) {
 		ConfigFile:           defaultConfigFile,
 		DebugLevel:           defaultLogLevel,
 		MaxPeers:             defaultMaxPeers,
+		BlockRelayOnlyConns:  defaultBlockRelayOnlyConns,
 		BanDuration:          defaultBanDuration,
 		BanThreshold:         defaultBanThreshold,
 		RPCMaxClients:        defaultMaxRPCClients,
@@ -350,6 +402,11 @@ func loadConfig() (*config, []string, er.R) {
 		HomeDir:              defaultHomeDir,
 		DataDir:              defaultDataDir,
 		LogDir:               defaultLogDir,
+		LogFormat:            defaultLogFormat,
+		LogMaxSize:           defaultLogMaxSize,
+		LogMaxAge:            defaultLogMaxAge,
+		LogMaxFiles:          defaultLogMaxFiles,
+		NotifyTimeout:        defaultNotifyTimeout,
 		DbType:               defaultDbType,
 		RPCKey:               defaultRPCKeyFile,
 		RPCCert:              defaultRPCCertFile,
@@ -364,6 +421,12 @@ func loadConfig() (*config, []string, er.R) {
 		BlockMaxWeight:       defaultBlockMaxWeight,
 		BlockPrioritySize:    mempool.DefaultBlockPrioritySize,
 		MaxOrphanTxs:         defaultMaxOrphanTransactions,
+		MaxMempool:           defaultMaxMempool,
+		MempoolExpiry:        defaultMempoolExpiry,
+		LimitAncestorCount:   defaultLimitAncestorCount,
+		LimitAncestorSize:    defaultLimitAncestorSize,
+		LimitDescendantCount: defaultLimitDescendantCount,
+		LimitDescendantSize:  defaultLimitDescendantSize,
 		SigCacheMaxSize:      defaultSigCacheMaxSize,
 		Generate:             defaultGenerate,
 		TxIndex:              defaultTxIndex,
@@ -547,6 +610,41 @@ func loadConfig() (*config, []string, er.R) {
 		return nil, nil, err
 	}
 
+	// Validate and set the log format.
+	if err := log.SetLogFormat(cfg.LogFormat); err != nil {
+		err := er.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Don't allow negative log rotation limits.
+	if cfg.LogMaxSize < 0 || cfg.LogMaxAge < 0 || cfg.LogMaxFiles < 0 {
+		str := "%s: The logmaxsize, logmaxage and logmaxfiles options " +
+			"may not be negative"
+		err := er.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Write the log to a file in the log directory unless disabled.
+	if !cfg.NoLogFile {
+		err := log.SetLogFile(log.FileConfig{
+			Dir:      cfg.LogDir,
+			Name:     defaultLogFilename,
+			MaxSize:  cfg.LogMaxSize * 1024 * 1024,
+			MaxAge:   cfg.LogMaxAge,
+			MaxFiles: cfg.LogMaxFiles,
+			Compress: !cfg.NoLogCompress,
+		})
+		if err != nil {
+			err := er.Errorf("%s: failed to open log file: %v", funcName, err)
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+	}
+
 	// Validate database type.
 	if !validDbType(cfg.DbType) {
 		str := "%s: The specified database type [%v] is invalid -- " +
@@ -581,6 +679,27 @@ func loadConfig() (*config, []string, er.R) {
 		}
 	}
 
+	// Validate the metrics listen address.
+	if cfg.MetricsListen != "" {
+		if _, _, errr := net.SplitHostPort(cfg.MetricsListen); errr != nil {
+			str := "%s: The metricslisten option must be an interface/port " +
+				"such as 127.0.0.1:9300 -- parsed [%v]"
+			err := er.Errorf(str, funcName, cfg.MetricsListen)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
+	// Don't allow notify commands to run without a timeout.
+	if cfg.NotifyTimeout <= 0 {
+		str := "%s: The notifytimeout option must be positive -- parsed [%v]"
+		err := er.Errorf(str, funcName, cfg.NotifyTimeout)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// Don't allow ban durations that are too short.
 	if cfg.BanDuration < time.Second {
 		str := "%s: The banduration option may not be less than 1s -- parsed [%v]"
@@ -592,33 +711,36 @@ func loadConfig() (*config, []string, er.R) {
 
 	// Validate any given whitelisted IP addresses and networks.
 	if len(cfg.Whitelists) > 0 {
-		var ip net.IP
-		cfg.whitelists = make([]*net.IPNet, 0, len(cfg.Whitelists))
+		cfg.whitelists = make([]whitelistEntry, 0, len(cfg.Whitelists))
 
 		for _, addr := range cfg.Whitelists {
-			_, ipnet, err := net.ParseCIDR(addr)
+			wl, err := parseWhitelist(addr)
 			if err != nil {
-				ip = net.ParseIP(addr)
-				if ip == nil {
-					str := "%s: The whitelist value of '%s' is invalid"
-					err := er.Errorf(str, funcName, addr)
-					fmt.Fprintln(os.Stderr, err)
-					fmt.Fprintln(os.Stderr, usageMessage)
-					return nil, nil, err
-				}
-				var bits int
-				if ip.To4() == nil {
-					// IPv6
-					bits = 128
-				} else {
-					bits = 32
-				}
-				ipnet = &net.IPNet{
-					IP:   ip,
-					Mask: net.CIDRMask(bits, bits),
-				}
+				str := "%s: The whitelist value of '%s' is invalid: %v"
+				err := er.Errorf(str, funcName, addr, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
 			}
-			cfg.whitelists = append(cfg.whitelists, ipnet)
+			cfg.whitelists = append(cfg.whitelists, wl)
+		}
+	}
+
+	// Validate any given whitelisted bind addresses and listen on them.
+	if len(cfg.Whitebinds) > 0 {
+		cfg.whitebinds = make([]whitebindEntry, 0, len(cfg.Whitebinds))
+
+		for _, addr := range cfg.Whitebinds {
+			wb, err := parseWhitebind(addr, activeNetParams.DefaultPort)
+			if err != nil {
+				str := "%s: The whitebind value of '%s' is invalid: %v"
+				err := er.Errorf(str, funcName, addr, err)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+			cfg.whitebinds = append(cfg.whitebinds, wb)
+			cfg.Listeners = append(cfg.Listeners, wb.addr)
 		}
 	}
 
@@ -672,29 +794,24 @@ func loadConfig() (*config, []string, er.R) {
 		return nil, nil, err
 	}
 
-	if cfg.RPCUser == "" || cfg.RPCPass == "" {
-		log.Infof("Creating a .cookie file")
-		cookiePath := filepath.Join(defaultHomeDir, ".cookie")
-		var buf [32]byte
-		if _, errr := rand.Read(buf[:]); errr != nil {
-			err := er.E(errr)
-			err.AddMessage("Unable to get random numbers")
-			return nil, nil, err
-		}
-		cfg.RPCUser = "__PKT_COOKIE__"
-		cfg.RPCPass = hex.EncodeToString(buf[:])
-		cookie := cfg.RPCUser + ":" + cfg.RPCPass
-		if errr := ioutil.WriteFile(cookiePath, []byte(cookie), 0600); errr != nil {
-			err := er.E(errr)
-			err.AddMessage("Could not write cookie")
-			return nil, nil, err
-		}
+	if cfg.RPCUnix != "" {
+		cfg.RPCUnix = cleanAndExpandPath(cfg.RPCUnix)
 	}
 
-	// The RPC server is disabled if no username or password is provided.
-	if (cfg.RPCUser == "" || cfg.RPCPass == "") &&
-		(cfg.RPCLimitUser == "" || cfg.RPCLimitPass == "") {
-		cfg.DisableRPC = true
+	// Validate the RPC users and their access lists.
+	if _, err := newRPCAuth(&cfg); err != nil {
+		err := er.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Validate the RPC rate limits and method costs.
+	if _, err := newRPCRateLimiter(&cfg); err != nil {
+		err := er.Errorf("%s: %v", funcName, err)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
 	}
 
 	if cfg.DisableRPC {
@@ -765,6 +882,20 @@ func loadConfig() (*config, []string, er.R) {
 		return nil, nil, err
 	}
 
+	// Block-relay-only connections may not be negative and count toward
+	// the max number of peers.
+	if cfg.BlockRelayOnlyConns < 0 {
+		str := "%s: The blockrelayonlyconns option may not be less " +
+			"than 0 -- parsed [%d]"
+		err := er.Errorf(str, funcName, cfg.BlockRelayOnlyConns)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.BlockRelayOnlyConns > cfg.MaxPeers {
+		cfg.BlockRelayOnlyConns = cfg.MaxPeers
+	}
+
 	// Limit the max orphan count to a sane vlue.
 	if cfg.MaxOrphanTxs < 0 {
 		str := "%s: The maxorphantx option may not be less than 0 " +
@@ -775,6 +906,29 @@ func loadConfig() (*config, []string, er.R) {
 		return nil, nil, err
 	}
 
+	// The mempool limits may not be negative.
+	mempoolLimits := []struct {
+		option string
+		value  int
+	}{
+		{"maxmempool", cfg.MaxMempool},
+		{"mempoolexpiry", cfg.MempoolExpiry},
+		{"limitancestorcount", cfg.LimitAncestorCount},
+		{"limitancestorsize", cfg.LimitAncestorSize},
+		{"limitdescendantcount", cfg.LimitDescendantCount},
+		{"limitdescendantsize", cfg.LimitDescendantSize},
+	}
+	for _, limit := range mempoolLimits {
+		if limit.value < 0 {
+			str := "%s: The %s option may not be less than 0 " +
+				"-- parsed [%d]"
+			err := er.Errorf(str, funcName, limit.option, limit.value)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	// Limit the block priority and minimum block sizes to max block size.
 	cfg.BlockPrioritySize = minUint32(cfg.BlockPrioritySize, cfg.BlockMaxSize)
 	cfg.BlockMinSize = minUint32(cfg.BlockMinSize, cfg.BlockMaxSize)
@@ -862,6 +1016,38 @@ func loadConfig() (*config, []string, er.R) {
 		cfg.miningAddrs[addr] = float64(1)
 	}
 
+	// Validate any given webhooks and the addresses they watch.
+	for _, wh := range cfg.Webhooks {
+		entry, err := parseWebhook(wh)
+		if err != nil {
+			str := "%s: The webhook value of '%s' is invalid: %v"
+			err := er.Errorf(str, funcName, redactWebhook(wh), err)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.webhooks = append(cfg.webhooks, entry)
+	}
+	if len(cfg.webhooks) > 0 && cfg.WebhookSecret == "" {
+		str := "%s: The webhooksecret option is required to sign webhooks"
+		err := er.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	cfg.webhookAddrs = make(map[string]struct{}, len(cfg.WebhookAddrs))
+	for _, strAddr := range cfg.WebhookAddrs {
+		addr, err := btcutil.DecodeAddress(strAddr, activeNetParams.Params)
+		if err != nil || !addr.IsForNet(activeNetParams.Params) {
+			str := "%s: webhook address '%s' is not an address on this network"
+			err := er.Errorf(str, funcName, strAddr)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		cfg.webhookAddrs[addr.EncodeAddress()] = struct{}{}
+	}
+
 	// Ensure there is at least one mining address when the generate flag is
 	// set.
 	if cfg.Generate && len(cfg.MiningAddrs) == 0 {
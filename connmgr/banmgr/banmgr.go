@@ -2,29 +2,63 @@ package banmgr
 
 import (
 	"net"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
 	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// banListVersion is the version of the ban list file format.
+const banListVersion = 1
+
+var (
+	Err = er.NewErrorType("banmgr.Err")
+
+	// ErrInvalidSubnet indicates that an address or subnet to ban or unban
+	// could not be parsed.
+	ErrInvalidSubnet = Err.CodeWithDetail("ErrInvalidSubnet",
+		"invalid IP address or subnet")
+
+	// ErrNotBanned indicates that an address or subnet to unban was not
+	// banned.
+	ErrNotBanned = Err.CodeWithDetail("ErrNotBanned",
+		"address or subnet is not banned")
 )
 
 type Config struct {
 	DisableBanning bool
 	IpWhiteList    []string
 	BanThreashold  uint32
+
+	// BanDuration is how long a peer is banned for once its ban score
+	// exceeds the threshold, or when Ban is called without an expiry.
+	BanDuration time.Duration
+
+	// BanListFile is the file where bans are saved so they survive a
+	// restart.  If it is empty, bans are only kept in memory.
+	BanListFile string
 }
 
 type BanInfo struct {
 	Addr           string
 	Reason         string
 	BanScore       int32
+	BanCreatedTime time.Time
 	BanExpiresTime time.Time
 }
 
 type BannedPeers struct {
-	time   time.Time
-	reason string
+	time    time.Time
+	created time.Time
+	reason  string
+
+	// subnet is set for bans which cover more than a single address.
+	subnet *net.IPNet
 }
 
 type SuspiciousPeers struct {
@@ -39,6 +73,20 @@ type BanMgr struct {
 	suspicious map[string]SuspiciousPeers
 }
 
+// serializedBan is the format of a single ban in the ban list file.
+type serializedBan struct {
+	Addr    string `json:"address"`
+	Reason  string `json:"reason"`
+	Created int64  `json:"ban_created"`
+	Expires int64  `json:"banned_until"`
+}
+
+// serializedBanList is the format of the ban list file.
+type serializedBanList struct {
+	Version int              `json:"version"`
+	Bans    []*serializedBan `json:"bans"`
+}
+
 func TrimAddress(host string) string {
 	address, _, err := net.SplitHostPort(host)
 	if err != nil {
@@ -48,22 +96,164 @@ func TrimAddress(host string) string {
 	return address
 }
 
+// parseSubnet parses an IP address or a subnet in CIDR notation and returns
+// the key it is banned under.  A subnet is returned only when the mask covers
+// more than one address, single addresses are keyed by the bare IP so they
+// match the peers TrimAddress is called on.
+func parseSubnet(s string) (string, *net.IPNet, er.R) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return "", nil, ErrInvalidSubnet.New(s, nil)
+		}
+		return ip.String(), nil, nil
+	}
+	_, subnet, errr := net.ParseCIDR(s)
+	if errr != nil {
+		return "", nil, ErrInvalidSubnet.New(s, er.E(errr))
+	}
+	if ones, bits := subnet.Mask.Size(); ones == bits {
+		return subnet.IP.String(), nil, nil
+	}
+	return subnet.String(), subnet, nil
+}
+
 func New(config *Config) *BanMgr {
-	return &BanMgr{
+	b := &BanMgr{
 		config:     config,
 		suspicious: make(map[string]SuspiciousPeers),
 		banned:     make(map[string]BannedPeers),
 	}
+	if config.BanListFile != "" {
+		if err := b.load(); err != nil {
+			log.Errorf("Failed to load ban list %s: %v", config.BanListFile, err)
+		}
+	}
+	return b
+}
+
+// load reads the bans saved in the ban list file, skipping those which have
+// expired in the meantime.
+func (b *BanMgr) load() er.R {
+	r, errr := os.Open(b.config.BanListFile)
+	if os.IsNotExist(errr) {
+		return nil
+	} else if errr != nil {
+		return er.E(errr)
+	}
+	defer r.Close()
+
+	var sbl serializedBanList
+	if errr := jsoniter.NewDecoder(r).Decode(&sbl); errr != nil {
+		return er.E(errr)
+	}
+	if sbl.Version > banListVersion {
+		return er.Errorf("unknown version %v in ban list", sbl.Version)
+	}
+
+	now := time.Now()
+	for _, sb := range sbl.Bans {
+		key, subnet, err := parseSubnet(sb.Addr)
+		if err != nil {
+			log.Warnf("Skipping ban of %s: %v", sb.Addr, err)
+			continue
+		}
+		expires := time.Unix(sb.Expires, 0)
+		if !now.Before(expires) {
+			continue
+		}
+		b.banned[key] = BannedPeers{
+			time:    expires,
+			created: time.Unix(sb.Created, 0),
+			reason:  sb.Reason,
+			subnet:  subnet,
+		}
+	}
+	log.Debugf("Loaded %d bans from file '%s'", len(b.banned),
+		b.config.BanListFile)
+	return nil
+}
+
+// save writes the current bans to the ban list file.  The caller MUST hold
+// the lock.
+func (b *BanMgr) save() {
+	if b.config.BanListFile == "" {
+		return
+	}
+	sbl := serializedBanList{
+		Version: banListVersion,
+		Bans:    make([]*serializedBan, 0, len(b.banned)),
+	}
+	for addr, peer := range b.banned {
+		sbl.Bans = append(sbl.Bans, &serializedBan{
+			Addr:    addr,
+			Reason:  peer.reason,
+			Created: peer.created.Unix(),
+			Expires: peer.time.Unix(),
+		})
+	}
+
+	// Write to a temporary file first so a crash while saving does not
+	// lose the previous list.
+	tmpFile := b.config.BanListFile + ".tmp"
+	w, errr := os.Create(tmpFile)
+	if errr != nil {
+		log.Errorf("Error opening file %s: %v", tmpFile, errr)
+		return
+	}
+	errr = jsoniter.NewEncoder(w).Encode(&sbl)
+	w.Close()
+	if errr != nil {
+		log.Errorf("Failed to encode file %s: %v", tmpFile, errr)
+		return
+	}
+	if errr := os.Rename(tmpFile, b.config.BanListFile); errr != nil {
+		log.Errorf("Failed to rename %s: %v", tmpFile, errr)
+	}
+}
+
+// bannedLocked returns the ban which covers addr, removing any expired ban it
+// comes across.  The caller MUST hold the lock.
+func (b *BanMgr) bannedLocked(addr string) (BannedPeers, bool) {
+	now := time.Now()
+	expired := false
+	defer func() {
+		if expired {
+			b.save()
+		}
+	}()
+	if banned, ok := b.banned[addr]; ok {
+		if now.Before(banned.time) {
+			return banned, true
+		}
+		log.Infof("Peer %s is no longer banned", addr)
+		delete(b.banned, addr)
+		expired = true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return BannedPeers{}, false
+	}
+	for key, banned := range b.banned {
+		if banned.subnet == nil || !banned.subnet.Contains(ip) {
+			continue
+		}
+		if now.Before(banned.time) {
+			return banned, true
+		}
+		log.Infof("Subnet %s is no longer banned", key)
+		delete(b.banned, key)
+		expired = true
+	}
+	return BannedPeers{}, false
 }
 
 func (b *BanMgr) BanScore(ip string) uint32 {
 	addr := TrimAddress(ip)
 	b.m.Lock()
 	defer b.m.Unlock()
-	if banned, ok := b.banned[addr]; ok {
-		if time.Now().Before(banned.time) {
-			return 9999
-		}
+	if _, ok := b.bannedLocked(addr); ok {
+		return 9999
 	}
 	if sus, ok := b.suspicious[addr]; ok {
 		return sus.dynamicBanScore.Int()
@@ -74,30 +264,87 @@ func (b *BanMgr) BanScore(ip string) uint32 {
 func (b *BanMgr) IsBanned(ip string) bool {
 	addr := TrimAddress(ip)
 	b.m.Lock()
-	if banned, ok := b.banned[addr]; ok {
-		if time.Now().Before(banned.time) {
-			log.Debugf("Peer %s is banned for another %v - disconnecting", addr, time.Until(banned.time))
-			b.m.Unlock()
-			return true
-		}
-		log.Infof("Peer %s is no longer banned", addr)
-		delete(b.banned, addr)
+	defer b.m.Unlock()
+	if banned, ok := b.bannedLocked(addr); ok {
+		log.Debugf("Peer %s is banned for another %v - disconnecting", addr, time.Until(banned.time))
+		return true
 	}
-	b.m.Unlock()
 	return false
 }
 
+// Ban bans an IP address or a subnet in CIDR notation until the given time.
+// If until is the zero time, the ban lasts for the configured ban duration.
+// An existing ban of the same address or subnet is replaced.
+func (b *BanMgr) Ban(subnet string, until time.Time, reason string) er.R {
+	key, ipNet, err := parseSubnet(subnet)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if until.IsZero() {
+		until = now.Add(b.config.BanDuration)
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.banned[key] = BannedPeers{
+		time:    until,
+		created: now,
+		reason:  reason,
+		subnet:  ipNet,
+	}
+	b.save()
+	log.Infof("Banned %s until %v: %s", key, until, reason)
+	return nil
+}
+
+// Unban removes the ban of an IP address or a subnet.  The address or subnet
+// must be given exactly as it was banned.
+func (b *BanMgr) Unban(subnet string) er.R {
+	key, _, err := parseSubnet(subnet)
+	if err != nil {
+		return err
+	}
+	b.m.Lock()
+	defer b.m.Unlock()
+	if _, ok := b.banned[key]; !ok {
+		return ErrNotBanned.New(key, nil)
+	}
+	delete(b.banned, key)
+	b.save()
+	log.Infof("Unbanned %s", key)
+	return nil
+}
+
+// ClearBanned removes all bans.  Ban scores of suspicious peers are kept.
+func (b *BanMgr) ClearBanned() {
+	b.m.Lock()
+	defer b.m.Unlock()
+	b.banned = make(map[string]BannedPeers)
+	b.save()
+	log.Infof("Cleared all bans")
+}
+
 func (b *BanMgr) ForEachIp(f func(bi BanInfo) er.R) er.R {
 	b.m.Lock()
 	var notExpired []BanInfo
+	expired := false
 	//Go through banned peers
 	for ip, peer := range b.banned {
 		if !time.Now().Before(peer.time) {
 			delete(b.banned, ip)
+			expired = true
 		} else {
-			notExpired = append(notExpired, BanInfo{Addr: ip, Reason: peer.reason, BanScore: -1, BanExpiresTime: peer.time})
+			score := int32(-1)
+			if sus, ok := b.suspicious[ip]; ok {
+				score = int32(sus.dynamicBanScore.Int())
+			}
+			notExpired = append(notExpired, BanInfo{Addr: ip, Reason: peer.reason, BanScore: score,
+				BanCreatedTime: peer.created, BanExpiresTime: peer.time})
 		}
 	}
+	if expired {
+		b.save()
+	}
 	//Go through suspicious peers
 	for ip, peer := range b.suspicious {
 		score := peer.dynamicBanScore.Int()
@@ -140,13 +387,15 @@ func (b *BanMgr) AddBanScore(host string, persistent, transient uint32, reason s
 	}
 
 	if b.suspicious == nil {
-		log.Debugf("Misbehaving peer %s: %s and no ban manager yet")
+		log.Debugf("Misbehaving peer %s: %s and no ban manager yet", ip, reason)
 		return false
 	}
-	b.suspicious[ip] = SuspiciousPeers{
-		banReason:       &reason,
-		dynamicBanScore: &DynamicBanScore{},
+	sus, ok := b.suspicious[ip]
+	if !ok {
+		sus.dynamicBanScore = &DynamicBanScore{}
 	}
+	sus.banReason = &reason
+	b.suspicious[ip] = sus
 	warnThreshold := b.config.BanThreashold >> 1
 	if transient == 0 && persistent == 0 {
 		// The score is not being increased, but a warning message is still
@@ -165,7 +414,13 @@ func (b *BanMgr) AddBanScore(host string, persistent, transient uint32, reason s
 		if score > b.config.BanThreashold {
 			log.Warnf("Misbehaving peer %s -- banning and disconnecting", ip)
 			//add to banned
-			b.banned[ip] = BannedPeers{time.Now(), reason}
+			now := time.Now()
+			b.banned[ip] = BannedPeers{
+				time:    now.Add(b.config.BanDuration),
+				created: now,
+				reason:  reason,
+			}
+			b.save()
 			return true
 			//Will be done by the server
 			//sp.server.BanPeer(ip)
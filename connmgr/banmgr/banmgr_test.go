@@ -0,0 +1,101 @@
+package banmgr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+)
+
+// TestBanSubnet tests banning and unbanning addresses and subnets.
+func TestBanSubnet(t *testing.T) {
+	b := New(&Config{BanDuration: time.Hour})
+
+	if err := b.Ban("10.1.0.0/16", time.Time{}, "spam"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := b.Ban("192.168.0.1", time.Time{}, "spam"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := b.Ban("not an address", time.Time{}, ""); !ErrInvalidSubnet.Is(err) {
+		t.Fatalf("Ban of invalid address: got %v, want ErrInvalidSubnet", err)
+	}
+
+	tests := []struct {
+		addr   string
+		banned bool
+	}{
+		{"10.1.2.3:8333", true},
+		{"10.2.0.1:8333", false},
+		{"192.168.0.1:8333", true},
+		{"192.168.0.2:8333", false},
+	}
+	for _, test := range tests {
+		if got := b.IsBanned(test.addr); got != test.banned {
+			t.Errorf("IsBanned(%s): got %v, want %v", test.addr,
+				got, test.banned)
+		}
+	}
+
+	if err := b.Unban("10.1.0.0/16"); err != nil {
+		t.Fatalf("Unban: %v", err)
+	}
+	if b.IsBanned("10.1.2.3:8333") {
+		t.Errorf("address still banned after unbanning subnet")
+	}
+	if err := b.Unban("10.1.0.0/16"); !ErrNotBanned.Is(err) {
+		t.Fatalf("second Unban: got %v, want ErrNotBanned", err)
+	}
+
+	b.ClearBanned()
+	if b.IsBanned("192.168.0.1:8333") {
+		t.Errorf("address still banned after clearing bans")
+	}
+}
+
+// TestBanExpiry tests that expired bans no longer apply.
+func TestBanExpiry(t *testing.T) {
+	b := New(&Config{BanDuration: time.Hour})
+	if err := b.Ban("10.0.0.1", time.Now().Add(-time.Second), ""); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if b.IsBanned("10.0.0.1:8333") {
+		t.Errorf("expired ban still applies")
+	}
+}
+
+// TestBanListPersistence tests that bans are restored from the ban list file.
+func TestBanListPersistence(t *testing.T) {
+	dir, errr := os.MkdirTemp("", "banmgr")
+	if errr != nil {
+		t.Fatalf("MkdirTemp: %v", errr)
+	}
+	defer os.RemoveAll(dir)
+	cfg := &Config{
+		BanDuration: time.Hour,
+		BanListFile: filepath.Join(dir, "banlist.json"),
+	}
+
+	b := New(cfg)
+	if err := b.Ban("2001:db8::/32", time.Time{}, "spam"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+	if err := b.Ban("10.0.0.1", time.Now().Add(time.Minute), "dos"); err != nil {
+		t.Fatalf("Ban: %v", err)
+	}
+
+	b = New(cfg)
+	if !b.IsBanned("[2001:db8::1]:8333") {
+		t.Errorf("subnet ban was not restored")
+	}
+	reasons := make(map[string]string)
+	b.ForEachIp(func(bi BanInfo) er.R {
+		reasons[bi.Addr] = bi.Reason
+		return nil
+	})
+	if reasons["2001:db8::/32"] != "spam" || reasons["10.0.0.1"] != "dos" {
+		t.Errorf("unexpected restored bans %v", reasons)
+	}
+}
@@ -57,6 +57,40 @@ const (
 	ConnDisconnected
 )
 
+// ConnType describes the purpose of an outbound connection.
+type ConnType uint8
+
+const (
+	// ConnFullRelay is a connection which relays blocks, transactions and
+	// addresses.
+	ConnFullRelay ConnType = iota
+
+	// ConnBlockRelayOnly is a connection which only relays blocks.  Not
+	// relaying transactions or addresses makes these connections much
+	// harder to discover by observing the network, which protects against
+	// eclipse attacks.
+	ConnBlockRelayOnly
+
+	// ConnFeeler is a short lived connection which is only used to test
+	// that an address is reachable.
+	ConnFeeler
+)
+
+// Map of connection types back to their constant names for pretty printing.
+var connTypeStrings = map[ConnType]string{
+	ConnFullRelay:      "full-relay",
+	ConnBlockRelayOnly: "block-relay-only",
+	ConnFeeler:         "feeler",
+}
+
+// String returns the ConnType in human-readable form.
+func (t ConnType) String() string {
+	if s, ok := connTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown ConnType (%d)", uint8(t))
+}
+
 // ConnReq is the connection request to a network address. If permanent, the
 // connection will be retried on disconnection.
 type ConnReq struct {
@@ -65,6 +99,7 @@ type ConnReq struct {
 
 	Addr      net.Addr
 	Permanent bool
+	Type      ConnType
 
 	conn       net.Conn
 	state      ConnState
@@ -129,6 +164,20 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of block-relay-only outbound
+	// connections to maintain in addition to TargetOutbound.
+	TargetBlockRelayOnly uint32
+
+	// Anchors are addresses of block-relay-only peers from a previous run
+	// which are connected to first when filling the block-relay-only
+	// connections.
+	Anchors []net.Addr
+
+	// FeelerInterval is the interval between feeler connections, which
+	// test whether addresses are reachable.  Feelers are disabled if it is
+	// zero.
+	FeelerInterval time.Duration
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -209,6 +258,9 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 		time.AfterFunc(d, func() {
 			cm.Connect(c)
 		})
+	} else if c.Type == ConnFeeler {
+		// Feelers are never retried.
+		go cm.Remove(c.id)
 	} else if cm.cfg.GetNewAddress != nil {
 		cm.failedAttempts++
 		if cm.failedAttempts >= maxFailedAttempts {
@@ -218,12 +270,12 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 			theId := c.id
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
 				cm.Remove(theId)
-				cm.NewConnReq()
+				cm.NewConnReqWithType(c.Type)
 			})
 		} else {
 			go func(theId uint64) {
 				cm.Remove(theId)
-				cm.NewConnReq()
+				cm.NewConnReqWithType(c.Type)
 			}(c.id)
 		}
 	}
@@ -374,9 +426,15 @@ func (cm *ConnManager) NotifyConnectionRequestActuallyCompleted() {
 	cm.failedAttempts = 0
 }
 
-// NewConnReq creates a new connection request and connects to the
+// NewConnReq creates a new full-relay connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.NewConnReqWithType(ConnFullRelay)
+}
+
+// NewConnReqWithType creates a new connection request of the given type and
+// connects to the corresponding address.
+func (cm *ConnManager) NewConnReqWithType(connType ConnType) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -384,7 +442,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{Type: connType}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	// Submit a request of a pending connection attempt to the connection
@@ -433,7 +491,9 @@ func (cm *ConnManager) Connect(c *ConnReq) {
 		log.Infof("Ignoring canceled connreq=%v, attempting new connection.", c)
 		theId := c.id
 		cm.Remove(theId)
-		cm.NewConnReq()
+		if c.Type != ConnFeeler {
+			cm.NewConnReqWithType(c.Type)
+		}
 		return
 	}
 
@@ -550,6 +610,45 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+
+	// Fill the block-relay-only connections, reconnecting to the anchors
+	// from the previous run first.
+	anchors := cm.cfg.Anchors
+	if uint32(len(anchors)) > cm.cfg.TargetBlockRelayOnly {
+		anchors = anchors[:cm.cfg.TargetBlockRelayOnly]
+	}
+	for _, addr := range anchors {
+		log.Debugf("Connecting to anchor %v", addr)
+		go cm.Connect(&ConnReq{Addr: addr, Type: ConnBlockRelayOnly})
+	}
+	for i := uint32(len(anchors)); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		go cm.NewConnReqWithType(ConnBlockRelayOnly)
+	}
+
+	if cm.cfg.FeelerInterval > 0 && cm.cfg.GetNewAddress != nil {
+		cm.wg.Add(1)
+		go cm.feelerHandler()
+	}
+}
+
+// feelerHandler periodically makes a feeler connection to test whether an
+// address is reachable.  It must be run as a goroutine.
+func (cm *ConnManager) feelerHandler() {
+	ticker := time.NewTicker(cm.cfg.FeelerInterval)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			go cm.NewConnReqWithType(ConnFeeler)
+		case <-cm.quit:
+			break out
+		}
+	}
+
+	cm.wg.Done()
+	log.Trace("Feeler handler done")
 }
 
 // Wait blocks until the connection manager halts gracefully.
@@ -216,6 +216,59 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests that the block-relay-only connections are
+// maintained in addition to the full-relay ones and that anchors are used
+// first.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(4)
+	targetBlockRelayOnly := uint32(2)
+	anchor := &net.TCPAddr{IP: net.ParseIP("127.0.0.2"), Port: 18555}
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Anchors:              []net.Addr{anchor},
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, er.R) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	counts := make(map[ConnType]int)
+	anchored := false
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		counts[c.Type]++
+		if c.Addr.String() == anchor.String() {
+			anchored = c.Type == ConnBlockRelayOnly
+		}
+	}
+	if counts[ConnFullRelay] != int(targetOutbound) ||
+		counts[ConnBlockRelayOnly] != int(targetBlockRelayOnly) {
+		t.Fatalf("unexpected connection types: %v", counts)
+	}
+	if !anchored {
+		t.Fatalf("anchor was not connected as block-relay-only")
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("target outbound: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -1776,6 +1776,9 @@ type db struct {
 // Enforce db implements the database.DB interface.
 var _ database.DB = (*db)(nil)
 
+// Enforce db implements the database.CacheReporter interface.
+var _ database.CacheReporter = (*db)(nil)
+
 // Type returns the database driver type the current database instance was
 // created with.
 //
@@ -1784,6 +1787,19 @@ func (db *db) Type() string {
 	return dbType
 }
 
+// CacheSize returns the current size of the database cache and the size at
+// which it is flushed, in bytes.
+//
+// This function is part of the database.CacheReporter interface
+// implementation.
+func (db *db) CacheSize() (uint64, uint64) {
+	c := db.cache
+	c.cacheLock.RLock()
+	size := c.cachedKeys.Size() + c.cachedRemove.Size()
+	c.cacheLock.RUnlock()
+	return size, c.maxSize
+}
+
 // begin is the implementation function for the Begin database method.  See its
 // documentation for more details.
 //
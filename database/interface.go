@@ -465,3 +465,11 @@ type DB interface {
 	// back or committed).
 	Close() er.R
 }
+
+// CacheReporter is implemented by databases which hold writes in a memory
+// cache before flushing them to persistent storage.
+type CacheReporter interface {
+	// CacheSize returns the current size of the cache and the size at
+	// which it is flushed, in bytes.
+	CacheSize() (size, maxSize uint64)
+}
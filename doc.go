@@ -25,6 +25,15 @@ Application Options:
   -C, --configfile=         Path to configuration file
   -b, --datadir=            Directory to store data
       --logdir=             Directory to log output.
+      --nologfile           Do not write the log to a file in the log directory
+      --logformat=          Format of the log output {text, json} (text)
+      --logmaxsize=         Rotate the log file once it reaches the given size
+                            in MiB (0 = no limit) (50)
+      --logmaxage=          Rotate the log file once it has been written to for
+                            the given duration (0 = no limit) (24h0m0s)
+      --logmaxfiles=        Number of rotated log files to keep (0 = keep all)
+                            (14)
+      --nologcompress       Do not compress rotated log files
   -a, --addpeer=            Add a peer to connect with at startup
       --connect=            Connect only to the specified peers at startup
       --nolisten            Disable listening for incoming connections -- NOTE:
@@ -34,27 +43,77 @@ Application Options:
       --listen=             Add an interface/port to listen for connections
                             (default all interfaces port: 8333, testnet: 18333)
       --maxpeers=           Max number of inbound and outbound peers (125)
+      --blockrelayonlyconns=
+                            Number of outbound connections which only relay
+                            blocks, these are kept across restarts (2)
       --nobanning           Disable banning of misbehaving peers
       --banduration=        How long to ban misbehaving peers.  Valid time units
                             are {s, m, h}.  Minimum 1 second (24h0m0s)
       --banthreshold=       Maximum allowed ban score before disconnecting and
                             banning misbehaving peers.
-      --whitelist=          Add an IP network or IP that will not be banned.
-                            (eg. 192.168.1.0/24 or ::1)
+      --whitelist=          Grant permissions to peers connecting from an IP
+                            network or IP, in the form [perm,...@]<network>.
+                            Permissions are noban, relay, forcerelay, mempool,
+                            download and all, the default is
+                            noban,relay,mempool,download (eg. 192.168.1.0/24,
+                            ::1 or noban,mempool@10.0.0.1)
+      --whitebind=          Listen on an interface/port and grant permissions
+                            to peers connecting to it, in the form
+                            [perm,...@]<addr>. Permissions are the same as for
+                            --whitelist
+      --maxuploadtarget=    Try to keep upload traffic under the given target in
+                            MiB per 24h, once it is reached historical blocks
+                            are only served to peers with the download
+                            permission (0 = no limit)
+      --maxuploadrate=      Limit the combined upload rate to peers without the
+                            download permission in KiB/s (0 = no limit)
+      --maxpeeruploadrate=  Limit the upload rate to each peer without the
+                            download permission in KiB/s (0 = no limit)
   -u, --rpcuser=            Username for RPC connections
   -P, --rpcpass=            Password for RPC connections
       --rpclimituser=       Username for limited RPC connections
       --rpclimitpass=       Password for limited RPC connections
+      --rpcauth=            Add an RPC user with hashed credentials as
+                            <user>:<salt>$<hmac>, where <hmac> is the hex
+                            HMAC-SHA256 of the password keyed by the salt
+      --rpcallow=           Only allow an RPC user to call the listed methods,
+                            as <user>:<method>[,<method>...]
+      --rpcdeny=            Do not allow an RPC user to call the listed
+                            methods, as <user>:<method>[,<method>...]
+      --rpcallownotify=     Only send an RPC user the listed websocket
+                            notifications, as
+                            <user>:<notification>[,<notification>...]
+      --rpcdenynotify=      Do not send an RPC user the listed websocket
+                            notifications, as
+                            <user>:<notification>[,<notification>...]
       --rpclisten=          Add an interface/port to listen for RPC connections
                             (default port: 8334, testnet: 18334)
+      --rpcunix=            Path of a Unix domain socket to listen for RPC
+                            connections on -- only the owner of the process may
+                            connect and requests without credentials are served
+                            as the admin user
       --rpccert=            File containing the certificate file
       --rpckey=             File containing the certificate key
       --rpcmaxclients=      Max number of RPC clients for standard connections
                             (10)
       --rpcmaxwebsockets=   Max number of RPC websocket connections (25)
+      --rpcuserrate=        Cost of the RPC calls each user may make per second,
+                            most calls cost 1 (0 = no limit)
+      --rpcuserburst=       Cost of the RPC calls each user may make at once
+                            (default: 10 seconds worth)
+      --rpciprate=          Cost of the RPC calls which may be made from each IP
+                            address per second (0 = no limit)
+      --rpcipburst=         Cost of the RPC calls which may be made from each IP
+                            address at once (default: 10 seconds worth)
+      --rpcuserlimit=       Set the rate limit of one RPC user as
+                            <user>:<rate>[:<burst>] (0 = no limit)
+      --rpcmethodcost=      Set the cost of an RPC method as <method>:<cost>
       --rpcquirks           Mirror some JSON-RPC quirks of Bitcoin Core -- NOTE:
                             Discouraged unless interoperability issues need to
                             be worked around
+      --rpcstrictjsonrpc    Serve HTTP RPC requests strictly as JSON-RPC 2.0:
+                            require "jsonrpc":"2.0", reply with 2.0 response
+                            objects and do not reply to notifications
       --norpc               Disable built-in RPC server -- NOTE: The RPC server
                             is disabled by default if no rpcuser/rpcpass or
                             rpclimituser/rpclimitpass is specified
@@ -76,6 +135,23 @@ Application Options:
       --profile=            Enable HTTP profiling on given port -- NOTE port
                             must be between 1024 and 65536
       --cpuprofile=         Write CPU profile to the specified file
+      --metricslisten=      Serve Prometheus metrics on the given interface/port
+                            at /metrics
+      --blocknotify=        Execute a command when the best block changes (%s
+                            in the command is replaced by the block hash)
+      --stewardnotify=      Execute a command when the network steward changes
+                            (%s in the command is replaced by the hex steward
+                            script)
+      --notifytimeout=      Kill blocknotify and stewardnotify commands which
+                            run for longer than this (1m0s)
+      --webhook=            POST chain and mempool events to a URL, in the form
+                            [event,...@]<url>. Events are blockconnected,
+                            blockdisconnected, reorg, steward and mempooltx,
+                            the default is all of them
+      --webhooksecret=      Secret used to sign webhook payloads with
+                            HMAC-SHA256, required with --webhook
+      --webhookaddr=        Send the mempooltx webhook event for transactions
+                            paying to this address
   -d, --debuglevel=         Logging level for all subsystems {trace, debug,
                             info, warn, error, critical} -- You may also specify
                             <subsystem>=<level>,<subsystem2>=<level>,... to set
@@ -91,6 +167,31 @@ Application Options:
                             high priority for relaying
       --maxorphantx=        Max number of orphan transactions to keep in memory
                             (100)
+      --nopersistmempool    Do not save the mempool on shutdown and load it on
+                            startup
+      --maxmempool=         Max size of the memory pool in megabytes, the
+                            transactions paying the lowest fee rate are evicted
+                            when it is exceeded -- 0 for no limit (300)
+      --mempoolexpiry=      Evict transactions and their descendants from the
+                            memory pool after this many hours -- 0 for no
+                            limit (336)
+      --limitancestorcount= Do not accept transactions with more than this many
+                            unconfirmed ancestors in the memory pool, counting
+                            themselves -- 0 for no limit (25)
+      --limitancestorsize=  Do not accept transactions which together with
+                            their unconfirmed ancestors in the memory pool are
+                            larger than this many kilobytes -- 0 for no limit
+                            (101)
+      --limitdescendantcount=
+                            Do not accept transactions which would give an
+                            unconfirmed transaction in the memory pool more
+                            than this many descendants, counting itself -- 0
+                            for no limit (25)
+      --limitdescendantsize=
+                            Do not accept transactions which would make an
+                            unconfirmed transaction in the memory pool and its
+                            descendants larger than this many kilobytes -- 0
+                            for no limit (101)
       --generate            Generate (mine) bitcoins using the CPU
       --miningaddr=         Add the specified payment address to the list of
                             addresses to use for generated blocks -- At least
@@ -104,6 +205,7 @@ Application Options:
                             when creating a block (50000)
       --nopeerbloomfilters  Disable bloom filtering support.
       --nocfilters          Disable committed filtering (CF) support.
+      --nov2transport       Disable the BIP0324 encrypted v2 peer transport.
       --sigcachemaxsize=    The maximum number of entries in the signature
                             verification cache.
       --blocksonly          Do not accept transactions from remote peers.
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/addrmgr/addrutil"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+)
+
+const (
+	// evictProtectNetGroup is the number of inbound peers with distinct
+	// network groups which are protected from eviction.
+	evictProtectNetGroup = 4
+
+	// evictProtectPing is the number of inbound peers with the lowest ping
+	// times which are protected from eviction.
+	evictProtectPing = 8
+
+	// evictProtectTx is the number of inbound peers which most recently
+	// relayed a new transaction to us which are protected from eviction.
+	evictProtectTx = 4
+
+	// evictProtectBlock is the number of inbound peers which most recently
+	// relayed a new block to us which are protected from eviction.
+	evictProtectBlock = 4
+)
+
+// evictionCandidate holds the attributes of an inbound peer which are used to
+// decide which peer to evict when the server is full.
+type evictionCandidate struct {
+	id            int32
+	connTime      time.Time
+	pingMicros    int64
+	lastBlockTime int64
+	lastTxTime    int64
+	netGroup      uint64
+}
+
+// protectEvictionCandidates removes up to n candidates from the end of the
+// candidates once sorted by less, where the best candidates sort last.
+func protectEvictionCandidates(candidates []evictionCandidate, n int,
+	less func(a, b *evictionCandidate) bool) []evictionCandidate {
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return less(&candidates[i], &candidates[j])
+	})
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	return candidates[:len(candidates)-n]
+}
+
+// selectPeerToEvict chooses an inbound peer to disconnect so a new inbound
+// peer can take its place.  It follows the approach of Bitcoin Core: peers
+// which are useful in ways an attacker would find hard to fake are protected,
+// and of those remaining the youngest peer of the most represented network
+// group is chosen.  It returns false if every candidate is protected.
+func selectPeerToEvict(candidates []evictionCandidate) (int32, bool) {
+	// Protect peers in distinct network groups.  The groups are hashed with
+	// a secret key so an attacker can not choose which groups are kept.
+	candidates = protectEvictionCandidates(candidates, evictProtectNetGroup,
+		func(a, b *evictionCandidate) bool {
+			return a.netGroup > b.netGroup
+		})
+
+	// Protect the peers with the lowest ping times, peers which have not
+	// responded to a ping yet are the least preferred.
+	candidates = protectEvictionCandidates(candidates, evictProtectPing,
+		func(a, b *evictionCandidate) bool {
+			if a.pingMicros == 0 || b.pingMicros == 0 {
+				return a.pingMicros == 0 && b.pingMicros != 0
+			}
+			return a.pingMicros > b.pingMicros
+		})
+
+	// Protect the peers which most recently relayed novel transactions and
+	// blocks.
+	candidates = protectEvictionCandidates(candidates, evictProtectTx,
+		func(a, b *evictionCandidate) bool {
+			return a.lastTxTime < b.lastTxTime
+		})
+	candidates = protectEvictionCandidates(candidates, evictProtectBlock,
+		func(a, b *evictionCandidate) bool {
+			return a.lastBlockTime < b.lastBlockTime
+		})
+
+	// Protect half of the remaining peers, those connected the longest.
+	candidates = protectEvictionCandidates(candidates, len(candidates)/2,
+		func(a, b *evictionCandidate) bool {
+			return a.connTime.After(b.connTime)
+		})
+
+	if len(candidates) == 0 {
+		return 0, false
+	}
+
+	// Find the network group with the most peers, preferring the group
+	// with the youngest peer when there is a tie, and evict its youngest
+	// peer.
+	groups := make(map[uint64][]*evictionCandidate)
+	for i := range candidates {
+		c := &candidates[i]
+		groups[c.netGroup] = append(groups[c.netGroup], c)
+	}
+	var victim *evictionCandidate
+	victimGroupSize := 0
+	for _, group := range groups {
+		youngest := group[0]
+		for _, c := range group[1:] {
+			if c.connTime.After(youngest.connTime) {
+				youngest = c
+			}
+		}
+		if len(group) > victimGroupSize || (len(group) == victimGroupSize &&
+			youngest.connTime.After(victim.connTime)) {
+
+			victim = youngest
+			victimGroupSize = len(group)
+		}
+	}
+	return victim.id, true
+}
+
+// keyedNetGroup returns the network group of the peer hashed with the secret
+// eviction key of the server.
+func (s *server) keyedNetGroup(sp *serverPeer) uint64 {
+	var key [8]byte
+	binary.LittleEndian.PutUint64(key[:], s.evictionKey)
+	h := fnv.New64a()
+	h.Write(key[:])
+	h.Write([]byte(addrutil.GroupKey(sp.NA())))
+	return h.Sum64()
+}
+
+// evictInboundPeer tries to disconnect an inbound peer to make room for a new
+// one.  Peers with the noban permission are never evicted, nor are outbound
+// peers since they are not candidates at all.  It returns whether a peer was
+// evicted.
+//
+// This function MUST be called from the peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	candidates := make([]evictionCandidate, 0, len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		if sp.hasPermission(permNoBan) || !sp.Connected() {
+			continue
+		}
+		stats := sp.StatsSnapshot()
+		candidates = append(candidates, evictionCandidate{
+			id:            sp.ID(),
+			connTime:      stats.ConnTime,
+			pingMicros:    stats.LastPingMicros,
+			lastBlockTime: atomic.LoadInt64(&sp.lastBlockTime),
+			lastTxTime:    atomic.LoadInt64(&sp.lastTxTime),
+			netGroup:      s.keyedNetGroup(sp),
+		})
+	}
+
+	id, ok := selectPeerToEvict(candidates)
+	if !ok {
+		return false
+	}
+	victim := state.inboundPeers[id]
+	log.Infof("Evicting inbound peer %s to make room for a new peer", victim)
+	delete(state.inboundPeers, id)
+	victim.Disconnect()
+	return true
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestSelectPeerToEvict ensures protected peers are never chosen and that a
+// peer from the most crowded network group is evicted.
+func TestSelectPeerToEvict(t *testing.T) {
+	now := time.Now()
+
+	// Too few peers to evict any after protection.
+	var candidates []evictionCandidate
+	for i := 0; i < evictProtectNetGroup+evictProtectPing; i++ {
+		candidates = append(candidates, evictionCandidate{
+			id:       int32(i),
+			connTime: now.Add(-time.Duration(i) * time.Minute),
+			netGroup: uint64(i),
+		})
+	}
+	if id, ok := selectPeerToEvict(candidates); ok {
+		t.Fatalf("unexpected eviction of peer %d", id)
+	}
+
+	// Many peers from one network group alongside good peers which relay
+	// blocks, relay transactions or have low ping times.
+	candidates = nil
+	id := int32(0)
+	add := func(c evictionCandidate) {
+		c.id = id
+		id++
+		candidates = append(candidates, c)
+	}
+	for i := 0; i < 4; i++ {
+		add(evictionCandidate{connTime: now, netGroup: 1000 + uint64(i)})
+		add(evictionCandidate{connTime: now, netGroup: 1, lastBlockTime: now.Unix()})
+		add(evictionCandidate{connTime: now, netGroup: 1, lastTxTime: now.Unix()})
+	}
+	for i := 0; i < evictProtectPing; i++ {
+		add(evictionCandidate{connTime: now, netGroup: 1, pingMicros: 10})
+	}
+	var attackers []int32
+	for i := 0; i < 20; i++ {
+		attackers = append(attackers, id)
+		add(evictionCandidate{
+			connTime: now.Add(-time.Duration(20-i) * time.Second),
+			netGroup: 2,
+		})
+	}
+
+	evicted, ok := selectPeerToEvict(candidates)
+	if !ok {
+		t.Fatalf("expected a peer to be evicted")
+	}
+	isAttacker := false
+	for _, a := range attackers {
+		if evicted == a {
+			isAttacker = true
+		}
+	}
+	if !isAttacker {
+		t.Fatalf("evicted peer %d which is not in the crowded group", evicted)
+	}
+}
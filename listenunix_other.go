@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package main
+
+import "net"
+
+// listenUnixPrivate listens on a unix socket at path.  There is no umask to
+// restrict on this platform, so the permissions of the socket are only set
+// once it is created.
+func listenUnixPrivate(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnixPrivate listens on a unix socket at path which can only be
+// accessed by the user running the process.  The umask is restricted while the
+// socket is created so it is never accessible to other users, even before its
+// permissions are set.
+func listenUnixPrivate(path string) (net.Listener, error) {
+	oldMask := syscall.Umask(0077)
+	defer syscall.Umask(oldMask)
+	return net.Listen("unix", path)
+}
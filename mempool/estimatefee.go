@@ -566,6 +566,44 @@ func (ef *FeeEstimator) EstimateFee(numBlocks uint32) (BtcPerKilobyte, er.R) {
 	return ef.cached[int(numBlocks)-1].ToBtcPerKb(), nil
 }
 
+// FeeEstimatorBucket describes the transactions the fee estimator observed
+// being confirmed within a given number of blocks.
+type FeeEstimatorBucket struct {
+	// Blocks is the number of blocks the transactions took to confirm.
+	Blocks uint32
+
+	// Transactions is the number of observed transactions in the bucket.
+	Transactions int
+
+	// FeeRate is the fee estimate for confirming within Blocks blocks, it
+	// is -1 until enough blocks have been observed.
+	FeeRate BtcPerKilobyte
+}
+
+// Buckets returns the state of each bucket of the fee estimator.
+func (ef *FeeEstimator) Buckets() []FeeEstimatorBucket {
+	ef.mtx.Lock()
+	defer ef.mtx.Unlock()
+
+	ready := ef.numBlocksRegistered >= ef.minRegisteredBlocks
+	if ready && ef.cached == nil {
+		ef.cached = ef.estimates()
+	}
+
+	buckets := make([]FeeEstimatorBucket, estimateFeeDepth)
+	for i := range buckets {
+		buckets[i] = FeeEstimatorBucket{
+			Blocks:       uint32(i + 1),
+			Transactions: len(ef.bin[i]),
+			FeeRate:      -1,
+		}
+		if ready {
+			buckets[i].FeeRate = ef.cached[i].ToBtcPerKb()
+		}
+	}
+	return buckets
+}
+
 // int confTarget, FeeCalculation *feeCalc, bool conservative
 // This adheres to the API of estimateSmartFee but it is not actually smart.
 func (ef *FeeEstimator) EstimateSmartFee(numBlocks uint32, conservitive bool) btcjson.EstimateSmartFeeResult {
@@ -579,6 +617,20 @@ func (ef *FeeEstimator) EstimateSmartFee(numBlocks uint32, conservitive bool) bt
 	return out
 }
 
+// EstimateSmartFeeWithPool is like EstimateSmartFee, except that the estimate
+// is never lower than poolFeeRate, an estimate from the transactions in the
+// pool such as the one returned by EstimateFeeFromPool.  While the fee
+// estimator has not observed enough blocks, poolFeeRate is returned alone, so
+// fees can be estimated on chains with too few transactions for the fee
+// estimator to warm up quickly.
+func (ef *FeeEstimator) EstimateSmartFeeWithPool(numBlocks uint32, poolFeeRate BtcPerKilobyte) btcjson.EstimateSmartFeeResult {
+	feeRate := float64(poolFeeRate)
+	if fpk, err := ef.EstimateFee(numBlocks); err == nil {
+		feeRate = math.Max(feeRate, float64(fpk))
+	}
+	return btcjson.EstimateSmartFeeResult{FeeRate: &feeRate}
+}
+
 // In case the format for the serialized version of the FeeEstimator changes,
 // we use a version number. If the version number changes, it does not make
 // sense to try to upgrade a previous version to a new version. Instead, just
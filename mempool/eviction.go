@@ -0,0 +1,109 @@
+package mempool
+
+import (
+	"container/heap"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+)
+
+// evictionEntry is a transaction of the main pool in the eviction index.
+type evictionEntry struct {
+	hash chainhash.Hash
+
+	// rate is the fee rate in satoshi/kB by which the transaction is
+	// ranked for eviction, as returned by evictionFeeRate.
+	rate float64
+
+	// index is the position of the entry in the heap.
+	index int
+}
+
+// evictionIndex orders the transactions of the main pool by the fee rate they
+// are ranked by for eviction, the lowest first, so the pool does not need to be
+// ranked each time it is trimmed.  It implements heap.Interface.
+type evictionIndex struct {
+	entries []*evictionEntry
+	byHash  map[chainhash.Hash]*evictionEntry
+}
+
+// newEvictionIndex returns an empty eviction index.
+func newEvictionIndex() *evictionIndex {
+	return &evictionIndex{
+		byHash: make(map[chainhash.Hash]*evictionEntry),
+	}
+}
+
+// Len returns the number of transactions in the index.  It is part of the
+// heap.Interface implementation.
+func (ei *evictionIndex) Len() int {
+	return len(ei.entries)
+}
+
+// Less returns whether the transaction at index i is to be evicted before the
+// one at index j.  It is part of the heap.Interface implementation.
+func (ei *evictionIndex) Less(i, j int) bool {
+	return ei.entries[i].rate < ei.entries[j].rate
+}
+
+// Swap swaps the transactions at the passed indices.  It is part of the
+// heap.Interface implementation.
+func (ei *evictionIndex) Swap(i, j int) {
+	ei.entries[i], ei.entries[j] = ei.entries[j], ei.entries[i]
+	ei.entries[i].index = i
+	ei.entries[j].index = j
+}
+
+// Push adds the passed *evictionEntry to the index.  It is part of the
+// heap.Interface implementation.
+func (ei *evictionIndex) Push(x interface{}) {
+	entry := x.(*evictionEntry)
+	entry.index = len(ei.entries)
+	ei.entries = append(ei.entries, entry)
+	ei.byHash[entry.hash] = entry
+}
+
+// Pop removes the last entry of the index and returns it.  It is part of the
+// heap.Interface implementation.
+func (ei *evictionIndex) Pop() interface{} {
+	n := len(ei.entries)
+	entry := ei.entries[n-1]
+	ei.entries[n-1] = nil
+	ei.entries = ei.entries[:n-1]
+	delete(ei.byHash, entry.hash)
+	return entry
+}
+
+// updateEvictionIndex updates the eviction index once the passed transaction
+// has been added to or removed from the main pool, or its fee delta changed.
+// The fee rates by which the transaction and its ancestors are ranked depend
+// on it, so they are computed again, while the ones of other transactions
+// stay the same.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updateEvictionIndex(tx *btcutil.Tx) {
+	hashes := []chainhash.Hash{*tx.Hash()}
+	for hash := range mp.txAncestors(tx, nil) {
+		hashes = append(hashes, hash)
+	}
+
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	for _, hash := range hashes {
+		entry, indexed := mp.evictionIndex.byHash[hash]
+		txD, inPool := mp.pool[hash]
+		switch {
+		case !inPool && indexed:
+			heap.Remove(mp.evictionIndex, entry.index)
+
+		case inPool && indexed:
+			entry.rate = mp.evictionFeeRate(txD, cache)
+			heap.Fix(mp.evictionIndex, entry.index)
+
+		case inPool:
+			heap.Push(mp.evictionIndex, &evictionEntry{
+				hash: hash,
+				rate: mp.evictionFeeRate(txD, cache),
+			})
+		}
+	}
+}
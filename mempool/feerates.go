@@ -0,0 +1,179 @@
+package mempool
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkt-cash/PKT-FullNode/btcjson"
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+)
+
+const (
+	// feeHistogramBinSize is the virtual size of the first bin of the fee
+	// histogram.  Each following bin is feeHistogramBinGrowth times larger
+	// than the previous one so the fee rates the most likely to be mined
+	// soon are the most detailed.
+	feeHistogramBinSize = 30000
+
+	// feeHistogramBinGrowth is the factor by which the size of each bin of
+	// the fee histogram grows.
+	feeHistogramBinGrowth = 1.1
+)
+
+// FeeHistogram returns the fee rates paid by the transactions of the pool as
+// pairs of a fee rate in satoshi per virtual byte and the total virtual size
+// of the transactions paying at least that fee rate and less than the fee rate
+// of the previous pair.  Pairs are sorted by decreasing fee rate and grouped
+// into bins of growing size, which is the format of the fee histogram of the
+// Electrum protocol.  Fee rates are rounded down to a tenth of a satoshi per
+// virtual byte and do not include fee deltas.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FeeHistogram() btcjson.GetMempoolFeeHistogramResult {
+	mp.mtx.RLock()
+	sizes := make(map[float64]int64)
+	for _, desc := range mp.pool {
+		size := GetTxVirtualSize(desc.Tx)
+		rate := math.Floor(float64(desc.Fee)*10/float64(size)) / 10
+		sizes[rate] += size
+	}
+	mp.mtx.RUnlock()
+
+	rates := make([]float64, 0, len(sizes))
+	for rate := range sizes {
+		rates = append(rates, rate)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(rates)))
+
+	histogram := make(btcjson.GetMempoolFeeHistogramResult, 0)
+	binSize := float64(feeHistogramBinSize)
+	var size, excess int64
+	for i, rate := range rates {
+		size += sizes[rate]
+		if float64(size+excess) <= binSize && i < len(rates)-1 {
+			continue
+		}
+		histogram = append(histogram, [2]float64{rate, float64(size)})
+
+		// A bin which overflowed makes the next one smaller, so bins
+		// keep lining up with their intended sizes.
+		excess += size - int64(binSize)
+		size = 0
+		binSize *= feeHistogramBinGrowth
+	}
+	return histogram
+}
+
+// projectedBlock is a block the transactions of the pool are expected to be
+// mined in, assuming no other transaction is added to the pool.
+type projectedBlock struct {
+	// size is the total virtual size of the transactions of the block.
+	size int64
+
+	// minFeeRate is the lowest fee rate in satoshi/kB of the packages of
+	// transactions of the block.
+	minFeeRate float64
+
+	// full is whether the next package did not fit in the block.
+	full bool
+}
+
+// projectBlocks returns up to numBlocks blocks which the transactions of the
+// pool would be mined in, in order, given the maximum virtual size of a block.
+// Like block templates, transactions are ranked by the fee rate of the package
+// of them and their ancestors in the pool, adjusted by their fee deltas, and
+// mined along with their ancestors.  Unlike block templates, packages are not
+// ranked again as their ancestors are mined, which is close enough to project
+// the fee rates of the next blocks.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) projectBlocks(numBlocks int, maxBlockSize int64) []projectedBlock {
+	type poolPackage struct {
+		hash      chainhash.Hash
+		ancestors []chainhash.Hash
+		feeRate   float64
+	}
+
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	packages := make([]*poolPackage, 0, len(mp.pool))
+	for hash, desc := range mp.pool {
+		pkg := &poolPackage{hash: hash}
+		fee := desc.Fee + desc.FeeDelta
+		size := GetTxVirtualSize(desc.Tx)
+		for ancestorHash := range mp.txAncestors(desc.Tx, cache) {
+			ancestor := mp.pool[ancestorHash]
+			fee += ancestor.Fee + ancestor.FeeDelta
+			size += GetTxVirtualSize(ancestor.Tx)
+			pkg.ancestors = append(pkg.ancestors, ancestorHash)
+		}
+		pkg.feeRate = float64(fee) * 1000 / float64(size)
+		packages = append(packages, pkg)
+	}
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].feeRate > packages[j].feeRate
+	})
+
+	blocks := []projectedBlock{{minFeeRate: math.Inf(1)}}
+	mined := make(map[chainhash.Hash]struct{}, len(mp.pool))
+	for _, pkg := range packages {
+		if _, ok := mined[pkg.hash]; ok {
+			continue
+		}
+
+		// The ancestors which are not mined yet are mined along with
+		// the transaction.
+		txns := []chainhash.Hash{pkg.hash}
+		for _, hash := range pkg.ancestors {
+			if _, ok := mined[hash]; !ok {
+				txns = append(txns, hash)
+			}
+		}
+		var size int64
+		for _, hash := range txns {
+			size += GetTxVirtualSize(mp.pool[hash].Tx)
+		}
+
+		block := &blocks[len(blocks)-1]
+		if block.size > 0 && block.size+size > maxBlockSize {
+			block.full = true
+			if len(blocks) >= numBlocks {
+				break
+			}
+			blocks = append(blocks, projectedBlock{
+				minFeeRate: math.Inf(1),
+			})
+			block = &blocks[len(blocks)-1]
+		}
+		block.size += size
+		block.minFeeRate = math.Min(block.minFeeRate, pkg.feeRate)
+		for _, hash := range txns {
+			mined[hash] = struct{}{}
+		}
+	}
+	return blocks
+}
+
+// EstimateFeeFromPool estimates the fee rate a transaction needs to pay to be
+// mined within numBlocks blocks from the blocks the transactions of the pool
+// are expected to be mined in, given the maximum virtual size of a block.
+// When the pool does not fill that many blocks, the estimate is the minimum
+// fee rate for a transaction to be accepted into the pool.  Unlike the
+// FeeEstimator, it needs no history of the blocks transactions were mined in.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) EstimateFeeFromPool(numBlocks uint32, maxBlockSize int64) BtcPerKilobyte {
+	minFee := float64(mp.MinFee())
+
+	mp.mtx.RLock()
+	blocks := mp.projectBlocks(int(numBlocks), maxBlockSize)
+	mp.mtx.RUnlock()
+
+	feeRate := minFee
+	if numBlocks > 0 && int(numBlocks) <= len(blocks) &&
+		blocks[numBlocks-1].full {
+
+		feeRate = math.Max(feeRate, blocks[numBlocks-1].minFeeRate)
+	}
+	return SatoshiPerByte(feeRate / bytePerKb).ToBtcPerKb()
+}
@@ -0,0 +1,82 @@
+package mempool
+
+import (
+	"math"
+	"testing"
+
+	"github.com/pkt-cash/PKT-FullNode/chaincfg"
+)
+
+// TestFeeRates ensures the fee histogram adds up the sizes of the transactions
+// of the pool by fee rate, and that fees are estimated from the blocks the
+// transactions would be mined in, with children paying for their parents.
+func TestFeeRates(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	if histogram := harness.txPool.FeeHistogram(); len(histogram) != 0 {
+		t.Fatalf("histogram of an empty pool is %v", histogram)
+	}
+
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 0)},
+		1, 10000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(parent, 0)},
+		1, 400000, false, false)
+	other := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 1)},
+		1, 100000, false, false)
+	pkgSize := GetTxVirtualSize(parent) + GetTxVirtualSize(child)
+	totalSize := pkgSize + GetTxVirtualSize(other)
+
+	// The transactions fit in the first bin, which has the lowest fee rate.
+	histogram := harness.txPool.FeeHistogram()
+	parentRate := math.Floor(10000*10/float64(GetTxVirtualSize(parent))) / 10
+	if len(histogram) != 1 || histogram[0][0] != parentRate ||
+		histogram[0][1] != float64(totalSize) {
+
+		t.Fatalf("histogram is %v, want [[%v %v]]", histogram,
+			parentRate, totalSize)
+	}
+
+	// The parent and its child fill the first block, so a transaction
+	// needs to pay as much as their package to be mined in it.  The other
+	// transaction does not fill the second block, so the minimum fee is
+	// enough to be mined in it.
+	maxBlockSize := pkgSize + 10
+	toBtcPerKb := func(feeRate float64) BtcPerKilobyte {
+		return SatoshiPerByte(feeRate / bytePerKb).ToBtcPerKb()
+	}
+	tests := []struct {
+		numBlocks uint32
+		want      BtcPerKilobyte
+	}{
+		{1, toBtcPerKb(410000 * 1000 / float64(pkgSize))},
+		{2, toBtcPerKb(float64(harness.txPool.MinFee()))},
+		{3, toBtcPerKb(float64(harness.txPool.MinFee()))},
+	}
+	for _, test := range tests {
+		got := harness.txPool.EstimateFeeFromPool(test.numBlocks,
+			maxBlockSize)
+		if got != test.want {
+			t.Fatalf("estimate for %d blocks is %v, want %v",
+				test.numBlocks, got, test.want)
+		}
+	}
+
+	// Estimates from the pool are used alone until the fee estimator has
+	// observed enough blocks.
+	estimator := NewFeeEstimator(DefaultEstimateFeeMaxRollback,
+		DefaultEstimateFeeMinRegisteredBlocks)
+	result := estimator.EstimateSmartFeeWithPool(1, 0.001)
+	if result.FeeRate == nil || *result.FeeRate != 0.001 ||
+		len(result.Errors) != 0 {
+
+		t.Fatalf("estimate without enough blocks is %v, errors %v",
+			result.FeeRate, result.Errors)
+	}
+}
@@ -43,6 +43,11 @@ const (
 	// scans of the orphan pool to evict expired transactions.
 	orphanExpireScanInterval = time.Minute * 5
 
+	// poolExpireScanInterval is the minimum amount of time in between
+	// scans of the main pool to evict transactions which are older than
+	// the maximum age.
+	poolExpireScanInterval = time.Minute * 5
+
 	// MaxRBFSequence is the maximum sequence number an input can use to
 	// signal that the transaction spending it can be replaced using the
 	// Replace-By-Fee (RBF) policy.
@@ -52,6 +57,12 @@ const (
 	// can be evicted from the mempool when accepting a transaction
 	// replacement.
 	MaxReplacementEvictions = 100
+
+	// rollingMinFeeHalfLife is the time in which the minimum fee raised by
+	// evictions halves when the pool is over half its size limit.  It
+	// halves twice and four times as fast when the pool is under half and
+	// a quarter of the limit.
+	rollingMinFeeHalfLife = time.Hour * 12
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -107,6 +118,12 @@ type Config struct {
 	// FeeEstimatator provides a feeEstimator. If it is not nil, the mempool
 	// records all new transactions it observes into the feeEstimator.
 	FeeEstimator *FeeEstimator
+
+	// Notify, if not nil, is called for each transaction added to or
+	// removed from the main pool.  It is called with the pool lock held so
+	// events arrive in sequence order, and so it must not call back into
+	// the pool.
+	Notify func(*Event)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -152,6 +169,27 @@ type Policy struct {
 	// transactions using the Replace-By-Fee (RBF) signaling policy into
 	// the mempool.
 	RejectReplacement bool
+
+	// MaxPoolSize is the maximum total virtual size in bytes of the
+	// transactions in the main pool.  When it is exceeded the packages
+	// with the lowest fee rate are evicted.  Zero means no limit.
+	MaxPoolSize int64
+
+	// MaxTxAge is how long a transaction may stay in the main pool before
+	// it is evicted along with its descendants.  Zero means no limit.
+	MaxTxAge time.Duration
+
+	// MaxAncestors and MaxDescendants are the maximum number of
+	// transactions in the main pool a transaction may have as ancestors
+	// and as descendants, counting itself.  Zero means no limit.
+	MaxAncestors   int
+	MaxDescendants int
+
+	// MaxAncestorSize and MaxDescendantSize are the maximum total virtual
+	// size in bytes of a transaction together with its ancestors and with
+	// its descendants in the main pool.  Zero means no limit.
+	MaxAncestorSize   int64
+	MaxDescendantSize int64
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -164,6 +202,60 @@ type TxDesc struct {
 	StartingPriority float64
 }
 
+// RemovalReason describes why a transaction was removed from the main pool.
+type RemovalReason int
+
+// These constants are the reasons a transaction is removed from the pool.
+const (
+	// RemovalConfirmed means the transaction was included in a block
+	// connected to the main chain.
+	RemovalConfirmed RemovalReason = iota
+
+	// RemovalReplaced means the transaction, or one of its ancestors, was
+	// replaced by a transaction paying a higher fee.
+	RemovalReplaced
+
+	// RemovalConflict means the transaction, or one of its ancestors,
+	// spends an output which was spent by a transaction in the main chain,
+	// or depends on a transaction which is no longer valid after a
+	// reorganization.
+	RemovalConflict
+
+	// RemovalExpiry means the transaction was in the pool for too long.
+	RemovalExpiry
+
+	// RemovalEviction means the transaction was evicted to keep the pool
+	// within its size limit.
+	RemovalEviction
+)
+
+// removalReasonStrings maps removal reasons to their names.
+var removalReasonStrings = map[RemovalReason]string{
+	RemovalConfirmed: "confirmed",
+	RemovalReplaced:  "replaced",
+	RemovalConflict:  "conflict",
+	RemovalExpiry:    "expiry",
+	RemovalEviction:  "eviction",
+}
+
+// String returns the RemovalReason as a human-readable name.
+func (r RemovalReason) String() string {
+	if s, ok := removalReasonStrings[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown RemovalReason (%d)", int(r))
+}
+
+// Event is a transaction being added to or removed from the main pool.  The
+// sequence number of the pool is incremented by each event and the event
+// carries the new value.
+type Event struct {
+	Tx       *btcutil.Tx
+	Removed  bool
+	Reason   RemovalReason
+	Sequence uint64
+}
+
 // orphanTx is normal transaction that references an ancestor transaction
 // that is not yet available.  It also contains additional information related
 // to it such as an expiration time to help prevent caching the orphan forever.
@@ -186,9 +278,34 @@ type TxPool struct {
 	orphans       map[chainhash.Hash]*orphanTx
 	orphansByPrev map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx
 	outpoints     map[wire.OutPoint]*btcutil.Tx
+	feeDeltas     map[chainhash.Hash]int64
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 
+	// sequence is incremented each time a transaction is added to or
+	// removed from the main pool.
+	sequence uint64
+
+	// poolSize is the total virtual size of the transactions in the main
+	// pool.
+	poolSize int64
+
+	// evictionIndex orders the transactions of the main pool by the fee
+	// rate they are ranked by for eviction.
+	evictionIndex *evictionIndex
+
+	// rollingMinFee is the fee rate in satoshi/kB a new transaction must
+	// pay since transactions were evicted to keep the pool within its
+	// size limit, as of lastRollingMinFee.  It decays over time.
+	rollingMinFee     float64
+	lastRollingMinFee time.Time
+
+	// nextPoolExpireScan is the time after which the main pool will be
+	// scanned in order to evict transactions older than the maximum age.
+	// Like nextExpireScan, it is only checked when a transaction is
+	// processed.
+	nextPoolExpireScan time.Time
+
 	// nextExpireScan is the time after which the orphan pool will be
 	// scanned in order to evict orphans.  This is NOT a hard deadline as
 	// the scan will only run when an orphan is added to the pool as opposed
@@ -464,18 +581,42 @@ func (mp *TxPool) HaveTransaction(hash *chainhash.Hash) bool {
 	return haveTx
 }
 
+// notify increments the sequence number of the pool and passes the event to
+// the Notify callback, if there is one.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) notify(tx *btcutil.Tx, removed bool, reason RemovalReason) {
+	mp.sequence++
+	if mp.cfg.Notify == nil {
+		return
+	}
+	mp.cfg.Notify(&Event{
+		Tx:       tx,
+		Removed:  removed,
+		Reason:   reason,
+		Sequence: mp.sequence,
+	})
+}
+
 // removeTransaction is the internal function which implements the public
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool, reason RemovalReason) {
 	txHash := tx.Hash()
+
+	// The fee delta of a transaction is of no further use once it is
+	// mined, whether or not it was in the pool.
+	if reason == RemovalConfirmed {
+		delete(mp.feeDeltas, *txHash)
+	}
+
 	if removeRedeemers {
 		// Remove any transactions which rely on this one.
 		for i := uint32(0); i < uint32(len(tx.MsgTx().TxOut)); i++ {
 			prevOut := wire.OutPoint{Hash: *txHash, Index: i}
 			if txRedeemer, exists := mp.outpoints[prevOut]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, reason)
 			}
 		}
 	}
@@ -493,20 +634,24 @@ func (mp *TxPool) removeTransaction(tx *btcutil.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+		mp.poolSize -= GetTxVirtualSize(tx)
+		mp.updateEvictionIndex(tx)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		mp.notify(tx, true, reason)
 	}
 }
 
 // RemoveTransaction removes the passed transaction from the mempool. When the
 // removeRedeemers flag is set, any transactions that redeem outputs from the
 // removed transaction will also be removed recursively from the mempool, as
-// they would otherwise become orphans.
+// they would otherwise become orphans.  The reason is reported to the Notify
+// callback for each removed transaction.
 //
 // This function is safe for concurrent access.
-func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool) {
+func (mp *TxPool) RemoveTransaction(tx *btcutil.Tx, removeRedeemers bool, reason RemovalReason) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
+	mp.removeTransaction(tx, removeRedeemers, reason)
 	mp.mtx.Unlock()
 }
 
@@ -524,12 +669,43 @@ func (mp *TxPool) RemoveDoubleSpends(tx *btcutil.Tx) {
 	for _, txIn := range tx.MsgTx().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOutPoint]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true,
+					RemovalConflict)
 			}
 		}
 	}
 }
 
+// PrioritiseTransaction adds the passed delta to the fee delta of the
+// transaction with the passed hash.  The fee of the transaction is adjusted by
+// its fee delta when it is checked for acceptance, ranked for eviction or
+// replacement, and selected for mining, although the fee it pays is unchanged.
+// The transaction does not need to be in the pool, in which case the delta is
+// applied once it is added.  Deltas are forgotten once the transaction is
+// mined.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PrioritiseTransaction(hash *chainhash.Hash, delta int64) {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	feeDelta := mp.feeDeltas[*hash] + delta
+	if feeDelta == 0 {
+		delete(mp.feeDeltas, *hash)
+	} else {
+		mp.feeDeltas[*hash] = feeDelta
+	}
+	// The descriptor is replaced rather than updated since callers of
+	// TxDescs and MiningDescs read it without holding the lock.
+	if txD, exists := mp.pool[*hash]; exists {
+		updated := *txD
+		updated.FeeDelta = feeDelta
+		mp.pool[*hash] = &updated
+		mp.updateEvictionIndex(txD.Tx)
+	}
+	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+}
+
 // addTransaction adds the passed transaction to the memory pool.  It should
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
@@ -545,14 +721,17 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 			Height:   height,
 			Fee:      fee,
 			FeePerKB: fee * 1000 / GetTxVirtualSize(tx),
+			FeeDelta: mp.feeDeltas[*tx.Hash()],
 		},
 		StartingPriority: mining.CalcPriority(tx.MsgTx(), utxoView, height),
 	}
 
 	mp.pool[*tx.Hash()] = txD
+	mp.poolSize += GetTxVirtualSize(tx)
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+	mp.updateEvictionIndex(tx)
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
 	// Add unconfirmed address index entries associated with the transaction
@@ -566,6 +745,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *btcutil
 		mp.cfg.FeeEstimator.ObserveTransaction(txD)
 	}
 
+	mp.notify(tx, false, 0)
 	return txD
 }
 
@@ -767,6 +947,196 @@ func (mp *TxPool) txConflicts(tx *btcutil.Tx) map[chainhash.Hash]*btcutil.Tx {
 	return conflicts
 }
 
+// rollingFee returns the rolling minimum fee rate in satoshi/kB, decayed to
+// now.  It is dropped once it falls to half of the minimum relay fee.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) rollingFee(now time.Time) float64 {
+	if mp.rollingMinFee == 0 {
+		return 0
+	}
+	halfLife := rollingMinFeeHalfLife
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	switch {
+	case mp.poolSize < maxSize/4:
+		halfLife /= 4
+	case mp.poolSize < maxSize/2:
+		halfLife /= 2
+	}
+	elapsed := now.Sub(mp.lastRollingMinFee).Seconds()
+	mp.rollingMinFee *= math.Pow(0.5, elapsed/halfLife.Seconds())
+	mp.lastRollingMinFee = now
+	if mp.rollingMinFee < float64(mp.cfg.Policy.MinRelayTxFee)/2 {
+		mp.rollingMinFee = 0
+	}
+	return mp.rollingMinFee
+}
+
+// evictionFeeRate returns the fee rate in satoshi/kB by which the passed
+// transaction is ranked for eviction.  It is the higher of the fee rate of the
+// transaction and that of the package of it and its descendants, so neither
+// a transaction paying well nor one whose children pay for it is evicted
+// early.  Fees are adjusted by the fee deltas of the transactions.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) evictionFeeRate(txD *TxDesc,
+	cache map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx) float64 {
+
+	size := GetTxVirtualSize(txD.Tx)
+	fee := txD.Fee + txD.FeeDelta
+	rate := float64(fee) * 1000 / float64(size)
+	for hash := range mp.txDescendants(txD.Tx, cache) {
+		if desc, ok := mp.pool[hash]; ok {
+			size += GetTxVirtualSize(desc.Tx)
+			fee += desc.Fee + desc.FeeDelta
+		}
+	}
+	return math.Max(rate, float64(fee)*1000/float64(size))
+}
+
+// trimToSize evicts the transactions with the lowest fee rate, along with
+// their descendants, until the pool is within its size limit.  The rolling
+// minimum fee is raised above the fee rate of each evicted package so that
+// transactions paying no more are not accepted only to be evicted.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) trimToSize(now time.Time) {
+	maxSize := mp.cfg.Policy.MaxPoolSize
+	if maxSize <= 0 {
+		return
+	}
+
+	for mp.poolSize > maxSize && mp.evictionIndex.Len() > 0 {
+		entry := mp.evictionIndex.entries[0]
+		rate := entry.rate
+		txD := mp.pool[entry.hash]
+
+		log.Debugf("Evicting transaction %v (fee_rate=%v sat/kb) to "+
+			"keep the pool size under %d bytes", entry.hash,
+			int64(rate), maxSize)
+		mp.removeTransaction(txD.Tx, true, RemovalEviction)
+
+		minFee := rate + float64(mp.cfg.Policy.MinRelayTxFee)
+		if minFee > mp.rollingFee(now) {
+			mp.rollingMinFee = minFee
+			mp.lastRollingMinFee = now
+		}
+	}
+}
+
+// expireTransactions evicts the transactions which have been in the main pool
+// for longer than the maximum age, along with their descendants.  The pool is
+// scanned no more often than every poolExpireScanInterval.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) expireTransactions(now time.Time) {
+	maxAge := mp.cfg.Policy.MaxTxAge
+	if maxAge <= 0 || now.Before(mp.nextPoolExpireScan) {
+		return
+	}
+	mp.nextPoolExpireScan = now.Add(poolExpireScanInterval)
+
+	var numExpired int
+	for _, txD := range mp.pool {
+		if now.Sub(txD.Added) <= maxAge {
+			continue
+		}
+		// The descendants are removed along with the transaction and
+		// may still be visited by this loop, so check it is still in
+		// the pool.
+		if _, ok := mp.pool[*txD.Tx.Hash()]; !ok {
+			continue
+		}
+		before := len(mp.pool)
+		mp.removeTransaction(txD.Tx, true, RemovalExpiry)
+		numExpired += before - len(mp.pool)
+	}
+	if numExpired > 0 {
+		log.Debugf("Expired %d transactions older than %v (pool size: %v)",
+			numExpired, maxAge, len(mp.pool))
+	}
+}
+
+// checkChainLimits returns an error if accepting the passed transaction would
+// give it more, or larger, ancestors in the main pool than the policy allows,
+// or would do so for the descendants of any of those ancestors.  The package is
+// optional, its transactions are counted as if they were in the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkChainLimits(tx *btcutil.Tx, size int64,
+	pkg *txPackage) er.R {
+
+	policy := &mp.cfg.Policy
+	ancestors := mp.txPackageAncestors(tx, pkg, nil)
+	if policy.MaxAncestors > 0 && len(ancestors)+1 > policy.MaxAncestors {
+		str := fmt.Sprintf("transaction %v has %d unconfirmed ancestors "+
+			"[limit: %d]", tx.Hash(), len(ancestors)+1,
+			policy.MaxAncestors)
+		return ruleerror.ErrTooManyAncestors.New(str, nil)
+	}
+
+	ancestorSize := size
+	for _, ancestor := range ancestors {
+		ancestorSize += GetTxVirtualSize(ancestor)
+	}
+	if policy.MaxAncestorSize > 0 && ancestorSize > policy.MaxAncestorSize {
+		str := fmt.Sprintf("transaction %v and its unconfirmed "+
+			"ancestors have a virtual size of %d [limit: %d]",
+			tx.Hash(), ancestorSize, policy.MaxAncestorSize)
+		return ruleerror.ErrAncestorsTooLarge.New(str, nil)
+	}
+
+	if policy.MaxDescendants <= 0 && policy.MaxDescendantSize <= 0 {
+		return nil
+	}
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	for hash, ancestor := range ancestors {
+		descendants := mp.txPackageDescendants(ancestor, pkg, cache)
+		numDescendants := len(descendants) + 2
+		if policy.MaxDescendants > 0 &&
+			numDescendants > policy.MaxDescendants {
+
+			str := fmt.Sprintf("transaction %v would give its "+
+				"ancestor %v %d descendants [limit: %d]",
+				tx.Hash(), hash, numDescendants,
+				policy.MaxDescendants)
+			return ruleerror.ErrTooManyDescendants.New(str, nil)
+		}
+
+		descendantSize := GetTxVirtualSize(ancestor) + size
+		for _, descendant := range descendants {
+			descendantSize += GetTxVirtualSize(descendant)
+		}
+		if policy.MaxDescendantSize > 0 &&
+			descendantSize > policy.MaxDescendantSize {
+
+			str := fmt.Sprintf("transaction %v would make its "+
+				"ancestor %v and its descendants have a "+
+				"virtual size of %d [limit: %d]", tx.Hash(),
+				hash, descendantSize, policy.MaxDescendantSize)
+			return ruleerror.ErrDescendantsTooLarge.New(str, nil)
+		}
+	}
+	return nil
+}
+
+// MinFee returns the minimum fee rate in satoshi/kB a transaction must pay to
+// be accepted into the pool.  It is the minimum relay fee unless transactions
+// have been evicted to keep the pool within its size limit, after which it is
+// the higher rolling minimum fee which decays over time.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinFee() btcutil.Amount {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	minFee := btcutil.Amount(math.Ceil(mp.rollingFee(time.Now())))
+	if minFee < mp.cfg.Policy.MinRelayTxFee {
+		minFee = mp.cfg.Policy.MinRelayTxFee
+	}
+	return minFee
+}
+
 // CheckSpend checks whether the passed outpoint is already spent by a
 // transaction in the mempool. If that's the case the spending transaction will
 // be returned, if not nil will be returned.
@@ -827,10 +1197,29 @@ func (mp *TxPool) FetchTransaction(txHash *chainhash.Hash) (*btcutil.Tx, er.R) {
 	return nil, er.Errorf("transaction is not in the pool")
 }
 
+// FetchTxDesc returns the descriptor of the requested transaction from the
+// transaction pool.  This only fetches from the main transaction pool and does
+// not include orphans.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FetchTxDesc(txHash *chainhash.Hash) (*TxDesc, er.R) {
+	// Protect concurrent access.
+	mp.mtx.RLock()
+	txDesc, exists := mp.pool[*txHash]
+	mp.mtx.RUnlock()
+
+	if exists {
+		return txDesc, nil
+	}
+
+	return nil, er.Errorf("transaction is not in the pool")
+}
+
 // validateReplacement determines whether a transaction is deemed as a valid
 // replacement of all of its conflicts according to the RBF policy. If it is
 // valid, no error is returned. Otherwise, an error is returned indicating what
-// went wrong.
+// went wrong.  The fees of the transaction and of its conflicts are compared
+// after being adjusted by their fee deltas.
 //
 // This function MUST be called with the mempool lock held (for reads).
 func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
@@ -873,15 +1262,18 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 		conflictsParents = make(map[chainhash.Hash]struct{})
 	)
 	for hash, conflict := range conflicts {
-		if txFeeRate <= mp.pool[hash].FeePerKB {
+		desc := mp.pool[hash]
+		conflictFee := desc.Fee + desc.FeeDelta
+		conflictFeeRate := conflictFee * 1000 / GetTxVirtualSize(desc.Tx)
+		if txFeeRate <= conflictFeeRate {
 			str := fmt.Sprintf("replacement transaction %v has an "+
 				"insufficient fee rate: needs more than %v, "+
-				"has %v", tx.Hash(), mp.pool[hash].FeePerKB,
+				"has %v", tx.Hash(), conflictFeeRate,
 				txFeeRate)
 			return nil, txRuleError(wire.RejectInsufficientFee, str)
 		}
 
-		conflictsFee += mp.pool[hash].Fee
+		conflictsFee += conflictFee
 
 		// We'll track each conflict's parents to ensure the replacement
 		// isn't spending any new unconfirmed inputs.
@@ -921,12 +1313,31 @@ func (mp *TxPool) validateReplacement(tx *btcutil.Tx,
 	return conflicts, nil
 }
 
-// maybeAcceptTransaction is the internal function which implements the public
-// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
-// more details.
+// txCheck holds the details of a transaction which passed all of the checks
+// for acceptance into the pool and which are needed to add it.
+type txCheck struct {
+	tx        *btcutil.Tx
+	utxoView  *blockchain.UtxoViewpoint
+	height    int32
+	fee       int64
+	size      int64
+	conflicts map[chainhash.Hash]*btcutil.Tx
+}
+
+// checkTransaction performs all of the policy and consensus checks for the
+// acceptance of the passed transaction into the pool without adding it.  If
+// the transaction is an orphan, the missing parents are returned instead.
+//
+// The package is optional.  When it is set, the transaction may spend outputs
+// of the transactions of the package, which are checked as if they were in the
+// pool, and must not conflict with them.  The fee checks are skipped when the
+// fees are checked for the package as a whole, in which case the transaction
+// may not replace transactions in the pool.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]wire.OutPoint, *TxDesc, er.R) {
+func (mp *TxPool) checkTransaction(tx *btcutil.Tx, isNew, rateLimit,
+	rejectDupOrphans bool, pkg *txPackage) ([]wire.OutPoint, *txCheck, er.R) {
+
 	txHash := tx.Hash()
 
 	// If a transaction has iwtness data, and segwit isn't active yet, If
@@ -955,6 +1366,11 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		str := fmt.Sprintf("%v", txHash)
 		return nil, nil, ruleerror.ErrTxExistsInMempool.New(str, nil)
 	}
+	if pkg != nil {
+		if err := pkg.checkDoubleSpend(tx); err != nil {
+			return nil, nil, err
+		}
+	}
 
 	// Perform preliminary sanity checks on the transaction.  This makes
 	// use of blockchain which contains the invariant rules for what
@@ -1004,6 +1420,15 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// The transactions of a package whose fees are checked as a whole may
+	// not replace transactions in the pool, since the conflicts of each of
+	// them are found before any of them is added and may overlap.
+	if isReplacement && pkg != nil && pkg.deferFees {
+		str := fmt.Sprintf("package transaction %v replaces "+
+			"transactions in the memory pool", txHash)
+		return nil, nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
 	// Fetch all of the unspent transaction outputs referenced by the inputs
 	// to this transaction.  This function also attempts to fetch the
 	// transaction itself to be used for detecting a duplicate transaction
@@ -1012,6 +1437,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	if err != nil {
 		return nil, nil, err
 	}
+	if pkg != nil {
+		pkg.fetchInputUtxos(tx, utxoView)
+	}
 
 	// Don't allow the transaction if it exists in the main chain and is not
 	// not already fully spent.
@@ -1067,6 +1495,10 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
+	// The fee checks below use the fee adjusted by the fee delta set for
+	// the transaction, if any.
+	modifiedFee := txFee + mp.feeDeltas[*txHash]
+
 	// Don't allow transactions with non-standard inputs if the network
 	// parameters forbid their acceptance.
 	if !mp.cfg.Policy.AcceptNonStd {
@@ -1107,12 +1539,18 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	// which is more desirable.  Therefore, as long as the size of the
 	// transaction does not exceeed 1000 less than the reserved space for
 	// high-priority transactions, don't require a fee for it.
+	//
+	// The fees of the transactions of a package may instead be checked
+	// for the package as a whole, in which case these checks are skipped.
+	checkFees := pkg == nil || !pkg.deferFees
 	serializedSize := GetTxVirtualSize(tx)
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
 		mp.cfg.Policy.MinRelayTxFee)
-	if serializedSize >= (DefaultBlockPrioritySize-1000) && txFee < minFee {
+	if checkFees && serializedSize >= (DefaultBlockPrioritySize-1000) &&
+		modifiedFee < minFee {
+
 		str := fmt.Sprintf("transaction %v has %d fees which is under "+
-			"the required amount of %d", txHash, txFee,
+			"the required amount of %d", txHash, modifiedFee,
 			minFee)
 		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
@@ -1121,7 +1559,9 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 	// in the next block.  Transactions which are being added back to the
 	// memory pool from blocks that have been disconnected during a reorg
 	// are exempted.
-	if isNew && !mp.cfg.Policy.DisableRelayPriority && txFee < minFee {
+	if checkFees && isNew && !mp.cfg.Policy.DisableRelayPriority &&
+		modifiedFee < minFee {
+
 		currentPriority := mining.CalcPriority(tx.MsgTx(), utxoView,
 			nextBlockHeight)
 		if currentPriority <= mining.MinHighPriority() {
@@ -1134,7 +1574,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 
 	// Free-to-relay transactions are rate limited here to prevent
 	// penny-flooding with tiny transactions as a form of attack.
-	if rateLimit && txFee < minFee {
+	if rateLimit && modifiedFee < minFee {
 		nowUnix := time.Now().Unix()
 		// Decay passed data with an exponentially decaying ~10 minute
 		// window - matches bitcoind handling.
@@ -1156,11 +1596,35 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 			mp.cfg.Policy.FreeTxRelayLimit*10*1000)
 	}
 
+	// Don't allow transactions which would make the chains of unconfirmed
+	// transactions in the pool longer or larger than the policy allows.
+	// Long chains are costly to evaluate when generating block templates.
+	err = mp.checkChainLimits(tx, serializedSize, pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Once transactions have been evicted to keep the pool within its size
+	// limit, require new transactions to pay more than the evicted ones.
+	// Transactions which are being added back to the memory pool from
+	// blocks that have been disconnected during a reorg are exempted.
+	if checkFees && isNew {
+		rollingFee := mp.rollingFee(time.Now())
+		rollingMinFee := calcMinRequiredTxRelayFee(serializedSize,
+			btcutil.Amount(math.Ceil(rollingFee)))
+		if rollingFee > 0 && modifiedFee < rollingMinFee {
+			str := fmt.Sprintf("transaction %v has %d fees which is "+
+				"under the mempool minimum fee of %d", txHash,
+				modifiedFee, rollingMinFee)
+			return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
+		}
+	}
+
 	// If the transaction has any conflicts and we've made it this far, then
 	// we're processing a potential replacement.
 	var conflicts map[chainhash.Hash]*btcutil.Tx
 	if isReplacement {
-		conflicts, err = mp.validateReplacement(tx, txFee)
+		conflicts, err = mp.validateReplacement(tx, modifiedFee)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -1175,21 +1639,72 @@ func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejec
 		return nil, nil, err
 	}
 
-	// Now that we've deemed the transaction as valid, we can add it to the
-	// mempool. If it ended up replacing any transactions, we'll remove them
-	// first.
-	for _, conflict := range conflicts {
+	return nil, &txCheck{
+		tx:        tx,
+		utxoView:  utxoView,
+		height:    bestHeight,
+		fee:       txFee,
+		size:      serializedSize,
+		conflicts: conflicts,
+	}, nil
+}
+
+// addCheckedTransaction adds a transaction which passed checkTransaction to the
+// pool, after removing the transactions it replaces.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) addCheckedTransaction(check *txCheck) *TxDesc {
+	// If the transaction ended up replacing any transactions, we'll remove
+	// them first.
+	for _, conflict := range check.conflicts {
+		txD, ok := mp.pool[*conflict.Hash()]
+		if !ok {
+			continue
+		}
 		log.Debugf("Replacing transaction %v (fee_rate=%v sat/kb) "+
 			"with %v (fee_rate=%v sat/kb)\n", conflict.Hash(),
-			mp.pool[*conflict.Hash()].FeePerKB, tx.Hash(),
-			txFee*1000/serializedSize)
+			txD.FeePerKB, check.tx.Hash(),
+			check.fee*1000/check.size)
 
 		// The conflict set should already include the descendants for
 		// each one, so we don't need to remove the redeemers within
 		// this call as they'll be removed eventually.
-		mp.removeTransaction(conflict, false)
+		mp.removeTransaction(conflict, false, RemovalReplaced)
+	}
+	return mp.addTransaction(check.utxoView, check.tx, check.height,
+		check.fee)
+}
+
+// maybeAcceptTransaction is the internal function which implements the public
+// MaybeAcceptTransaction.  See the comment for MaybeAcceptTransaction for
+// more details.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) maybeAcceptTransaction(tx *btcutil.Tx, isNew, rateLimit, rejectDupOrphans bool) ([]wire.OutPoint, *TxDesc, er.R) {
+	txHash := tx.Hash()
+
+	// Evict the transactions which have been in the pool for too long
+	// before looking up the inputs of this one, which may spend them.
+	mp.expireTransactions(time.Now())
+
+	missingParents, check, err := mp.checkTransaction(tx, isNew, rateLimit,
+		rejectDupOrphans, nil)
+	if err != nil || len(missingParents) > 0 {
+		return missingParents, nil, err
+	}
+
+	// Now that we've deemed the transaction as valid, we can add it to the
+	// mempool.
+	txD := mp.addCheckedTransaction(check)
+
+	// Keep the pool within its size limit, which evicts the transaction
+	// itself if it pays the lowest fee rate.
+	mp.trimToSize(time.Now())
+	if _, ok := mp.pool[*txHash]; !ok {
+		str := fmt.Sprintf("transaction %v was evicted as the memory "+
+			"pool is full", txHash)
+		return nil, nil, txRuleError(wire.RejectInsufficientFee, str)
 	}
-	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
@@ -1318,6 +1833,22 @@ func (mp *TxPool) ProcessOrphans(acceptedTx *btcutil.Tx) []*TxDesc {
 	return acceptedTxns
 }
 
+// errMissingParents returns the error rejecting an orphan transaction when
+// orphans are not allowed.
+func errMissingParents(tx *btcutil.Tx, missingParents []wire.OutPoint) er.R {
+	// Only use the first missing parent transaction in the error message.
+	//
+	// NOTE: RejectDuplicate is really not an accurate reject code here,
+	// but it matches the reference implementation and there isn't a better
+	// choice due to the limited number of reject codes.  Missing inputs is
+	// assumed to mean they are already spent which is not really always
+	// the case.
+	str := fmt.Sprintf("orphan transaction %v references outputs of "+
+		"unknown or fully-spent transaction %v", tx.Hash(),
+		missingParents[0].String())
+	return ruleerror.ErrOrphanTransactionDisallowed.New(str, nil)
+}
+
 // ProcessTransaction is the main workhorse for handling insertion of new
 // free-standing transactions into the memory pool.  It includes functionality
 // such as rejecting duplicate transactions, ensuring transactions follow all
@@ -1362,19 +1893,7 @@ func (mp *TxPool) ProcessTransaction(tx *btcutil.Tx, allowOrphan, rateLimit bool
 	// The transaction is an orphan (has inputs missing).  Reject
 	// it if the flag to allow orphans is not set.
 	if !allowOrphan {
-		// Only use the first missing parent transaction in
-		// the error message.
-		//
-		// NOTE: RejectDuplicate is really not an accurate
-		// reject code here, but it matches the reference
-		// implementation and there isn't a better choice due
-		// to the limited number of reject codes.  Missing
-		// inputs is assumed to mean they are already spent
-		// which is not really always the case.
-		str := fmt.Sprintf("orphan transaction %v references "+
-			"outputs of unknown or fully-spent "+
-			"transaction %v", tx.Hash(), missingParents[0].String())
-		return nil, ruleerror.ErrOrphanTransactionDisallowed.New(str, nil)
+		return nil, errMissingParents(tx, missingParents)
 	}
 
 	// Potentially add the orphan transaction to the orphan pool.
@@ -1394,6 +1913,17 @@ func (mp *TxPool) Count() int {
 	return count
 }
 
+// OrphanCount returns the number of transactions in the orphan pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) OrphanCount() int {
+	mp.mtx.RLock()
+	count := len(mp.orphans)
+	mp.mtx.RUnlock()
+
+	return count
+}
+
 // TxDescs returns a slice of descriptors for all the transactions in the pool.
 // The descriptors are to be treated as read only.
 //
@@ -1411,6 +1941,36 @@ func (mp *TxPool) TxDescs() []*TxDesc {
 	return descs
 }
 
+// TxDescsWithSequence returns the descriptors for all the transactions in the
+// pool together with the sequence number of the pool when they were taken, so
+// a client can apply the events which follow it.  The descriptors are to be
+// treated as read only.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TxDescsWithSequence() ([]*TxDesc, uint64) {
+	mp.mtx.RLock()
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+	sequence := mp.sequence
+	mp.mtx.RUnlock()
+
+	return descs, sequence
+}
+
+// Sequence returns the sequence number of the pool, which is incremented
+// each time a transaction is added to or removed from the main pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Sequence() uint64 {
+	mp.mtx.RLock()
+	sequence := mp.sequence
+	mp.mtx.RUnlock()
+
+	return sequence
+}
+
 // MiningDescs returns a slice of mining descriptors for all the transactions
 // in the pool.
 //
@@ -1457,6 +2017,8 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 			Size:             int32(tx.MsgTx().SerializeSize()),
 			Vsize:            int32(GetTxVirtualSize(tx)),
 			Fee:              btcutil.Amount(desc.Fee).ToBTC(),
+			ModifiedFee:      btcutil.Amount(desc.Fee + desc.FeeDelta).ToBTC(),
+			FeeDelta:         btcutil.Amount(desc.FeeDelta).ToBTC(),
 			Time:             desc.Added.Unix(),
 			Height:           int64(desc.Height),
 			StartingPriority: desc.StartingPriority,
@@ -1495,5 +2057,7 @@ func New(cfg *Config) *TxPool {
 		orphansByPrev:  make(map[wire.OutPoint]map[chainhash.Hash]*btcutil.Tx),
 		nextExpireScan: time.Now().Add(orphanExpireScanInterval),
 		outpoints:      make(map[wire.OutPoint]*btcutil.Tx),
+		feeDeltas:      make(map[chainhash.Hash]int64),
+		evictionIndex:  newEvictionIndex(),
 	}
 }
@@ -1801,3 +1801,439 @@ func TestRBF(t *testing.T) {
 		}
 	}
 }
+
+// TestEvents ensures each transaction added to or removed from the pool is
+// reported with the next sequence number and the reason it was removed.
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	type event struct {
+		hash     chainhash.Hash
+		removed  bool
+		reason   RemovalReason
+		sequence uint64
+	}
+	var events []event
+	harness.txPool.cfg.Notify = func(ev *Event) {
+		events = append(events, event{*ev.Tx.Hash(), ev.Removed,
+			ev.Reason, ev.Sequence})
+	}
+	popEvents := func() map[chainhash.Hash]event {
+		t.Helper()
+		got := make(map[chainhash.Hash]event, len(events))
+		for _, ev := range events {
+			got[ev.hash] = ev
+		}
+		events = nil
+		return got
+	}
+	checkEvent := func(got map[chainhash.Hash]event, tx *btcutil.Tx,
+		removed bool, reason RemovalReason) {
+
+		t.Helper()
+		ev, ok := got[*tx.Hash()]
+		if !ok {
+			t.Fatalf("no event for %v", tx.Hash())
+		}
+		if ev.removed != removed || (removed && ev.reason != reason) {
+			t.Fatalf("unexpected event for %v: removed %v, reason %v",
+				tx.Hash(), ev.removed, ev.reason)
+		}
+	}
+
+	// A transaction with a child which is replaced, which removes both,
+	// and then the replacement being confirmed.
+	coinbase := ctx.addCoinbaseTx(1)
+	outs := []spendableOutput{txOutToSpendableOut(coinbase, 0)}
+	parent := ctx.addSignedTx(outs, 1, 1000000, true, false)
+	child := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(parent, 0)},
+		1, 1000000, true, false)
+	got := popEvents()
+	checkEvent(got, parent, false, 0)
+	checkEvent(got, child, false, 0)
+	if got[*child.Hash()].sequence != 2 {
+		t.Fatalf("unexpected child sequence %d", got[*child.Hash()].sequence)
+	}
+
+	replacement := ctx.addSignedTx(outs, 1, 5000000, true, false)
+	got = popEvents()
+	checkEvent(got, parent, true, RemovalReplaced)
+	checkEvent(got, child, true, RemovalReplaced)
+	checkEvent(got, replacement, false, 0)
+	if got[*replacement.Hash()].sequence != 5 {
+		t.Fatalf("unexpected replacement sequence %d",
+			got[*replacement.Hash()].sequence)
+	}
+
+	harness.txPool.RemoveTransaction(replacement, false, RemovalConfirmed)
+	got = popEvents()
+	checkEvent(got, replacement, true, RemovalConfirmed)
+
+	// A transaction which conflicts with one in a block.
+	coinbase = ctx.addCoinbaseTx(1)
+	outs = []spendableOutput{txOutToSpendableOut(coinbase, 0)}
+	spend := ctx.addSignedTx(outs, 1, 1000000, false, false)
+	doubleSpend, err := harness.CreateSignedTx(outs, 2, 1000000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	popEvents()
+	harness.txPool.RemoveDoubleSpends(doubleSpend)
+	got = popEvents()
+	checkEvent(got, spend, true, RemovalConflict)
+
+	txDescs, sequence := harness.txPool.TxDescsWithSequence()
+	if len(txDescs) != 0 || sequence != 8 ||
+		harness.txPool.Sequence() != sequence {
+
+		t.Fatalf("unexpected pool of %d transactions at sequence %d",
+			len(txDescs), sequence)
+	}
+}
+
+// TestPoolSizeLimit ensures the transactions with the lowest fee rate are
+// evicted when the pool exceeds its size limit, that a child paying for its
+// parent protects it, and that the rolling minimum fee is raised and decays.
+func TestPoolSizeLimit(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	var evicted []chainhash.Hash
+	harness.txPool.cfg.Notify = func(ev *Event) {
+		if ev.Removed && ev.Reason == RemovalEviction {
+			evicted = append(evicted, *ev.Tx.Hash())
+		}
+	}
+
+	coinbase := ctx.addCoinbaseTx(5)
+	spend := func(i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(coinbase, i)}
+	}
+	low := ctx.addSignedTx(spend(0), 1, 20000, false, false)
+	parent := ctx.addSignedTx(spend(1), 1, 10000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(parent, 0)},
+		1, 100000, false, false)
+	mid := ctx.addSignedTx(spend(2), 1, 50000, false, false)
+
+	// Limit the pool to about its current size, allowing for signatures
+	// of differing lengths, so adding another transaction evicts the one
+	// with the lowest fee rate.  The parent pays less but its child pays
+	// for it.
+	harness.txPool.cfg.Policy.MaxPoolSize = harness.txPool.poolSize + 10
+	if minFee := harness.txPool.MinFee(); minFee != 1000 {
+		t.Fatalf("minimum fee before eviction is %v", minFee)
+	}
+	high := ctx.addSignedTx(spend(3), 1, 200000, false, false)
+	if len(evicted) != 1 || evicted[0] != *low.Hash() {
+		t.Fatalf("unexpected evictions %v", evicted)
+	}
+	testPoolMembership(ctx, low, false, false)
+	for _, tx := range []*btcutil.Tx{parent, child, mid, high} {
+		testPoolMembership(ctx, tx, false, true)
+	}
+
+	lowRate := 20000 * 1000 / GetTxVirtualSize(low)
+	minFee := harness.txPool.MinFee()
+	if int64(minFee) < lowRate+1000 || int64(minFee) > lowRate+1001 {
+		t.Fatalf("minimum fee after eviction is %v, want %v", minFee,
+			lowRate+1000)
+	}
+
+	// A transaction paying no more than the evicted one is rejected.
+	tx, err := harness.CreateSignedTx(spend(4), 1, 20000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, true, false, 0)
+	code, _, found := ruleerror.ExtractRejectCode(err)
+	if !found || code != wire.RejectInsufficientFee {
+		t.Fatalf("low fee transaction: got %v", err)
+	}
+
+	// The rolling minimum fee decays back to the minimum relay fee.
+	harness.txPool.mtx.Lock()
+	harness.txPool.lastRollingMinFee = time.Now().Add(-10 * 24 * time.Hour)
+	harness.txPool.mtx.Unlock()
+	if minFee := harness.txPool.MinFee(); minFee != 1000 {
+		t.Fatalf("minimum fee after decay is %v", minFee)
+	}
+
+	// A single trim evicts as many packages as needed, lowest fee rate
+	// first.
+	evicted = nil
+	harness.txPool.mtx.Lock()
+	harness.txPool.cfg.Policy.MaxPoolSize = GetTxVirtualSize(high) + 10
+	harness.txPool.trimToSize(time.Now())
+	harness.txPool.mtx.Unlock()
+	if len(evicted) != 3 || evicted[0] != *mid.Hash() {
+		t.Fatalf("unexpected evictions %v", evicted)
+	}
+	for _, tx := range []*btcutil.Tx{parent, child, mid} {
+		testPoolMembership(ctx, tx, false, false)
+	}
+	testPoolMembership(ctx, high, false, true)
+}
+
+// TestEvictionIndex ensures the fee rates the transactions of the pool are
+// ranked by for eviction are kept up to date as transactions are added,
+// removed and prioritised.
+func TestEvictionIndex(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	checkIndex := func(lowest *btcutil.Tx) {
+		t.Helper()
+		mp := harness.txPool
+		mp.mtx.Lock()
+		defer mp.mtx.Unlock()
+		if mp.evictionIndex.Len() != len(mp.pool) {
+			t.Fatalf("index has %d transactions, pool has %d",
+				mp.evictionIndex.Len(), len(mp.pool))
+		}
+		for hash, txD := range mp.pool {
+			entry, ok := mp.evictionIndex.byHash[hash]
+			if !ok {
+				t.Fatalf("transaction %v is not indexed", hash)
+			}
+			if rate := mp.evictionFeeRate(txD, nil); entry.rate != rate {
+				t.Fatalf("transaction %v is indexed with fee rate "+
+					"%v, want %v", hash, entry.rate, rate)
+			}
+		}
+		if mp.evictionIndex.entries[0].hash != *lowest.Hash() {
+			t.Fatalf("lowest transaction is %v, want %v",
+				mp.evictionIndex.entries[0].hash, lowest.Hash())
+		}
+	}
+
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0)}, 1, 10000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0)}, 1, 100000, false, false)
+	other := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 1)}, 1, 30000, false, false)
+	checkIndex(other)
+
+	// Prioritising a transaction ranks it again.
+	harness.txPool.PrioritiseTransaction(other.Hash(), 100000)
+	checkIndex(parent)
+
+	// Removing the child leaves the parent to be ranked on its own, and
+	// the parent is then the next to be evicted.
+	harness.txPool.PrioritiseTransaction(other.Hash(), -100000)
+	harness.txPool.RemoveTransaction(child, false, RemovalConflict)
+	checkIndex(parent)
+
+	harness.txPool.mtx.Lock()
+	harness.txPool.cfg.Policy.MaxPoolSize = GetTxVirtualSize(other)
+	harness.txPool.trimToSize(time.Now())
+	harness.txPool.mtx.Unlock()
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, other, false, true)
+	checkIndex(other)
+}
+
+// TestChainLimits ensures transactions which would make a chain of unconfirmed
+// transactions longer or larger than the policy allows are rejected with the
+// reason for the limit.
+func TestChainLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	policy := &harness.txPool.cfg.Policy
+	checkRejected := func(inputs []spendableOutput, numOutputs uint32,
+		code *er.ErrorCode) {
+
+		t.Helper()
+		tx, err := harness.CreateSignedTx(inputs, numOutputs, 1000000,
+			false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if !code.Is(err) {
+			t.Fatalf("got %v, want %v", err, code)
+		}
+		rejectCode, _, _ := ruleerror.ExtractRejectCode(err)
+		if rejectCode != wire.RejectNonstandard {
+			t.Fatalf("got reject code %v", rejectCode)
+		}
+		testPoolMembership(ctx, tx, false, false)
+	}
+	spend := func(tx *btcutil.Tx, i uint32) []spendableOutput {
+		return []spendableOutput{txOutToSpendableOut(tx, i)}
+	}
+
+	// A chain of three transactions, counting the last one, is allowed
+	// but not a fourth.
+	policy.MaxAncestors = 3
+	coinbase := ctx.addCoinbaseTx(1)
+	tx := ctx.addSignedTx(spend(coinbase, 0), 1, 1000000, false, false)
+	size := GetTxVirtualSize(tx)
+	for i := 0; i < 2; i++ {
+		tx = ctx.addSignedTx(spend(tx, 0), 1, 1000000, false, false)
+	}
+	checkRejected(spend(tx, 0), 1, ruleerror.ErrTooManyAncestors)
+	policy.MaxAncestors = 0
+
+	// A transaction with two ancestors is too large when the limit is
+	// under the size of three transactions.
+	policy.MaxAncestorSize = size*3 - 10
+	checkRejected(spend(tx, 0), 1, ruleerror.ErrAncestorsTooLarge)
+	policy.MaxAncestorSize = 0
+
+	// A transaction may have two children, counting itself, but not three.
+	policy.MaxDescendants = 3
+	coinbase = ctx.addCoinbaseTx(1)
+	parent := ctx.addSignedTx(spend(coinbase, 0), 3, 1000000, false, false)
+	ctx.addSignedTx(spend(parent, 0), 1, 1000000, false, false)
+	ctx.addSignedTx(spend(parent, 1), 1, 1000000, false, false)
+	checkRejected(spend(parent, 2), 1, ruleerror.ErrTooManyDescendants)
+	policy.MaxDescendants = 0
+
+	policy.MaxDescendantSize = GetTxVirtualSize(parent) + size*2
+	checkRejected(spend(parent, 2), 1, ruleerror.ErrDescendantsTooLarge)
+}
+
+// TestExpiry ensures transactions which have been in the pool for longer than
+// the maximum age are evicted along with their descendants.
+func TestExpiry(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	var expired []chainhash.Hash
+	harness.txPool.cfg.Notify = func(ev *Event) {
+		if ev.Removed && ev.Reason == RemovalExpiry {
+			expired = append(expired, *ev.Tx.Hash())
+		}
+	}
+
+	coinbase := ctx.addCoinbaseTx(3)
+	old := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 0)},
+		1, 1000000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(old, 0)},
+		1, 1000000, false, false)
+	recent := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 1)},
+		1, 1000000, false, false)
+	harness.txPool.mtx.Lock()
+	harness.txPool.pool[*old.Hash()].Added = time.Now().Add(-2 * time.Hour)
+	harness.txPool.mtx.Unlock()
+
+	// The pool is scanned when the next transaction is processed.
+	harness.txPool.cfg.Policy.MaxTxAge = time.Hour
+	ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 2)},
+		1, 1000000, false, false)
+	if len(expired) != 2 {
+		t.Fatalf("expired %d transactions, want 2", len(expired))
+	}
+	testPoolMembership(ctx, old, false, false)
+	testPoolMembership(ctx, child, false, false)
+	testPoolMembership(ctx, recent, false, true)
+}
+
+// TestPrioritiseTransaction ensures fee deltas apply to transactions before
+// they are added to the pool, adjust the fee they are ranked by without
+// changing the fee they pay, and are forgotten once they are mined.
+func TestPrioritiseTransaction(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	// Raise the minimum fee to 1000 satoshi per byte so the transaction is
+	// rejected without a fee delta.
+	harness.txPool.mtx.Lock()
+	harness.txPool.rollingMinFee = 1000000
+	harness.txPool.lastRollingMinFee = time.Now()
+	harness.txPool.mtx.Unlock()
+
+	coinbase := ctx.addCoinbaseTx(1)
+	tx, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0)}, 1, 20000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(tx, true, false, 0)
+	code, _, found := ruleerror.ExtractRejectCode(err)
+	if !found || code != wire.RejectInsufficientFee {
+		t.Fatalf("transaction without fee delta: got %v", err)
+	}
+
+	// Deltas add up and are applied when the transaction is accepted.
+	harness.txPool.PrioritiseTransaction(tx.Hash(), 200000)
+	harness.txPool.PrioritiseTransaction(tx.Hash(), 300000)
+	_, err = harness.txPool.ProcessTransaction(tx, true, false, 0)
+	if err != nil {
+		t.Fatalf("transaction with fee delta: %v", err)
+	}
+	desc, err := harness.txPool.FetchTxDesc(tx.Hash())
+	if err != nil {
+		t.Fatalf("FetchTxDesc: %v", err)
+	}
+	if desc.Fee != 20000 || desc.FeeDelta != 500000 {
+		t.Fatalf("fee %d, fee delta %d, want 20000 and 500000",
+			desc.Fee, desc.FeeDelta)
+	}
+	if desc.FeePerKB != 20000*1000/GetTxVirtualSize(tx) {
+		t.Fatalf("fee per kB %d does not match the fee paid",
+			desc.FeePerKB)
+	}
+	verbose := harness.txPool.RawMempoolVerbose()[tx.Hash().String()]
+	if verbose.ModifiedFee != 0.0052 || verbose.FeeDelta != 0.005 {
+		t.Fatalf("verbose modified fee %v, fee delta %v",
+			verbose.ModifiedFee, verbose.FeeDelta)
+	}
+
+	// A delta cancelling the previous ones is forgotten, and descriptors
+	// handed out before are left untouched.
+	harness.txPool.PrioritiseTransaction(tx.Hash(), -500000)
+	if desc.FeeDelta != 500000 {
+		t.Fatalf("descriptor fetched before was changed")
+	}
+	desc, err = harness.txPool.FetchTxDesc(tx.Hash())
+	if err != nil {
+		t.Fatalf("FetchTxDesc: %v", err)
+	}
+	if desc.FeeDelta != 0 || len(harness.txPool.feeDeltas) != 0 {
+		t.Fatalf("fee delta %d after cancelling, %d deltas",
+			desc.FeeDelta, len(harness.txPool.feeDeltas))
+	}
+
+	// Deltas are forgotten once the transaction is mined, but not when it
+	// is removed for another reason.
+	harness.txPool.PrioritiseTransaction(tx.Hash(), 1000)
+	harness.txPool.RemoveTransaction(tx, false, RemovalEviction)
+	if len(harness.txPool.feeDeltas) != 1 {
+		t.Fatalf("fee delta forgotten after eviction")
+	}
+	harness.txPool.RemoveTransaction(tx, false, RemovalConfirmed)
+	if len(harness.txPool.feeDeltas) != 0 {
+		t.Fatalf("fee delta kept after the transaction was mined")
+	}
+}
@@ -0,0 +1,415 @@
+package mempool
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/blockchain"
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/mining"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+	"github.com/pkt-cash/PKT-FullNode/wire"
+)
+
+// MaxPackageCount is the maximum number of transactions which can be checked
+// or submitted together as a package.
+const MaxPackageCount = 25
+
+// txPackage holds transactions which are checked together before any of them
+// is in the pool, so the ones which spend the outputs of others can be checked
+// as well.  The chain limits of a transaction count the transactions of the
+// package along with the ones in the pool.
+type txPackage struct {
+	txns      map[chainhash.Hash]*btcutil.Tx
+	outpoints map[wire.OutPoint]*btcutil.Tx
+
+	// deferFees is set when the fees of the transactions are checked for
+	// the package as a whole rather than for each transaction, so a child
+	// can pay for parents which don't pay enough on their own.
+	deferFees bool
+}
+
+// newTxPackage returns an empty package.
+func newTxPackage() *txPackage {
+	return &txPackage{
+		txns:      make(map[chainhash.Hash]*btcutil.Tx),
+		outpoints: make(map[wire.OutPoint]*btcutil.Tx),
+	}
+}
+
+// add adds a transaction which passed the checks to the package.
+func (p *txPackage) add(tx *btcutil.Tx) {
+	p.txns[*tx.Hash()] = tx
+	for _, txIn := range tx.MsgTx().TxIn {
+		p.outpoints[txIn.PreviousOutPoint] = tx
+	}
+}
+
+// checkDoubleSpend returns an error if the passed transaction is already in the
+// package or spends an output which is spent by a transaction of the package.
+func (p *txPackage) checkDoubleSpend(tx *btcutil.Tx) er.R {
+	if _, ok := p.txns[*tx.Hash()]; ok {
+		str := fmt.Sprintf("transaction %v is already in the package",
+			tx.Hash())
+		return txRuleError(wire.RejectDuplicate, str)
+	}
+	for _, txIn := range tx.MsgTx().TxIn {
+		if conflict, ok := p.outpoints[txIn.PreviousOutPoint]; ok {
+			str := fmt.Sprintf("output %v already spent by "+
+				"transaction %v in the package",
+				txIn.PreviousOutPoint, conflict.Hash())
+			return txRuleError(wire.RejectDuplicate, str)
+		}
+	}
+	return nil
+}
+
+// fetchInputUtxos adds the outputs of the transactions of the package which are
+// spent by the passed transaction to its utxo view.
+func (p *txPackage) fetchInputUtxos(tx *btcutil.Tx,
+	utxoView *blockchain.UtxoViewpoint) {
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		prevOut := &txIn.PreviousOutPoint
+		entry := utxoView.LookupEntry(*prevOut)
+		if entry != nil && !entry.IsSpent() {
+			continue
+		}
+
+		if parent, ok := p.txns[prevOut.Hash]; ok {
+			// AddTxOut ignores out of range index values, so it is
+			// safe to call without bounds checking here.
+			utxoView.AddTxOut(parent, prevOut.Index,
+				mining.UnminedHeight)
+		}
+	}
+}
+
+// txPackageAncestors returns all of the unconfirmed ancestors of the given
+// transaction, which are either in the pool or in the package.  The package and
+// the cache are optional, and the cache works like the one of txAncestors.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txPackageAncestors(tx *btcutil.Tx, pkg *txPackage,
+	cache map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx) map[chainhash.Hash]*btcutil.Tx {
+
+	if pkg == nil {
+		return mp.txAncestors(tx, cache)
+	}
+	if cache == nil {
+		cache = make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	}
+
+	ancestors := make(map[chainhash.Hash]*btcutil.Tx)
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		parent, ok := pkg.txns[hash]
+		if txD, inPool := mp.pool[hash]; inPool {
+			parent, ok = txD.Tx, true
+		}
+		if !ok {
+			continue
+		}
+		ancestors[hash] = parent
+
+		moreAncestors, ok := cache[hash]
+		if !ok {
+			moreAncestors = mp.txPackageAncestors(parent, pkg, cache)
+			cache[hash] = moreAncestors
+		}
+		for ancestorHash, ancestor := range moreAncestors {
+			ancestors[ancestorHash] = ancestor
+		}
+	}
+	return ancestors
+}
+
+// txPackageDescendants returns all of the unconfirmed descendants of the given
+// transaction, which are either in the pool or in the package.  The package and
+// the cache are optional, and the cache works like the one of txDescendants.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txPackageDescendants(tx *btcutil.Tx, pkg *txPackage,
+	cache map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx) map[chainhash.Hash]*btcutil.Tx {
+
+	if pkg == nil {
+		return mp.txDescendants(tx, cache)
+	}
+	if cache == nil {
+		cache = make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	}
+
+	// An output may be spent both in the pool and in the package when a
+	// transaction of the package replaces one in the pool.
+	descendants := make(map[chainhash.Hash]*btcutil.Tx)
+	op := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		op.Index = uint32(i)
+		for _, spenders := range []map[wire.OutPoint]*btcutil.Tx{
+			mp.outpoints, pkg.outpoints,
+		} {
+			descendant, ok := spenders[op]
+			if !ok {
+				continue
+			}
+			descendants[*descendant.Hash()] = descendant
+
+			moreDescendants, ok := cache[*descendant.Hash()]
+			if !ok {
+				moreDescendants = mp.txPackageDescendants(
+					descendant, pkg, cache)
+				cache[*descendant.Hash()] = moreDescendants
+			}
+			for hash, moreDescendant := range moreDescendants {
+				descendants[hash] = moreDescendant
+			}
+		}
+	}
+	return descendants
+}
+
+// TestAcceptResult is the result of checking whether a transaction would be
+// accepted into the pool.
+type TestAcceptResult struct {
+	Tx *btcutil.Tx
+
+	// Fee and Size are the fee and virtual size of the transaction.  They
+	// are only set when the transaction would be accepted.
+	Fee  int64
+	Size int64
+
+	// Err is the reason why the transaction would not be accepted, it is
+	// nil when the transaction would be.
+	Err er.R
+}
+
+// TestAccept checks whether the passed transactions would be accepted into the
+// main pool in the given order, each one being able to spend the outputs of the
+// ones before it which would be accepted.  The chain limits count those earlier
+// transactions as if they were in the pool.  Nothing is added to the pool and
+// the free transaction rate limiter is not applied.  Transactions paying a fee rate
+// above maxFeeRate, in satoshi/kB, are rejected so that they are not spent by
+// the ones after them either.  A maxFeeRate of zero allows any fee rate.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TestAccept(txns []*btcutil.Tx, maxFeeRate btcutil.Amount) []TestAcceptResult {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	pkg := newTxPackage()
+	results := make([]TestAcceptResult, len(txns))
+	for i, tx := range txns {
+		results[i].Tx = tx
+		missingParents, check, err := mp.checkTransaction(tx, true,
+			false, true, pkg)
+		if err == nil && len(missingParents) > 0 {
+			err = errMissingParents(tx, missingParents)
+		}
+		if err == nil && maxFeeRate > 0 &&
+			check.fee*1000/check.size > int64(maxFeeRate) {
+
+			err = txRuleError(wire.RejectNonstandard,
+				"max-fee-exceeded")
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		results[i].Fee = check.fee
+		results[i].Size = check.size
+		pkg.add(tx)
+	}
+	return results
+}
+
+// checkPackageTopology returns an error unless the passed transactions are a
+// child preceded by ancestors of it, each of them after the ones it spends.
+func checkPackageTopology(txns []*btcutil.Tx) er.R {
+	if len(txns) == 0 || len(txns) > MaxPackageCount {
+		str := fmt.Sprintf("package must have between 1 and %d "+
+			"transactions", MaxPackageCount)
+		return txRuleError(wire.RejectInvalid, str)
+	}
+
+	index := make(map[chainhash.Hash]int, len(txns))
+	for i, tx := range txns {
+		if _, ok := index[*tx.Hash()]; ok {
+			str := fmt.Sprintf("transaction %v is in the package "+
+				"twice", tx.Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+		index[*tx.Hash()] = i
+	}
+
+	// Walk the package back from the child, which must reach every other
+	// transaction, and only through transactions coming earlier.
+	reached := make([]bool, len(txns))
+	reached[len(txns)-1] = true
+	for i := len(txns) - 1; i >= 0; i-- {
+		if !reached[i] {
+			str := fmt.Sprintf("transaction %v is not an ancestor "+
+				"of the last transaction of the package",
+				txns[i].Hash())
+			return txRuleError(wire.RejectInvalid, str)
+		}
+		for _, txIn := range txns[i].MsgTx().TxIn {
+			j, ok := index[txIn.PreviousOutPoint.Hash]
+			if !ok {
+				continue
+			}
+			if j > i {
+				str := fmt.Sprintf("transaction %v comes before "+
+					"its parent %v in the package",
+					txns[i].Hash(), txns[j].Hash())
+				return txRuleError(wire.RejectInvalid, str)
+			}
+			reached[j] = true
+		}
+	}
+	return nil
+}
+
+// checkPackageFee returns an error if the checked transactions of a package
+// pay less than the minimum fee for their total size.  The fees are adjusted by
+// the fee deltas of the transactions.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) checkPackageFee(checks []*txCheck) er.R {
+	var fee, size int64
+	for _, check := range checks {
+		fee += check.fee + mp.feeDeltas[*check.tx.Hash()]
+		size += check.size
+	}
+
+	minFee := calcMinRequiredTxRelayFee(size, mp.cfg.Policy.MinRelayTxFee)
+	rollingFee := btcutil.Amount(math.Ceil(mp.rollingFee(time.Now())))
+	if rollingFee > mp.cfg.Policy.MinRelayTxFee {
+		minFee = calcMinRequiredTxRelayFee(size, rollingFee)
+	}
+	if fee < minFee {
+		str := fmt.Sprintf("package of %d transactions has %d fees "+
+			"which is under the required amount of %d", len(checks),
+			fee, minFee)
+		return txRuleError(wire.RejectInsufficientFee, str)
+	}
+	return nil
+}
+
+// ProcessPackage processes a package of transactions made of a child preceded
+// by its ancestors which are not in the pool yet.  The transactions which can
+// be accepted on their own are accepted first, the others are then accepted
+// together if they pay the minimum fee for their total size, which allows the
+// child to pay for parents which don't pay enough on their own.  Transactions
+// of the package which are already in the pool are skipped, and the ones which
+// spend outputs of unknown transactions are added to the orphan pool.
+//
+// It returns the transactions added to the main pool, including orphans which
+// were accepted as a result.  Transactions may have been added even when an
+// error is returned.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) ProcessPackage(txns []*btcutil.Tx, tag Tag) ([]*TxDesc, er.R) {
+	if err := checkPackageTopology(txns); err != nil {
+		return nil, err
+	}
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	// Accept the transactions which don't depend on others of the package
+	// which are pending, and don't need the child to pay for them.
+	var accepted []*TxDesc
+	var pending []*btcutil.Tx
+	pendingHashes := make(map[chainhash.Hash]struct{})
+	for _, tx := range txns {
+		if mp.isTransactionInPool(tx.Hash()) {
+			continue
+		}
+
+		dependsOnPending := false
+		for _, txIn := range tx.MsgTx().TxIn {
+			hash := txIn.PreviousOutPoint.Hash
+			if _, ok := pendingHashes[hash]; ok {
+				dependsOnPending = true
+				break
+			}
+		}
+		if !dependsOnPending {
+			missingParents, txD, err := mp.maybeAcceptTransaction(tx,
+				true, false, false)
+			if err == nil && len(missingParents) == 0 {
+				mp.removeOrphan(tx, false)
+				accepted = append(accepted, txD)
+				continue
+			}
+			if err == nil {
+				if err := mp.maybeAddOrphan(tx, tag); err != nil {
+					return accepted, err
+				}
+				continue
+			}
+		}
+		pending = append(pending, tx)
+		pendingHashes[*tx.Hash()] = struct{}{}
+	}
+
+	// Check the remaining transactions together, which are added only if
+	// all of them are valid and they pay enough fees as a whole.
+	pkg := newTxPackage()
+	pkg.deferFees = true
+	checks := make([]*txCheck, 0, len(pending))
+	for _, tx := range pending {
+		missingParents, check, err := mp.checkTransaction(tx, true,
+			false, false, pkg)
+		if err != nil {
+			return accepted, err
+		}
+		if len(missingParents) > 0 {
+			if err := mp.maybeAddOrphan(tx, tag); err != nil {
+				return accepted, err
+			}
+			continue
+		}
+		pkg.add(tx)
+		checks = append(checks, check)
+	}
+	if len(checks) > 0 {
+		if err := mp.checkPackageFee(checks); err != nil {
+			return accepted, err
+		}
+	}
+	for _, check := range checks {
+		mp.removeOrphan(check.tx, false)
+		accepted = append(accepted, mp.addCheckedTransaction(check))
+	}
+
+	// Keep the pool within its size limit, which may evict transactions of
+	// the package if they pay the lowest fee rate.
+	mp.trimToSize(time.Now())
+	kept := make([]*TxDesc, 0, len(accepted))
+	for _, txD := range accepted {
+		if _, ok := mp.pool[*txD.Tx.Hash()]; ok {
+			kept = append(kept, txD)
+		}
+	}
+	if len(kept) < len(accepted) {
+		str := fmt.Sprintf("%d transactions of the package were "+
+			"evicted as the memory pool is full",
+			len(accepted)-len(kept))
+		return kept, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	// Accept the orphans which depend on the added transactions.
+	for _, txD := range kept {
+		accepted = append(accepted, mp.processOrphans(txD.Tx)...)
+	}
+
+	log.Debugf("Accepted package of %d transactions (pool size: %v)",
+		len(txns), len(mp.pool))
+
+	return accepted, nil
+}
@@ -0,0 +1,309 @@
+package mempool
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg"
+	"github.com/pkt-cash/PKT-FullNode/wire/ruleerror"
+)
+
+// TestTestAccept ensures transactions are checked along with the ones before
+// them without being added to the pool.
+func TestTestAccept(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	coinbase := ctx.addCoinbaseTx(2)
+	newTx := func(input spendableOutput) *btcutil.Tx {
+		tx, err := harness.CreateSignedTx([]spendableOutput{input}, 1,
+			100000, false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	parent := newTx(txOutToSpendableOut(coinbase, 0))
+	child := newTx(txOutToSpendableOut(parent, 0))
+	doubleSpend := newTx(txOutToSpendableOut(coinbase, 0))
+
+	// The child is accepted along with its parent, but it is an orphan on
+	// its own, and the package can't spend an output twice.
+	results := harness.txPool.TestAccept([]*btcutil.Tx{parent, child,
+		doubleSpend}, 0)
+	if results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("package rejected: %v, %v", results[0].Err,
+			results[1].Err)
+	}
+	if results[1].Fee != 100000 || results[1].Size != GetTxVirtualSize(child) {
+		t.Fatalf("child has fee %d and size %d, want %d and %d",
+			results[1].Fee, results[1].Size, 100000,
+			GetTxVirtualSize(child))
+	}
+	if results[2].Err == nil {
+		t.Fatalf("double spend in the package accepted")
+	}
+	results = harness.txPool.TestAccept([]*btcutil.Tx{child}, 0)
+	if !ruleerror.ErrOrphanTransactionDisallowed.Is(results[0].Err) {
+		t.Fatalf("orphan result: %v", results[0].Err)
+	}
+
+	// A parent paying more than the max fee rate is rejected, and so is
+	// its child which can't spend it.
+	results = harness.txPool.TestAccept([]*btcutil.Tx{parent, child}, 1000)
+	if !ruleerror.ErrRejectNonstandard.Is(results[0].Err) ||
+		!strings.Contains(results[0].Err.Message(), "max-fee-exceeded") {
+
+		t.Fatalf("parent above the max fee rate: %v", results[0].Err)
+	}
+	if !ruleerror.ErrOrphanTransactionDisallowed.Is(results[1].Err) {
+		t.Fatalf("child of a parent above the max fee rate: %v",
+			results[1].Err)
+	}
+
+	// The chain limits of the child count its parent although it is not
+	// in the pool.
+	policy := &harness.txPool.cfg.Policy
+	policy.MaxAncestors = 1
+	results = harness.txPool.TestAccept([]*btcutil.Tx{parent, child}, 0)
+	if results[0].Err != nil ||
+		!ruleerror.ErrTooManyAncestors.Is(results[1].Err) {
+
+		t.Fatalf("package over the ancestor limit: %v, %v",
+			results[0].Err, results[1].Err)
+	}
+	policy.MaxAncestors = 0
+
+	// Nothing was added to the pool.
+	if harness.txPool.Count() != 0 || harness.txPool.OrphanCount() != 0 {
+		t.Fatalf("pool has %d transactions and %d orphans",
+			harness.txPool.Count(), harness.txPool.OrphanCount())
+	}
+
+	// Once the parent is in the pool, it is a duplicate and the child is
+	// accepted on its own.
+	ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 0)}, 1,
+		100000, false, false)
+	results = harness.txPool.TestAccept([]*btcutil.Tx{parent, child}, 0)
+	if !ruleerror.ErrTxExistsInMempool.Is(results[0].Err) ||
+		results[1].Err != nil {
+
+		t.Fatalf("results with the parent in the pool: %v, %v",
+			results[0].Err, results[1].Err)
+	}
+}
+
+// TestProcessPackage ensures a child can pay for a parent which does not pay
+// the minimum fee on its own, and that packages whose parents are unknown are
+// added to the orphan pool.
+func TestProcessPackage(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	coinbase := ctx.addCoinbaseTx(3)
+	newTx := func(input spendableOutput, fee btcutil.Amount) *btcutil.Tx {
+		tx, err := harness.CreateSignedTx([]spendableOutput{input}, 1,
+			fee, false)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+
+	// Raise the minimum fee to 50 satoshi per byte so a parent paying no
+	// fee is rejected on its own.
+	harness.txPool.mtx.Lock()
+	harness.txPool.rollingMinFee = 50000
+	harness.txPool.lastRollingMinFee = time.Now()
+	harness.txPool.mtx.Unlock()
+
+	parent := newTx(txOutToSpendableOut(coinbase, 0), 0)
+	child := newTx(txOutToSpendableOut(parent, 0), 100000)
+	_, err = harness.txPool.ProcessTransaction(parent, false, false, 0)
+	if err == nil {
+		t.Fatalf("parent accepted without fee")
+	}
+
+	// The package must be the child preceded by its ancestors.
+	other := newTx(txOutToSpendableOut(coinbase, 1), 100000)
+	for _, txns := range [][]*btcutil.Tx{
+		{child, parent}, {other, parent, child}, {parent, child, child},
+	} {
+		if _, err := harness.txPool.ProcessPackage(txns, 0); err == nil {
+			t.Fatalf("invalid package accepted")
+		}
+	}
+
+	// A child which does not pay enough for both is rejected.
+	lowChild := newTx(txOutToSpendableOut(parent, 0), 1000)
+	_, err = harness.txPool.ProcessPackage([]*btcutil.Tx{parent, lowChild}, 0)
+	if err == nil {
+		t.Fatalf("package paying too little accepted")
+	}
+	testPoolMembership(ctx, parent, false, false)
+
+	accepted, err := harness.txPool.ProcessPackage([]*btcutil.Tx{parent,
+		child}, 0)
+	if err != nil || len(accepted) != 2 {
+		t.Fatalf("ProcessPackage: accepted %d, err %v", len(accepted), err)
+	}
+	testPoolMembership(ctx, parent, false, true)
+	testPoolMembership(ctx, child, false, true)
+
+	// Submitting it again skips the transactions already in the pool.
+	accepted, err = harness.txPool.ProcessPackage([]*btcutil.Tx{parent,
+		child}, 0)
+	if err != nil || len(accepted) != 0 {
+		t.Fatalf("second ProcessPackage: accepted %d, err %v",
+			len(accepted), err)
+	}
+
+	// A package whose parent is unknown is kept in the orphan pool and
+	// accepted along with the parent.
+	unknown := newTx(txOutToSpendableOut(coinbase, 2), 100000)
+	orphan := newTx(txOutToSpendableOut(unknown, 0), 100000)
+	orphanChild := newTx(txOutToSpendableOut(orphan, 0), 100000)
+	accepted, err = harness.txPool.ProcessPackage([]*btcutil.Tx{orphan,
+		orphanChild}, 0)
+	if err != nil || len(accepted) != 0 {
+		t.Fatalf("orphan ProcessPackage: accepted %d, err %v",
+			len(accepted), err)
+	}
+	testPoolMembership(ctx, orphan, true, false)
+	testPoolMembership(ctx, orphanChild, true, false)
+
+	accepted, err = harness.txPool.ProcessTransaction(unknown, false, false, 0)
+	if err != nil || len(accepted) != 3 {
+		t.Fatalf("ProcessTransaction: accepted %d, err %v", len(accepted),
+			err)
+	}
+	testPoolMembership(ctx, orphanChild, false, true)
+}
+
+// TestProcessPackageReplacement ensures transactions of a package whose fees
+// are checked as a whole can't replace transactions in the pool, even when the
+// conflicts of several of them overlap.
+func TestProcessPackageReplacement(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	// The replaced transaction has a descendant which also spends another
+	// output the child of the package spends.
+	coinbase := ctx.addCoinbaseTx(2)
+	replaced := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0)}, 1, 10000, true, false)
+	descendant := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(replaced, 0),
+		txOutToSpendableOut(coinbase, 1)}, 1, 10000, true, false)
+
+	// Raise the minimum fee to 1000 satoshi per byte so the parent is
+	// rejected on its own although it pays enough to replace.
+	harness.txPool.mtx.Lock()
+	harness.txPool.rollingMinFee = 1000000
+	harness.txPool.lastRollingMinFee = time.Now()
+	harness.txPool.mtx.Unlock()
+
+	parent, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0)}, 1, 50000, true)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0),
+		txOutToSpendableOut(coinbase, 1)}, 1, 1000000, true)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+
+	_, err = harness.txPool.ProcessPackage([]*btcutil.Tx{parent, child}, 0)
+	if !ruleerror.ErrRejectNonstandard.Is(err) {
+		t.Fatalf("package replacing pool transactions: %v", err)
+	}
+	testPoolMembership(ctx, replaced, false, true)
+	testPoolMembership(ctx, descendant, false, true)
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+}
+
+// TestProcessPackageChainLimits ensures the chain limits of the transactions of
+// a package count the other transactions of the package along with the ones in
+// the pool.
+func TestProcessPackageChainLimits(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+	policy := &harness.txPool.cfg.Policy
+
+	coinbase := ctx.addCoinbaseTx(1)
+	root := ctx.addSignedTx([]spendableOutput{
+		txOutToSpendableOut(coinbase, 0)}, 1, 100000, false, false)
+
+	// Raise the minimum fee to 50 satoshi per byte so the parent paying no
+	// fee is checked along with its child.
+	harness.txPool.mtx.Lock()
+	harness.txPool.rollingMinFee = 50000
+	harness.txPool.lastRollingMinFee = time.Now()
+	harness.txPool.mtx.Unlock()
+
+	parent, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(root, 0)}, 1, 0, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	child, err := harness.CreateSignedTx([]spendableOutput{
+		txOutToSpendableOut(parent, 0)}, 1, 100000, false)
+	if err != nil {
+		t.Fatalf("unable to create transaction: %v", err)
+	}
+	txns := []*btcutil.Tx{parent, child}
+
+	// The child has two ancestors, only one of which is in the pool, and
+	// the transaction in the pool gets two descendants.
+	harness.txPool.mtx.Lock()
+	policy.MaxAncestors = 2
+	harness.txPool.mtx.Unlock()
+	_, err = harness.txPool.ProcessPackage(txns, 0)
+	if !ruleerror.ErrTooManyAncestors.Is(err) {
+		t.Fatalf("package over the ancestor limit: %v", err)
+	}
+
+	harness.txPool.mtx.Lock()
+	policy.MaxAncestors = 0
+	policy.MaxDescendants = 2
+	harness.txPool.mtx.Unlock()
+	_, err = harness.txPool.ProcessPackage(txns, 0)
+	if !ruleerror.ErrTooManyDescendants.Is(err) {
+		t.Fatalf("package over the descendant limit: %v", err)
+	}
+	testPoolMembership(ctx, parent, false, false)
+	testPoolMembership(ctx, child, false, false)
+
+	harness.txPool.mtx.Lock()
+	policy.MaxDescendants = 3
+	harness.txPool.mtx.Unlock()
+	accepted, err := harness.txPool.ProcessPackage(txns, 0)
+	if err != nil || len(accepted) != 2 {
+		t.Fatalf("ProcessPackage: accepted %d, err %v", len(accepted), err)
+	}
+}
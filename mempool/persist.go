@@ -0,0 +1,181 @@
+package mempool
+
+import (
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+	"github.com/pkt-cash/PKT-FullNode/wire"
+)
+
+// mempoolSaveVersion is the version of the format written by Save.  Files of
+// other versions are not loaded.
+const mempoolSaveVersion = 1
+
+// savedTx is a transaction of the main pool as it is saved, along with the
+// time it was accepted and its fee delta.  The fee delta is the amount by
+// which the fee of the transaction is adjusted when ranking it, it is zero
+// for transactions which were not prioritised.
+type savedTx struct {
+	tx       *btcutil.Tx
+	added    time.Time
+	feeDelta int64
+}
+
+// Save writes the transactions of the main pool to w, parents before their
+// children, and returns the number of transactions written.  The orphan pool
+// is not saved.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Save(w io.Writer) (int, er.R) {
+	mp.mtx.RLock()
+	cache := make(map[chainhash.Hash]map[chainhash.Hash]*btcutil.Tx)
+	numAncestors := make(map[*TxDesc]int, len(mp.pool))
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		numAncestors[desc] = len(mp.txAncestors(desc.Tx, cache))
+		descs = append(descs, desc)
+	}
+	mp.mtx.RUnlock()
+
+	// A transaction has more ancestors in the pool than any of them, so
+	// this order loads every parent before its children.
+	sort.Slice(descs, func(i, j int) bool {
+		if numAncestors[descs[i]] != numAncestors[descs[j]] {
+			return numAncestors[descs[i]] < numAncestors[descs[j]]
+		}
+		return descs[i].Added.Before(descs[j].Added)
+	})
+
+	header := []uint32{mempoolSaveVersion, uint32(len(descs))}
+	if errr := binary.Write(w, binary.BigEndian, header); errr != nil {
+		return 0, er.E(errr)
+	}
+	for _, desc := range descs {
+		st := savedTx{
+			tx:       desc.Tx,
+			added:    desc.Added,
+			feeDelta: desc.FeeDelta,
+		}
+		if err := st.serialize(w); err != nil {
+			return 0, err
+		}
+	}
+	return len(descs), nil
+}
+
+// serialize writes the saved transaction to w.
+func (st *savedTx) serialize(w io.Writer) er.R {
+	times := []int64{st.added.Unix(), st.feeDelta}
+	if errr := binary.Write(w, binary.BigEndian, times); errr != nil {
+		return er.E(errr)
+	}
+	return st.tx.MsgTx().Serialize(w)
+}
+
+// deserializeSavedTx reads a saved transaction from r.
+func deserializeSavedTx(r io.Reader) (*savedTx, er.R) {
+	var times [2]int64
+	if errr := binary.Read(r, binary.BigEndian, &times); errr != nil {
+		return nil, er.E(errr)
+	}
+	var msgTx wire.MsgTx
+	if err := msgTx.Deserialize(r); err != nil {
+		return nil, err
+	}
+	return &savedTx{
+		tx:       btcutil.NewTx(&msgTx),
+		added:    time.Unix(times[0], 0),
+		feeDelta: times[1],
+	}, nil
+}
+
+// Load reads transactions written by Save from r and processes each of them
+// as a new transaction, keeping the time it was first accepted.  Transactions
+// which are already in the pool are skipped.  It returns the number of
+// transactions which were accepted and the number which were not, for example
+// because they were mined or became invalid in the meantime.  Loading stops
+// early when quit is closed.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader, quit <-chan struct{}) (int, int, er.R) {
+	var header [2]uint32
+	if errr := binary.Read(r, binary.BigEndian, &header); errr != nil {
+		return 0, 0, er.E(errr)
+	}
+	if header[0] != mempoolSaveVersion {
+		return 0, 0, er.Errorf("Incorrect version: expected %d found %d",
+			mempoolSaveVersion, header[0])
+	}
+
+	var accepted, failed int
+	for i := uint32(0); i < header[1]; i++ {
+		select {
+		case <-quit:
+			return accepted, failed, nil
+		default:
+		}
+		st, err := deserializeSavedTx(r)
+		if err != nil {
+			return accepted, failed, err
+		}
+		if mp.loadTransaction(st) {
+			accepted++
+		} else {
+			failed++
+		}
+	}
+	return accepted, failed, nil
+}
+
+// loadTransaction processes a saved transaction and sets the time it was
+// accepted to when it was first accepted.  The saved fee delta applies unless
+// the transaction was prioritised again since.  It returns whether the
+// transaction was accepted.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) loadTransaction(st *savedTx) bool {
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	if mp.isTransactionInPool(st.tx.Hash()) {
+		return false
+	}
+	if maxAge := mp.cfg.Policy.MaxTxAge; maxAge > 0 &&
+		time.Since(st.added) > maxAge {
+
+		log.Debugf("Not loading transaction %v: it is older than %v",
+			st.tx.Hash(), maxAge)
+		return false
+	}
+	hash := *st.tx.Hash()
+	_, hasDelta := mp.feeDeltas[hash]
+	if st.feeDelta != 0 && !hasDelta {
+		mp.feeDeltas[hash] = st.feeDelta
+		defer func() {
+			if !mp.isTransactionInPool(&hash) {
+				delete(mp.feeDeltas, hash)
+			}
+		}()
+	}
+	missingParents, txD, err := mp.maybeAcceptTransaction(st.tx, true,
+		false, true)
+	if err != nil {
+		log.Debugf("Not loading transaction %v: %v", st.tx.Hash(), err)
+		return false
+	}
+	if len(missingParents) > 0 {
+		log.Debugf("Not loading transaction %v: it spends unknown "+
+			"outputs", st.tx.Hash())
+		return false
+	}
+	if st.added.Before(txD.Added) {
+		txD.Added = st.added
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg"
+)
+
+// TestSaveLoad ensures the transactions of the pool are saved with the times
+// they were accepted and their fee deltas, and are loaded back in an order
+// which accepts children after their parents.
+func TestSaveLoad(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	ctx := &testContext{t, harness}
+
+	coinbase := ctx.addCoinbaseTx(2)
+	parent := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 0)},
+		1, 100000, false, false)
+	child := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(parent, 0)},
+		1, 100000, false, false)
+	other := ctx.addSignedTx([]spendableOutput{txOutToSpendableOut(coinbase, 1)},
+		1, 100000, false, false)
+	txs := []*btcutil.Tx{parent, child, other}
+
+	// Make the child look older than its parent, so saving in the order of
+	// acceptance would load it first.
+	added := time.Now().Add(-time.Hour).Truncate(time.Second)
+	harness.txPool.mtx.Lock()
+	harness.txPool.pool[*child.Hash()].Added = added.Add(-time.Minute)
+	harness.txPool.pool[*parent.Hash()].Added = added
+	harness.txPool.pool[*other.Hash()].Added = added
+	harness.txPool.mtx.Unlock()
+
+	harness.txPool.PrioritiseTransaction(child.Hash(), 5000)
+
+	var buf bytes.Buffer
+	n, err := harness.txPool.Save(&buf)
+	if err != nil || n != 3 {
+		t.Fatalf("Save: saved %d, err %v", n, err)
+	}
+	saved := buf.Bytes()
+
+	for _, tx := range txs {
+		harness.txPool.RemoveTransaction(tx, false, RemovalConfirmed)
+	}
+	loaded, failed, err := harness.txPool.Load(bytes.NewReader(saved), nil)
+	if err != nil || loaded != 3 || failed != 0 {
+		t.Fatalf("Load: loaded %d, failed %d, err %v", loaded, failed, err)
+	}
+	for _, tx := range txs {
+		testPoolMembership(ctx, tx, false, true)
+	}
+	desc, err := harness.txPool.FetchTxDesc(child.Hash())
+	if err != nil {
+		t.Fatalf("FetchTxDesc: %v", err)
+	}
+	if !desc.Added.Equal(added.Add(-time.Minute)) {
+		t.Fatalf("child was accepted at %v, want %v", desc.Added,
+			added.Add(-time.Minute))
+	}
+	if desc.FeeDelta != 5000 {
+		t.Fatalf("child has fee delta %d, want 5000", desc.FeeDelta)
+	}
+
+	// Loading again skips the transactions already in the pool.
+	loaded, failed, err = harness.txPool.Load(bytes.NewReader(saved), nil)
+	if err != nil || loaded != 0 || failed != 3 {
+		t.Fatalf("second Load: loaded %d, failed %d, err %v", loaded,
+			failed, err)
+	}
+
+	// Loading stops when quit is closed.
+	quit := make(chan struct{})
+	close(quit)
+	loaded, failed, err = harness.txPool.Load(bytes.NewReader(saved), quit)
+	if err != nil || loaded != 0 || failed != 0 {
+		t.Fatalf("interrupted Load: loaded %d, failed %d, err %v",
+			loaded, failed, err)
+	}
+
+	// A file of another version is not loaded.
+	binary.BigEndian.PutUint32(saved, mempoolSaveVersion+1)
+	_, _, err = harness.txPool.Load(bytes.NewReader(saved), nil)
+	if err == nil {
+		t.Fatalf("Load of another version succeeded")
+	}
+}
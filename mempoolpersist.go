@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/mempool"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+)
+
+// mempoolPersist saves the transactions of the mempool to the mempool file
+// and loads them back, so they are not lost when the node restarts.
+type mempoolPersist struct {
+	// loaded is set atomically once the saved transactions have been
+	// loaded.  Until then the pool is not saved, as that would lose the
+	// transactions which have not been loaded yet.
+	loaded int32
+
+	// mtx serializes saving and loading.
+	mtx    sync.Mutex
+	txPool *mempool.TxPool
+	path   string
+}
+
+// newMempoolPersist returns a mempoolPersist saving the pool to the mempool
+// file in the data directory.
+func newMempoolPersist(txPool *mempool.TxPool, dataDir string) *mempoolPersist {
+	return &mempoolPersist{
+		txPool: txPool,
+		path:   filepath.Join(dataDir, mempoolFilename),
+	}
+}
+
+// isLoaded returns whether the saved transactions have been loaded.
+func (p *mempoolPersist) isLoaded() bool {
+	return atomic.LoadInt32(&p.loaded) != 0
+}
+
+// save writes the transactions of the mempool to the mempool file and returns
+// how many were written.  The file is replaced only once it has been written
+// completely.
+func (p *mempoolPersist) save() (int, er.R) {
+	if !p.isLoaded() {
+		return 0, er.New("the saved mempool has not been loaded yet")
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	tmpPath := p.path + ".new"
+	f, errr := os.Create(tmpPath)
+	if errr != nil {
+		return 0, er.E(errr)
+	}
+	w := bufio.NewWriter(f)
+	n, err := p.txPool.Save(w)
+	if err == nil {
+		err = er.E(w.Flush())
+	}
+	if errr := f.Close(); err == nil {
+		err = er.E(errr)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if errr := os.Rename(tmpPath, p.path); errr != nil {
+		return 0, er.E(errr)
+	}
+	log.Debugf("Saved %d mempool transactions to file '%s'", n, p.path)
+	return n, nil
+}
+
+// load processes the transactions saved in the mempool file and returns how
+// many were accepted and how many were not.  A missing file is not an error.
+// Loading stops early when quit is closed, in which case the pool is not
+// marked as loaded so the transactions which were not loaded yet are not
+// overwritten.
+func (p *mempoolPersist) load(quit <-chan struct{}) (int, int, er.R) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	f, errr := os.Open(p.path)
+	if os.IsNotExist(errr) {
+		atomic.StoreInt32(&p.loaded, 1)
+		return 0, 0, nil
+	} else if errr != nil {
+		atomic.StoreInt32(&p.loaded, 1)
+		return 0, 0, er.E(errr)
+	}
+	defer f.Close()
+
+	loaded, failed, err := p.txPool.Load(bufio.NewReader(f), quit)
+	select {
+	case <-quit:
+		return loaded, failed, err
+	default:
+	}
+	atomic.StoreInt32(&p.loaded, 1)
+	if err != nil {
+		return loaded, failed, err
+	}
+	log.Infof("Loaded %d mempool transactions from file '%s', %d "+
+		"could not be accepted", loaded, p.path, failed)
+	return loaded, failed, nil
+}
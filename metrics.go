@@ -0,0 +1,396 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/blockchain"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/connmgr/banmgr"
+	"github.com/pkt-cash/PKT-FullNode/database"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+)
+
+const (
+	// metricsPath is the HTTP path the metrics are served on.
+	metricsPath = "/metrics"
+
+	// metricsNamespace is prefixed to the name of every metric.
+	metricsNamespace = "pktd_"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histograms.
+var latencyBuckets = []float64{
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+}
+
+// histogram is a Prometheus style histogram of observed values.
+type histogram struct {
+	mtx    sync.Mutex
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// newHistogram returns an empty histogram with buckets with the given upper
+// bounds, which must be sorted.
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// observe adds a value to the histogram.
+func (h *histogram) observe(v float64) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+// observeDuration adds a duration to the histogram, in seconds.
+func (h *histogram) observeDuration(d time.Duration) {
+	h.observe(d.Seconds())
+}
+
+// histogramSnapshot is a copy of the state of a histogram, the bucket counts
+// are cumulative.
+type histogramSnapshot struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// snapshot returns a copy of the histogram with cumulative bucket counts.
+func (h *histogram) snapshot() histogramSnapshot {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	snap := histogramSnapshot{
+		bounds: h.bounds,
+		counts: make([]uint64, len(h.counts)),
+		count:  h.count,
+		sum:    h.sum,
+	}
+	var cumulative uint64
+	for i, c := range h.counts {
+		cumulative += c
+		snap.counts[i] = cumulative
+	}
+	return snap
+}
+
+// rpcMethodStats are the statistics of the calls to a single RPC method.
+type rpcMethodStats struct {
+	requests uint64
+	errors   uint64
+	latency  *histogram
+}
+
+// rpcCallStats accounts the calls to each RPC method.
+type rpcCallStats struct {
+	mtx     sync.Mutex
+	methods map[string]*rpcMethodStats
+}
+
+// newRPCCallStats returns an empty rpcCallStats.
+func newRPCCallStats() *rpcCallStats {
+	return &rpcCallStats{methods: make(map[string]*rpcMethodStats)}
+}
+
+// observe accounts a call to method which took elapsed and failed if failed
+// is set.
+func (s *rpcCallStats) observe(method string, elapsed time.Duration, failed bool) {
+	s.mtx.Lock()
+	stats, ok := s.methods[method]
+	if !ok {
+		stats = &rpcMethodStats{latency: newHistogram(latencyBuckets)}
+		s.methods[method] = stats
+	}
+	stats.requests++
+	if failed {
+		stats.errors++
+	}
+	s.mtx.Unlock()
+	stats.latency.observeDuration(elapsed)
+}
+
+// rpcMethodSnapshot is a copy of the statistics of a single RPC method.
+type rpcMethodSnapshot struct {
+	method   string
+	requests uint64
+	errors   uint64
+	latency  histogramSnapshot
+}
+
+// snapshot returns a copy of the statistics of every method, sorted by method
+// name.
+func (s *rpcCallStats) snapshot() []rpcMethodSnapshot {
+	s.mtx.Lock()
+	snaps := make([]rpcMethodSnapshot, 0, len(s.methods))
+	latencies := make([]*histogram, 0, len(s.methods))
+	for method, stats := range s.methods {
+		snaps = append(snaps, rpcMethodSnapshot{
+			method:   method,
+			requests: stats.requests,
+			errors:   stats.errors,
+		})
+		latencies = append(latencies, stats.latency)
+	}
+	s.mtx.Unlock()
+	for i := range snaps {
+		snaps[i].latency = latencies[i].snapshot()
+	}
+	sort.Slice(snaps, func(i, j int) bool {
+		return snaps[i].method < snaps[j].method
+	})
+	return snaps
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes the header of a metric family.
+func (w *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s%s %s\n", metricsNamespace, name, help)
+	fmt.Fprintf(&w.buf, "# TYPE %s%s %s\n", metricsNamespace, name, typ)
+}
+
+// sample writes a single sample, labels are given as name, value pairs.
+func (w *metricsWriter) sample(name string, value float64, labels ...string) {
+	w.buf.WriteString(metricsNamespace)
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i])
+			w.buf.WriteString(`="`)
+			w.buf.WriteString(escapeLabelValue(labels[i+1]))
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatMetricValue(value))
+	w.buf.WriteByte('\n')
+}
+
+// gauge writes a metric family with a single unlabelled gauge sample.
+func (w *metricsWriter) gauge(name, help string, value float64) {
+	w.family(name, "gauge", help)
+	w.sample(name, value)
+}
+
+// histogram writes the samples of a histogram.
+func (w *metricsWriter) histogram(name string, snap histogramSnapshot, labels ...string) {
+	for i, bound := range snap.bounds {
+		le := append(append([]string{}, labels...), "le", formatMetricValue(bound))
+		w.sample(name+"_bucket", float64(snap.counts[i]), le...)
+	}
+	le := append(append([]string{}, labels...), "le", "+Inf")
+	w.sample(name+"_bucket", float64(snap.count), le...)
+	w.sample(name+"_sum", snap.sum, labels...)
+	w.sample(name+"_count", float64(snap.count), labels...)
+}
+
+// escapeLabelValue escapes a label value for the text exposition format.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// formatMetricValue formats a sample value for the text exposition format.
+func formatMetricValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// metricsServer serves the metrics of the node to Prometheus.
+type metricsServer struct {
+	server     *server
+	listener   net.Listener
+	httpServer *http.Server
+}
+
+// newMetricsServer returns a metrics server for s listening on addr.
+func newMetricsServer(s *server, addr string) (*metricsServer, er.R) {
+	listener, errr := net.Listen("tcp", addr)
+	if errr != nil {
+		return nil, er.E(errr)
+	}
+	m := &metricsServer{
+		server:   s,
+		listener: listener,
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, m)
+	m.httpServer = &http.Server{
+		Handler:     mux,
+		ReadTimeout: 10 * time.Second,
+	}
+	return m, nil
+}
+
+// Start begins serving metrics.
+func (m *metricsServer) Start() {
+	log.Infof("Metrics server listening on %s", m.listener.Addr())
+	go func() {
+		errr := m.httpServer.Serve(m.listener)
+		if errr != nil && errr != http.ErrServerClosed {
+			log.Errorf("Metrics server failed: %v", errr)
+		}
+	}()
+}
+
+// Stop shuts down the metrics server.
+func (m *metricsServer) Stop() {
+	m.httpServer.Close()
+}
+
+// ServeHTTP writes the current metrics of the node.
+func (m *metricsServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var mw metricsWriter
+	m.collect(&mw)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(mw.buf.Bytes())
+}
+
+// peerCounts returns the number of connected inbound and outbound peers.  It
+// returns false if the server is shutting down.
+func (m *metricsServer) peerCounts() (int, int, bool) {
+	s := m.server
+	reply := make(chan []*serverPeer)
+	select {
+	case s.query <- getPeersMsg{reply: reply}:
+	case <-s.quit:
+		return 0, 0, false
+	}
+	inbound, outbound := 0, 0
+	for _, sp := range <-reply {
+		if sp.Inbound() {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+	return inbound, outbound, true
+}
+
+// collect writes all metrics of the node.
+func (m *metricsServer) collect(w *metricsWriter) {
+	s := m.server
+	best := s.chain.BestSnapshot()
+
+	// Chain.
+	w.gauge("chain_height", "Height of the best block", float64(best.Height))
+	w.gauge("header_height", "Height of the best known header",
+		float64(s.syncManager.BestHeaderHeight()))
+	w.gauge("chain_orphan_blocks", "Number of orphan blocks held",
+		float64(s.chain.OrphanCount()))
+	w.family("block_validation_seconds", "histogram",
+		"Time taken to process blocks received from peers and submitted over RPC")
+	w.histogram("block_validation_seconds", s.blockProcessTime.snapshot())
+
+	// Network steward.
+	w.gauge("steward_disapproval", "Coins voting against the current network steward",
+		float64(best.Elect.Disapproval))
+	w.gauge("steward_total_possible", "Total coins which can vote on the network steward",
+		float64(blockchain.PktCalcTotalMoney(best.Height)))
+
+	// Peers.
+	if inbound, outbound, ok := m.peerCounts(); ok {
+		w.family("peers", "gauge", "Number of connected peers by direction")
+		w.sample("peers", float64(inbound), "direction", "inbound")
+		w.sample("peers", float64(outbound), "direction", "outbound")
+	}
+	banned := 0
+	s.banMgr.ForEachIp(func(bi banmgr.BanInfo) er.R {
+		if !bi.BanExpiresTime.IsZero() {
+			banned++
+		}
+		return nil
+	})
+	w.gauge("banned_peers", "Number of banned addresses and subnets", float64(banned))
+
+	// Mempool.
+	var mempoolBytes int
+	txDescs := s.txMemPool.TxDescs()
+	for _, txD := range txDescs {
+		mempoolBytes += txD.Tx.MsgTx().SerializeSize()
+	}
+	w.gauge("mempool_transactions", "Number of transactions in the mempool",
+		float64(len(txDescs)))
+	w.gauge("mempool_bytes", "Serialized size of the transactions in the mempool",
+		float64(mempoolBytes))
+	w.gauge("mempool_orphans", "Number of orphan transactions held",
+		float64(s.txMemPool.OrphanCount()))
+
+	// Fee estimator.
+	buckets := s.feeEstimator.Buckets()
+	w.family("fee_estimator_transactions", "gauge",
+		"Number of observed transactions by blocks taken to confirm")
+	for _, b := range buckets {
+		w.sample("fee_estimator_transactions", float64(b.Transactions),
+			"blocks", strconv.Itoa(int(b.Blocks)))
+	}
+	w.family("fee_estimate_btc_per_kb", "gauge",
+		"Estimated fee rate to confirm within the given number of blocks")
+	for _, b := range buckets {
+		if b.FeeRate < 0 {
+			continue
+		}
+		w.sample("fee_estimate_btc_per_kb", float64(b.FeeRate),
+			"blocks", strconv.Itoa(int(b.Blocks)))
+	}
+
+	// Database.
+	if cr, ok := s.db.(database.CacheReporter); ok {
+		size, maxSize := cr.CacheSize()
+		w.gauge("db_cache_bytes", "Size of the database write cache", float64(size))
+		w.gauge("db_cache_max_bytes", "Size at which the database write cache is flushed",
+			float64(maxSize))
+	}
+
+	// RPC.
+	if s.rpcServer != nil {
+		calls := s.rpcServer.callStats.snapshot()
+		w.family("rpc_requests_total", "counter", "Number of RPC requests by method")
+		for _, c := range calls {
+			w.sample("rpc_requests_total", float64(c.requests), "method", c.method)
+		}
+		w.family("rpc_errors_total", "counter", "Number of failed RPC requests by method")
+		for _, c := range calls {
+			w.sample("rpc_errors_total", float64(c.errors), "method", c.method)
+		}
+		w.family("rpc_request_duration_seconds", "histogram",
+			"Time taken to handle RPC requests by method")
+		for _, c := range calls {
+			w.histogram("rpc_request_duration_seconds", c.latency, "method", c.method)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHistogram ensures observed values are counted in cumulative buckets.
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 5, 10})
+	for _, v := range []float64{0.5, 1, 3, 20} {
+		h.observe(v)
+	}
+	snap := h.snapshot()
+	want := []uint64{2, 3, 3}
+	for i, c := range snap.counts {
+		if c != want[i] {
+			t.Errorf("bucket %v: got %d, want %d", snap.bounds[i], c, want[i])
+		}
+	}
+	if snap.count != 4 || snap.sum != 24.5 {
+		t.Errorf("unexpected count %d and sum %v", snap.count, snap.sum)
+	}
+}
+
+// TestMetricsWriter ensures metrics are written in the Prometheus text
+// exposition format.
+func TestMetricsWriter(t *testing.T) {
+	stats := newRPCCallStats()
+	stats.observe("getinfo", 2*time.Millisecond, false)
+	stats.observe("getinfo", 2*time.Second, true)
+
+	var w metricsWriter
+	w.gauge("chain_height", "Height of the best block", 42)
+	for _, c := range stats.snapshot() {
+		w.sample("rpc_errors_total", float64(c.errors), "method", c.method)
+		w.histogram("rpc_request_duration_seconds", c.latency, "method", c.method)
+	}
+	w.sample("quoted", 1, "label", "a\"b\\c")
+
+	got := w.buf.String()
+	for _, line := range []string{
+		"# HELP pktd_chain_height Height of the best block\n",
+		"# TYPE pktd_chain_height gauge\n",
+		"pktd_chain_height 42\n",
+		`pktd_rpc_errors_total{method="getinfo"} 1` + "\n",
+		`pktd_rpc_request_duration_seconds_bucket{method="getinfo",le="0.0025"} 1` + "\n",
+		`pktd_rpc_request_duration_seconds_bucket{method="getinfo",le="+Inf"} 2` + "\n",
+		`pktd_rpc_request_duration_seconds_count{method="getinfo"} 2` + "\n",
+		`pktd_quoted{label="a\"b\\c"} 1` + "\n",
+	} {
+		if !strings.Contains(got, line) {
+			t.Errorf("missing %q in:\n%s", line, got)
+		}
+	}
+}
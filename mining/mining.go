@@ -8,6 +8,7 @@ package mining
 import (
 	"bytes"
 	"container/heap"
+	"sort"
 	"time"
 
 	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
@@ -61,6 +62,11 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// FeeDelta is the amount by which the fee of the transaction is
+	// adjusted when ranking it, as set by the operator.  It does not change
+	// the fee the transaction pays.
+	FeeDelta int64
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -88,7 +94,14 @@ type TxSource interface {
 type txPrioItem struct {
 	tx       *btcutil.Tx
 	fee      int64
+	feeDelta int64
 	priority float64
+	weight   int64
+	size     int64
+
+	// feePerKB is the fee per kilobyte of the transaction along with its
+	// ancestors which have not been included in the block yet, since they
+	// all have to be included for it to be.
 	feePerKB int64
 
 	// dependsOn holds a map of transaction hashes which this one depends
@@ -96,6 +109,86 @@ type txPrioItem struct {
 	// transactions in the source pool and hence must come after them in
 	// a block.
 	dependsOn map[chainhash.Hash]struct{}
+
+	// ancestors holds the transactions in the source pool which this one
+	// depends on, directly or through other ones, and which have not been
+	// included in the block yet.  pkgFee and pkgSize are the total modified
+	// fee and virtual size of those transactions along with this one.
+	ancestors map[chainhash.Hash]*txPrioItem
+	pkgFee    int64
+	pkgSize   int64
+
+	// skipped is set once the transaction will not be included in the
+	// block, which means the transactions depending on it will not be
+	// either.
+	skipped bool
+
+	// index is the position of the item in the priority queue, or -1 when
+	// it is not queued.
+	index int
+}
+
+// modifiedFee returns the fee of the transaction adjusted by its fee delta,
+// which is the fee it is ranked by.
+func (item *txPrioItem) modifiedFee() int64 {
+	return item.fee + item.feeDelta
+}
+
+// updateFeePerKB sets the fee per kilobyte of the item from the fee and size
+// of its package.
+func (item *txPrioItem) updateFeePerKB() {
+	item.feePerKB = item.pkgFee * 1000 / item.pkgSize
+}
+
+// setAncestors sets the ancestors of the item, along with the fee and size of
+// its package, from the items of the transactions it depends on.  It returns
+// false when the transaction can't be included in the block because one of
+// its ancestors can't be.
+func (item *txPrioItem) setAncestors(items map[chainhash.Hash]*txPrioItem) bool {
+	if item.skipped {
+		return false
+	}
+	if item.dependsOn == nil || item.ancestors != nil {
+		return true
+	}
+
+	// The ancestors are only set once all of them are known, so items
+	// which end up skipped never take part in the package of any other.
+	ancestors := make(map[chainhash.Hash]*txPrioItem)
+	for hash := range item.dependsOn {
+		parent, ok := items[hash]
+		if !ok || !parent.setAncestors(items) {
+			item.skipped = true
+			return false
+		}
+		ancestors[hash] = parent
+		for ancestorHash, ancestor := range parent.ancestors {
+			ancestors[ancestorHash] = ancestor
+		}
+	}
+	for _, ancestor := range ancestors {
+		item.pkgFee += ancestor.modifiedFee()
+		item.pkgSize += ancestor.size
+	}
+	item.ancestors = ancestors
+	item.updateFeePerKB()
+	return true
+}
+
+// sortedPackage returns the ancestors of the item which have not been included
+// in the block yet followed by the item itself, ordered so each transaction
+// comes after the ones it depends on.
+func (item *txPrioItem) sortedPackage() []*txPrioItem {
+	pkg := make([]*txPrioItem, 0, len(item.ancestors)+1)
+	for _, ancestor := range item.ancestors {
+		pkg = append(pkg, ancestor)
+	}
+
+	// A transaction has more ancestors left to include than any of them.
+	sort.Slice(pkg, func(i, j int) bool {
+		return len(pkg[i].ancestors) < len(pkg[j].ancestors)
+	})
+	return append(pkg, item)
 }
 
 // txPriorityQueueLessFunc describes a function that can be used as a compare
@@ -126,12 +219,16 @@ func (pq *txPriorityQueue) Less(i, j int) bool {
 // part of the heap.Interface implementation.
 func (pq *txPriorityQueue) Swap(i, j int) {
 	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+	pq.items[i].index = i
+	pq.items[j].index = j
 }
 
 // Push pushes the passed item onto the priority queue.  It is part of the
 // heap.Interface implementation.
 func (pq *txPriorityQueue) Push(x interface{}) {
-	pq.items = append(pq.items, x.(*txPrioItem))
+	item := x.(*txPrioItem)
+	item.index = len(pq.items)
+	pq.items = append(pq.items, item)
 }
 
 // Pop removes the highest priority item (according to Less) from the priority
@@ -139,6 +236,7 @@ func (pq *txPriorityQueue) Push(x interface{}) {
 func (pq *txPriorityQueue) Pop() interface{} {
 	n := len(pq.items)
 	item := pq.items[n-1]
+	item.index = -1
 	pq.items[n-1] = nil
 	pq.items = pq.items[0 : n-1]
 	return item
@@ -359,6 +457,43 @@ func logSkippedDeps(tx *btcutil.Tx, deps map[chainhash.Hash]*txPrioItem) {
 	}
 }
 
+// updateDescendants removes the passed item, whose transaction has been
+// included in the block, from the packages of the transactions which depend
+// on it and updates their positions in the priority queue accordingly.
+func updateDescendants(pq *txPriorityQueue,
+	dependers map[chainhash.Hash]map[chainhash.Hash]*txPrioItem,
+	included *txPrioItem) {
+
+	visited := make(map[chainhash.Hash]struct{})
+	next := []*txPrioItem{included}
+	for len(next) > 0 {
+		item := next[0]
+		next = next[1:]
+		for hash, dep := range dependers[*item.tx.Hash()] {
+			if _, ok := visited[hash]; ok || dep.skipped ||
+				dep.ancestors == nil {
+
+				continue
+			}
+			visited[hash] = struct{}{}
+			next = append(next, dep)
+
+			// The package of the item only holds the included
+			// transaction when its ancestors were set after it.
+			if _, ok := dep.ancestors[*included.tx.Hash()]; !ok {
+				continue
+			}
+			delete(dep.ancestors, *included.tx.Hash())
+			dep.pkgFee -= included.modifiedFee()
+			dep.pkgSize -= included.size
+			dep.updateFeePerKB()
+			if dep.index >= 0 {
+				heap.Fix(pq, dep.index)
+			}
+		}
+	}
+}
+
 // MinimumMedianTime returns the minimum allowed timestamp for a block building
 // on the end of the provided best chain.  In particular, it is one second after
 // the median timestamp of the last several blocks per the chain consensus
@@ -440,14 +575,17 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // higher fee per kilobyte are preferred.  Finally, the block generation related
 // policy settings are all taken into account.
 //
-// Transactions which only spend outputs from other transactions already in the
-// block chain are immediately added to a priority queue which either
+// All of the transactions are added to a priority queue which either
 // prioritizes based on the priority (then fee per kilobyte) or the fee per
 // kilobyte (then priority) depending on whether or not the BlockPrioritySize
-// policy setting allots space for high-priority transactions.  Transactions
-// which spend outputs from other transactions in the source pool are added to a
-// dependency map so they can be added to the priority queue once the
-// transactions they depend on have been included.
+// policy setting allots space for high-priority transactions.  A transaction
+// which spends outputs from other transactions in the source pool can only be
+// included along with those ancestors, so its fee per kilobyte is that of the
+// package made of it and the ancestors which are not in the block yet.  When a
+// transaction is selected, its package is added to the block with parents
+// before their children, and the packages of the transactions which depend on
+// the added ones are updated.  This way a transaction paying a high fee can
+// have its low-fee ancestors mined along with it.
 //
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
@@ -540,6 +678,11 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddresses map[btcutil.Address]f
 	// in the block once each transaction has been included.
 	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
 
+	// prioItems holds the items of the transactions which may be included
+	// in the block, so the ancestors of each one can be found once all of
+	// them are known.
+	prioItems := make(map[chainhash.Hash]*txPrioItem, len(sourceTxns))
+
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
 	// coinbase.  This allows the code below to simply append details about
@@ -587,7 +730,7 @@ mempoolLoop:
 		// Setup dependencies for any transactions which reference
 		// other transactions in the mempool so they can be properly
 		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
+		prioItem := &txPrioItem{tx: tx, index: -1}
 		for _, txIn := range tx.MsgTx().TxIn {
 			originHash := &txIn.PreviousOutPoint.Hash
 			entry := utxos.LookupEntry(txIn.PreviousOutPoint)
@@ -627,15 +770,18 @@ mempoolLoop:
 		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
 			nextBlockHeight)
 
-		// Calculate the fee in Satoshi/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
+		// Calculate the fee in Satoshi/kB, adjusted by the fee delta
+		// of the transaction.
 		prioItem.fee = txDesc.Fee
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
-		}
+		prioItem.feeDelta = txDesc.FeeDelta
+		prioItem.weight = blockchain.GetTransactionWeight(tx)
+		prioItem.size = (prioItem.weight +
+			(blockchain.WitnessScaleFactor - 1)) /
+			blockchain.WitnessScaleFactor
+		prioItem.pkgFee = prioItem.modifiedFee()
+		prioItem.pkgSize = prioItem.size
+		prioItem.updateFeePerKB()
+		prioItems[*tx.Hash()] = prioItem
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
@@ -643,6 +789,20 @@ mempoolLoop:
 		mergeUtxoView(blockUtxos, utxos)
 	}
 
+	// Add the transactions to the priority queue along with the ancestors
+	// they depend on in the source pool, so a transaction is ranked by the
+	// fee per kilobyte of the whole package which has to be included for
+	// it to be.
+	for _, prioItem := range prioItems {
+		if !prioItem.setAncestors(prioItems) {
+			log.Tracef("Skipping tx %s since it depends on a "+
+				"transaction which is not available",
+				prioItem.tx.Hash())
+			continue
+		}
+		heap.Push(priorityQueue, prioItem)
+	}
+
 	log.Tracef("Priority queue len %d, dependers len %d",
 		priorityQueue.Len(), len(dependers))
 
@@ -669,21 +829,43 @@ mempoolLoop:
 	// Choose which transactions make it into the block.
 	for priorityQueue.Len() > 0 {
 		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
+		// depending on the sort order) transaction along with the
+		// ancestors which have to be included before it.
 		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
 		tx := prioItem.tx
+		pkg := prioItem.sortedPackage()
+
+		// Grab any transactions which depend on this one.
+		deps := dependers[*tx.Hash()]
+
+		// The transaction can't be included when one of its ancestors
+		// was skipped.  That was logged when skipping the ancestor.
+		pkgWeight := uint32(0)
+		pkgHasWitness := false
+		for _, item := range pkg {
+			if item.skipped {
+				prioItem.skipped = true
+				break
+			}
+			pkgWeight += uint32(item.weight)
+			pkgHasWitness = pkgHasWitness || item.tx.HasWitness()
+		}
+		if prioItem.skipped {
+			continue
+		}
 
 		switch {
 		// If segregated witness has not been activated yet, then we
 		// shouldn't include any witness transactions in the block.
-		case !segwitActive && tx.HasWitness():
+		case !segwitActive && pkgHasWitness:
+			prioItem.skipped = true
 			continue
 
 		// Otherwise, Keep track of if we've included a transaction
 		// with witness data or not. If so, then we'll need to include
 		// the witness commitment as the last output in the coinbase
 		// transaction.
-		case segwitActive && !witnessIncluded && tx.HasWitness():
+		case segwitActive && !witnessIncluded && pkgHasWitness:
 			// If we're about to include a transaction bearing
 			// witness data, then we'll also need to include a
 			// witness commitment in the coinbase transaction.
@@ -712,35 +894,15 @@ mempoolLoop:
 			witnessIncluded = true
 		}
 
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
-
-		// Enforce maximum block size.  Also check for overflow.
-		txWeight := uint32(blockchain.GetTransactionWeight(tx))
-		blockPlusTxWeight := blockWeight + txWeight
+		// Enforce maximum block size for the whole package.  Also
+		// check for overflow.
+		blockPlusTxWeight := blockWeight + pkgWeight
 		if blockPlusTxWeight < blockWeight ||
 			blockPlusTxWeight >= g.policy.BlockMaxWeight {
 
 			log.Tracef("Skipping tx %s because it would exceed "+
 				"the max block weight", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost, err := blockchain.GetSigOpCost(tx, false,
-			blockUtxos, true, segwitActive)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"GetSigOpCost: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
-			log.Tracef("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash())
+			prioItem.skipped = true
 			logSkippedDeps(tx, deps)
 			continue
 		}
@@ -756,6 +918,7 @@ mempoolLoop:
 				"minBlockWeight %d", tx.Hash(), prioItem.feePerKB,
 				g.policy.TxMinFreeFee, blockPlusTxWeight,
 				g.policy.BlockMinWeight)
+			prioItem.skipped = true
 			logSkippedDeps(tx, deps)
 			continue
 		}
@@ -789,60 +952,88 @@ mempoolLoop:
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
+		// Add the ancestors of the transaction which are not in the
+		// block yet and then the transaction itself.  Should one of
+		// them fail the checks below, the ones already added stay in
+		// the block since they don't depend on it.
+		for _, item := range pkg {
+			tx := item.tx
+			deps := dependers[*tx.Hash()]
+
+			// Enforce maximum signature operation cost per block.
+			// Also check for overflow.
+			sigOpCost, err := blockchain.GetSigOpCost(tx, false,
+				blockUtxos, true, segwitActive)
+			if err != nil {
+				log.Tracef("Skipping tx %s due to error in "+
+					"GetSigOpCost: %v", tx.Hash(), err)
+				item.skipped = true
+				logSkippedDeps(tx, deps)
+				break
+			}
+			if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
+				blockSigOpCost+int64(sigOpCost) > blockchain.MaxBlockSigOpsCost {
+				log.Tracef("Skipping tx %s because it would "+
+					"exceed the maximum sigops per block", tx.Hash())
+				item.skipped = true
+				logSkippedDeps(tx, deps)
+				break
+			}
 
-		if g.policy.SkipChecks&CheckTxns == 0 {
-			startTime := time.Now()
-			err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-				txscript.StandardVerifyFlags, g.sigCache,
-				g.hashCache)
+			// Ensure the transaction inputs pass all of the
+			// necessary preconditions before allowing it to be
+			// added to the block.
+			_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
+				blockUtxos, g.chainParams)
 			if err != nil {
-				log.Infof("Skipping tx %s due to error in "+
-					"ValidateTransactionScripts: %v", tx.Hash(), err)
+				log.Tracef("Skipping tx %s due to error in "+
+					"CheckTransactionInputs: %v", tx.Hash(), err)
+				item.skipped = true
 				logSkippedDeps(tx, deps)
-				continue
+				break
+			}
+
+			if g.policy.SkipChecks&CheckTxns == 0 {
+				startTime := time.Now()
+				err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
+					txscript.StandardVerifyFlags, g.sigCache,
+					g.hashCache)
+				if err != nil {
+					log.Infof("Skipping tx %s due to error in "+
+						"ValidateTransactionScripts: %v", tx.Hash(), err)
+					item.skipped = true
+					logSkippedDeps(tx, deps)
+					break
+				}
+				timeCheckingSigs += time.Since(startTime)
 			}
-			timeCheckingSigs += time.Since(startTime)
-		}
 
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
-
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockWeight += txWeight
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %.2f)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
+			// Spend the transaction inputs in the block utxo view
+			// and add an entry for it to ensure any transactions
+			// which reference this one have it available as an
+			// input and can ensure they aren't double spending.
+			spendTransaction(blockUtxos, tx, nextBlockHeight)
+
+			// Add the transaction to the block, increment counters,
+			// and save the fees and signature operation counts to
+			// the block template.
+			blockTxns = append(blockTxns, tx)
+			blockWeight += uint32(item.weight)
+			blockSigOpCost += int64(sigOpCost)
+			totalFees += item.fee
+			txFees = append(txFees, item.fee)
+			txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+
+			log.Tracef("Adding tx %s (priority %.2f, feePerKB %d)",
+				tx.Hash(), item.priority, item.feePerKB)
+
+			// The transaction no longer needs to be selected on its
+			// own, and the packages of the transactions which depend
+			// on it no longer include it, so they are re-prioritized.
+			if item.index >= 0 {
+				heap.Remove(priorityQueue, item.index)
 			}
+			updateDescendants(priorityQueue, dependers, item)
 		}
 	}
 
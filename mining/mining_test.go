@@ -10,6 +10,9 @@ import (
 	"testing"
 
 	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/wire"
+	"github.com/pkt-cash/PKT-FullNode/wire/constants"
 )
 
 // TestTxFeePrioHeap ensures the priority queue for transaction fees and
@@ -108,3 +111,149 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// TestAncestorPackages ensures transactions are ranked by the fee per kilobyte
+// of their package of ancestors which are not in the block yet, and that the
+// packages are updated as transactions are included.
+func TestAncestorPackages(t *testing.T) {
+	items := make(map[chainhash.Hash]*txPrioItem)
+	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
+	newItem := func(lockTime uint32, fee int64,
+		parents ...*txPrioItem) *txPrioItem {
+
+		msgTx := wire.NewMsgTx(constants.TxVersion)
+		msgTx.LockTime = lockTime
+		item := &txPrioItem{
+			tx:      btcutil.NewTx(msgTx),
+			fee:     fee,
+			size:    1000,
+			pkgFee:  fee,
+			pkgSize: 1000,
+			index:   -1,
+		}
+		item.updateFeePerKB()
+		for _, parent := range parents {
+			if item.dependsOn == nil {
+				item.dependsOn = make(map[chainhash.Hash]struct{})
+			}
+			item.dependsOn[*parent.tx.Hash()] = struct{}{}
+			hash := *parent.tx.Hash()
+			if dependers[hash] == nil {
+				dependers[hash] = make(map[chainhash.Hash]*txPrioItem)
+			}
+			dependers[hash][*item.tx.Hash()] = item
+		}
+		items[*item.tx.Hash()] = item
+		return item
+	}
+
+	// The parent pays too little to be selected before other on its own,
+	// but its child pays enough for both of them.  The grandchild is
+	// worth including once its ancestors are in the block.
+	parent := newItem(1, 1000)
+	child := newItem(2, 9000, parent)
+	grandchild := newItem(3, 4000, child)
+	other := newItem(4, 3000)
+	unavailable := newItem(5, 0)
+	missing := newItem(6, 100000, unavailable)
+	delete(items, *unavailable.tx.Hash())
+
+	// A transaction spending both an unavailable parent and one which is
+	// included must be left out without its package being updated as the
+	// included parent goes into the block.
+	partial := newItem(7, 100000, unavailable, parent)
+
+	priorityQueue := newTxPriorityQueue(len(items), true)
+	for _, item := range items {
+		if item.setAncestors(items) {
+			heap.Push(priorityQueue, item)
+		}
+	}
+	if !missing.skipped || missing.index != -1 {
+		t.Fatalf("transaction with a missing parent was queued")
+	}
+	if !partial.skipped || partial.index != -1 || partial.ancestors != nil {
+		t.Fatalf("transaction with a missing and a present parent " +
+			"was queued")
+	}
+	if child.feePerKB != 5000 || grandchild.feePerKB != 14000/3 {
+		t.Fatalf("package fees per KB are %d and %d, want 5000 and %d",
+			child.feePerKB, grandchild.feePerKB, 14000/3)
+	}
+
+	var included []*txPrioItem
+	for priorityQueue.Len() > 0 {
+		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+		for _, item := range prioItem.sortedPackage() {
+			included = append(included, item)
+			if item.index >= 0 {
+				heap.Remove(priorityQueue, item.index)
+			}
+			updateDescendants(priorityQueue, dependers, item)
+		}
+	}
+
+	want := []*txPrioItem{parent, child, grandchild, other}
+	if len(included) != len(want) {
+		t.Fatalf("included %d transactions, want %d", len(included),
+			len(want))
+	}
+	for i := range want {
+		if included[i] != want[i] {
+			t.Fatalf("transaction %d is %v, want %v", i,
+				included[i].tx.Hash(), want[i].tx.Hash())
+		}
+	}
+	if grandchild.feePerKB != 4000 || len(grandchild.ancestors) != 0 {
+		t.Fatalf("grandchild package was not updated: fee per KB %d, "+
+			"%d ancestors", grandchild.feePerKB,
+			len(grandchild.ancestors))
+	}
+}
+
+// TestFeeDeltaPackages ensures transactions and their packages are ranked by
+// their fees adjusted by their fee deltas.
+func TestFeeDeltaPackages(t *testing.T) {
+	newItem := func(lockTime uint32, fee, feeDelta int64) *txPrioItem {
+		msgTx := wire.NewMsgTx(constants.TxVersion)
+		msgTx.LockTime = lockTime
+		item := &txPrioItem{
+			tx:       btcutil.NewTx(msgTx),
+			fee:      fee,
+			feeDelta: feeDelta,
+			size:     1000,
+			pkgSize:  1000,
+			index:    -1,
+		}
+		item.pkgFee = item.modifiedFee()
+		item.updateFeePerKB()
+		return item
+	}
+
+	// The parent pays nothing but was prioritised above other, and its
+	// child benefits from the delta of the parent.
+	parent := newItem(1, 0, 6000)
+	child := newItem(2, 1000, 0)
+	child.dependsOn = map[chainhash.Hash]struct{}{*parent.tx.Hash(): {}}
+	other := newItem(3, 5000, 0)
+	items := map[chainhash.Hash]*txPrioItem{
+		*parent.tx.Hash(): parent,
+		*child.tx.Hash():  child,
+		*other.tx.Hash():  other,
+	}
+
+	priorityQueue := newTxPriorityQueue(len(items), true)
+	for _, item := range items {
+		if item.setAncestors(items) {
+			heap.Push(priorityQueue, item)
+		}
+	}
+	if child.feePerKB != 3500 {
+		t.Fatalf("child package fee per KB is %d, want 3500",
+			child.feePerKB)
+	}
+	if first := heap.Pop(priorityQueue).(*txPrioItem); first != parent {
+		t.Fatalf("first transaction is %v, want the prioritised parent",
+			first.tx.Hash())
+	}
+}
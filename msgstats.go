@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/pkt-cash/PKT-FullNode/wire"
+)
+
+// msgStatOther is the command traffic is accounted to when no message could
+// be decoded, for example when a read fails.
+const msgStatOther = "*other*"
+
+// msgStat is the traffic of a single message type.
+type msgStat struct {
+	Bytes uint64
+	Count uint64
+}
+
+// msgStats accounts the traffic of each message type in both directions.
+type msgStats struct {
+	mtx  sync.Mutex
+	sent map[string]msgStat
+	recv map[string]msgStat
+}
+
+// newMsgStats returns an empty msgStats.
+func newMsgStats() *msgStats {
+	return &msgStats{
+		sent: make(map[string]msgStat),
+		recv: make(map[string]msgStat),
+	}
+}
+
+// msgCommand returns the command traffic for msg is accounted to.
+func msgCommand(msg wire.Message) string {
+	if msg == nil {
+		return msgStatOther
+	}
+	return msg.Command()
+}
+
+// add accounts n bytes of msg to the stats of the given direction.
+func (m *msgStats) add(stats map[string]msgStat, msg wire.Message, n int) {
+	if n <= 0 {
+		return
+	}
+	cmd := msgCommand(msg)
+	m.mtx.Lock()
+	stat := stats[cmd]
+	stat.Bytes += uint64(n)
+	if msg != nil {
+		stat.Count++
+	}
+	stats[cmd] = stat
+	m.mtx.Unlock()
+}
+
+// addSent accounts a message which was sent.
+func (m *msgStats) addSent(msg wire.Message, n int) {
+	m.add(m.sent, msg, n)
+}
+
+// addRecv accounts a message which was received.
+func (m *msgStats) addRecv(msg wire.Message, n int) {
+	m.add(m.recv, msg, n)
+}
+
+// snapshot returns copies of the sent and received stats.
+func (m *msgStats) snapshot() (map[string]msgStat, map[string]msgStat) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	sent := make(map[string]msgStat, len(m.sent))
+	for cmd, stat := range m.sent {
+		sent[cmd] = stat
+	}
+	recv := make(map[string]msgStat, len(m.recv))
+	for cmd, stat := range m.recv {
+		recv[cmd] = stat
+	}
+	return sent, recv
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pkt-cash/PKT-FullNode/wire"
+)
+
+// TestMsgStats ensures traffic is accounted to the right message types and
+// that snapshots are not affected by later updates.
+func TestMsgStats(t *testing.T) {
+	stats := newMsgStats()
+	stats.addSent(wire.NewMsgPing(1), 32)
+	stats.addSent(wire.NewMsgPing(2), 32)
+	stats.addRecv(wire.NewMsgPong(1), 32)
+	stats.addRecv(nil, 10)
+	stats.addRecv(wire.NewMsgVerAck(), 0)
+
+	sent, recv := stats.snapshot()
+	if got := sent["ping"]; got.Bytes != 64 || got.Count != 2 {
+		t.Errorf("unexpected ping stats: %+v", got)
+	}
+	if got := recv["pong"]; got.Bytes != 32 || got.Count != 1 {
+		t.Errorf("unexpected pong stats: %+v", got)
+	}
+	if got := recv[msgStatOther]; got.Bytes != 10 || got.Count != 0 {
+		t.Errorf("unexpected %s stats: %+v", msgStatOther, got)
+	}
+	if _, ok := recv["verack"]; ok {
+		t.Errorf("empty read was accounted")
+	}
+
+	stats.addSent(wire.NewMsgPing(3), 32)
+	if got := sent["ping"]; got.Count != 2 {
+		t.Errorf("snapshot changed after update: %+v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"net"
+	"strings"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+)
+
+// netPermissions is a set of privileges granted to peers which connect from a
+// whitelisted network or to a whitelisted bind address.
+type netPermissions uint32
+
+const (
+	// permNoBan protects the peer from being banned, disconnected for
+	// misbehavior or evicted to make room for other peers.
+	permNoBan netPermissions = 1 << iota
+
+	// permRelay accepts transactions from the peer even when running in
+	// blocksonly mode.
+	permRelay
+
+	// permForceRelay relays transactions from the peer again if they are
+	// already in the mempool.  They are also exempt from the free
+	// transaction rate limiter and processed again even if they were
+	// rejected before, but are otherwise subject to the same policy as
+	// other transactions.  It implies permRelay.
+	permForceRelay
+
+	// permMempool allows the peer to request the contents of the mempool
+	// even when bloom filtering is disabled.
+	permMempool
+
+	// permDownload allows the peer to download headers and blocks while
+	// the node is syncing, and exempts it from the upload limit.
+	permDownload
+
+	// permDefault is used when a whitelist entry does not list any
+	// permissions.
+	permDefault = permNoBan | permRelay | permMempool | permDownload
+
+	// permAll grants every permission.
+	permAll = permNoBan | permRelay | permForceRelay | permMempool |
+		permDownload
+)
+
+// netPermissionNames maps the names used in the config to permissions.
+var netPermissionNames = []struct {
+	name string
+	perm netPermissions
+}{
+	{"noban", permNoBan},
+	{"relay", permRelay},
+	{"forcerelay", permForceRelay},
+	{"mempool", permMempool},
+	{"download", permDownload},
+}
+
+// String returns the names of the permissions in the set separated by commas.
+func (p netPermissions) String() string {
+	var names []string
+	for _, np := range netPermissionNames {
+		if p&np.perm != 0 {
+			names = append(names, np.name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// parsePermissions splits a whitelist or whitebind value of the form
+// [perm,perm,...@]value into the permissions and the value.  Permissions
+// default to permDefault if none are given.
+func parsePermissions(s string) (netPermissions, string, er.R) {
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return permDefault, s, nil
+	}
+	var perms netPermissions
+	for _, name := range strings.Split(s[:i], ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			perms |= permAll
+			continue
+		}
+		found := false
+		for _, np := range netPermissionNames {
+			if np.name == name {
+				perms |= np.perm
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0, "", er.Errorf("invalid permission '%s'", name)
+		}
+	}
+	if perms&permForceRelay != 0 {
+		perms |= permRelay
+	}
+	return perms, s[i+1:], nil
+}
+
+// whitelistEntry is a network whose peers are granted permissions.
+type whitelistEntry struct {
+	ipnet *net.IPNet
+	perms netPermissions
+}
+
+// whitebindEntry is a bind address where the peers which connect to it are
+// granted permissions.
+type whitebindEntry struct {
+	addr  string
+	perms netPermissions
+}
+
+// parseWhitelist parses a --whitelist value, which is an IP address or a
+// network in CIDR notation with optional permissions.
+func parseWhitelist(s string) (whitelistEntry, er.R) {
+	perms, addr, err := parsePermissions(s)
+	if err != nil {
+		return whitelistEntry{}, err
+	}
+	_, ipnet, errr := net.ParseCIDR(addr)
+	if errr != nil {
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return whitelistEntry{}, er.Errorf("invalid IP address "+
+				"or network '%s'", addr)
+		}
+		var bits int
+		if ip.To4() == nil {
+			// IPv6
+			bits = 128
+		} else {
+			bits = 32
+		}
+		ipnet = &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(bits, bits),
+		}
+	}
+	return whitelistEntry{ipnet: ipnet, perms: perms}, nil
+}
+
+// parseWhitebind parses a --whitebind value, which is a bind address with
+// optional permissions.  The default port is added to the address if needed.
+func parseWhitebind(s, defaultPort string) (whitebindEntry, er.R) {
+	perms, addr, err := parsePermissions(s)
+	if err != nil {
+		return whitebindEntry{}, err
+	}
+	return whitebindEntry{
+		addr:  normalizeAddress(addr, defaultPort),
+		perms: perms,
+	}, nil
+}
+
+// peerPermissions returns the permissions granted to a peer connected from
+// remoteAddr to localAddr, which is nil for outbound connections.
+func peerPermissions(remoteAddr, localAddr net.Addr) netPermissions {
+	var perms netPermissions
+	if localAddr != nil {
+		for _, wb := range cfg.whitebinds {
+			if bindAddrMatches(wb.addr, localAddr) {
+				perms |= wb.perms
+			}
+		}
+	}
+	if len(cfg.whitelists) == 0 {
+		return perms
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr.String())
+	if err != nil {
+		log.Warnf("Unable to SplitHostPort on '%s': %v", remoteAddr, err)
+		return perms
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		log.Warnf("Unable to parse IP '%s'", remoteAddr)
+		return perms
+	}
+
+	for _, wl := range cfg.whitelists {
+		if wl.ipnet.Contains(ip) {
+			perms |= wl.perms
+		}
+	}
+	return perms
+}
+
+// bindAddrMatches returns whether a connection accepted on localAddr was
+// accepted by the listener bound to bindAddr.
+func bindAddrMatches(bindAddr string, localAddr net.Addr) bool {
+	bindHost, bindPort, err := net.SplitHostPort(bindAddr)
+	if err != nil {
+		return false
+	}
+	host, port, err := net.SplitHostPort(localAddr.String())
+	if err != nil || port != bindPort {
+		return false
+	}
+	if bindHost == "" {
+		return true
+	}
+	bindIP := net.ParseIP(bindHost)
+	if bindIP != nil && bindIP.IsUnspecified() {
+		return true
+	}
+	return bindIP.Equal(net.ParseIP(host))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"testing"
+)
+
+// TestParsePermissions tests parsing of whitelist and whitebind permissions.
+func TestParsePermissions(t *testing.T) {
+	tests := []struct {
+		in    string
+		perms netPermissions
+		value string
+		err   bool
+	}{
+		{"10.0.0.1", permDefault, "10.0.0.1", false},
+		{"noban@10.0.0.0/8", permNoBan, "10.0.0.0/8", false},
+		{"forcerelay,mempool@::1", permForceRelay | permRelay | permMempool, "::1", false},
+		{"all@10.0.0.1", permAll, "10.0.0.1", false},
+		{"bogus@10.0.0.1", 0, "", true},
+	}
+	for _, test := range tests {
+		perms, value, err := parsePermissions(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("parsePermissions(%q): expected error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parsePermissions(%q): %v", test.in, err)
+			continue
+		}
+		if perms != test.perms || value != test.value {
+			t.Errorf("parsePermissions(%q): got %v %q, want %v %q",
+				test.in, perms, value, test.perms, test.value)
+		}
+	}
+}
+
+// TestBindAddrMatches tests matching accepted connections to bind addresses.
+func TestBindAddrMatches(t *testing.T) {
+	local := &net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 64764}
+	tests := []struct {
+		bind  string
+		match bool
+	}{
+		{"192.168.1.2:64764", true},
+		{":64764", true},
+		{"0.0.0.0:64764", true},
+		{"192.168.1.3:64764", false},
+		{"192.168.1.2:64765", false},
+	}
+	for _, test := range tests {
+		if got := bindAddrMatches(test.bind, local); got != test.match {
+			t.Errorf("bindAddrMatches(%q): got %v, want %v",
+				test.bind, got, test.match)
+		}
+	}
+}
@@ -5,6 +5,8 @@
 package netsync
 
 import (
+	"time"
+
 	"github.com/pkt-cash/PKT-FullNode/blockchain"
 	"github.com/pkt-cash/PKT-FullNode/btcutil"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg"
@@ -38,4 +40,8 @@ type Config struct {
 	MaxPeers           int
 
 	FeeEstimator *mempool.FeeEstimator
+
+	// BlockProcessed is an optional function which is invoked with the
+	// time taken by the chain to process each block.
+	BlockProcessed func(elapsed time.Duration)
 }
@@ -99,9 +99,10 @@ type donePeerMsg struct {
 // txMsg packages a bitcoin tx message and the peer it came from together
 // so the block handler has access to that information.
 type txMsg struct {
-	tx    *btcutil.Tx
-	peer  *peerpkg.Peer
-	reply chan struct{}
+	tx         *btcutil.Tx
+	peer       *peerpkg.Peer
+	forceRelay bool
+	reply      chan struct{}
 }
 
 // getSyncPeerMsg is a message type to be sent across the message channel for
@@ -196,6 +197,13 @@ type SyncManager struct {
 	// An optional fee estimator.
 	feeEstimator  *mempool.FeeEstimator
 	syncPeerMutex sync.RWMutex
+
+	// bestHeaderHeight is the height of the best header downloaded in
+	// headers-first mode, it must be accessed atomically.
+	bestHeaderHeight int32
+
+	// An optional function invoked with block processing times.
+	blockProcessed func(elapsed time.Duration)
 }
 
 func (sm *SyncManager) SyncPeer() *peerpkg.Peer {
@@ -593,19 +601,21 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	// interoperability.
 	txHash := tmsg.tx.Hash()
 
-	// Ignore transactions that we have already rejected.  Do not
-	// send a reject message here because if the transaction was already
-	// rejected, the transaction was unsolicited.
-	if _, exists = sm.rejectedTxns[*txHash]; exists {
+	// Ignore transactions that we have already rejected, unless the peer
+	// has the forcerelay permission.  Do not send a reject message here
+	// because if the transaction was already rejected, the transaction was
+	// unsolicited.
+	if _, exists = sm.rejectedTxns[*txHash]; exists && !tmsg.forceRelay {
 		log.Debugf("Ignoring unsolicited previously rejected "+
 			"transaction %v from %s", txHash, peer)
 		return
 	}
 
 	// Process the transaction to include validation, insertion in the
-	// memory pool, orphan handling, etc.
+	// memory pool, orphan handling, etc.  Transactions from peers with
+	// the forcerelay permission are not rate limited.
 	acceptedTxs, err := sm.txMemPool.ProcessTransaction(tmsg.tx,
-		true, true, mempool.Tag(peer.ID()))
+		true, !tmsg.forceRelay, mempool.Tag(peer.ID()))
 
 	// Remove transaction from request maps. Either the mempool/chain
 	// already knows about it and as such we shouldn't have any more
@@ -615,6 +625,18 @@ func (sm *SyncManager) handleTxMsg(tmsg *txMsg) {
 	delete(sm.requestedTxns, *txHash)
 
 	if err != nil {
+		// Transactions from peers with the forcerelay permission are
+		// relayed again if we already have them.
+		if tmsg.forceRelay {
+			if txD, err := sm.txMemPool.FetchTxDesc(txHash); err == nil {
+				log.Debugf("Force relaying transaction %v from %s",
+					txHash, peer)
+				iv := wire.NewInvVect(wire.InvTypeTx, txHash)
+				sm.peerNotifier.RelayInventory(iv, txD)
+				return
+			}
+		}
+
 		// Do not request this transaction again until a new block
 		// has been processed.
 		sm.rejectedTxns[*txHash] = struct{}{}
@@ -726,7 +748,7 @@ func (sm *SyncManager) handleBlockMsg(bmsg *blockMsg) {
 
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
-	_, isOrphan, err := sm.chain.ProcessBlock(bmsg.block, behaviorFlags)
+	_, isOrphan, err := sm.processBlock(bmsg.block, behaviorFlags)
 	if ruleerror.ErrPowCannotVerify.Is(err) {
 		err = nil
 	}
@@ -998,6 +1020,9 @@ func (sm *SyncManager) handleHeadersMsg(hmsg *headersMsg) {
 			if sm.startHeader == nil {
 				sm.startHeader = e
 			}
+			if node.height > atomic.LoadInt32(&sm.bestHeaderHeight) {
+				atomic.StoreInt32(&sm.bestHeaderHeight, node.height)
+			}
 		} else {
 			log.Warnf("Received block header that does not "+
 				"properly connect to the chain from peer %s "+
@@ -1377,7 +1402,7 @@ out:
 				msg.reply <- peerID
 
 			case processBlockMsg:
-				_, isOrphan, err := sm.chain.ProcessBlock(
+				_, isOrphan, err := sm.processBlock(
 					msg.block, msg.flags)
 				msg.reply <- processBlockResponse{
 					isOrphan: isOrphan,
@@ -1408,6 +1433,17 @@ out:
 	log.Trace("Block handler done")
 }
 
+// processBlock passes the block to the chain for processing and reports the
+// time it took to the blockProcessed function, if any.
+func (sm *SyncManager) processBlock(block *btcutil.Block, flags blockchain.BehaviorFlags) (bool, bool, er.R) {
+	start := time.Now()
+	isMainChain, isOrphan, err := sm.chain.ProcessBlock(block, flags)
+	if sm.blockProcessed != nil {
+		sm.blockProcessed(time.Since(start))
+	}
+	return isMainChain, isOrphan, err
+}
+
 // handleBlockchainNotification handles notifications from blockchain.  It does
 // things such as request orphan block parents and relay accepted blocks to
 // connected peers.
@@ -1449,7 +1485,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 		// transaction are NOT removed recursively because they are still
 		// valid.
 		for _, tx := range block.Transactions()[1:] {
-			sm.txMemPool.RemoveTransaction(tx, false)
+			sm.txMemPool.RemoveTransaction(tx, false,
+				mempool.RemovalConfirmed)
 			sm.txMemPool.RemoveDoubleSpends(tx)
 			sm.txMemPool.RemoveOrphan(tx)
 			sm.peerNotifier.TransactionConfirmed(tx)
@@ -1488,7 +1525,8 @@ func (sm *SyncManager) handleBlockchainNotification(notification *blockchain.Not
 				// Remove the transaction and all transactions
 				// that depend on it if it wasn't accepted into
 				// the transaction pool.
-				sm.txMemPool.RemoveTransaction(tx, true)
+				sm.txMemPool.RemoveTransaction(tx, true,
+					mempool.RemovalConflict)
 			}
 		}
 
@@ -1516,15 +1554,18 @@ func (sm *SyncManager) NewPeer(peer *peerpkg.Peer) {
 
 // QueueTx adds the passed transaction message and peer to the block handling
 // queue. Responds to the done channel argument after the tx message is
-// processed.
-func (sm *SyncManager) QueueTx(tx *btcutil.Tx, peer *peerpkg.Peer, done chan struct{}) {
+// processed.  When forceRelay is set the transaction is processed even if it
+// was rejected before, is not rate limited, and is relayed again if it is
+// already in the mempool.
+func (sm *SyncManager) QueueTx(tx *btcutil.Tx, peer *peerpkg.Peer, forceRelay bool, done chan struct{}) {
 	// Don't accept more transactions if we're shutting down.
 	if atomic.LoadInt32(&sm.shutdown) != 0 {
 		done <- struct{}{}
 		return
 	}
 
-	sm.msgChan <- &txMsg{tx: tx, peer: peer, reply: done}
+	sm.msgChan <- &txMsg{tx: tx, peer: peer, forceRelay: forceRelay,
+		reply: done}
 }
 
 // QueueBlock adds the passed block message and peer to the block handling
@@ -1616,6 +1657,18 @@ func (sm *SyncManager) ProcessBlock(block *btcutil.Block, flags blockchain.Behav
 	return response.isOrphan, response.err
 }
 
+// BestHeaderHeight returns the height of the best known header, which is ahead
+// of the chain height while headers are downloaded ahead of their blocks.
+//
+// This function is safe for concurrent access.
+func (sm *SyncManager) BestHeaderHeight() int32 {
+	height := sm.chain.BestSnapshot().Height
+	if headerHeight := atomic.LoadInt32(&sm.bestHeaderHeight); headerHeight > height {
+		return headerHeight
+	}
+	return height
+}
+
 // IsCurrent returns whether or not the sync manager believes it is synced with
 // the connected peers.
 func (sm *SyncManager) IsCurrent() bool {
@@ -1651,6 +1704,7 @@ func New(config *Config) (*SyncManager, er.R) {
 		headerList:      list.New(),
 		quit:            make(chan struct{}),
 		feeEstimator:    config.FeeEstimator,
+		blockProcessed:  config.BlockProcessed,
 	}
 
 	best := sm.chain.BestSnapshot()
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/blockchain"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+)
+
+const (
+	// notifyQueueSize is the number of commands which may be waiting to
+	// run, further notifications are dropped until the queue drains.
+	notifyQueueSize = 64
+
+	// defaultNotifyTimeout is the default time a notify command may run
+	// before it is killed.
+	defaultNotifyTimeout = time.Minute
+)
+
+// notifyCmd runs a shell command for each notification, with %s in the command
+// replaced by the argument of the notification.  Commands run one at a time in
+// the background so slow commands never hold up the caller.
+type notifyCmd struct {
+	name    string
+	command string
+	timeout time.Duration
+	queue   chan string
+	wg      sync.WaitGroup
+}
+
+// newNotifyCmd returns a notifyCmd for the command, name is the option which
+// configured it and is used in log messages.
+func newNotifyCmd(name, command string, timeout time.Duration) *notifyCmd {
+	return &notifyCmd{
+		name:    name,
+		command: command,
+		timeout: timeout,
+		queue:   make(chan string, notifyQueueSize),
+	}
+}
+
+// notify queues the command to run with arg substituted for %s.  The
+// notification is dropped if the queue is full.
+func (n *notifyCmd) notify(arg string) {
+	select {
+	case n.queue <- arg:
+	default:
+		log.Warnf("Dropping %s for %s, too many commands are queued",
+			n.name, arg)
+	}
+}
+
+// start begins running the queued commands until quit is closed.
+func (n *notifyCmd) start(quit <-chan struct{}) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+		for {
+			select {
+			case arg := <-n.queue:
+				n.run(arg)
+			case <-quit:
+				return
+			}
+		}
+	}()
+}
+
+// stop waits for the command which is running, if any, to finish.
+func (n *notifyCmd) stop() {
+	n.wg.Wait()
+}
+
+// run runs the command with arg substituted for %s and waits for it to exit or
+// time out.  The output of the command is discarded, capturing it would keep
+// run waiting on processes the command left running after it was killed.
+func (n *notifyCmd) run(arg string) {
+	command := strings.ReplaceAll(n.command, "%s", arg)
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	start := time.Now()
+	errr := cmd.Run()
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Warnf("%s command [%s] timed out after %v", n.name, command,
+			n.timeout)
+	case errr != nil:
+		log.Warnf("%s command [%s] failed: %v", n.name, command, errr)
+	default:
+		log.Debugf("%s command [%s] completed in %v", n.name, command,
+			time.Since(start))
+	}
+}
+
+// tipNotifier runs the --blocknotify and --stewardnotify commands when the tip
+// of the best chain or the network steward changes.
+type tipNotifier struct {
+	chain   *blockchain.BlockChain
+	block   *notifyCmd
+	steward *notifyCmd
+
+	// mtx protects the last notified tip and steward, chain notifications
+	// are not delivered concurrently but this keeps it safe regardless.
+	mtx         sync.Mutex
+	lastTip     string
+	lastSteward string
+}
+
+// newTipNotifier returns a tipNotifier for the configured commands, or nil if
+// none are configured.
+func newTipNotifier(chain *blockchain.BlockChain) *tipNotifier {
+	if cfg.BlockNotify == "" && cfg.StewardNotify == "" {
+		return nil
+	}
+	best := chain.BestSnapshot()
+	t := &tipNotifier{
+		chain:       chain,
+		lastTip:     best.Hash.String(),
+		lastSteward: hex.EncodeToString(best.Elect.NetworkSteward),
+	}
+	if cfg.BlockNotify != "" {
+		t.block = newNotifyCmd("blocknotify", cfg.BlockNotify,
+			cfg.NotifyTimeout)
+	}
+	if cfg.StewardNotify != "" {
+		t.steward = newNotifyCmd("stewardnotify", cfg.StewardNotify,
+			cfg.NotifyTimeout)
+	}
+	chain.Subscribe(t.handleBlockchainNotification)
+	return t
+}
+
+// start begins running notification commands until quit is closed.
+func (t *tipNotifier) start(quit <-chan struct{}) {
+	if t.block != nil {
+		t.block.start(quit)
+	}
+	if t.steward != nil {
+		t.steward.start(quit)
+	}
+}
+
+// stop waits for running commands to finish.
+func (t *tipNotifier) stop() {
+	if t.block != nil {
+		t.block.stop()
+	}
+	if t.steward != nil {
+		t.steward.stop()
+	}
+}
+
+// handleBlockchainNotification queues the commands when a block connected to
+// or disconnected from the main chain changes the tip or the network steward.
+// Nothing is run while the chain is syncing so the initial download does not
+// spawn a command for every block.
+func (t *tipNotifier) handleBlockchainNotification(n *blockchain.Notification) {
+	switch n.Type {
+	case blockchain.NTBlockConnected, blockchain.NTBlockDisconnected:
+	default:
+		return
+	}
+
+	best := t.chain.BestSnapshot()
+	tip := best.Hash.String()
+	steward := hex.EncodeToString(best.Elect.NetworkSteward)
+
+	t.mtx.Lock()
+	tipChanged := tip != t.lastTip
+	stewardChanged := steward != t.lastSteward
+	t.lastTip = tip
+	t.lastSteward = steward
+	t.mtx.Unlock()
+
+	if !t.chain.IsCurrent() {
+		return
+	}
+	if tipChanged && t.block != nil {
+		t.block.notify(tip)
+	}
+	if stewardChanged && t.steward != nil {
+		t.steward.notify(steward)
+	}
+}
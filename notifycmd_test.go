@@ -0,0 +1,46 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestNotifyCmd ensures notify commands run with %s substituted and are killed
+// once they time out.
+func TestNotifyCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses a POSIX shell")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+
+	n := newNotifyCmd("blocknotify", "echo %s >> "+out, time.Minute)
+	quit := make(chan struct{})
+	n.start(quit)
+	n.notify("aa")
+	n.notify("bb")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, _ := os.ReadFile(out)
+		if string(got) == "aa\nbb\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected output %q", got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(quit)
+	n.stop()
+
+	slow := newNotifyCmd("blocknotify", "sleep 10", 50*time.Millisecond)
+	start := time.Now()
+	slow.run("")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command was not killed after its timeout, ran %v", elapsed)
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"github.com/pkt-cash/PKT-FullNode/blockchain"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/v2transport"
 	"github.com/pkt-cash/PKT-FullNode/wire"
 )
 
@@ -297,6 +298,11 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// V2Transport specifies whether to use the BIP0324 encrypted transport.
+	// Outbound peers initiate the v2 handshake, inbound peers accept it but
+	// fall back to the v1 protocol when the remote peer does not use it.
+	V2Transport bool
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners `json:"-"`
@@ -402,6 +408,8 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	TransportType  string
+	SessionID      []byte
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -450,6 +458,10 @@ type Peer struct {
 
 	conn net.Conn
 
+	// connReader is the reader messages are read from, normally conn but
+	// it replays bytes which were consumed while detecting the transport.
+	connReader io.Reader
+
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
 	addr    string
@@ -467,6 +479,8 @@ type Peer struct {
 	sendHeadersPreferred bool   // peer sent a sendheaders message
 	verAckReceived       bool
 	witnessEnabled       bool
+	v2Transport          *v2transport.Transport
+	v2Rejected           bool // outbound v2 handshake hit a v1 peer
 
 	wireEncoding wire.MessageEncoding
 
@@ -635,6 +649,12 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 	userAgent := p.userAgent
 	services := p.services
 	protocolVersion := p.advertisedProtoVer
+	transportType := "v1"
+	var sessionID []byte
+	if p.v2Transport != nil {
+		transportType = "v2"
+		sessionID = p.v2Transport.SessionID()
+	}
 	p.flagsMtx.Unlock()
 
 	// Get a copy of all relevant flags and stats.
@@ -656,6 +676,8 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		TransportType:  transportType,
+		SessionID:      sessionID,
 	}
 
 	p.statsMtx.RUnlock()
@@ -1098,8 +1120,17 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage(encoding wire.MessageEncoding) (wire.Message, []byte, er.R) {
-	n, msg, buf, err := wire.ReadMessageWithEncodingN(p.conn,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	var n int
+	var msg wire.Message
+	var buf []byte
+	var err er.R
+	if p.v2Transport != nil {
+		n, msg, buf, err = p.v2Transport.ReadMessage(p.connReader,
+			p.ProtocolVersion(), encoding)
+	} else {
+		n, msg, buf, err = wire.ReadMessageWithEncodingN(p.connReader,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net, encoding)
+	}
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
@@ -1161,8 +1192,15 @@ func (p *Peer) writeMessage(msg wire.Message, enc wire.MessageEncoding) er.R {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageWithEncodingN(p.conn, msg,
-		p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	var n int
+	var err er.R
+	if p.v2Transport != nil {
+		n, err = p.v2Transport.WriteMessage(p.conn, msg,
+			p.ProtocolVersion(), enc)
+	} else {
+		n, err = wire.WriteMessageWithEncodingN(p.conn, msg,
+			p.ProtocolVersion(), p.cfg.ChainParams.Net, enc)
+	}
 	atomic.AddUint64(&p.bytesSent, uint64(n))
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
@@ -2151,12 +2189,66 @@ func (p *Peer) negotiateOutboundProtocol() er.R {
 	return p.readRemoteVersionMsg()
 }
 
+// negotiateTransport performs the BIP0324 handshake when the v2 transport is
+// enabled.  Inbound peers which turn out to be using the v1 transport carry on
+// with it, replaying the bytes which were consumed by the detection.
+func (p *Peer) negotiateTransport() er.R {
+	if !p.cfg.V2Transport {
+		return nil
+	}
+	t, err := v2transport.NewTransport(p.cfg.ChainParams.Net, !p.inbound)
+	if err != nil {
+		return err
+	}
+	read, written, err := t.Handshake(p.conn)
+	atomic.AddUint64(&p.bytesSent, uint64(written))
+	if v2transport.ErrV1Peer.Is(err) {
+		prefix := t.V1Prefix()
+		atomic.AddUint64(&p.bytesReceived, uint64(read-len(prefix)))
+		p.connReader = io.MultiReader(bytes.NewReader(prefix), p.conn)
+		log.Debugf("Peer %s is using the v1 transport", p)
+		return nil
+	}
+	atomic.AddUint64(&p.bytesReceived, uint64(read))
+	if v2transport.ErrV1Responder.Is(err) {
+		p.flagsMtx.Lock()
+		p.v2Rejected = true
+		p.flagsMtx.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	p.flagsMtx.Lock()
+	p.v2Transport = t
+	p.flagsMtx.Unlock()
+	log.Debugf("Negotiated v2 transport with %s", p)
+	return nil
+}
+
+// V2HandshakeFailed returns whether this is an outbound peer whose v2 transport
+// handshake failed because the remote peer appears to be using the v1
+// transport.  The caller may retry the connection using the v1 transport.
+// Handshakes failing for other reasons, such as timeouts, are not reported.
+//
+// This function is safe for concurrent access.
+func (p *Peer) V2HandshakeFailed() bool {
+	p.flagsMtx.Lock()
+	failed := p.v2Rejected
+	p.flagsMtx.Unlock()
+	return failed
+}
+
 // start begins processing input and output messages.
 func (p *Peer) start() er.R {
 	log.Tracef("Starting peer %s", p)
 
 	negotiateErr := make(chan er.R, 1)
 	go func() {
+		if err := p.negotiateTransport(); err != nil {
+			negotiateErr <- err
+			return
+		}
 		if p.inbound {
 			negotiateErr <- p.negotiateInboundProtocol()
 		} else {
@@ -2199,6 +2291,7 @@ func (p *Peer) AssociateConnection(conn net.Conn) {
 	}
 
 	p.conn = conn
+	p.connReader = conn
 	p.timeConnected = time.Now()
 
 	if p.inbound {
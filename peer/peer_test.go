@@ -6,6 +6,7 @@
 package peer_test
 
 import (
+	"bytes"
 	"io"
 	"net"
 	"testing"
@@ -325,6 +326,118 @@ func TestPeerConnection(t *testing.T) {
 	}
 }
 
+// TestPeerV2Transport tests that peers negotiate the v2 transport when both
+// sides enable it and that an inbound v2 peer falls back to v1.
+func TestPeerV2Transport(t *testing.T) {
+	peer.TstAllowSelfConns()
+	tests := []struct {
+		name      string
+		inV2      bool
+		outV2     bool
+		transport string
+	}{
+		{"both v2", true, true, "v2"},
+		{"inbound v2, outbound v1", true, false, "v1"},
+	}
+	for _, test := range tests {
+		verack := make(chan struct{}, 4)
+		listeners := peer.MessageListeners{
+			OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+				verack <- struct{}{}
+			},
+		}
+		inCfg := &peer.Config{
+			Listeners:       listeners,
+			ChainParams:     &chaincfg.MainNetParams,
+			TrickleInterval: time.Second,
+			V2Transport:     test.inV2,
+		}
+		outCfg := *inCfg
+		outCfg.V2Transport = test.outV2
+
+		inConn, outConn := pipe(
+			&conn{raddr: "10.0.0.1:8333"},
+			&conn{raddr: "10.0.0.2:8333"},
+		)
+		inPeer := peer.NewInboundPeer(inCfg)
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(&outCfg, "10.0.0.2:8333")
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: %v", test.name, err)
+		}
+		outPeer.AssociateConnection(outConn)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-verack:
+			case <-time.After(time.Second * 5):
+				t.Fatalf("%s: verack timeout", test.name)
+			}
+		}
+
+		inStats := inPeer.StatsSnapshot()
+		outStats := outPeer.StatsSnapshot()
+		if inStats.TransportType != test.transport ||
+			outStats.TransportType != test.transport {
+			t.Errorf("%s: transport - got: %s/%s, want: %s", test.name,
+				inStats.TransportType, outStats.TransportType,
+				test.transport)
+		}
+		if !bytes.Equal(inStats.SessionID, outStats.SessionID) {
+			t.Errorf("%s: session id mismatch - got: %x/%x", test.name,
+				inStats.SessionID, outStats.SessionID)
+		}
+		if outPeer.V2HandshakeFailed() {
+			t.Errorf("%s: unexpected v2 handshake failure", test.name)
+		}
+
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+		inPeer.WaitForDisconnect()
+		outPeer.WaitForDisconnect()
+	}
+}
+
+// TestPeerV2Fallback tests that an outbound v2 peer connected to a v1 peer
+// reports that the handshake failed, so the connection can be retried with v1.
+func TestPeerV2Fallback(t *testing.T) {
+	peer.TstAllowSelfConns()
+	inCfg := &peer.Config{
+		ChainParams:     &chaincfg.MainNetParams,
+		TrickleInterval: time.Second,
+	}
+	outCfg := *inCfg
+	outCfg.V2Transport = true
+
+	inConn, outConn := pipe(
+		&conn{raddr: "10.0.0.1:8333"},
+		&conn{raddr: "10.0.0.2:8333"},
+	)
+	inPeer := peer.NewInboundPeer(inCfg)
+	inPeer.AssociateConnection(inConn)
+	outPeer, err := peer.NewOutboundPeer(&outCfg, "10.0.0.2:8333")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+
+	done := make(chan struct{})
+	go func() {
+		outPeer.WaitForDisconnect()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatalf("outbound peer still connected")
+	}
+	if !outPeer.V2HandshakeFailed() {
+		t.Errorf("v2 handshake with a v1 peer not reported as failed")
+	}
+	inPeer.Disconnect()
+	inPeer.WaitForDisconnect()
+}
+
 // TestPeerListeners tests that the peer listeners are called as expected.
 func TestPeerListeners(t *testing.T) {
 	verack := make(chan struct{}, 1)
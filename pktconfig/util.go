@@ -65,39 +65,64 @@ type userpass struct {
 	Password    string `long:"rpcpass"`
 	OldUsername string `long:"username"`
 	OldPassword string `long:"password"`
+	RPCUnix     string `long:"rpcunix"`
 }
 
-// ReadUserPass reads out the username and password from a config file
-func ReadUserPass(filePath string) ([]string, er.R) {
+// readServerConfig reads out the RPC settings from a config file, a missing
+// file is not an error.
+func readServerConfig(filePath string) (*userpass, er.R) {
 	cfg := userpass{}
 	parser := flags.NewParser(&cfg, flags.IgnoreUnknown)
-	if errr := flags.NewIniParser(parser).ParseFile(filePath); errr == nil {
-		if cfg.Username == "" {
-			cfg.Username = cfg.OldUsername
-		}
-		if cfg.Password == "" {
-			cfg.Password = cfg.OldPassword
-		}
-		if cfg.Username != "" && cfg.Password != "" {
-			return []string{cfg.Username, cfg.Password}, nil
-		}
-	} else if _, ok := errr.(*os.PathError); !ok {
-		return nil, er.E(errr)
-	}
-	// Couldn't find the file or didn't have user/pass
-	// Lets see if there's a .cookie file
-	cookiePath := strings.ReplaceAll(filePath, "pktd.conf", ".cookie")
-	if cookiePath == filePath {
-		return nil, nil
-	} else if cookie, errr := ioutil.ReadFile(cookiePath); errr != nil {
+	if errr := flags.NewIniParser(parser).ParseFile(filePath); errr != nil {
 		if _, ok := errr.(*os.PathError); !ok {
 			return nil, er.E(errr)
-		} else {
+		}
+	}
+	if cfg.Username == "" {
+		cfg.Username = cfg.OldUsername
+	}
+	if cfg.Password == "" {
+		cfg.Password = cfg.OldPassword
+	}
+	return &cfg, nil
+}
+
+// ReadUserPass reads out the username and password from a config file
+func ReadUserPass(filePath string) ([]string, er.R) {
+	cfg, err := readServerConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Username != "" && cfg.Password != "" {
+		return []string{cfg.Username, cfg.Password}, nil
+	}
+	return nil, nil
+}
+
+// ReadRPCUnix reads out the path of the RPC Unix domain socket from a config
+// file, it is empty if the server does not listen on one.
+func ReadRPCUnix(filePath string) (string, er.R) {
+	cfg, err := readServerConfig(filePath)
+	if err != nil {
+		return "", err
+	}
+	return cfg.RPCUnix, nil
+}
+
+// ReadCookie reads out the username and password from the .cookie file which
+// pktd writes to its data directory while the RPC server is running.  It
+// returns nil if there is no cookie file.
+func ReadCookie(cookiePath string) ([]string, er.R) {
+	cookie, errr := ioutil.ReadFile(cookiePath)
+	if errr != nil {
+		if os.IsNotExist(errr) {
 			return nil, nil
 		}
-	} else if up := strings.Split(string(cookie), ":"); len(up) != 2 {
+		return nil, er.E(errr)
+	}
+	up := strings.SplitN(strings.TrimSpace(string(cookie)), ":", 2)
+	if len(up) != 2 {
 		return nil, er.Errorf("Unexpected cookie file format")
-	} else {
-		return []string{up[0], up[1]}, nil
 	}
+	return up, nil
 }
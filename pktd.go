@@ -360,7 +360,9 @@ func main() {
 	}
 
 	// Work around defer not working after os.Exit()
-	if err := pktdMain(nil); err != nil {
+	err := pktdMain(nil)
+	log.Close()
+	if err != nil {
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,77 @@
+package log
+
+import "sort"
+
+// Fields are key/value pairs attached to a log message.  In the text format
+// they are appended to the message as key=value, in the JSON format they are
+// carried in the fields object.
+type Fields map[string]interface{}
+
+// keys returns the keys of the fields, sorted.
+func (f Fields) keys() []string {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Entry logs messages with a set of fields attached.
+type Entry struct {
+	fields Fields
+}
+
+// WithFields returns an Entry which attaches the given fields to the messages
+// logged through it.
+func WithFields(fields Fields) Entry {
+	return Entry{fields: fields}
+}
+
+func (e Entry) Trace(args ...interface{}) {
+	doLogln(LevelTrace, e.fields, args...)
+}
+
+func (e Entry) Tracef(format string, args ...interface{}) {
+	doLogf(LevelTrace, e.fields, format, args...)
+}
+
+func (e Entry) Debug(args ...interface{}) {
+	doLogln(LevelDebug, e.fields, args...)
+}
+
+func (e Entry) Debugf(format string, args ...interface{}) {
+	doLogf(LevelDebug, e.fields, format, args...)
+}
+
+func (e Entry) Info(args ...interface{}) {
+	doLogln(LevelInfo, e.fields, args...)
+}
+
+func (e Entry) Infof(format string, args ...interface{}) {
+	doLogf(LevelInfo, e.fields, format, args...)
+}
+
+func (e Entry) Warn(args ...interface{}) {
+	doLogln(LevelWarn, e.fields, args...)
+}
+
+func (e Entry) Warnf(format string, args ...interface{}) {
+	doLogf(LevelWarn, e.fields, format, args...)
+}
+
+func (e Entry) Error(args ...interface{}) {
+	doLogln(LevelError, e.fields, args...)
+}
+
+func (e Entry) Errorf(format string, args ...interface{}) {
+	doLogf(LevelError, e.fields, format, args...)
+}
+
+func (e Entry) Critical(args ...interface{}) {
+	doLogln(LevelCritical, e.fields, args...)
+}
+
+func (e Entry) Criticalf(format string, args ...interface{}) {
+	doLogf(LevelCritical, e.fields, format, args...)
+}
@@ -34,6 +34,7 @@ package log
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -81,6 +82,9 @@ const (
 // levelStrs defines the human-readable names for each logging level.
 var levelStrs = [...]string{"TRC", "DBG", "INF", "WRN", "ERR", "CRT", "OFF"}
 
+// levelNames defines the names of each logging level used in JSON output.
+var levelNames = [...]string{"trace", "debug", "info", "warn", "error", "critical", "off"}
+
 // LevelFromString returns a level based on the input string s.  If the input
 // can't be interpreted as a valid log level, the info level and false is
 // returned.
@@ -195,16 +199,16 @@ func newBackend(w io.Writer) *backend {
 	}
 
 	b := &backend{
-		flag: flags,
-		ch:   make(chan *[]byte, 1024),
-		lvl:  defaultLevel,
-		lmap: make(map[string]Level),
+		flag:    flags,
+		ch:      make(chan *record, 1024),
+		lvl:     defaultLevel,
+		lmap:    make(map[string]Level),
+		console: w,
 	}
 	go func() {
 		for {
-			l := <-b.ch
-			w.Write(*l)
-			recycleBuffer(l)
+			r := <-b.ch
+			b.output(r)
 		}
 	}()
 	return b
@@ -386,10 +390,10 @@ const calldepth = 3
 
 // callsite returns the file name and line number of the callsite to the
 // subsystem logger.
-func callsite(flag uint32) (string, string, int) {
-	_, file, line, ok := runtime.Caller(calldepth)
+func callsite(flag uint32) (string, string, int, uintptr) {
+	pc, file, line, ok := runtime.Caller(calldepth)
 	if !ok {
-		return "???", "", 0
+		return "???", "", 0, 0
 	}
 	short := file
 	for i := len(file) - 1; i > 0; i-- {
@@ -401,29 +405,243 @@ func callsite(flag uint32) (string, string, int) {
 	if flag&Lshortfile != 0 {
 		file = short
 	}
-	return file, short, line
+	return file, short, line, pc
+}
+
+// subsystem returns the name of the package of the function at pc.
+func subsystem(pc uintptr) string {
+	fn := runtime.FuncForPC(pc)
+	if fn == nil {
+		return ""
+	}
+	name := fn.Name()
+	if i := strings.LastIndexByte(name, '/'); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// record is a log message on its way to the outputs of the backend.
+type record struct {
+	t         time.Time
+	lvl       Level
+	file      string
+	line      int
+	subsystem string
+	msg       string
+	fields    Fields
+
+	// flushed is closed once the record is reached by the output
+	// goroutine, it is only set by Flush.
+	flushed chan struct{}
 }
 
-func (b *backend) write(buf *[]byte) {
+func (b *backend) write(r *record) {
 	select {
-	case b.ch <- buf:
+	case b.ch <- r:
 		// ok
 	default:
-		// failed, recycle the buffer ourselves
-		recycleBuffer(buf)
+		// failed, drop the message
 	}
 }
 
+// output writes a record to the console and the log file.
+func (b *backend) output(r *record) {
+	if r.flushed != nil {
+		close(r.flushed)
+		return
+	}
+
+	b.outLock.Lock()
+	defer b.outLock.Unlock()
+
+	buf := buffer()
+	if b.json {
+		formatJSON(buf, r)
+	} else {
+		formatText(buf, b.flag, r)
+	}
+	b.console.Write(*buf)
+
+	if b.file != nil {
+		if !b.json {
+			// Colors are only meant for terminals.
+			*buf = (*buf)[:0]
+			formatText(buf, b.flag&^Lcolor, r)
+			*buf = stripColors(*buf)
+		}
+		b.file.Write(*buf)
+	}
+	recycleBuffer(buf)
+}
+
+// formatText appends a record to buf in the text format.
+func formatText(buf *[]byte, flags uint32, r *record) {
+	hasColor := formatHeader(flags, buf, r.t, r.lvl, r.file, r.line)
+	msg := r.msg
+	if len(r.fields) > 0 {
+		msg = strings.TrimSuffix(msg, "\n")
+	}
+	*buf = append(*buf, msg...)
+	for _, k := range r.fields.keys() {
+		*buf = append(*buf, ' ')
+		*buf = append(*buf, k...)
+		*buf = append(*buf, '=')
+		*buf = append(*buf, fmt.Sprint(r.fields[k])...)
+	}
+	if hasColor {
+		*buf = append(*buf, Reset...)
+	}
+	*buf = append(*buf, '\n')
+}
+
+// jsonRecord is the representation of a record in the JSON format.
+type jsonRecord struct {
+	Time      string                 `json:"time"`
+	Level     string                 `json:"level"`
+	Subsystem string                 `json:"subsystem"`
+	Caller    string                 `json:"caller"`
+	Msg       string                 `json:"msg"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// formatJSON appends a record to buf as a single line of JSON.
+func formatJSON(buf *[]byte, r *record) {
+	jr := jsonRecord{
+		Time:      r.t.UTC().Format(time.RFC3339Nano),
+		Level:     levelNames[r.lvl],
+		Subsystem: r.subsystem,
+		Caller:    r.file + ":" + strconv.Itoa(r.line),
+		Msg:       string(stripColors([]byte(strings.TrimSuffix(r.msg, "\n")))),
+	}
+	if len(r.fields) > 0 {
+		jr.Fields = make(map[string]interface{}, len(r.fields))
+		for k, v := range r.fields {
+			jr.Fields[k] = jsonValue(v)
+		}
+	}
+	out, err := json.Marshal(&jr)
+	if err != nil {
+		// A field could not be encoded, fall back to formatting
+		// all of them as strings.
+		for k, v := range r.fields {
+			jr.Fields[k] = fmt.Sprint(v)
+		}
+		out, _ = json.Marshal(&jr)
+	}
+	*buf = append(*buf, out...)
+	*buf = append(*buf, '\n')
+}
+
+// jsonValue returns the value a field is encoded as in JSON, errors and
+// Stringers are encoded as their string.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case er.R:
+		return v.Message()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	}
+	return v
+}
+
+// stripColors removes terminal color escape sequences from buf, in place.
+func stripColors(buf []byte) []byte {
+	if bytes.IndexByte(buf, '\x1b') < 0 {
+		return buf
+	}
+	out := buf[:0]
+	for i := 0; i < len(buf); i++ {
+		if buf[i] == '\x1b' && i+1 < len(buf) && buf[i+1] == '[' {
+			j := i + 2
+			for j < len(buf) && (buf[j] == ';' || (buf[j] >= '0' && buf[j] <= '9')) {
+				j++
+			}
+			if j < len(buf) && buf[j] == 'm' {
+				i = j
+				continue
+			}
+		}
+		out = append(out, buf[i])
+	}
+	return out
+}
+
 // backend is a logging backend.  Subsystems created from the backend write to
 // the backend's Writer.  backend provides atomic writes to the Writer from all
 // subsystems.
 type backend struct {
-	ch   chan *[]byte
+	ch   chan *record
 	flag uint32
 
 	lock sync.RWMutex
 	lvl  Level
 	lmap map[string]Level
+
+	// The outputs are protected by outLock.
+	outLock sync.Mutex
+	console io.Writer
+	json    bool
+	file    *rotator
+}
+
+// SetLogFormat sets the format of the log output, either "text" or "json".
+func SetLogFormat(format string) er.R {
+	var json bool
+	switch format {
+	case "text":
+	case "json":
+		json = true
+	default:
+		return er.Errorf("The specified log format [%v] is invalid", format)
+	}
+	b.outLock.Lock()
+	b.json = json
+	b.outLock.Unlock()
+	return nil
+}
+
+// SetLogFile starts writing the log to a file in addition to the console.  Any
+// file which was previously being written to is closed.
+func SetLogFile(cfg FileConfig) er.R {
+	r, err := openRotator(cfg)
+	if err != nil {
+		return err
+	}
+	b.outLock.Lock()
+	old := b.file
+	b.file = r
+	b.outLock.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// Flush blocks until all messages which were logged before the call are
+// written out.
+func Flush() {
+	r := &record{flushed: make(chan struct{})}
+	b.ch <- r
+	<-r.flushed
+}
+
+// Close flushes the log and closes the log file, if any.  Messages which are
+// logged afterwards are only written to the console.
+func Close() {
+	Flush()
+	b.outLock.Lock()
+	old := b.file
+	b.file = nil
+	b.outLock.Unlock()
+	if old != nil {
+		old.Close()
+	}
 }
 
 var b *backend
@@ -433,21 +651,21 @@ func init() {
 	pktlog := os.Getenv("PKTLOG")
 	if pktlog != "" {
 		if err := SetLogLevels(pktlog); err != nil {
-			Errorf("Error setting log parame: ", err.String())
+			Errorf("Error setting log parame: %s", err.String())
 		}
 	}
 }
 
-// doLog outputs a log message to the writer associated with the backend after
-// creating a prefix for the given level and tag according to the formatHeader
-// function and formatting the provided arguments according to the given format
-// specifier.
-func doLog(
+// newRecord returns a record of a log message at the given level, with the
+// given fields, logged from the given callsite, or nil if messages at that
+// level are not logged there.
+func newRecord(
 	lvl Level,
-	format string,
-	args ...interface{},
-) {
-	file, shortFile, line := callsite(b.flag)
+	fields Fields,
+	file, shortFile string,
+	line int,
+	pc uintptr,
+) *record {
 	doit := true
 	b.lock.RLock()
 	if lvl >= b.lvl {
@@ -457,73 +675,85 @@ func doLog(
 	}
 	b.lock.RUnlock()
 	if !doit {
-		return
+		return nil
 	}
 
-	t := time.Now()
-	bytebuf := buffer()
-	hasColor := formatHeader(b.flag, bytebuf, t, lvl, file, line)
-	buf := bytes.NewBuffer(*bytebuf)
-	if format == "" {
-		fmt.Fprintln(buf, args...)
-	} else {
-		fmt.Fprintf(buf, format, args...)
+	return &record{
+		t:         time.Now(),
+		lvl:       lvl,
+		file:      file,
+		line:      line,
+		subsystem: subsystem(pc),
+		fields:    fields,
 	}
-	*bytebuf = buf.Bytes()
-	if hasColor {
-		*bytebuf = append(*bytebuf, Reset...)
+}
+
+// doLogf outputs a log message to the writer associated with the backend after
+// formatting the provided arguments according to the given format specifier.
+func doLogf(lvl Level, fields Fields, format string, args ...interface{}) {
+	file, shortFile, line, pc := callsite(b.flag)
+	if r := newRecord(lvl, fields, file, shortFile, line, pc); r != nil {
+		r.msg = fmt.Sprintf(format, args...)
+		b.write(r)
 	}
-	*bytebuf = append(*bytebuf, '\n')
+}
 
-	b.write(bytebuf)
+// doLogln outputs a log message to the writer associated with the backend after
+// formatting the provided arguments using the default formats.
+func doLogln(lvl Level, fields Fields, args ...interface{}) {
+	file, shortFile, line, pc := callsite(b.flag)
+	if r := newRecord(lvl, fields, file, shortFile, line, pc); r != nil {
+		r.msg = fmt.Sprintln(args...)
+		b.write(r)
+	}
 }
 
 func Trace(args ...interface{}) {
-	doLog(LevelTrace, "", args...)
+	doLogln(LevelTrace, nil, args...)
 }
 
 func Tracef(format string, args ...interface{}) {
-	doLog(LevelTrace, format, args...)
+	doLogf(LevelTrace, nil, format, args...)
 }
 
 func Debug(args ...interface{}) {
-	doLog(LevelDebug, "", args...)
+	doLogln(LevelDebug, nil, args...)
 }
 
 func Debugf(format string, args ...interface{}) {
-	doLog(LevelDebug, format, args...)
+	doLogf(LevelDebug, nil, format, args...)
 }
 
 func Info(args ...interface{}) {
-	doLog(LevelInfo, "", args...)
+	doLogln(LevelInfo, nil, args...)
 }
 
 func Infof(format string, args ...interface{}) {
-	doLog(LevelInfo, format, args...)
+	doLogf(LevelInfo, nil, format, args...)
 }
 
 func Warn(args ...interface{}) {
-	doLog(LevelWarn, "", args...)
+	doLogln(LevelWarn, nil, args...)
 }
 
 func Warnf(format string, args ...interface{}) {
-	doLog(LevelWarn, format, args...)
+	doLogf(LevelWarn, nil, format, args...)
 }
 
 func Error(args ...interface{}) {
-	doLog(LevelError, "", args...)
+	doLogln(LevelError, nil, args...)
 }
 
 func Errorf(format string, args ...interface{}) {
-	doLog(LevelError, format, args...)
+	doLogf(LevelError, nil, format, args...)
 }
 
 func Critical(args ...interface{}) {
-	doLog(LevelCritical, "", args...)
+	doLogln(LevelCritical, nil, args...)
 }
 
 func Criticalf(format string, args ...interface{}) {
-	doLog(LevelCritical, format, args...)
+	doLogf(LevelCritical, nil, format, args...)
 }
 
 // logClosure is used to provide a closure over expensive logging operations so
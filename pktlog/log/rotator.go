@@ -0,0 +1,252 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+)
+
+// rotatedTimeFormat is the format of the time of rotation which is inserted in
+// the names of rotated log files.  It sorts lexically and has no characters
+// which are invalid in file names.
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// compressedSuffix is appended to the names of compressed log files.
+const compressedSuffix = ".gz"
+
+// FileConfig configures logging to a file.
+type FileConfig struct {
+	// Dir is the directory the log files are kept in, it is created if
+	// it does not exist.
+	Dir string
+
+	// Name is the name of the log file which is written to.  Rotated
+	// files get the time of rotation inserted before the extension.
+	Name string
+
+	// MaxSize is the size in bytes after which the log file is rotated,
+	// 0 disables rotation by size.
+	MaxSize int64
+
+	// MaxAge is how long the log file is written to before it is rotated,
+	// 0 disables rotation by age.
+	MaxAge time.Duration
+
+	// MaxFiles is the number of rotated files which are kept, the oldest
+	// ones are removed.  0 keeps all of them.
+	MaxFiles int
+
+	// Compress enables gzip compression of rotated files.
+	Compress bool
+}
+
+// rotator is an io.Writer which writes to a log file and rotates it according
+// to a FileConfig.  Writes are not safe for concurrent access.
+type rotator struct {
+	cfg    FileConfig
+	path   string
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	// pruneLock serializes pruning and compression of rotated files, wg
+	// tracks the compressions running in the background.
+	pruneLock sync.Mutex
+	wg        sync.WaitGroup
+}
+
+// openRotator opens the log file described by cfg for appending.
+func openRotator(cfg FileConfig) (*rotator, er.R) {
+	if errr := os.MkdirAll(cfg.Dir, 0700); errr != nil {
+		return nil, er.E(errr)
+	}
+	r := &rotator{
+		cfg:  cfg,
+		path: filepath.Join(cfg.Dir, cfg.Name),
+	}
+	if err := r.open(); err != nil {
+		return nil, er.E(err)
+	}
+	return r, nil
+}
+
+// open opens the log file for appending.
+func (r *rotator) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+// Write writes p to the log file, rotating it first if needed.
+func (r *rotator) Write(p []byte) (int, error) {
+	if r.f == nil {
+		// A previous rotation failed, try again.
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.needsRotation(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// needsRotation returns whether the log file needs to be rotated before n
+// more bytes are written to it.
+func (r *rotator) needsRotation(n int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.cfg.MaxSize > 0 && r.size+n > r.cfg.MaxSize {
+		return true
+	}
+	return r.cfg.MaxAge > 0 && time.Since(r.opened) >= r.cfg.MaxAge
+}
+
+// rotatedName returns the name the log file is given when it is rotated at t.
+func (r *rotator) rotatedName(t time.Time) string {
+	ext := filepath.Ext(r.cfg.Name)
+	base := strings.TrimSuffix(r.cfg.Name, ext)
+	return base + "-" + t.Format(rotatedTimeFormat) + ext
+}
+
+// isRotatedName returns whether name is the name of a rotated log file,
+// possibly compressed.
+func (r *rotator) isRotatedName(name string) bool {
+	ext := filepath.Ext(r.cfg.Name)
+	base := strings.TrimSuffix(r.cfg.Name, ext)
+	name = strings.TrimSuffix(name, compressedSuffix)
+	if !strings.HasPrefix(name, base+"-") || !strings.HasSuffix(name, ext) {
+		return false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, base+"-"), ext)
+	_, err := time.Parse(rotatedTimeFormat, stamp)
+	return err == nil
+}
+
+// rotate closes the log file, renames it and opens a new one.
+func (r *rotator) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	rotated := filepath.Join(r.cfg.Dir, r.rotatedName(time.Now()))
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.pruneLock.Lock()
+		defer r.pruneLock.Unlock()
+		if r.cfg.Compress {
+			if err := compressFile(rotated); err != nil {
+				Warnf("Failed to compress log file %s: %v", rotated, err)
+			}
+		}
+		r.prune()
+	}()
+	return nil
+}
+
+// compressFile replaces the file at path with a gzip compressed copy.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+compressedSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		zw.Close()
+		out.Close()
+		os.Remove(path + compressedSuffix)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(path + compressedSuffix)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(path + compressedSuffix)
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes the oldest rotated files beyond the MaxFiles limit.  It must be
+// called with pruneLock held.
+func (r *rotator) prune() {
+	if r.cfg.MaxFiles <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		return
+	}
+
+	// A rotated file is counted once even if both the plain and the
+	// compressed copy exist.
+	rotated := make(map[string][]string)
+	for _, e := range entries {
+		if e.IsDir() || !r.isRotatedName(e.Name()) {
+			continue
+		}
+		key := strings.TrimSuffix(e.Name(), compressedSuffix)
+		rotated[key] = append(rotated[key], e.Name())
+	}
+	if len(rotated) <= r.cfg.MaxFiles {
+		return
+	}
+	keys := make([]string, 0, len(rotated))
+	for k := range rotated {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys[:len(keys)-r.cfg.MaxFiles] {
+		for _, name := range rotated[k] {
+			os.Remove(filepath.Join(r.cfg.Dir, name))
+		}
+	}
+}
+
+// Close closes the log file and waits for background compressions to finish.
+func (r *rotator) Close() error {
+	var err error
+	if r.f != nil {
+		err = r.f.Close()
+		r.f = nil
+	}
+	r.wg.Wait()
+	return err
+}
@@ -0,0 +1,113 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotatedFiles returns the names of the rotated files in dir.
+func rotatedFiles(t *testing.T, r *rotator) []string {
+	entries, err := os.ReadDir(r.cfg.Dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if r.isRotatedName(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+// TestRotatorSize ensures the log file is rotated by size, rotated files are
+// compressed and only MaxFiles of them are kept.
+func TestRotatorSize(t *testing.T) {
+	dir := t.TempDir()
+	r, err := openRotator(FileConfig{
+		Dir:      dir,
+		Name:     "test.log",
+		MaxSize:  100,
+		MaxFiles: 2,
+		Compress: true,
+	})
+	if err != nil {
+		t.Fatalf("openRotator: %v", err)
+	}
+	line := []byte(strings.Repeat("x", 59) + "\n")
+	for i := 0; i < 5; i++ {
+		if _, err := r.Write(line); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		// Rotated file names have a millisecond resolution.
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	names := rotatedFiles(t, r)
+	if len(names) != 2 {
+		t.Fatalf("expected 2 rotated files, got %v", names)
+	}
+	for _, name := range names {
+		if !strings.HasSuffix(name, compressedSuffix) {
+			t.Errorf("rotated file %s was not compressed", name)
+		}
+	}
+	fi, errr := os.Stat(filepath.Join(dir, "test.log"))
+	if errr != nil {
+		t.Fatalf("Stat: %v", errr)
+	}
+	if fi.Size() != int64(len(line)) {
+		t.Errorf("unexpected log file size %d", fi.Size())
+	}
+}
+
+// TestRotatorAge ensures the log file is rotated by age.
+func TestRotatorAge(t *testing.T) {
+	dir := t.TempDir()
+	r, err := openRotator(FileConfig{
+		Dir:    dir,
+		Name:   "test.log",
+		MaxAge: time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("openRotator: %v", err)
+	}
+	defer r.Close()
+
+	r.Write([]byte("first\n"))
+	if names := rotatedFiles(t, r); len(names) != 0 {
+		t.Fatalf("unexpected rotation: %v", names)
+	}
+	r.opened = r.opened.Add(-2 * time.Hour)
+	r.Write([]byte("second\n"))
+	r.wg.Wait()
+	names := rotatedFiles(t, r)
+	if len(names) != 1 || strings.HasSuffix(names[0], compressedSuffix) {
+		t.Fatalf("expected 1 uncompressed rotated file, got %v", names)
+	}
+}
+
+// TestFormatJSON ensures records are written as JSON with colors stripped.
+func TestFormatJSON(t *testing.T) {
+	var buf []byte
+	formatJSON(&buf, &record{
+		t:         time.Unix(0, 0),
+		lvl:       LevelWarn,
+		file:      "manager.go",
+		line:      42,
+		subsystem: "netsync",
+		msg:       "height " + Height(7) + "\n",
+		fields:    Fields{"peer": "1.2.3.4:8333"},
+	})
+	want := `{"time":"1970-01-01T00:00:00Z","level":"warn","subsystem":"netsync",` +
+		`"caller":"manager.go:42","msg":"height 7","fields":{"peer":"1.2.3.4:8333"}}` + "\n"
+	if string(buf) != want {
+		t.Errorf("got %s, want %s", buf, want)
+	}
+}
@@ -6,15 +6,18 @@ package main
 
 import (
 	"sync/atomic"
+	"time"
 
 	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
 
 	"github.com/pkt-cash/PKT-FullNode/blockchain"
 	"github.com/pkt-cash/PKT-FullNode/btcutil"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/connmgr/banmgr"
 	"github.com/pkt-cash/PKT-FullNode/mempool"
 	"github.com/pkt-cash/PKT-FullNode/netsync"
 	"github.com/pkt-cash/PKT-FullNode/peer"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
 	"github.com/pkt-cash/PKT-FullNode/wire"
 )
 
@@ -64,6 +67,15 @@ func (p *rpcPeer) FeeFilter() int64 {
 	return atomic.LoadInt64(&(*serverPeer)(p).feeFilter)
 }
 
+// MsgStats returns the traffic sent to and received from the peer by message
+// type.
+//
+// This function is safe for concurrent access and is part of the rpcserverPeer
+// interface implementation.
+func (p *rpcPeer) MsgStats() (map[string]msgStat, map[string]msgStat) {
+	return (*serverPeer)(p).msgStats.snapshot()
+}
+
 // rpcConnManager provides a connection manager for use with the RPC server and
 // implements the rpcserverConnManager interface.
 type rpcConnManager struct {
@@ -167,6 +179,23 @@ func (cm *rpcConnManager) NetTotals() (uint64, uint64) {
 	return cm.server.NetTotals()
 }
 
+// UploadTarget returns the state of the upload target.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) UploadTarget() uploadTargetStats {
+	return cm.server.uploadTarget.stats()
+}
+
+// MsgStats returns the traffic sent to and received from all peers by message
+// type.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) MsgStats() (map[string]msgStat, map[string]msgStat) {
+	return cm.server.msgStats.snapshot()
+}
+
 // ConnectedPeers returns an array consisting of all connected peers.
 //
 // This function is safe for concurrent access and is part of the
@@ -226,6 +255,59 @@ func (cm *rpcConnManager) RelayTransactions(txns []*mempool.TxDesc) {
 	cm.server.relayTransactions(txns)
 }
 
+// SetBan bans an IP address or subnet until the provided time, or for the
+// default ban duration if it is the zero time, and disconnects any connected
+// peers which the ban covers.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) SetBan(subnet string, until time.Time) er.R {
+	banMgr := cm.server.banMgr
+	if err := banMgr.Ban(subnet, until, "manually added"); err != nil {
+		return err
+	}
+	for _, p := range cm.ConnectedPeers() {
+		sp := (*serverPeer)(p.(*rpcPeer))
+		if banMgr.IsBanned(sp.Addr()) {
+			log.Infof("Disconnecting banned peer %s", sp)
+			sp.Disconnect()
+		}
+	}
+	return nil
+}
+
+// Unban removes the ban of an IP address or subnet.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) Unban(subnet string) er.R {
+	return cm.server.banMgr.Unban(subnet)
+}
+
+// ClearBanned removes all bans.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) ClearBanned() {
+	cm.server.banMgr.ClearBanned()
+}
+
+// BannedPeers returns the addresses and subnets which are currently banned.
+//
+// This function is safe for concurrent access and is part of the
+// rpcserverConnManager interface implementation.
+func (cm *rpcConnManager) BannedPeers() []banmgr.BanInfo {
+	var bans []banmgr.BanInfo
+	cm.server.banMgr.ForEachIp(func(bi banmgr.BanInfo) er.R {
+		// Peers which are only suspicious have no ban expiry.
+		if !bi.BanExpiresTime.IsZero() {
+			bans = append(bans, bi)
+		}
+		return nil
+	})
+	return bans
+}
+
 // rpcSyncMgr provides a block manager for use with the RPC server and
 // implements the rpcserverSyncManager interface.
 type rpcSyncMgr struct {
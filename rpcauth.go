@@ -0,0 +1,283 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/pkt-cash/PKT-FullNode/btcjson"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+)
+
+// rpcUnixUser is the name of the user of requests without credentials on the
+// Unix domain socket.
+const rpcUnixUser = "__PKT_UNIX__"
+
+// rpcUser is a user of the RPC server along with the methods it may call and
+// the websocket notifications it may receive.
+type rpcUser struct {
+	name string
+
+	// isAdmin specifies whether the user may change the state of the
+	// server; false means its access is only to the limited set of RPC
+	// calls.
+	isAdmin bool
+
+	// allow and deny are the methods the user is restricted to and the
+	// methods it may not call.  An empty allow list places no
+	// restriction.
+	allow map[string]struct{}
+	deny  map[string]struct{}
+
+	// allowNotify and denyNotify are the same for websocket
+	// notifications.
+	allowNotify map[string]struct{}
+	denyNotify  map[string]struct{}
+}
+
+// permitted returns whether name passes an allow and a deny list.
+func permitted(name string, allow, deny map[string]struct{}) bool {
+	if len(allow) > 0 {
+		if _, ok := allow[name]; !ok {
+			return false
+		}
+	}
+	_, denied := deny[name]
+	return !denied
+}
+
+// checkMethod returns an RPC error if the user may not call method.
+func (u *rpcUser) checkMethod(method string) er.R {
+	if !u.isAdmin {
+		if _, ok := rpcLimited[method]; !ok {
+			return btcjson.NewRPCError(
+				btcjson.ErrRPCInvalidParams,
+				"limited user not authorized for this method",
+				nil,
+			)
+		}
+	}
+	if !permitted(method, u.allow, u.deny) {
+		return btcjson.NewRPCError(
+			btcjson.ErrRPCInvalidParams,
+			"user "+u.name+" not authorized for this method",
+			nil,
+		)
+	}
+	return nil
+}
+
+// hasNotifyRules returns whether the user receives only some websocket
+// notifications.
+func (u *rpcUser) hasNotifyRules() bool {
+	return len(u.allowNotify) > 0 || len(u.denyNotify) > 0
+}
+
+// mayNotify returns whether the user may receive the websocket notification
+// with the given method.
+func (u *rpcUser) mayNotify(method string) bool {
+	return permitted(method, u.allowNotify, u.denyNotify)
+}
+
+// mayNotifyJSON returns whether the user may receive a marshalled websocket
+// notification.
+func (u *rpcUser) mayNotifyJSON(marshalledJSON []byte) bool {
+	if !u.hasNotifyRules() {
+		return true
+	}
+	var ntfn struct {
+		Method string `json:"method"`
+	}
+	if errr := jsoniter.Unmarshal(marshalledJSON, &ntfn); errr != nil {
+		return false
+	}
+	return u.mayNotify(ntfn.Method)
+}
+
+// rpcCredential is a user name and password, which is known by the SHA256 of
+// the HTTP Basic authorization header it results in.
+type rpcCredential struct {
+	authsha [sha256.Size]byte
+	user    *rpcUser
+}
+
+// rpcHashedCredential is a --rpcauth credential.  The password is known only
+// by the HMAC-SHA256 of it keyed by the salt.
+type rpcHashedCredential struct {
+	salt string
+	hmac []byte
+	user *rpcUser
+}
+
+// rpcAuth holds the users of the RPC server and their credentials.
+type rpcAuth struct {
+	users  map[string]*rpcUser
+	creds  []rpcCredential
+	hashed map[string][]rpcHashedCredential
+}
+
+// basicAuthHeader returns the HTTP Basic authorization header for a user name
+// and password.
+func basicAuthHeader(user, pass string) string {
+	login := user + ":" + pass
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+}
+
+// parseRPCAuth parses a --rpcauth entry of the form <user>:<salt>$<hmac>.
+func parseRPCAuth(entry string) (string, string, []byte, er.R) {
+	colon := strings.Index(entry, ":")
+	dollar := strings.LastIndex(entry, "$")
+	if colon < 1 || dollar < colon+2 {
+		return "", "", nil, er.Errorf("invalid rpcauth entry %q, "+
+			"expected <user>:<salt>$<hmac>", entry)
+	}
+	mac, errr := hex.DecodeString(entry[dollar+1:])
+	if errr != nil || len(mac) != sha256.Size {
+		return "", "", nil, er.Errorf("invalid rpcauth entry %q, the "+
+			"hmac must be a hex encoded HMAC-SHA256", entry)
+	}
+	return entry[:colon], entry[colon+1 : dollar], mac, nil
+}
+
+// newRPCAuth returns the RPC users of the configuration along with their
+// credentials and access lists.  The cookie user is known but has no
+// credentials until addPassword is called for it.
+func newRPCAuth(cfg *config) (*rpcAuth, er.R) {
+	a := &rpcAuth{
+		users:  make(map[string]*rpcUser),
+		hashed: make(map[string][]rpcHashedCredential),
+	}
+	a.users[rpcCookieUser] = &rpcUser{name: rpcCookieUser, isAdmin: true}
+	a.users[rpcUnixUser] = &rpcUser{name: rpcUnixUser, isAdmin: true}
+	if cfg.RPCUser != "" && cfg.RPCPass != "" {
+		a.users[cfg.RPCUser] = &rpcUser{name: cfg.RPCUser, isAdmin: true}
+		a.addPassword(cfg.RPCUser, cfg.RPCPass)
+	}
+	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
+		a.users[cfg.RPCLimitUser] = &rpcUser{name: cfg.RPCLimitUser}
+		a.addPassword(cfg.RPCLimitUser, cfg.RPCLimitPass)
+	}
+
+	for _, entry := range cfg.RPCAuth {
+		name, salt, mac, err := parseRPCAuth(entry)
+		if err != nil {
+			return nil, err
+		}
+		user, ok := a.users[name]
+		switch {
+		case !ok:
+			user = &rpcUser{name: name, isAdmin: true}
+			a.users[name] = user
+		case !user.isAdmin || name == rpcCookieUser || name == rpcUnixUser:
+			return nil, er.Errorf("rpcauth user %s is already a "+
+				"limited or built in user", name)
+		}
+		a.hashed[name] = append(a.hashed[name], rpcHashedCredential{
+			salt: salt,
+			hmac: mac,
+			user: user,
+		})
+	}
+
+	lists := []struct {
+		option  string
+		entries []string
+		notify  bool
+		list    func(u *rpcUser) *map[string]struct{}
+	}{
+		{"rpcallow", cfg.RPCAllow, false,
+			func(u *rpcUser) *map[string]struct{} { return &u.allow }},
+		{"rpcdeny", cfg.RPCDeny, false,
+			func(u *rpcUser) *map[string]struct{} { return &u.deny }},
+		{"rpcallownotify", cfg.RPCAllowNotify, true,
+			func(u *rpcUser) *map[string]struct{} { return &u.allowNotify }},
+		{"rpcdenynotify", cfg.RPCDenyNotify, true,
+			func(u *rpcUser) *map[string]struct{} { return &u.denyNotify }},
+	}
+	for _, l := range lists {
+		for _, entry := range l.entries {
+			colon := strings.LastIndex(entry, ":")
+			if colon < 1 || colon == len(entry)-1 {
+				return nil, er.Errorf("invalid %s entry %q, "+
+					"expected <user>:<name>[,<name>...]",
+					l.option, entry)
+			}
+			user, ok := a.users[entry[:colon]]
+			if !ok {
+				return nil, er.Errorf("%s entry %q is for an "+
+					"unknown RPC user", l.option, entry)
+			}
+			list := l.list(user)
+			if *list == nil {
+				*list = make(map[string]struct{})
+			}
+			kind := "method"
+			if l.notify {
+				kind = "notification"
+			}
+			for _, name := range strings.Split(entry[colon+1:], ",") {
+				flags, err := btcjson.MethodUsageFlags(name)
+				isNtfn := err == nil && flags&btcjson.UFNotification != 0
+				if err != nil || isNtfn != l.notify {
+					return nil, er.Errorf("%s entry %q names "+
+						"unknown %s %q", l.option, entry,
+						kind, name)
+				}
+				(*list)[name] = struct{}{}
+			}
+		}
+	}
+
+	return a, nil
+}
+
+// addPassword adds a user name and password credential for a known user.
+func (a *rpcAuth) addPassword(name, pass string) {
+	a.creds = append(a.creds, rpcCredential{
+		authsha: sha256.Sum256([]byte(basicAuthHeader(name, pass))),
+		user:    a.users[name],
+	})
+}
+
+// authenticate returns the user an HTTP Basic authorization header belongs
+// to, or nil if the credentials are not valid.
+//
+// This check is time-constant for the user name and password credentials.
+func (a *rpcAuth) authenticate(header string) *rpcUser {
+	authsha := sha256.Sum256([]byte(header))
+	var user *rpcUser
+	for i := range a.creds {
+		cmp := subtle.ConstantTimeCompare(authsha[:], a.creds[i].authsha[:])
+		if cmp == 1 {
+			user = a.creds[i].user
+		}
+	}
+	if user != nil {
+		return user
+	}
+
+	if !strings.HasPrefix(header, "Basic ") {
+		return nil
+	}
+	login, errr := base64.StdEncoding.DecodeString(header[len("Basic "):])
+	if errr != nil {
+		return nil
+	}
+	up := strings.SplitN(string(login), ":", 2)
+	if len(up) != 2 {
+		return nil
+	}
+	for _, cred := range a.hashed[up[0]] {
+		mac := hmac.New(sha256.New, []byte(cred.salt))
+		mac.Write([]byte(up[1]))
+		if hmac.Equal(mac.Sum(nil), cred.hmac) {
+			return cred.user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// testRPCAuthEntry returns a --rpcauth entry for a user name and password.
+func testRPCAuthEntry(user, salt, pass string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(pass))
+	return user + ":" + salt + "$" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// TestRPCAuth ensures users are authenticated by plain and hashed credentials
+// and that their method and notification access lists are applied.
+func TestRPCAuth(t *testing.T) {
+	auth, err := newRPCAuth(&config{
+		RPCUser:      "admin",
+		RPCPass:      "adminpass",
+		RPCLimitUser: "limited",
+		RPCLimitPass: "limitedpass",
+		RPCAuth: []string{
+			testRPCAuthEntry("explorer", "salt1", "explorerpass"),
+			testRPCAuthEntry("pool", "salt2", "poolpass"),
+		},
+		RPCAllow:       []string{"explorer:getblock,getblockcount"},
+		RPCDeny:        []string{"pool:stop", "limited:uptime"},
+		RPCAllowNotify: []string{"explorer:blockconnected"},
+		RPCDenyNotify:  []string{"pool:txaccepted,txacceptedverbose"},
+	})
+	if err != nil {
+		t.Fatalf("newRPCAuth: %v", err)
+	}
+
+	logins := []struct {
+		user, pass string
+		want       string
+	}{
+		{"admin", "adminpass", "admin"},
+		{"limited", "limitedpass", "limited"},
+		{"explorer", "explorerpass", "explorer"},
+		{"pool", "poolpass", "pool"},
+		{"pool", "explorerpass", ""},
+		{"admin", "limitedpass", ""},
+		{rpcCookieUser, "", ""},
+	}
+	for _, login := range logins {
+		user := auth.authenticate(basicAuthHeader(login.user, login.pass))
+		name := ""
+		if user != nil {
+			name = user.name
+		}
+		if name != login.want {
+			t.Errorf("login %s:%s authenticated as %q, want %q",
+				login.user, login.pass, name, login.want)
+		}
+	}
+
+	calls := []struct {
+		user, method string
+		ok           bool
+	}{
+		{"admin", "stop", true},
+		{"explorer", "getblockcount", true},
+		{"explorer", "getrawmempool", false},
+		{"pool", "getblocktemplate", true},
+		{"pool", "stop", false},
+		{"limited", "getblockcount", true},
+		{"limited", "uptime", false},
+		{"limited", "stop", false},
+	}
+	for _, call := range calls {
+		err := auth.users[call.user].checkMethod(call.method)
+		if (err == nil) != call.ok {
+			t.Errorf("%s calling %s: got err %v, want ok %v", call.user,
+				call.method, err, call.ok)
+		}
+	}
+
+	ntfns := []struct {
+		user, ntfn string
+		ok         bool
+	}{
+		{"admin", `{"method":"txaccepted","params":[]}`, true},
+		{"explorer", `{"method":"blockconnected","params":[]}`, true},
+		{"explorer", `{"method":"blockdisconnected","params":[]}`, false},
+		{"pool", `{"method":"blockconnected","params":[]}`, true},
+		{"pool", `{"method":"txaccepted","params":[]}`, false},
+	}
+	for _, ntfn := range ntfns {
+		ok := auth.users[ntfn.user].mayNotifyJSON([]byte(ntfn.ntfn))
+		if ok != ntfn.ok {
+			t.Errorf("%s sent %s: got %v, want %v", ntfn.user, ntfn.ntfn,
+				ok, ntfn.ok)
+		}
+	}
+
+	invalid := []config{
+		{RPCAuth: []string{"nosalt"}},
+		{RPCAuth: []string{"user:salt$nothex"}},
+		{RPCLimitUser: "limited", RPCLimitPass: "pass",
+			RPCAuth: []string{testRPCAuthEntry("limited", "s", "p")}},
+		{RPCAllow: []string{"nobody:getblock"}},
+		{RPCAllow: []string{rpcCookieUser + ":nosuchmethod"}},
+		{RPCAllow: []string{rpcCookieUser + ":blockconnected"}},
+		{RPCDenyNotify: []string{rpcCookieUser + ":getblock"}},
+	}
+	for i := range invalid {
+		if _, err := newRPCAuth(&invalid[i]); err == nil {
+			t.Errorf("invalid config %d was accepted", i)
+		}
+	}
+}
@@ -251,7 +251,7 @@ func (r FutureGetRawMempoolResult) Receive() ([]*chainhash.Hash, er.R) {
 //
 // See GetRawMempool for the blocking version and more details.
 func (c *Client) GetRawMempoolAsync() FutureGetRawMempoolResult {
-	cmd := btcjson.NewGetRawMempoolCmd(btcjson.Bool(false))
+	cmd := btcjson.NewGetRawMempoolCmd(btcjson.Bool(false), nil)
 	return c.sendCmd(cmd)
 }
 
@@ -263,6 +263,46 @@ func (c *Client) GetRawMempool() ([]*chainhash.Hash, er.R) {
 	return c.GetRawMempoolAsync().Receive()
 }
 
+// FutureGetRawMempoolSequenceResult is a future promise to deliver the result
+// of a GetRawMempoolSequenceAsync RPC invocation (or an applicable error).
+type FutureGetRawMempoolSequenceResult chan *response
+
+// Receive waits for the response promised by the future and returns the hashes
+// of all transactions in the memory pool along with the mempool sequence
+// number.
+func (r FutureGetRawMempoolSequenceResult) Receive() (*btcjson.GetRawMempoolSequenceResult, er.R) {
+	res, err := receiveFuture(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unmarshal the result as a getrawmempool sequence result object.
+	var result btcjson.GetRawMempoolSequenceResult
+	err = er.E(jsoniter.Unmarshal(res, &result))
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetRawMempoolSequenceAsync returns an instance of a type that can be used to
+// get the result of the RPC at some future time by invoking the Receive
+// function on the returned instance.
+//
+// See GetRawMempoolSequence for the blocking version and more details.
+func (c *Client) GetRawMempoolSequenceAsync() FutureGetRawMempoolSequenceResult {
+	cmd := btcjson.NewGetRawMempoolCmd(btcjson.Bool(false), btcjson.Bool(true))
+	return c.sendCmd(cmd)
+}
+
+// GetRawMempoolSequence returns the hashes of all transactions in the memory
+// pool along with the mempool sequence number, which allows the mempoolevent
+// notifications which follow it to be applied.
+func (c *Client) GetRawMempoolSequence() (*btcjson.GetRawMempoolSequenceResult, er.R) {
+	return c.GetRawMempoolSequenceAsync().Receive()
+}
+
 // FutureGetTxOutResult is a future promise to deliver the result of a
 // GetTxOutAsync RPC invocation (or an applicable error).
 type FutureGetTxOutResult chan *response
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcjson"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+)
+
+const (
+	// rpcRateBurstSeconds is the number of seconds worth of calls which
+	// may be made at once when no burst is configured.
+	rpcRateBurstSeconds = 10
+
+	// rpcRateSweepBuckets is the number of buckets above which buckets
+	// which have refilled are forgotten.
+	rpcRateSweepBuckets = 1000
+)
+
+// rpcMethodCosts are the costs of the RPC methods which are more expensive to
+// serve than most, which cost 1.
+var rpcMethodCosts = map[string]float64{
+	"rescan":                50,
+	"rescanblocks":          50,
+	"searchrawtransactions": 20,
+	"submitpackage":         10,
+	"testmempoolaccept":     10,
+	"verifychain":           100,
+}
+
+// rpcCallCost returns the cost of a parsed RPC call, overrides are the costs
+// configured with --rpcmethodcost.
+func rpcCallCost(method string, cmd interface{}, overrides map[string]float64) float64 {
+	if cost, ok := overrides[method]; ok {
+		return cost
+	}
+	if cost, ok := rpcMethodCosts[method]; ok {
+		return cost
+	}
+	if c, ok := cmd.(*btcjson.GetBlockCmd); ok {
+		switch {
+		case c.VerboseTx != nil && *c.VerboseTx:
+			return 10
+		case c.Verbose == nil || *c.Verbose:
+			return 2
+		}
+	}
+	return 1
+}
+
+// rateLimit is the rate at which a token bucket refills and the number of
+// tokens it holds when full.  A zero rate means no limit.
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+// newRateLimit returns a rate limit, defaulting the burst to some seconds
+// worth of the rate.
+func newRateLimit(rate, burst float64) rateLimit {
+	if burst <= 0 {
+		burst = math.Max(rate*rpcRateBurstSeconds, 1)
+	}
+	return rateLimit{rate: rate, burst: burst}
+}
+
+// rpcBucket holds the tokens of one user or source IP as of last.  The
+// tokens may be negative after an expensive call.
+type rpcBucket struct {
+	tokens float64
+	last   time.Time
+	limit  rateLimit
+}
+
+// refill adds the tokens earned since the last refill.
+func (b *rpcBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.limit.rate
+	if b.tokens > b.limit.burst {
+		b.tokens = b.limit.burst
+	}
+	b.last = now
+}
+
+// wait returns how long until the bucket can pay for a call costing cost.  A
+// call costing more than the burst may be made when the bucket is full.
+func (b *rpcBucket) wait(cost float64) time.Duration {
+	need := math.Min(cost, b.limit.burst)
+	if b.tokens >= need {
+		return 0
+	}
+	secs := (need - b.tokens) / b.limit.rate
+	return time.Duration(math.Ceil(secs*1000)) * time.Millisecond
+}
+
+// rpcRateLimiter limits the cost of the RPC calls made by each user and from
+// each source IP address with token buckets.
+type rpcRateLimiter struct {
+	mtx        sync.Mutex
+	user       rateLimit
+	ip         rateLimit
+	userLimits map[string]rateLimit
+	costs      map[string]float64
+	buckets    map[string]*rpcBucket
+	now        func() time.Time
+}
+
+// newRPCRateLimiter returns the rate limiter of the configuration.
+func newRPCRateLimiter(cfg *config) (*rpcRateLimiter, er.R) {
+	l := &rpcRateLimiter{
+		user:       newRateLimit(cfg.RPCUserRate, cfg.RPCUserBurst),
+		ip:         newRateLimit(cfg.RPCIPRate, cfg.RPCIPBurst),
+		userLimits: make(map[string]rateLimit),
+		costs:      make(map[string]float64),
+		buckets:    make(map[string]*rpcBucket),
+		now:        time.Now,
+	}
+	if cfg.RPCUserRate < 0 || cfg.RPCIPRate < 0 {
+		return nil, er.New("RPC rate limits may not be negative")
+	}
+
+	for _, entry := range cfg.RPCUserLimit {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, er.Errorf("invalid rpcuserlimit entry %q, "+
+				"expected <user>:<rate>[:<burst>]", entry)
+		}
+		var limit [2]float64
+		for i, part := range parts[1:] {
+			v, errr := strconv.ParseFloat(part, 64)
+			if errr != nil || v < 0 {
+				return nil, er.Errorf("invalid rpcuserlimit entry "+
+					"%q, the rate and burst must be numbers "+
+					"which are not negative", entry)
+			}
+			limit[i] = v
+		}
+		l.userLimits[parts[0]] = newRateLimit(limit[0], limit[1])
+	}
+
+	for _, entry := range cfg.RPCMethodCost {
+		colon := strings.LastIndex(entry, ":")
+		if colon < 1 {
+			return nil, er.Errorf("invalid rpcmethodcost entry %q, "+
+				"expected <method>:<cost>", entry)
+		}
+		method := entry[:colon]
+		if _, err := btcjson.MethodUsageFlags(method); err != nil {
+			return nil, er.Errorf("rpcmethodcost entry %q names "+
+				"unknown method %q", entry, method)
+		}
+		cost, errr := strconv.ParseFloat(entry[colon+1:], 64)
+		if errr != nil || cost < 0 {
+			return nil, er.Errorf("invalid rpcmethodcost entry %q, "+
+				"the cost must be a number which is not negative",
+				entry)
+		}
+		l.costs[method] = cost
+	}
+
+	return l, nil
+}
+
+// bucket returns the token bucket for key, creating a full one if there is
+// none.  It must be called with the mutex held.
+func (l *rpcRateLimiter) bucket(key string, limit rateLimit, now time.Time) *rpcBucket {
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rpcBucket{tokens: limit.burst, last: now, limit: limit}
+		l.buckets[key] = b
+	}
+	b.refill(now)
+	return b
+}
+
+// sweep forgets the buckets which have refilled, they are the same as new
+// ones.  It must be called with the mutex held.
+func (l *rpcRateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.limit.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// take charges a call costing cost to the user and the source address.  If
+// either can not pay for it, nothing is charged and the time to wait before
+// retrying is returned.  An address which is not an IP, such as that of a Unix
+// domain socket client, is not limited.
+func (l *rpcRateLimiter) take(user, remoteAddr string, cost float64) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	userLimit := l.user
+	if limit, ok := l.userLimits[user]; ok {
+		userLimit = limit
+	}
+	host, _, errr := net.SplitHostPort(remoteAddr)
+	if errr != nil {
+		host = remoteAddr
+	}
+	ipLimited := l.ip.rate > 0 && net.ParseIP(host) != nil
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := l.now()
+	if len(l.buckets) > rpcRateSweepBuckets {
+		l.sweep(now)
+	}
+	var charge []*rpcBucket
+	var wait time.Duration
+	if userLimit.rate > 0 {
+		b := l.bucket("user:"+user, userLimit, now)
+		wait = b.wait(cost)
+		charge = append(charge, b)
+	}
+	if ipLimited {
+		b := l.bucket("ip:"+host, l.ip, now)
+		if w := b.wait(cost); w > wait {
+			wait = w
+		}
+		charge = append(charge, b)
+	}
+	if wait > 0 {
+		return wait
+	}
+	for _, b := range charge {
+		b.tokens -= cost
+	}
+	return 0
+}
+
+// check charges a parsed call to the user and source address, it returns an
+// RPC error carrying a retry hint if they are over their limit.
+func (l *rpcRateLimiter) check(user, remoteAddr string, cmd *parsedRPCCmd) er.R {
+	if l == nil {
+		return nil
+	}
+	cost := rpcCallCost(cmd.method, cmd.cmd, l.costs)
+	wait := l.take(user, remoteAddr, cost)
+	if wait == 0 {
+		return nil
+	}
+	return btcjson.NewRPCError(btcjson.ErrRPCRateLimited,
+		fmt.Sprintf("rate limit exceeded, retry after %.3f seconds",
+			wait.Seconds()), nil)
+}
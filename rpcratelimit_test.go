@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/btcjson"
+)
+
+// TestRPCRateLimiter ensures calls are charged to both the user and the source
+// IP, that a limited caller is told how long to wait, and that per user limits
+// and method costs are applied.
+func TestRPCRateLimiter(t *testing.T) {
+	l, err := newRPCRateLimiter(&config{
+		RPCUserRate:   1,
+		RPCUserBurst:  5,
+		RPCIPRate:     2,
+		RPCIPBurst:    8,
+		RPCUserLimit:  []string{"pool:0"},
+		RPCMethodCost: []string{"getblockcount:3"},
+	})
+	if err != nil {
+		t.Fatalf("newRPCRateLimiter: %v", err)
+	}
+	now := time.Unix(1600000000, 0)
+	l.now = func() time.Time { return now }
+
+	// The explorer user may spend its burst of 5 and then has to wait a
+	// second for each further call.
+	for i := 0; i < 5; i++ {
+		if wait := l.take("explorer", "10.0.0.1:1000", 1); wait != 0 {
+			t.Fatalf("call %d waits %v", i, wait)
+		}
+	}
+	if wait := l.take("explorer", "10.0.0.1:1000", 1); wait != time.Second {
+		t.Fatalf("over the limit call waits %v, want 1s", wait)
+	}
+
+	// The pool user is not limited, but its calls from the same IP are,
+	// since that IP has spent 5 of its 8.
+	for i := 0; i < 3; i++ {
+		if wait := l.take("pool", "10.0.0.1:1001", 1); wait != 0 {
+			t.Fatalf("pool call %d waits %v", i, wait)
+		}
+	}
+	if wait := l.take("pool", "10.0.0.1:1002", 1); wait != 500*time.Millisecond {
+		t.Fatalf("call over the IP limit waits %v, want 500ms", wait)
+	}
+	if wait := l.take("pool", "10.0.0.2:1000", 1); wait != 0 {
+		t.Fatalf("call from another IP waits %v", wait)
+	}
+	if wait := l.take("pool", "", 1); wait != 0 {
+		t.Fatalf("call from the Unix socket waits %v", wait)
+	}
+
+	// A call costing more than the burst is allowed with a full bucket and
+	// leaves the caller in debt.
+	now = now.Add(time.Minute)
+	cmd := &parsedRPCCmd{method: "verifychain", cmd: &btcjson.VerifyChainCmd{}}
+	if err := l.check("explorer", "10.0.0.3:1000", cmd); err != nil {
+		t.Fatalf("expensive call with a full bucket: %v", err)
+	}
+	err = l.check("explorer", "10.0.0.3:1000", cmd)
+	if !btcjson.ErrRPCRateLimited.Is(err) {
+		t.Fatalf("expensive call in debt: got %v", err)
+	}
+
+	costs := []struct {
+		method string
+		cmd    interface{}
+		cost   float64
+	}{
+		{"getblockcount", nil, 3},
+		{"searchrawtransactions", nil, 20},
+		{"getblock", btcjson.NewGetBlockCmd("", btcjson.Bool(false), nil), 1},
+		{"getblock", btcjson.NewGetBlockCmd("", nil, nil), 2},
+		{"getblock", btcjson.NewGetBlockCmd("", btcjson.Bool(true),
+			btcjson.Bool(true)), 10},
+		{"getbestblockhash", nil, 1},
+	}
+	for _, c := range costs {
+		if cost := rpcCallCost(c.method, c.cmd, l.costs); cost != c.cost {
+			t.Errorf("%s costs %v, want %v", c.method, cost, c.cost)
+		}
+	}
+
+	invalid := []config{
+		{RPCUserRate: -1},
+		{RPCUserLimit: []string{"pool"}},
+		{RPCUserLimit: []string{"pool:x"}},
+		{RPCMethodCost: []string{"nosuchmethod:5"}},
+		{RPCMethodCost: []string{"getblock:-1"}},
+	}
+	for i := range invalid {
+		if _, err := newRPCRateLimiter(&invalid[i]); err == nil {
+			t.Errorf("invalid config %d was accepted", i)
+		}
+	}
+}
@@ -8,8 +8,8 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"crypto/subtle"
+	"context"
+	crand "crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
 	"fmt"
@@ -20,6 +20,8 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -40,6 +42,7 @@ import (
 	"github.com/pkt-cash/PKT-FullNode/chaincfg"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg/globalcfg"
+	"github.com/pkt-cash/PKT-FullNode/connmgr/banmgr"
 	"github.com/pkt-cash/PKT-FullNode/database"
 	"github.com/pkt-cash/PKT-FullNode/mempool"
 	"github.com/pkt-cash/PKT-FullNode/mining"
@@ -118,6 +121,7 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
 	"addnode":                handleAddNode,
+	"clearbanned":            handleClearBanned,
 	"configureminingpayouts": handleConfigureMiningPayouts,
 	"createrawtransaction":   handleCreateRawTransaction,
 	"debuglevel":             handleDebugLevel,
@@ -144,9 +148,11 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"gethashespersec":        handleGetHashesPerSec,
 	"getheaders":             handleGetHeaders,
 	"getinfo":                handleGetInfo,
+	"getmempoolfeehistogram": handleGetMempoolFeeHistogram,
 	"getmempoolinfo":         handleGetMempoolInfo,
 	"getmininginfo":          handleGetMiningInfo,
 	"getminingpayouts":       handleGetMiningPayouts,
+	"getnetmsgstats":         handleGetNetMsgStats,
 	"getnettotals":           handleGetNetTotals,
 	"getnetworkhashps":       handleGetNetworkHashPS,
 	"getnetworkinfo":         handleGetNetworkInfo,
@@ -159,14 +165,21 @@ var rpcHandlersBeforeInit = map[string]commandHandler{
 	"getrawtransaction":      handleGetRawTransaction,
 	"gettxout":               handleGetTxOut,
 	"help":                   handleHelp,
+	"listbanned":             handleListBanned,
+	"loadmempool":            handleLoadMempool,
 	"node":                   handleNode,
 	"ping":                   handlePing,
 	"echo":                   handleEcho,
+	"prioritisetransaction":  handlePrioritiseTransaction,
+	"savemempool":            handleSaveMempool,
 	"searchrawtransactions":  handleSearchRawTransactions,
 	"sendrawtransaction":     handleSendRawTransaction,
+	"setban":                 handleSetBan,
 	"setgenerate":            handleSetGenerate,
 	"stop":                   handleStop,
 	"submitblock":            handleSubmitBlock,
+	"submitpackage":          handleSubmitPackage,
+	"testmempoolaccept":      handleTestMempoolAccept,
 	"uptime":                 handleUptime,
 	"validateaddress":        handleValidateAddress,
 	"verifychain":            handleVerifyChain,
@@ -222,47 +235,54 @@ var rpcUnimplemented = map[string]struct{}{
 // Commands that are available to a limited user
 var rpcLimited = map[string]struct{}{
 	// Websockets commands
-	"loadtxfilter":          {},
-	"notifyblocks":          {},
-	"notifynewtransactions": {},
-	"notifyreceived":        {},
-	"notifyspent":           {},
-	"rescan":                {},
-	"rescanblocks":          {},
-	"session":               {},
+	"loadtxfilter":            {},
+	"notifyblocks":            {},
+	"notifychainevents":       {},
+	"notifymempoolevents":     {},
+	"notifynewtransactions":   {},
+	"notifyreceived":          {},
+	"notifyspent":             {},
+	"rescan":                  {},
+	"rescanblocks":            {},
+	"session":                 {},
+	"stopnotifychainevents":   {},
+	"stopnotifymempoolevents": {},
 
 	// Websockets AND HTTP/S commands
 	"help": {},
 
 	// HTTP/S-only commands
-	"createrawtransaction":  {},
-	"decoderawtransaction":  {},
-	"decodescript":          {},
-	"estimatefee":           {},
-	"getbestblock":          {},
-	"getbestblockhash":      {},
-	"getblock":              {},
-	"getblockcount":         {},
-	"getblockhash":          {},
-	"getblockheader":        {},
-	"getcfilter":            {},
-	"getcfilterheader":      {},
-	"getcurrentnet":         {},
-	"getdifficulty":         {},
-	"getheaders":            {},
-	"getinfo":               {},
-	"getnettotals":          {},
-	"getnetworkhashps":      {},
-	"getrawmempool":         {},
-	"getrawtransaction":     {},
-	"gettxout":              {},
-	"searchrawtransactions": {},
-	"sendrawtransaction":    {},
-	"submitblock":           {},
-	"uptime":                {},
-	"validateaddress":       {},
-	"verifymessage":         {},
-	"version":               {},
+	"createrawtransaction":   {},
+	"decoderawtransaction":   {},
+	"decodescript":           {},
+	"estimatefee":            {},
+	"getbestblock":           {},
+	"getbestblockhash":       {},
+	"getblock":               {},
+	"getblockcount":          {},
+	"getblockhash":           {},
+	"getblockheader":         {},
+	"getcfilter":             {},
+	"getcfilterheader":       {},
+	"getcurrentnet":          {},
+	"getdifficulty":          {},
+	"getheaders":             {},
+	"getinfo":                {},
+	"getmempoolfeehistogram": {},
+	"getnettotals":           {},
+	"getnetworkhashps":       {},
+	"getrawmempool":          {},
+	"getrawtransaction":      {},
+	"gettxout":               {},
+	"searchrawtransactions":  {},
+	"sendrawtransaction":     {},
+	"submitblock":            {},
+	"submitpackage":          {},
+	"testmempoolaccept":      {},
+	"uptime":                 {},
+	"validateaddress":        {},
+	"verifymessage":          {},
+	"version":                {},
 }
 
 // builderScript is a convenience function which is used for hard-coded scripts
@@ -481,6 +501,97 @@ func handleNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handleSetBan handles setban commands.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	var err er.R
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// A ban time of zero uses the default ban duration.
+		var until time.Time
+		if c.BanTime != nil && *c.BanTime > 0 {
+			if c.Absolute != nil && *c.Absolute {
+				until = time.Unix(*c.BanTime, 0)
+				if !until.After(time.Now()) {
+					return nil, btcjson.NewRPCError(
+						btcjson.ErrRPCInvalidParameter,
+						"absolute ban time is in the past",
+						nil,
+					)
+				}
+			} else {
+				until = time.Now().Add(
+					time.Duration(*c.BanTime) * time.Second)
+			}
+		}
+		err = s.cfg.ConnMgr.SetBan(c.Subnet, until)
+	case btcjson.SBRemove:
+		err = s.cfg.ConnMgr.Unban(c.Subnet)
+	default:
+		return nil, btcjson.NewRPCError(
+			btcjson.ErrRPCInvalidParameter,
+			"invalid subcommand for setban",
+			nil,
+		)
+	}
+
+	if err != nil {
+		return nil, btcjson.NewRPCError(
+			btcjson.ErrRPCInvalidParameter,
+			"",
+			err,
+		)
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	bans := s.cfg.ConnMgr.BannedPeers()
+	sort.Slice(bans, func(i, j int) bool {
+		return bans[i].Addr < bans[j].Addr
+	})
+
+	now := time.Now()
+	reply := make([]btcjson.ListBannedResult, 0, len(bans))
+	for _, ban := range bans {
+		reply = append(reply, btcjson.ListBannedResult{
+			Address:       ban.Addr,
+			Reason:        ban.Reason,
+			BanScore:      ban.BanScore,
+			BanCreated:    ban.BanCreatedTime.Unix(),
+			BannedUntil:   ban.BanExpiresTime.Unix(),
+			BanDuration:   int64(ban.BanExpiresTime.Sub(ban.BanCreatedTime) / time.Second),
+			TimeRemaining: int64(ban.BanExpiresTime.Sub(now) / time.Second),
+		})
+	}
+	return reply, nil
+}
+
+// handleLoadMempool implements the loadmempool command.
+func handleLoadMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	p := s.cfg.MempoolPersist
+	loaded, failed, err := p.load(closeChan)
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc,
+			"Unable to load the mempool: "+err.Message(), nil)
+	}
+	return &btcjson.LoadMempoolResult{
+		Filename: p.path,
+		Loaded:   loaded,
+		Failed:   failed,
+	}, nil
+}
+
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	s.cfg.ConnMgr.ClearBanned()
+	return nil, nil
+}
+
 // peerExists determines if a certain peer is currently connected given
 // information about all currently connected peers. Peer existence is
 // determined using either a target address or node id.
@@ -841,6 +952,24 @@ func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	c := cmd.(*btcjson.EstimateSmartFeeCmd)
 
+	// The mempool mode estimates the fee from the blocks the transactions
+	// of the mempool are expected to be mined in, so it works without the
+	// fee estimator.
+	if c.EstimateMode != nil && *c.EstimateMode == btcjson.EstimateModeMempool {
+		if c.ConfTarget <= 0 {
+			return -1.0, er.New("Parameter NumBlocks must be positive")
+		}
+		maxBlockSize := int64(cfg.BlockMaxWeight) / blockchain.WitnessScaleFactor
+		poolFeeRate := s.cfg.TxMemPool.EstimateFeeFromPool(
+			uint32(c.ConfTarget), maxBlockSize)
+		if s.cfg.FeeEstimator == nil {
+			feeRate := float64(poolFeeRate)
+			return btcjson.EstimateSmartFeeResult{FeeRate: &feeRate}, nil
+		}
+		return s.cfg.FeeEstimator.EstimateSmartFeeWithPool(
+			uint32(c.ConfTarget), poolFeeRate), nil
+	}
+
 	if s.cfg.FeeEstimator == nil {
 		return nil, er.New("Fee estimation disabled")
 	}
@@ -2510,6 +2639,11 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 	return ret, nil
 }
 
+// handleGetMempoolFeeHistogram implements the getmempoolfeehistogram command.
+func handleGetMempoolFeeHistogram(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	return s.cfg.TxMemPool.FeeHistogram(), nil
+}
+
 // handleGetMempoolInfo implements the getmempoolinfo command.
 func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	mempoolTxns := s.cfg.TxMemPool.TxDescs()
@@ -2520,8 +2654,9 @@ func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct
 	}
 
 	ret := &btcjson.GetMempoolInfoResult{
-		Size:  int64(len(mempoolTxns)),
-		Bytes: numBytes,
+		Size:          int64(len(mempoolTxns)),
+		Bytes:         numBytes,
+		MempoolMinFee: s.cfg.TxMemPool.MinFee().ToBTC(),
 	}
 
 	return ret, nil
@@ -2623,13 +2758,67 @@ func handleGetMiningPayouts(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return m, nil
 }
 
+// netMsgStatsResult converts message stats to their JSON-RPC representation.
+func netMsgStatsResult(stats map[string]msgStat) map[string]btcjson.NetMsgStat {
+	result := make(map[string]btcjson.NetMsgStat, len(stats))
+	for cmd, stat := range stats {
+		result[cmd] = btcjson.NetMsgStat{
+			Bytes: stat.Bytes,
+			Count: stat.Count,
+		}
+	}
+	return result
+}
+
+// handleGetNetMsgStats implements the getnetmsgstats command.
+func handleGetNetMsgStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	c := cmd.(*btcjson.GetNetMsgStatsCmd)
+
+	var sent, recv map[string]msgStat
+	if c.PeerID == nil {
+		sent, recv = s.cfg.ConnMgr.MsgStats()
+	} else {
+		found := false
+		for _, p := range s.cfg.ConnMgr.ConnectedPeers() {
+			if p.ToPeer().ID() == *c.PeerID {
+				sent, recv = p.MsgStats()
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, btcjson.NewRPCError(
+				btcjson.ErrRPCInvalidParameter,
+				fmt.Sprintf("no connected peer with id %d", *c.PeerID),
+				nil,
+			)
+		}
+	}
+
+	return &btcjson.GetNetMsgStatsResult{
+		Sent: netMsgStatsResult(sent),
+		Recv: netMsgStatsResult(recv),
+	}, nil
+}
+
 // handleGetNetTotals implements the getnettotals command.
 func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	totalBytesRecv, totalBytesSent := s.cfg.ConnMgr.NetTotals()
+	uploadTarget := s.cfg.ConnMgr.UploadTarget()
 	reply := &btcjson.GetNetTotalsResult{
 		TotalBytesRecv: totalBytesRecv,
 		TotalBytesSent: totalBytesSent,
 		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		UploadTarget: btcjson.GetNetTotalsUploadTargetResult{
+			TimeFrame:             int64(uploadTargetTimeframe / time.Second),
+			Target:                uploadTarget.Target,
+			TargetReached:         uploadTarget.TargetReached,
+			ServeHistoricalBlocks: uploadTarget.ServeHistorical,
+			BytesLeftInCycle:      uploadTarget.BytesLeft,
+			TimeLeftInCycle:       int64(uploadTarget.TimeLeft / time.Second),
+			MaxUploadRate:         cfg.MaxUploadRate * 1024,
+			MaxPeerUploadRate:     cfg.MaxPeerUploadRate * 1024,
+		},
 	}
 	return reply, nil
 }
@@ -2764,6 +2953,17 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BanScore:       int32(p.BanScore()),
 			FeeFilter:      p.FeeFilter(),
 			SyncNode:       statsSnap.ID == syncPeerID,
+			TransportType:  statsSnap.TransportType,
+			SessionID:      hex.EncodeToString(statsSnap.SessionID),
+		}
+		sent, recv := p.MsgStats()
+		info.BytesSentPerMsg = make(map[string]uint64, len(sent))
+		for cmd, stat := range sent {
+			info.BytesSentPerMsg[cmd] = stat.Bytes
+		}
+		info.BytesRecvPerMsg = make(map[string]uint64, len(recv))
+		for cmd, stat := range recv {
+			info.BytesRecvPerMsg[cmd] = stat.Bytes
 		}
 		if p.ToPeer().LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2779,11 +2979,34 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 func handleGetRawMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	c := cmd.(*btcjson.GetRawMempoolCmd)
 	mp := s.cfg.TxMemPool
+	verbose := c.Verbose != nil && *c.Verbose
+	withSequence := c.MempoolSequence != nil && *c.MempoolSequence
 
-	if c.Verbose != nil && *c.Verbose {
+	if verbose && withSequence {
+		return nil, btcjson.NewRPCError(
+			btcjson.ErrRPCInvalidParameter,
+			"verbose results can not contain mempoolsequence values",
+			nil,
+		)
+	}
+	if verbose {
 		return mp.RawMempoolVerbose(), nil
 	}
 
+	// Return the hashes along with the sequence number of the pool they
+	// were taken at so the client can apply the mempool events after it.
+	if withSequence {
+		descs, sequence := mp.TxDescsWithSequence()
+		result := &btcjson.GetRawMempoolSequenceResult{
+			TxIDs:           make([]string, len(descs)),
+			MempoolSequence: sequence,
+		}
+		for i := range descs {
+			result.TxIDs[i] = descs[i].Tx.Hash().String()
+		}
+		return result, nil
+	}
+
 	// The response is simply an array of the transaction hashes if the
 	// verbose flag is not set.
 	descs := mp.TxDescs()
@@ -3066,6 +3289,18 @@ func handlePing(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (inter
 	return nil, nil
 }
 
+// handlePrioritiseTransaction implements the prioritisetransaction command.
+func handlePrioritiseTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	c := cmd.(*btcjson.PrioritiseTransactionCmd)
+
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+	s.cfg.TxMemPool.PrioritiseTransaction(txHash, c.FeeDelta)
+	return true, nil
+}
+
 func handleEcho(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	c := cmd.(*btcjson.EchoCmd)
 	var out []string
@@ -3339,6 +3574,20 @@ func fetchMempoolTxnsForAddress(s *rpcServer, addr btcutil.Address, numToSkip, n
 	return mpTxns[numToSkip:rangeEnd], numToSkip
 }
 
+// handleSaveMempool implements the savemempool command.
+func handleSaveMempool(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	p := s.cfg.MempoolPersist
+	n, err := p.save()
+	if err != nil {
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCMisc,
+			"Unable to save the mempool: "+err.Message(), nil)
+	}
+	return &btcjson.SaveMempoolResult{
+		Filename: p.path,
+		Size:     n,
+	}, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	// Respond with an error if the address index is not enabled.
@@ -3660,7 +3909,8 @@ func handleSendRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan st
 	// Also, since an error is being returned to the caller, ensure the
 	// transaction is removed from the memory pool.
 	if len(acceptedTxs) == 0 || !acceptedTxs[0].Tx.Hash().IsEqual(tx.Hash()) {
-		s.cfg.TxMemPool.RemoveTransaction(tx, true)
+		s.cfg.TxMemPool.RemoveTransaction(tx, true,
+			mempool.RemovalConflict)
 
 		err := er.Errorf("transaction %v is not in accepted list", tx.Hash())
 		return nil, internalRPCError(err, "")
@@ -3763,6 +4013,130 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return nil, nil
 }
 
+// handleSubmitPackage implements the submitpackage command.
+func handleSubmitPackage(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	c := cmd.(*btcjson.SubmitPackageCmd)
+	txns, err := decodeRawTxs(c.RawTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Use 0 for the tag to represent local node.  Transactions may have
+	// been accepted even when the package as a whole was rejected.
+	reply := &btcjson.SubmitPackageResult{PackageMsg: "success"}
+	acceptedTxs, err := s.cfg.TxMemPool.ProcessPackage(txns, 0)
+	if err != nil {
+		if ruleerror.Err.Decode(err) == nil {
+			log.Errorf("Failed to process package: %v", err)
+			return nil, btcjson.NewRPCError(
+				btcjson.ErrRPCTxError, "Package processing failed", err)
+		}
+		log.Debugf("Rejected package: %v", err)
+		reply.PackageMsg = err.Message()
+	}
+
+	// Relay and notify the newly accepted transactions, and rebroadcast
+	// the ones of the package if they don't make their way into a block.
+	if len(acceptedTxs) > 0 {
+		s.cfg.ConnMgr.RelayTransactions(acceptedTxs)
+		s.NotifyNewTransactions(acceptedTxs)
+	}
+	for _, tx := range txns {
+		r := btcjson.SubmitPackageTxResult{
+			TxID:  tx.Hash().String(),
+			WTxID: tx.MsgTx().WitnessHash().String(),
+		}
+		txD, err := s.cfg.TxMemPool.FetchTxDesc(tx.Hash())
+		switch {
+		case err == nil:
+			r.VSize = mempool.GetTxVirtualSize(tx)
+			r.Fees = &btcjson.TestMempoolAcceptFees{
+				Base: btcutil.Amount(txD.Fee).ToBTC(),
+			}
+			iv := wire.NewInvVect(wire.InvTypeTx, tx.Hash())
+			s.cfg.ConnMgr.AddRebroadcastInventory(iv, txD)
+		case s.cfg.TxMemPool.IsOrphanInPool(tx.Hash()):
+			r.Error = "missing-inputs"
+		default:
+			r.Error = "not accepted"
+		}
+		reply.TxResults = append(reply.TxResults, r)
+	}
+	return reply, nil
+}
+
+// decodeRawTxs deserializes the hex-encoded transactions of a package.
+func decodeRawTxs(rawTxs []string) ([]*btcutil.Tx, er.R) {
+	if len(rawTxs) == 0 || len(rawTxs) > mempool.MaxPackageCount {
+		str := fmt.Sprintf("Array must contain between 1 and %d "+
+			"transactions", mempool.MaxPackageCount)
+		return nil, btcjson.NewRPCError(btcjson.ErrRPCInvalidParameter,
+			str, nil)
+	}
+
+	txns := make([]*btcutil.Tx, 0, len(rawTxs))
+	for _, hexStr := range rawTxs {
+		if len(hexStr)%2 != 0 {
+			hexStr = "0" + hexStr
+		}
+		serializedTx, errr := hex.DecodeString(hexStr)
+		if errr != nil {
+			return nil, rpcDecodeHexError(hexStr)
+		}
+		var msgTx wire.MsgTx
+		err := msgTx.Deserialize(bytes.NewReader(serializedTx))
+		if err != nil {
+			return nil, btcjson.NewRPCError(
+				btcjson.ErrRPCDeserialization, "TX decode failed", err)
+		}
+		txns = append(txns, btcutil.NewTx(&msgTx))
+	}
+	return txns, nil
+}
+
+// handleTestMempoolAccept implements the testmempoolaccept command.
+func handleTestMempoolAccept(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
+	c := cmd.(*btcjson.TestMempoolAcceptCmd)
+
+	var maxFeeRate btcutil.Amount
+	if c.MaxFeeRate != nil {
+		var err er.R
+		maxFeeRate, err = btcutil.NewAmount(*c.MaxFeeRate)
+		if err != nil || maxFeeRate < 0 {
+			return nil, btcjson.NewRPCError(
+				btcjson.ErrRPCInvalidParameter,
+				"Invalid maxfeerate", err)
+		}
+	}
+	txns, err := decodeRawTxs(c.RawTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Check the transactions without adding them to the pool.  A max fee
+	// rate of zero allows any fee.
+	results := s.cfg.TxMemPool.TestAccept(txns, maxFeeRate)
+	reply := make([]btcjson.TestMempoolAcceptResult, 0, len(results))
+	for _, result := range results {
+		r := btcjson.TestMempoolAcceptResult{
+			TxID:  result.Tx.Hash().String(),
+			WTxID: result.Tx.MsgTx().WitnessHash().String(),
+		}
+		switch {
+		case result.Err != nil:
+			r.RejectReason = result.Err.Message()
+		default:
+			r.Allowed = true
+			r.VSize = result.Size
+			r.Fees = &btcjson.TestMempoolAcceptFees{
+				Base: btcutil.Amount(result.Fee).ToBTC(),
+			}
+		}
+		reply = append(reply, r)
+	}
+	return reply, nil
+}
+
 // handleUptime implements the uptime command.
 func handleUptime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, er.R) {
 	return time.Now().Unix() - s.cfg.StartupTime, nil
@@ -3922,8 +4296,8 @@ type rpcServer struct {
 	started                int32
 	shutdown               int32
 	cfg                    rpcserverConfig
-	authsha                [sha256.Size]byte
-	limitauthsha           [sha256.Size]byte
+	auth                   *rpcAuth
+	rateLimiter            *rpcRateLimiter
 	ntfnMgr                *wsNotificationManager
 	numClients             int32
 	statusLines            map[int]string
@@ -3935,6 +4309,7 @@ type rpcServer struct {
 	quit                   chan int
 	reqNum                 int64
 	reqCompl               int64
+	callStats              *rpcCallStats
 }
 
 // httpStatusLine returns a response Status-Line (RFC 2616 Section 6.1)
@@ -4010,6 +4385,7 @@ func (s *rpcServer) Stop() er.R {
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
 	s.wg.Wait()
+	removeRPCCookie(cfg.DataDir)
 	log.Infof("RPC server shutdown complete")
 	return nil
 }
@@ -4071,45 +4447,34 @@ func (s *rpcServer) decrementClients() {
 
 // checkAuth checks the HTTP Basic authentication supplied by a wallet
 // or RPC client in the HTTP request r.  If the supplied authentication
-// does not match the username and password expected, a non-nil error is
-// returned.
+// does not match the credentials of any user, a non-nil error is returned.
 //
-// This check is time-constant.
-//
-// The first bool return value signifies auth success (true if successful) and
-// the second bool return value specifies whether the user can change the state
-// of the server (true) or whether the user is limited (false). The second is
-// always false if the first is.
-func (s *rpcServer) checkAuth(r *http.Request, require bool) (bool, bool, er.R) {
+// The user the credentials belong to is returned.  It is nil when no
+// authentication was supplied and it is not required.
+func (s *rpcServer) checkAuth(r *http.Request, require bool) (*rpcUser, er.R) {
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) <= 0 {
+		// Only the owner of the process can connect to the Unix
+		// socket, so those connections need no credentials.
+		if r.Context().Value(rpcUnixConnKey{}) != nil {
+			return s.auth.users[rpcUnixUser], nil
+		}
 		if require {
 			log.Warnf("RPC authentication failure from %s",
 				r.RemoteAddr)
-			return false, false, er.New("auth failure")
+			return nil, er.New("auth failure")
 		}
 
-		return false, false, nil
-	}
-
-	authsha := sha256.Sum256([]byte(authhdr[0]))
-
-	// Check for limited auth first as in environments with limited users, those
-	// are probably expected to have a higher volume of calls
-	limitcmp := subtle.ConstantTimeCompare(authsha[:], s.limitauthsha[:])
-	if limitcmp == 1 {
-		return true, false, nil
+		return nil, nil
 	}
 
-	// Check for admin-level auth
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp == 1 {
-		return true, true, nil
+	if user := s.auth.authenticate(authhdr[0]); user != nil {
+		return user, nil
 	}
 
-	// Request's auth doesn't match either user
+	// Request's auth doesn't match any user
 	log.Warnf("RPC authentication failure from %s", r.RemoteAddr)
-	return false, false, er.New("auth failure")
+	return nil, er.New("auth failure")
 }
 
 // parsedRPCCmd represents a JSON-RPC request object that has been parsed into
@@ -4144,7 +4509,10 @@ func (s *rpcServer) standardCmdResult(cmd *parsedRPCCmd, closeChan <-chan struct
 	return nil, btcjson.NewRPCError(btcjson.ErrRPCMethodNotFound, "Method not found", nil)
 handled:
 
-	return handler(s, cmd.cmd, closeChan)
+	start := time.Now()
+	result, err := handler(s, cmd.cmd, closeChan)
+	s.callStats.observe(cmd.method, time.Since(start), err != nil)
+	return result, err
 }
 
 // parseCmd parses a JSON-RPC request object into known concrete command.  The
@@ -4181,33 +4549,39 @@ func createMarshalledReply(id, result interface{}, jsonErr er.R) ([]byte, er.R)
 	return btcjson.MarshalResponse(id, result, jsonErr)
 }
 
-func createResponse(id, result interface{}, jsonErr er.R) (*btcjson.Response, er.R) {
+// createResponse returns the response object for a request with the given id.
+// In strict mode it is a JSON-RPC 2.0 response.
+func createResponse(id, result interface{}, jsonErr er.R, strict bool) (interface{}, er.R) {
 	marshalledResult, errr := jsoniter.Marshal(result)
 	if errr != nil {
 		return nil, er.E(errr)
 	}
-	return btcjson.NewResponse(id, marshalledResult, jsonErr)
+	if strict {
+		resp, err := btcjson.NewResponseV2(id, marshalledResult, jsonErr)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+	resp, err := btcjson.NewResponse(id, marshalledResult, jsonErr)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
 }
 
 func (s *rpcServer) jsonRPCReq(
 	request *btcjson.Request,
-	closeChan chan struct{},
-	isAdmin bool,
-) (*btcjson.Response, er.R) {
+	closeChan <-chan struct{},
+	user *rpcUser,
+	remoteAddr string,
+	strict bool,
+) (interface{}, er.R) {
 
-	var jsonErr er.R
 	var result interface{}
 
-	// Check if the user is limited and set error if method unauthorized
-	if !isAdmin {
-		if _, ok := rpcLimited[request.Method]; !ok {
-			jsonErr = btcjson.NewRPCError(
-				btcjson.ErrRPCInvalidParams,
-				"limited user not authorized for this method",
-				nil,
-			)
-		}
-	}
+	// Set an error if the user may not call the method
+	jsonErr := user.checkMethod(request.Method)
 
 	ps := make([]string, 0, len(request.Params))
 	for _, par := range request.Params {
@@ -4217,8 +4591,8 @@ func (s *rpcServer) jsonRPCReq(
 	reqNum := atomic.AddInt64(&s.reqNum, 1)
 	reqCompl := atomic.LoadInt64(&s.reqCompl)
 
-	log.Infof("> %d:%d RPC %s %s",
-		reqNum, (reqNum - reqCompl), request.Method, strings.Join(ps, " "))
+	log.Infof("> %d:%d RPC [%s] %s %s", reqNum, (reqNum - reqCompl),
+		user.name, request.Method, strings.Join(ps, " "))
 
 	// Attempt to parse the JSON-RPC request into a known concrete
 	// command.
@@ -4226,25 +4600,186 @@ func (s *rpcServer) jsonRPCReq(
 		parsedCmd := parseCmd(request)
 		if parsedCmd.err != nil {
 			jsonErr = parsedCmd.err
-		} else {
+		} else if jsonErr = s.rateLimiter.check(user.name, remoteAddr,
+			parsedCmd); jsonErr == nil {
+
 			result, jsonErr = s.standardCmdResult(parsedCmd, closeChan)
 		}
 	}
 
-	resp, err := createResponse(request.ID, result, jsonErr)
+	resp, err := createResponse(request.ID, result, jsonErr, strict)
 
 	resStr := "ok"
 	if err != nil {
 		resStr = err.Message()
 	}
-	log.Infof("< %d:%d RPC %s %s", reqNum, (reqNum - reqCompl), request.Method, resStr)
+	log.Infof("< %d:%d RPC [%s] %s %s", reqNum, (reqNum - reqCompl),
+		user.name, request.Method, resStr)
 	atomic.AddInt64(&s.reqCompl, 1)
 
 	return resp, err
 }
 
+// httpRPCRequest is one request object read from the body of an HTTP JSON-RPC
+// request.  When the object is not a valid request, err holds the error to
+// reply with.
+type httpRPCRequest struct {
+	req          btcjson.Request
+	notification bool
+	err          er.R
+}
+
+// parseHTTPRequest parses a single JSON-RPC request object.  In strict mode
+// the request must be a JSON-RPC 2.0 request, and a request without an "id"
+// member is a notification which is processed but never replied to.
+func parseHTTPRequest(raw []byte, strict bool) httpRPCRequest {
+	var r httpRPCRequest
+	invalid := func(msg string, err er.R) httpRPCRequest {
+		if !btcjson.IsValidIDType(r.req.ID) {
+			r.req.ID = nil
+		}
+		r.err = btcjson.NewRPCError(btcjson.ErrRPCInvalidRequest, msg, err)
+		return r
+	}
+
+	var members map[string]jsoniter.RawMessage
+	if errr := jsoniter.Unmarshal(raw, &members); errr != nil || members == nil {
+		return invalid("Request is not an object", nil)
+	}
+	if errr := jsoniter.Unmarshal(raw, &r.req); errr != nil {
+		return invalid("Invalid request", er.E(errr))
+	}
+	if !btcjson.IsValidIDType(r.req.ID) {
+		return invalid("Request id must be a string, number or null", nil)
+	}
+	if r.req.Method == "" {
+		return invalid("Request has no method", nil)
+	}
+	if strict {
+		if r.req.Jsonrpc != "2.0" {
+			return invalid(`Request jsonrpc member must be "2.0"`, nil)
+		}
+		_, hasID := members["id"]
+		r.notification = !hasID
+	}
+	return r
+}
+
+// httpRPCReply returns the response to one request of an HTTP JSON-RPC body, or
+// nil if the request is a notification.
+func (s *rpcServer) httpRPCReply(
+	r *httpRPCRequest,
+	closeChan <-chan struct{},
+	user *rpcUser,
+	remoteAddr string,
+	strict bool,
+) interface{} {
+
+	var resp interface{}
+	var err er.R
+	if r.err != nil {
+		resp, err = createResponse(r.req.ID, nil, r.err, strict)
+	} else {
+		resp, err = s.jsonRPCReq(&r.req, closeChan, user, remoteAddr, strict)
+	}
+	if err != nil {
+		log.Errorf("Failed to create reply to %s: %v", r.req.Method, err)
+		resp, err = createResponse(r.req.ID, nil,
+			btcjson.NewErrRPCInternal(), strict)
+		if err != nil {
+			log.Error(err)
+			return nil
+		}
+	}
+	if r.notification {
+		return nil
+	}
+	return resp
+}
+
+// jsonRPCHandle processes the body of an HTTP JSON-RPC request, which is either
+// a single request object or a batch array of them, and returns the marshalled
+// reply.  The requests of a batch are processed concurrently, with no more than
+// maxConcurrent of them running at once, and their responses are returned in
+// the order of the requests.  A nil reply means there is nothing to send back,
+// which happens in strict mode when the body holds only notifications.
+func (s *rpcServer) jsonRPCHandle(
+	body []byte,
+	closeChan <-chan struct{},
+	user *rpcUser,
+	remoteAddr string,
+	strict bool,
+	maxConcurrent int,
+) ([]byte, er.R) {
+
+	body = bytes.TrimSpace(body)
+	if !jsoniter.Valid(body) {
+		resp, err := createResponse(nil, nil, btcjson.NewRPCError(
+			btcjson.ErrRPCParse, "Failed to parse request", nil), strict)
+		if err != nil {
+			return nil, err
+		}
+		out, errr := jsoniter.Marshal(resp)
+		return out, er.E(errr)
+	}
+
+	if body[0] != '[' {
+		r := parseHTTPRequest(body, strict)
+		resp := s.httpRPCReply(&r, closeChan, user, remoteAddr, strict)
+		if resp == nil {
+			return nil, nil
+		}
+		out, errr := jsoniter.Marshal(resp)
+		return out, er.E(errr)
+	}
+
+	var batch []jsoniter.RawMessage
+	if errr := jsoniter.Unmarshal(body, &batch); errr != nil {
+		return nil, er.E(errr)
+	}
+	if len(batch) == 0 {
+		resp, err := createResponse(nil, nil, btcjson.NewRPCError(
+			btcjson.ErrRPCInvalidRequest, "Empty batch", nil), strict)
+		if err != nil {
+			return nil, err
+		}
+		out, errr := jsoniter.Marshal(resp)
+		return out, er.E(errr)
+	}
+
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	sem := makeSemaphore(maxConcurrent)
+	replies := make([]interface{}, len(batch))
+	var wg sync.WaitGroup
+	for i := range batch {
+		sem.acquire()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer sem.release()
+			r := parseHTTPRequest(batch[i], strict)
+			replies[i] = s.httpRPCReply(&r, closeChan, user, remoteAddr, strict)
+		}(i)
+	}
+	wg.Wait()
+
+	responses := make([]interface{}, 0, len(replies))
+	for _, resp := range replies {
+		if resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil, nil
+	}
+	out, errr := jsoniter.Marshal(responses)
+	return out, er.E(errr)
+}
+
 // jsonRPCRead handles reading and responding to RPC messages.
-func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin bool) {
+func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, user *rpcUser) {
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		return
 	}
@@ -4294,73 +4829,28 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 		}
 	}()
 
-	var requests []btcjson.Request
-	var req0 btcjson.Request
-	var jsonErr er.R
-	isArray := len(body) > 0 && body[0] == '['
-	if isArray {
-		if errr := jsoniter.Unmarshal(body, &requests); errr != nil {
-			jsonErr = btcjson.NewRPCError(
-				btcjson.ErrRPCParse,
-				"Failed to parse requests",
-				er.E(errr),
-			)
-		}
-	} else if errr := jsoniter.Unmarshal(body, &req0); errr != nil {
-		jsonErr = btcjson.NewRPCError(
-			btcjson.ErrRPCParse,
-			"Failed to parse request",
-			er.E(errr),
-		)
-	} else {
-		requests = append(requests, req0)
-	}
-
-	responses := make([]*btcjson.Response, 0, len(requests))
-	if jsonErr == nil {
-		for _, req := range requests {
-			res, jsonErr := s.jsonRPCReq(&req, closeChan, isAdmin)
-			if jsonErr != nil {
-				break
-			}
-			responses = append(responses, res)
-		}
+	msg, err := s.jsonRPCHandle(body, closeChan, user, r.RemoteAddr,
+		cfg.RPCStrictJSONRPC, cfg.RPCMaxConcurrentReqs)
+	if err != nil {
+		log.Error(err)
+		return
 	}
 
-	var msg []byte
-	if jsonErr != nil {
-		resp, err := createResponse(requests[0].ID, nil, jsonErr)
+	// Nothing is sent back for JSON-RPC 2.0 notifications.
+	if msg == nil {
+		w.Header().Add("Content-Length", "0")
+		err := s.writeHTTPResponseHeaders(r, w.Header(),
+			http.StatusNoContent, buf)
 		if err != nil {
 			log.Error(err)
-			return
-		}
-		out, errr := jsoniter.Marshal(&resp)
-		if errr != nil {
-			log.Error(er.E(errr))
-			return
 		}
-		msg = out
-	} else if isArray {
-		out, errr := jsoniter.Marshal(&responses)
-		if errr != nil {
-			log.Error(er.E(errr))
-			return
-		}
-		msg = out
-	} else {
-		var resp0 *btcjson.Response = responses[0]
-		out, errr := jsoniter.Marshal(&resp0)
-		if errr != nil {
-			log.Error(er.E(errr))
-			return
-		}
-		msg = out
+		return
 	}
 
 	w.Header().Add("Content-Length", strconv.FormatInt(int64(len(msg)+1), 10))
 
 	// Write the response.
-	err := s.writeHTTPResponseHeaders(r, w.Header(), http.StatusOK, buf)
+	err = s.writeHTTPResponseHeaders(r, w.Header(), http.StatusOK, buf)
 	if err != nil {
 		log.Error(err)
 		return
@@ -4395,6 +4885,8 @@ func (s *rpcServer) Start() {
 		// Timeout connections which don't complete the initial
 		// handshake within the allowed timeframe.
 		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
+
+		ConnContext: rpcConnContext,
 	}
 	rpcServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Connection", "close")
@@ -4409,19 +4901,40 @@ func (s *rpcServer) Start() {
 		// Keep track of the number of connected clients.
 		s.incrementClients()
 		defer s.decrementClients()
-		_, isAdmin, err := s.checkAuth(r, true)
+		user, err := s.checkAuth(r, true)
 		if err != nil {
 			jsonAuthFail(w)
 			return
 		}
 
 		// Read and respond to the request.
-		s.jsonRPCRead(w, r, isAdmin)
+		s.jsonRPCRead(w, r, user)
+	})
+
+	// Chain event stream endpoint.
+	rpcServeMux.HandleFunc("/chainevents", func(w http.ResponseWriter, r *http.Request) {
+		if s.limitConnections(w, r.RemoteAddr) {
+			return
+		}
+		s.incrementClients()
+		defer s.decrementClients()
+		user, err := s.checkAuth(r, true)
+		if err != nil {
+			jsonAuthFail(w)
+			return
+		}
+		if user.checkMethod("notifychainevents") != nil ||
+			!user.mayNotify(btcjson.ChainEventNtfnMethod) {
+
+			http.Error(w, "403 Forbidden.", http.StatusForbidden)
+			return
+		}
+		s.handleChainEventsStream(w, r)
 	})
 
 	// Websocket endpoint.
 	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		authenticated, isAdmin, err := s.checkAuth(r, false)
+		user, err := s.checkAuth(r, false)
 		if err != nil {
 			jsonAuthFail(w)
 			return
@@ -4442,7 +4955,7 @@ func (s *rpcServer) Start() {
 			http.Error(w, "400 Bad Request.", http.StatusBadRequest)
 			return
 		}
-		s.WebsocketHandler(ws, r.RemoteAddr, authenticated, isAdmin)
+		s.WebsocketHandler(ws, r.RemoteAddr, user)
 	})
 
 	for _, listener := range s.cfg.Listeners {
@@ -4504,6 +5017,10 @@ type rpcserverPeer interface {
 	// FeeFilter returns the requested current minimum fee rate for which
 	// transactions should be announced.
 	FeeFilter() int64
+
+	// MsgStats returns the traffic sent to and received from the peer by
+	// message type.
+	MsgStats() (sent, recv map[string]msgStat)
 }
 
 // rpcserverConnManager represents a connection manager for use with the RPC
@@ -4546,6 +5063,13 @@ type rpcserverConnManager interface {
 	// network for all peers.
 	NetTotals() (uint64, uint64)
 
+	// UploadTarget returns the state of the upload target.
+	UploadTarget() uploadTargetStats
+
+	// MsgStats returns the traffic sent to and received from all peers by
+	// message type.
+	MsgStats() (sent, recv map[string]msgStat)
+
 	// ConnectedPeers returns an array consisting of all connected peers.
 	ConnectedPeers() []rpcserverPeer
 
@@ -4565,6 +5089,22 @@ type rpcserverConnManager interface {
 	// RelayTransactions generates and relays inventory vectors for all of
 	// the passed transactions to all connected peers.
 	RelayTransactions(txns []*mempool.TxDesc)
+
+	// SetBan bans an IP address or subnet until the provided time, or for
+	// the default ban duration if it is the zero time.  Connected peers
+	// which the ban covers are disconnected.
+	SetBan(subnet string, until time.Time) er.R
+
+	// Unban removes the ban of an IP address or subnet.  Attempting to
+	// unban an address or subnet which is not banned will return an error.
+	Unban(subnet string) er.R
+
+	// ClearBanned removes all bans.
+	ClearBanned()
+
+	// BannedPeers returns the addresses and subnets which are currently
+	// banned.
+	BannedPeers() []banmgr.BanInfo
 }
 
 // rpcserverSyncManager represents a sync manager for use with the RPC server.
@@ -4625,6 +5165,10 @@ type rpcserverConfig struct {
 	// TxMemPool defines the transaction memory pool to interact with.
 	TxMemPool *mempool.TxPool
 
+	// MempoolPersist saves the mempool to and loads it from the mempool
+	// file.
+	MempoolPersist *mempoolPersist
+
 	// These fields allow the RPC server to interface with mining.
 	//
 	// Generator produces block templates and the CPUMiner solves them using
@@ -4643,9 +5187,76 @@ type rpcserverConfig struct {
 	// the mempool before they are mined into blocks.
 	FeeEstimator *mempool.FeeEstimator
 
+	// ChainEvents is the ordered log of blocks connected to and
+	// disconnected from the main chain which clients may stream.
+	ChainEvents *chainEventLog
+
 	ServiceFlags protocol.ServiceFlag
 }
 
+const (
+	// rpcCookieFilename is the name of the file in the data directory
+	// holding the credentials of the cookie user.
+	rpcCookieFilename = ".cookie"
+
+	// rpcCookieUser is the user name of the cookie credentials.
+	rpcCookieUser = "__PKT_COOKIE__"
+)
+
+// writeRPCCookie generates a random password for the cookie user and writes
+// the credentials to the cookie file in the data directory, which only the
+// owner of the process may read.  Local tools use it to authenticate as the
+// admin user without configured credentials.  It returns the password.
+func writeRPCCookie(dataDir string) (string, er.R) {
+	var buf [32]byte
+	if _, errr := crand.Read(buf[:]); errr != nil {
+		err := er.E(errr)
+		err.AddMessage("Unable to get random numbers")
+		return "", err
+	}
+	pass := hex.EncodeToString(buf[:])
+	cookie := rpcCookieUser + ":" + pass
+
+	if errr := os.MkdirAll(dataDir, 0700); errr != nil {
+		return "", er.E(errr)
+	}
+	cookiePath := filepath.Join(dataDir, rpcCookieFilename)
+	tmpPath := cookiePath + ".tmp"
+	if errr := ioutil.WriteFile(tmpPath, []byte(cookie), 0600); errr != nil {
+		err := er.E(errr)
+		err.AddMessage("Could not write cookie")
+		return "", err
+	}
+	if errr := os.Rename(tmpPath, cookiePath); errr != nil {
+		err := er.E(errr)
+		err.AddMessage("Could not write cookie")
+		return "", err
+	}
+	log.Infof("Wrote RPC cookie to %s", cookiePath)
+	return pass, nil
+}
+
+// removeRPCCookie removes the cookie file from the data directory.
+func removeRPCCookie(dataDir string) {
+	cookiePath := filepath.Join(dataDir, rpcCookieFilename)
+	if errr := os.Remove(cookiePath); errr != nil && !os.IsNotExist(errr) {
+		log.Warnf("Unable to remove RPC cookie: %v", errr)
+	}
+}
+
+// rpcUnixConnKey is the context key marking requests which were received on
+// the Unix domain socket listener.
+type rpcUnixConnKey struct{}
+
+// rpcConnContext marks the context of connections accepted on the Unix domain
+// socket listener.
+func rpcConnContext(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*net.UnixConn); ok {
+		return context.WithValue(ctx, rpcUnixConnKey{}, true)
+	}
+	return ctx
+}
+
 // newRPCServer returns a new instance of the rpcServer struct.
 func newRPCServer(config *rpcserverConfig) (*rpcServer, er.R) {
 	rpc := rpcServer{
@@ -4654,17 +5265,22 @@ func newRPCServer(config *rpcserverConfig) (*rpcServer, er.R) {
 		gbtWorkState:           newGbtWorkState(config.TimeSource),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
+		callStats:              newRPCCallStats(),
 		quit:                   make(chan int),
 	}
-	if cfg.RPCUser != "" && cfg.RPCPass != "" {
-		login := cfg.RPCUser + ":" + cfg.RPCPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.authsha = sha256.Sum256([]byte(auth))
+	auth, err := newRPCAuth(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cookie, err := writeRPCCookie(cfg.DataDir)
+	if err != nil {
+		return nil, err
 	}
-	if cfg.RPCLimitUser != "" && cfg.RPCLimitPass != "" {
-		login := cfg.RPCLimitUser + ":" + cfg.RPCLimitPass
-		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-		rpc.limitauthsha = sha256.Sum256([]byte(auth))
+	auth.addPassword(rpcCookieUser, cookie)
+	rpc.auth = auth
+	rpc.rateLimiter, err = newRPCRateLimiter(cfg)
+	if err != nil {
+		return nil, err
 	}
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
 	rpc.cfg.Chain.Subscribe(rpc.handleBlockchainNotification)
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// TestJSONRPCHandle ensures single and batch HTTP request bodies are answered
+// correctly in both the default and the strict JSON-RPC 2.0 mode.
+func TestJSONRPCHandle(t *testing.T) {
+	s := &rpcServer{callStats: newRPCCallStats()}
+
+	tests := []struct {
+		name    string
+		body    string
+		isAdmin bool
+		strict  bool
+		// want holds the expected error code, or 0 for a result, of each
+		// response in order.  A nil want means no reply is expected.
+		want    []int
+		isBatch bool
+	}{
+		{
+			name:    "single request",
+			body:    `{"jsonrpc":"1.0","method":"uptime","params":[],"id":1}`,
+			isAdmin: true,
+			want:    []int{0},
+		},
+		{
+			name:    "parse error",
+			body:    `{"method":`,
+			isAdmin: true,
+			want:    []int{-32700},
+		},
+		{
+			name:    "batch",
+			body:    `[{"method":"uptime","id":1},{"method":"nosuchmethod","id":2},3]`,
+			isAdmin: true,
+			want:    []int{0, -32601, -32600},
+			isBatch: true,
+		},
+		{
+			name:    "limited user per element",
+			body:    `[{"method":"uptime","id":1},{"method":"stop","id":2}]`,
+			want:    []int{0, -32602},
+			isBatch: true,
+		},
+		{
+			name:    "empty batch",
+			body:    `[]`,
+			isAdmin: true,
+			want:    []int{-32600},
+		},
+		{
+			name:    "strict rejects 1.0",
+			body:    `{"jsonrpc":"1.0","method":"uptime","id":1}`,
+			isAdmin: true,
+			strict:  true,
+			want:    []int{-32600},
+		},
+		{
+			name:    "strict notification",
+			body:    `{"jsonrpc":"2.0","method":"uptime"}`,
+			isAdmin: true,
+			strict:  true,
+		},
+		{
+			name: "strict batch with notification",
+			body: `[{"jsonrpc":"2.0","method":"uptime"},` +
+				`{"jsonrpc":"2.0","method":"uptime","id":null}]`,
+			isAdmin: true,
+			strict:  true,
+			want:    []int{0},
+			isBatch: true,
+		},
+		{
+			name:    "strict batch of notifications",
+			body:    `[{"jsonrpc":"2.0","method":"uptime"}]`,
+			isAdmin: true,
+			strict:  true,
+		},
+	}
+
+	type response struct {
+		Result *jsoniter.RawMessage `json:"result"`
+		Error  *struct {
+			Code int `json:"code"`
+		} `json:"error"`
+		Jsonrpc string `json:"jsonrpc"`
+	}
+	for _, test := range tests {
+		user := &rpcUser{name: "test", isAdmin: test.isAdmin}
+		out, err := s.jsonRPCHandle([]byte(test.body), nil, user, "",
+			test.strict, 2)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if test.want == nil {
+			if out != nil {
+				t.Errorf("%s: unexpected reply %s", test.name, out)
+			}
+			continue
+		}
+
+		var responses []response
+		if test.isBatch {
+			err := jsoniter.Unmarshal(out, &responses)
+			if err != nil {
+				t.Errorf("%s: bad batch reply %s", test.name, out)
+				continue
+			}
+		} else {
+			var resp response
+			if err := jsoniter.Unmarshal(out, &resp); err != nil {
+				t.Errorf("%s: bad reply %s", test.name, out)
+				continue
+			}
+			responses = append(responses, resp)
+		}
+		if len(responses) != len(test.want) {
+			t.Errorf("%s: got %d responses, want %d: %s", test.name,
+				len(responses), len(test.want), out)
+			continue
+		}
+		for i, resp := range responses {
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if code != test.want[i] {
+				t.Errorf("%s: response %d has code %d, want %d: %s",
+					test.name, i, code, test.want[i], out)
+			}
+			if test.strict {
+				if resp.Jsonrpc != "2.0" {
+					t.Errorf("%s: response %d is not 2.0: %s",
+						test.name, i, out)
+				}
+				if (resp.Result != nil) == (resp.Error != nil) {
+					t.Errorf("%s: response %d must have exactly "+
+						"one of result and error: %s",
+						test.name, i, out)
+				}
+			}
+		}
+	}
+}
+
+// TestRPCCookieAuth ensures the cookie credentials and requests without
+// credentials on the Unix domain socket are accepted.
+func TestRPCCookieAuth(t *testing.T) {
+	dataDir := t.TempDir()
+	pass, err := writeRPCCookie(dataDir)
+	if err != nil {
+		t.Fatalf("writeRPCCookie: %v", err)
+	}
+	cookiePath := filepath.Join(dataDir, rpcCookieFilename)
+	fi, errr := os.Stat(cookiePath)
+	if errr != nil {
+		t.Fatalf("stat cookie: %v", errr)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Fatalf("cookie has mode %v", fi.Mode().Perm())
+	}
+
+	auth, err := newRPCAuth(&config{})
+	if err != nil {
+		t.Fatalf("newRPCAuth: %v", err)
+	}
+	auth.addPassword(rpcCookieUser, pass)
+	s := &rpcServer{auth: auth}
+
+	tests := []struct {
+		name string
+		auth string
+		unix bool
+		user string
+	}{
+		{
+			name: "cookie",
+			auth: basicAuthHeader(rpcCookieUser, pass),
+			user: rpcCookieUser,
+		},
+		{name: "unix without credentials", unix: true, user: rpcUnixUser},
+		{name: "unix with bad credentials", auth: "Basic eDp5", unix: true},
+		{name: "tcp without credentials"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		if test.auth != "" {
+			r.Header.Set("Authorization", test.auth)
+		}
+		if test.unix {
+			r = r.WithContext(context.WithValue(r.Context(),
+				rpcUnixConnKey{}, true))
+		}
+		user, err := s.checkAuth(r, true)
+		name := ""
+		if user != nil {
+			name = user.name
+		}
+		if name != test.user || (err == nil) != (test.user != "") {
+			t.Errorf("%s: got user %q, err %v", test.name, name, err)
+		}
+	}
+
+	removeRPCCookie(dataDir)
+	if _, errr := os.Stat(cookiePath); !os.IsNotExist(errr) {
+		t.Fatalf("cookie was not removed: %v", errr)
+	}
+}
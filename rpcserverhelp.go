@@ -49,6 +49,37 @@ var helpDescsEnUS = map[string]string{
 	"node-target":        "Either the IP address and port of the peer to operate on, or a valid peer ID.",
 	"node-connectsubcmd": "'perm' to make the connected peer a permanent one, 'temp' to try a single connect to a peer",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Attempts to add or remove an IP address or subnet from the ban list.",
+	"setban-subnet":    "The IP address or subnet in CIDR notation to operate on",
+	"setban-subcmd":    "'add' to ban the address or subnet and disconnect matching peers, 'remove' to remove the ban",
+	"setban-bantime":   "Number of seconds to ban for, or 0 to use the --banduration setting",
+	"setban-absolute":  "If true, bantime is an absolute unix timestamp to ban until",
+
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the banned IP addresses and subnets.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":        "The banned IP address or subnet",
+	"listbannedresult-reason":         "Why the address or subnet was banned",
+	"listbannedresult-banscore":       "The ban score of the address, or -1 if it has none",
+	"listbannedresult-ban_created":    "The unix timestamp when the ban was added",
+	"listbannedresult-banned_until":   "The unix timestamp when the ban expires",
+	"listbannedresult-ban_duration":   "The length of the ban in seconds",
+	"listbannedresult-time_remaining": "The number of seconds until the ban expires",
+
+	// LoadMempoolCmd help.
+	"loadmempool--synopsis": "Loads the transactions saved in the mempool file in the data directory into the mempool.\n" +
+		"Transactions which are already in the mempool or are no longer valid are skipped.",
+
+	// LoadMempoolResult help.
+	"loadmempoolresult-filename": "The path of the mempool file",
+	"loadmempoolresult-loaded":   "The number of transactions accepted into the mempool",
+	"loadmempoolresult-failed":   "The number of transactions which were not accepted",
+
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all IP addresses and subnets from the ban list.",
+
 	// TransactionInput help.
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
@@ -139,8 +170,10 @@ var helpDescsEnUS = map[string]string{
 	"estimatefee--result0": "Estimated fee per kilobyte in satoshis for a block to " +
 		"be mined in the next NumBlocks blocks.",
 
-	"estimatesmartfee--synopsis":     "Better estimatefee, currently implemented using estimatefee",
-	"estimatesmartfee-estimatemode":  "ECONOMICAL or CONSERVATIVE to decide how to estimate fee rate",
+	"estimatesmartfee--synopsis": "Better estimatefee, currently implemented using estimatefee.\n" +
+		"In MEMPOOL mode, the estimate is also based on the blocks the transactions of the mempool are expected to be mined in, " +
+		"which works before estimatefee has observed enough blocks.",
+	"estimatesmartfee-estimatemode":  "ECONOMICAL or CONSERVATIVE to decide how to estimate fee rate, or MEMPOOL to also estimate it from the mempool",
 	"estimatesmartfee-conftarget":    "Target number of blocks until transaction confirms",
 	"estimatesmartfeeresult-feerate": "Fee in coins per kilobyte",
 	"estimatesmartfeeresult-errors":  "Array of string errors which may have occurred while processing",
@@ -455,12 +488,17 @@ var helpDescsEnUS = map[string]string{
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetMempoolFeeHistogramCmd help.
+	"getmempoolfeehistogram--synopsis": "Returns the fee rates paid by the transactions of the memory pool as a histogram in the format of the Electrum protocol.",
+	"getmempoolfeehistogram--result0":  "Array of pairs of a fee rate in satoshis per virtual byte and the total virtual size of the transactions paying at least that fee rate and less than the fee rate of the previous pair, by decreasing fee rate",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
 	// GetMempoolInfoResult help.
-	"getmempoolinforesult-bytes": "Size in bytes of the mempool",
-	"getmempoolinforesult-size":  "Number of transactions in the mempool",
+	"getmempoolinforesult-bytes":         "Size in bytes of the mempool",
+	"getmempoolinforesult-size":          "Number of transactions in the mempool",
+	"getmempoolinforesult-mempoolminfee": "Minimum fee rate in BTC/kB for a transaction to be accepted, raised above the minimum relay fee while the mempool is full",
 
 	// GetMiningInfoResult help.
 	"getmininginforesult-blocks":             "Height of the latest best block",
@@ -512,6 +550,33 @@ var helpDescsEnUS = map[string]string{
 	"getnettotalsresult-totalbytessent": "Total bytes sent",
 	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
 
+	// GetNetMsgStatsCmd help.
+	"getnetmsgstats--synopsis": "Returns the traffic sent and received by message type, for all peers or for a single peer.",
+	"getnetmsgstats-peerid":    "Only return the traffic of the connected peer with this id",
+
+	// GetNetMsgStatsResult help.
+	"getnetmsgstatsresult-sent":        "Traffic sent by message type",
+	"getnetmsgstatsresult-sent--key":   "command",
+	"getnetmsgstatsresult-sent--value": "stats",
+	"getnetmsgstatsresult-sent--desc":  "The traffic of the message type",
+	"getnetmsgstatsresult-recv":        "Traffic received by message type",
+	"getnetmsgstatsresult-recv--key":   "command",
+	"getnetmsgstatsresult-recv--value": "stats",
+	"getnetmsgstatsresult-recv--desc":  "The traffic of the message type",
+	"netmsgstat-bytes":                 "Number of bytes",
+	"netmsgstat-count":                 "Number of messages",
+
+	// GetNetTotalsResult upload target help.
+	"getnettotalsresult-uploadtarget":                        "The state of the upload target set by --maxuploadtarget",
+	"getnettotalsuploadtargetresult-timeframe":               "Length of the upload target cycle in seconds",
+	"getnettotalsuploadtargetresult-target":                  "Target in bytes per cycle, 0 when there is no target",
+	"getnettotalsuploadtargetresult-target_reached":          "Whether the target has been reached",
+	"getnettotalsuploadtargetresult-serve_historical_blocks": "Whether historical blocks are served to peers without the download permission",
+	"getnettotalsuploadtargetresult-bytes_left_in_cycle":     "Bytes left to send in the current cycle",
+	"getnettotalsuploadtargetresult-time_left_in_cycle":      "Seconds left in the current cycle",
+	"getnettotalsuploadtargetresult-max_upload_rate":         "Combined upload rate limit in bytes per second, 0 when there is no limit",
+	"getnettotalsuploadtargetresult-max_peer_upload_rate":    "Per peer upload rate limit in bytes per second, 0 when there is no limit",
+
 	// GetPeerInfoResult help.
 	"getpeerinforesult-id":             "A unique node ID",
 	"getpeerinforesult-addr":           "The ip address and port of the peer",
@@ -535,6 +600,20 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
 
+	// GetPeerInfoResult transport help.
+	"getpeerinforesult-transport_protocol_type": "The transport used with the peer (v1 or v2)",
+	"getpeerinforesult-session_id":              "The BIP0324 session ID for v2 connections, empty for v1",
+
+	// GetPeerInfoResult per message type traffic help.
+	"getpeerinforesult-bytessent_per_msg":        "Total bytes sent by message type",
+	"getpeerinforesult-bytessent_per_msg--key":   "command",
+	"getpeerinforesult-bytessent_per_msg--value": "bytes",
+	"getpeerinforesult-bytessent_per_msg--desc":  "Bytes sent for the message type",
+	"getpeerinforesult-bytesrecv_per_msg":        "Total bytes received by message type",
+	"getpeerinforesult-bytesrecv_per_msg--key":   "command",
+	"getpeerinforesult-bytesrecv_per_msg--value": "bytes",
+	"getpeerinforesult-bytesrecv_per_msg--desc":  "Bytes received for the message type",
+
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
 
@@ -560,6 +639,8 @@ var helpDescsEnUS = map[string]string{
 	// GetRawMempoolVerboseResult help.
 	"getrawmempoolverboseresult-size":             "Transaction size in bytes",
 	"getrawmempoolverboseresult-fee":              "Transaction fee in bitcoins",
+	"getrawmempoolverboseresult-modifiedfee":      "Transaction fee adjusted by its fee delta in bitcoins, used to rank the transaction",
+	"getrawmempoolverboseresult-feedelta":         "Fee delta set for the transaction with prioritisetransaction in bitcoins",
 	"getrawmempoolverboseresult-time":             "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
 	"getrawmempoolverboseresult-height":           "Block height when transaction entered the pool",
 	"getrawmempoolverboseresult-startingpriority": "Priority when transaction entered the pool",
@@ -574,6 +655,12 @@ var helpDescsEnUS = map[string]string{
 	"getrawmempool--condition1": "verbose=true",
 	"getrawmempool--result0":    "Array of transaction hashes",
 
+	// GetRawMempoolCmd mempool sequence help.
+	"getrawmempool-mempoolsequence":                "Returns the transaction hashes along with the mempool sequence number, which can not be combined with verbose",
+	"getrawmempool--condition2":                    "mempoolsequence=true",
+	"getrawmempoolsequenceresult-txids":            "The hashes of the transactions in the memory pool",
+	"getrawmempoolsequenceresult-mempool_sequence": "The sequence number of the mempool, mempoolevent notifications with a greater sequence number are not reflected in txids",
+
 	// GetRawTransactionCmd help.
 	"getrawtransaction--synopsis":   "Returns information about a transaction given its hash.",
 	"getrawtransaction-txid":        "The hash of the transaction",
@@ -622,6 +709,20 @@ var helpDescsEnUS = map[string]string{
 	"echo-f":         "anything",
 	"echo-g":         "anything",
 
+	// PrioritiseTransactionCmd help.
+	"prioritisetransaction--synopsis": "Adjusts the fee by which a transaction is ranked for acceptance, eviction, replacement and mining, without changing the fee it pays.\n" +
+		"The transaction does not need to be in the mempool yet. Fee deltas add up and are forgotten once the transaction is mined.",
+	"prioritisetransaction-txid":     "The hash of the transaction",
+	"prioritisetransaction-feedelta": "The fee delta in satoshis to add to the fee of the transaction, which may be negative",
+	"prioritisetransaction--result0": "Always true",
+
+	// SaveMempoolCmd help.
+	"savemempool--synopsis": "Saves the transactions of the mempool to the mempool file in the data directory.",
+
+	// SaveMempoolResult help.
+	"savemempoolresult-filename": "The path of the mempool file",
+	"savemempoolresult-size":     "The number of transactions saved",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -665,6 +766,40 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// SubmitPackageCmd help.
+	"submitpackage--synopsis": "Submits a package of raw transactions to the mempool and relays the accepted ones.\n" +
+		"The package is a child preceded by its ancestors.  Transactions which are accepted on their own are added first, the others are added together when they pay the minimum fee for their total size, so the child can pay for parents which don't pay enough on their own.\n" +
+		"Transactions spending outputs of unknown transactions are added to the orphan pool.",
+	"submitpackage-rawtxs": "Serialized, hex-encoded transactions, at most 25, ordered so parents come before their children and ending with the child",
+
+	// SubmitPackageResult help.
+	"submitpackageresult-package_msg": "The reason the package was rejected, or \"success\"",
+	"submitpackageresult-tx-results":  "The results for each transaction of the package",
+
+	// SubmitPackageTxResult help.
+	"submitpackagetxresult-txid":  "The hash of the transaction",
+	"submitpackagetxresult-wtxid": "The hash of the transaction including its witness data",
+	"submitpackagetxresult-vsize": "The virtual size of the transaction (only when in the mempool)",
+	"submitpackagetxresult-fees":  "The fees of the transaction (only when in the mempool)",
+	"submitpackagetxresult-error": "Why the transaction is not in the mempool: \"missing-inputs\" when it was added to the orphan pool, or \"not accepted\"",
+
+	// TestMempoolAcceptCmd help.
+	"testmempoolaccept--synopsis": "Returns whether the passed raw transactions would be accepted into the mempool, without adding or relaying them.\n" +
+		"Each transaction may spend the outputs of the transactions before it which would be accepted.",
+	"testmempoolaccept-rawtxs":     "Serialized, hex-encoded transactions, at most 25, ordered so parents come before their children",
+	"testmempoolaccept-maxfeerate": "Reject transactions paying a higher fee rate than this, in coins per kilobyte, 0 for no limit",
+
+	// TestMempoolAcceptResult help.
+	"testmempoolacceptresult-txid":          "The hash of the transaction",
+	"testmempoolacceptresult-wtxid":         "The hash of the transaction including its witness data",
+	"testmempoolacceptresult-allowed":       "Whether the transaction would be accepted into the mempool",
+	"testmempoolacceptresult-vsize":         "The virtual size of the transaction (only when allowed)",
+	"testmempoolacceptresult-fees":          "The fees of the transaction (only when allowed)",
+	"testmempoolacceptresult-reject-reason": "The reason the transaction would be rejected (only when not allowed)",
+
+	// TestMempoolAcceptFees help.
+	"testmempoolacceptfees-base": "The fee of the transaction in coins",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
 	"validateaddresschainresult-address": "The bitcoin address (only when isvalid is true)",
@@ -702,6 +837,24 @@ var helpDescsEnUS = map[string]string{
 	// StopNotifyBlocksCmd help.
 	"stopnotifyblocks--synopsis": "Cancel registered notifications for whenever a block is connected or disconnected from the main (best) chain.",
 
+	// NotifyChainEventsCmd help.
+	"notifychainevents--synopsis": "Stream the ordered log of blocks connected to and disconnected from the main (best) chain as chainevent notifications.\n" +
+		"Each event has a sequence number one greater than the event before it. A client which reconnects passes the sequence number of the last event it received, or the hash of the tip it last saw, and receives exactly the events it missed, including the disconnect of blocks which were orphaned meanwhile.\n" +
+		"Events replayed to a client which resumes by hash from before the start of the retained log have sequence number 0.\n" +
+		"The same stream is served as newline delimited JSON over HTTP at /chainevents?from=<seq or hash>.",
+	"notifychainevents-from": "The sequence number of the last event received or the hash of the last tip seen, the stream starts at the next event if omitted",
+
+	// StopNotifyChainEventsCmd help.
+	"stopnotifychainevents--synopsis": "Stop streaming the chain event log.",
+
+	// NotifyMempoolEventsCmd help.
+	"notifymempoolevents--synopsis": "Send a mempoolevent notification whenever a transaction is added to or removed from the mempool.\n" +
+		"Each event carries the mempool sequence number after it and removals carry the reason: confirmed, replaced, conflict, expiry or eviction.\n" +
+		"Request the notifications before calling getrawmempool with mempoolsequence=true, then apply the events with a greater sequence number.",
+
+	// StopNotifyMempoolEventsCmd help.
+	"stopnotifymempoolevents--synopsis": "Stop sending mempoolevent notifications.",
+
 	// NotifyNewTransactionsCmd help.
 	"notifynewtransactions--synopsis": "Send either a txaccepted or a txacceptedverbose notification when a new transaction is accepted into the mempool.",
 	"notifynewtransactions-verbose":   "Specifies which type of notification to receive. If verbose is true, then the caller receives txacceptedverbose, otherwise the caller receives txaccepted",
@@ -779,6 +932,7 @@ var helpDescsEnUS = map[string]string{
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
 	"addnode":                nil,
+	"clearbanned":            nil,
 	"configureminingpayouts": nil,
 	"createrawtransaction":   {(*string)(nil)},
 	"checkpcann":             {(*btcjson.CheckPcAnnResult)(nil)},
@@ -806,9 +960,11 @@ var rpcResultTypes = map[string][]interface{}{
 	"gethashespersec":        {(*float64)(nil)},
 	"getheaders":             {(*[]string)(nil)},
 	"getinfo":                {(*btcjson.InfoChainResult)(nil)},
+	"getmempoolfeehistogram": {(*btcjson.GetMempoolFeeHistogramResult)(nil)},
 	"getmempoolinfo":         {(*btcjson.GetMempoolInfoResult)(nil)},
 	"getmininginfo":          {(*btcjson.GetMiningInfoResult)(nil)},
 	"getminingpayouts":       {(*btcjson.GetMiningPayoutsResult)(nil)},
+	"getnetmsgstats":         {(*btcjson.GetNetMsgStatsResult)(nil)},
 	"getnettotals":           {(*btcjson.GetNetTotalsResult)(nil)},
 	"getnetworkinfo":         {(*btcjson.GetNetworkInfoResult)(nil)},
 	"getnetworksteward":      {(*btcjson.GetNetworkStewardResult)(nil)},
@@ -816,18 +972,25 @@ var rpcResultTypes = map[string][]interface{}{
 	"getpeerinfo":            {(*[]btcjson.GetPeerInfoResult)(nil)},
 	"getrawblocktemplate":    {(*string)(nil)},
 	"checkpcshare":           {(*string)(nil)},
-	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrawmempool":          {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil), (*btcjson.GetRawMempoolSequenceResult)(nil)},
 	"getrawtransaction":      {(*string)(nil), (*btcjson.TxRawResult)(nil)},
 	"gettxout":               {(*btcjson.GetTxOutResult)(nil)},
 	"node":                   nil,
 	"help":                   {(*string)(nil), (*string)(nil)},
+	"listbanned":             {(*[]btcjson.ListBannedResult)(nil)},
+	"loadmempool":            {(*btcjson.LoadMempoolResult)(nil)},
 	"ping":                   nil,
 	"echo":                   {(*[]string)(nil)},
+	"prioritisetransaction":  {(*bool)(nil)},
+	"savemempool":            {(*btcjson.SaveMempoolResult)(nil)},
 	"searchrawtransactions":  {(*string)(nil), (*[]btcjson.TxRawResult)(nil)},
 	"sendrawtransaction":     {(*string)(nil)},
+	"setban":                 nil,
 	"setgenerate":            nil,
 	"stop":                   {(*string)(nil)},
 	"submitblock":            {nil, (*string)(nil)},
+	"submitpackage":          {(*btcjson.SubmitPackageResult)(nil)},
+	"testmempoolaccept":      {(*[]btcjson.TestMempoolAcceptResult)(nil)},
 	"uptime":                 {(*int64)(nil)},
 	"validateaddress":        {(*btcjson.ValidateAddressChainResult)(nil)},
 	"verifychain":            {(*bool)(nil)},
@@ -839,6 +1002,10 @@ var rpcResultTypes = map[string][]interface{}{
 	"session":                   {(*btcjson.SessionResult)(nil)},
 	"notifyblocks":              nil,
 	"stopnotifyblocks":          nil,
+	"notifychainevents":         nil,
+	"stopnotifychainevents":     nil,
+	"notifymempoolevents":       nil,
+	"stopnotifymempoolevents":   nil,
 	"notifynewtransactions":     nil,
 	"stopnotifynewtransactions": nil,
 	"notifyreceived":            nil,
@@ -8,9 +8,6 @@ package main
 import (
 	"bytes"
 	"container/list"
-	"crypto/sha256"
-	"crypto/subtle"
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -28,6 +25,7 @@ import (
 	"github.com/pkt-cash/PKT-FullNode/chaincfg"
 	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
 	"github.com/pkt-cash/PKT-FullNode/database"
+	"github.com/pkt-cash/PKT-FullNode/mempool"
 	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
 	"github.com/pkt-cash/PKT-FullNode/txscript"
 	"github.com/pkt-cash/PKT-FullNode/wire"
@@ -67,11 +65,15 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 	"loadtxfilter":              handleLoadTxFilter,
 	"help":                      handleWebsocketHelp,
 	"notifyblocks":              handleNotifyBlocks,
+	"notifychainevents":         handleNotifyChainEvents,
+	"notifymempoolevents":       handleNotifyMempoolEvents,
 	"notifynewtransactions":     handleNotifyNewTransactions,
 	"notifyreceived":            handleNotifyReceived,
 	"notifyspent":               handleNotifySpent,
 	"session":                   handleSession,
 	"stopnotifyblocks":          handleStopNotifyBlocks,
+	"stopnotifychainevents":     handleStopNotifyChainEvents,
+	"stopnotifymempoolevents":   handleStopNotifyMempoolEvents,
 	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
 	"stopnotifyspent":           handleStopNotifySpent,
 	"stopnotifyreceived":        handleStopNotifyReceived,
@@ -85,7 +87,7 @@ var wsHandlersBeforeInit = map[string]wsCommandHandler{
 // server handler which runs each new connection in a new goroutine thereby
 // satisfying the requirement.
 func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
-	authenticated bool, isAdmin bool) {
+	user *rpcUser) {
 
 	// Clear the read deadline that was set before the websocket hijacked
 	// the connection.
@@ -104,7 +106,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	// Create a new websocket client to handle the new websocket connection
 	// and wait for it to shutdown.  Once it has shutdown (and hence
 	// disconnected), remove it and any notifications it registered for.
-	client, err := newWebsocketClient(s, conn, remoteAddr, authenticated, isAdmin)
+	client, err := newWebsocketClient(s, conn, remoteAddr, user)
 	if err != nil {
 		log.Errorf("Failed to serve client %s: %v", remoteAddr, err)
 		conn.Close()
@@ -113,6 +115,7 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 	s.ntfnMgr.AddClient(client)
 	client.Start()
 	client.WaitForShutdown()
+	client.stopChainEvents()
 	s.ntfnMgr.RemoveClient(client)
 	log.Infof("Disconnected websocket client %s", remoteAddr)
 }
@@ -247,6 +250,19 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *btcutil.Tx, isNew bool) {
 	}
 }
 
+// NotifyMempoolEvent passes a transaction added to or removed from the mempool
+// to the notification manager for mempool event notification processing.
+func (m *wsNotificationManager) NotifyMempoolEvent(ev *mempool.Event) {
+	// As NotifyMempoolEvent will be called by mempool and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationMempoolEvent)(ev):
+	case <-m.quit:
+	}
+}
+
 // wsClientFilter tracks relevant addresses for each websocket client for
 // the `rescanblocks` extension. It is modified by the `loadtxfilter` command.
 //
@@ -400,6 +416,7 @@ type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *btcutil.Tx
 }
+type notificationMempoolEvent mempool.Event
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -408,6 +425,8 @@ type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
+type notificationRegisterMempoolEvents wsClient
+type notificationUnregisterMempoolEvents wsClient
 type notificationRegisterSpent struct {
 	wsc *wsClient
 	ops []*wire.OutPoint
@@ -440,6 +459,7 @@ func (m *wsNotificationManager) notificationHandler() {
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	mempoolEventNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
@@ -488,6 +508,12 @@ out:
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
 
+			case *notificationMempoolEvent:
+				if len(mempoolEventNotifications) != 0 {
+					m.notifyMempoolEvent(mempoolEventNotifications,
+						(*mempool.Event)(n))
+				}
+
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
 				blockNotifications[wsc.quit] = wsc
@@ -506,6 +532,7 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(mempoolEventNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -535,6 +562,14 @@ out:
 				wsc := (*wsClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterMempoolEvents:
+				wsc := (*wsClient)(n)
+				mempoolEventNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterMempoolEvents:
+				wsc := (*wsClient)(n)
+				delete(mempoolEventNotifications, wsc.quit)
+
 			default:
 				log.Warn("Unhandled notification type")
 			}
@@ -768,6 +803,44 @@ func (m *wsNotificationManager) UnregisterNewMempoolTxsUpdates(wsc *wsClient) {
 	m.queueNotification <- (*notificationUnregisterNewMempoolTxs)(wsc)
 }
 
+// RegisterMempoolEvents requests notifications to the passed websocket client
+// when transactions are added to or removed from the memory pool.
+func (m *wsNotificationManager) RegisterMempoolEvents(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterMempoolEvents)(wsc)
+}
+
+// UnregisterMempoolEvents removes notifications to the passed websocket client
+// when transactions are added to or removed from the memory pool.
+func (m *wsNotificationManager) UnregisterMempoolEvents(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterMempoolEvents)(wsc)
+}
+
+// notifyMempoolEvent notifies websocket clients that have registered for
+// mempool events that a transaction was added to or removed from the memory
+// pool.
+func (*wsNotificationManager) notifyMempoolEvent(clients map[chan struct{}]*wsClient,
+	ev *mempool.Event) {
+
+	event := btcjson.MempoolEvent{
+		Seq:  ev.Sequence,
+		Type: btcjson.MempoolEventAdded,
+		TxID: ev.Tx.Hash().String(),
+	}
+	if ev.Removed {
+		event.Type = btcjson.MempoolEventRemoved
+		event.Reason = ev.Reason.String()
+	}
+	marshalledJSON, err := btcjson.MarshalCmd(nil,
+		btcjson.NewMempoolEventNtfn(event))
+	if err != nil {
+		log.Errorf("Failed to marshal mempool event notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // notifyForNewTx notifies websocket clients that have registered for updates
 // when a new transaction is added to the memory pool.
 func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClient, tx *btcutil.Tx) {
@@ -1212,9 +1285,9 @@ type wsClient struct {
 	// and therefore is allowed to communicated over the websocket.
 	authenticated bool
 
-	// isAdmin specifies whether a client may change the state of the server;
-	// false means its access is only to the limited set of RPC calls.
-	isAdmin bool
+	// user is the RPC user the client authenticated as, which decides the
+	// methods it may call and the notifications it is sent.
+	user *rpcUser
 
 	// sessionID is a random ID generated for each client when connected.
 	// These IDs may be queried by a client using the session RPC.  A change
@@ -1240,6 +1313,10 @@ type wsClient struct {
 	// `rescanblocks` methods.
 	filterData *wsClientFilter
 
+	// chainEventsQuit stops the goroutine streaming the chain event log to
+	// the client, it is nil unless notifychainevents was requested.
+	chainEventsQuit chan struct{}
+
 	// Networking infrastructure.
 	serviceRequestSem semaphore
 	ntfnChan          chan []byte
@@ -1331,7 +1408,10 @@ out:
 			c.SendMessage(reply, nil)
 			continue
 		}
-		log.Debugf("Received command <%s> from %s", cmd.method, c.addr)
+		if c.authenticated {
+			log.Infof("Received command <%s> from %s [%s]", cmd.method,
+				c.addr, c.user.name)
+		}
 
 		// Check auth.  The client is immediately disconnected if the
 		// first request of an unauthentiated websocket client is not
@@ -1349,17 +1429,18 @@ out:
 			break out
 		case !c.authenticated:
 			// Check credentials.
-			login := authCmd.Username + ":" + authCmd.Passphrase
-			auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
-			authSha := sha256.Sum256([]byte(auth))
-			cmp := subtle.ConstantTimeCompare(authSha[:], c.server.authsha[:])
-			limitcmp := subtle.ConstantTimeCompare(authSha[:], c.server.limitauthsha[:])
-			if cmp != 1 && limitcmp != 1 {
+			user := c.server.auth.authenticate(basicAuthHeader(
+				authCmd.Username, authCmd.Passphrase))
+			if user == nil {
 				log.Warnf("Auth failure.")
 				break out
 			}
+			c.Lock()
+			c.user = user
+			c.Unlock()
 			c.authenticated = true
-			c.isAdmin = cmp == 1
+			log.Infof("Websocket client %s authenticated as %s",
+				c.addr, user.name)
 
 			// Marshal and send response.
 			reply, err := createMarshalledReply(cmd.id, nil, nil)
@@ -1372,25 +1453,22 @@ out:
 			continue
 		}
 
-		// Check if the client is using limited RPC credentials and
-		// error when not authorized to call this RPC.
-		if !c.isAdmin {
-			if _, ok := rpcLimited[request.Method]; !ok {
-				jsonErr := btcjson.NewRPCError(
-					btcjson.ErrRPCInvalidParams,
-					"limited user not authorized for this method",
-					nil,
-				)
-				// Marshal and send response.
-				reply, err := createMarshalledReply(request.ID, nil, jsonErr)
-				if err != nil {
-					log.Errorf("Failed to marshal parse failure "+
-						"reply: %v", err)
-					continue
-				}
-				c.SendMessage(reply, nil)
+		// Check if the client's user may call this RPC and error when
+		// not authorized to.
+		jsonErr := c.user.checkMethod(request.Method)
+		if jsonErr == nil {
+			jsonErr = c.server.rateLimiter.check(c.user.name, c.addr, cmd)
+		}
+		if jsonErr != nil {
+			// Marshal and send response.
+			reply, err := createMarshalledReply(request.ID, nil, jsonErr)
+			if err != nil {
+				log.Errorf("Failed to marshal parse failure "+
+					"reply: %v", err)
 				continue
 			}
+			c.SendMessage(reply, nil)
+			continue
 		}
 
 		// Asynchronously handle the request.  A semaphore is used to
@@ -1604,6 +1682,14 @@ func (c *wsClient) QueueNotification(marshalledJSON []byte) er.R {
 		return ErrClientQuit.Default()
 	}
 
+	// Drop notifications the client's user may not receive.
+	c.Lock()
+	user := c.user
+	c.Unlock()
+	if user != nil && !user.mayNotifyJSON(marshalledJSON) {
+		return nil
+	}
+
 	c.ntfnChan <- marshalledJSON
 	return nil
 }
@@ -1657,7 +1743,7 @@ func (c *wsClient) WaitForShutdown() {
 // incoming and outgoing messages in separate goroutines complete with queuing
 // and asynchrous handling for long-running operations.
 func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
-	remoteAddr string, authenticated bool, isAdmin bool) (*wsClient, er.R) {
+	remoteAddr string, user *rpcUser) (*wsClient, er.R) {
 
 	sessionID, err := wire.RandomUint64()
 	if err != nil {
@@ -1667,8 +1753,8 @@ func newWebsocketClient(server *rpcServer, conn *websocket.Conn,
 	client := &wsClient{
 		conn:              conn,
 		addr:              remoteAddr,
-		authenticated:     authenticated,
-		isAdmin:           isAdmin,
+		authenticated:     user != nil,
+		user:              user,
 		sessionID:         sessionID,
 		server:            server,
 		addrRequests:      make(map[string]struct{}),
@@ -1782,6 +1868,85 @@ func handleNotifyBlocks(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
 	return nil, nil
 }
 
+// handleNotifyChainEvents implements the notifychainevents command extension
+// for websocket connections.  It replaces any chain event stream the client
+// already has with one starting from the requested position.
+func handleNotifyChainEvents(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
+	cmd, ok := icmd.(*btcjson.NotifyChainEventsCmd)
+	if !ok {
+		return nil, btcjson.NewErrRPCInternal()
+	}
+
+	var from string
+	if cmd.From != nil {
+		from = *cmd.From
+	}
+	stream, err := wsc.server.cfg.ChainEvents.subscribe(from)
+	if err != nil {
+		return nil, btcjson.NewRPCError(
+			btcjson.ErrRPCInvalidParameter,
+			err.Message(),
+			nil,
+		)
+	}
+
+	quit := make(chan struct{})
+	wsc.Lock()
+	if wsc.chainEventsQuit != nil {
+		close(wsc.chainEventsQuit)
+	}
+	wsc.chainEventsQuit = quit
+	wsc.Unlock()
+	go wsc.streamChainEvents(stream, quit)
+	return nil, nil
+}
+
+// handleStopNotifyChainEvents implements the stopnotifychainevents command
+// extension for websocket connections.
+func handleStopNotifyChainEvents(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
+	wsc.stopChainEvents()
+	return nil, nil
+}
+
+// streamChainEvents sends the events in the stream to the client as chainevent
+// notifications until quit is closed.  The client is disconnected if it falls
+// so far behind that the events it needs are no longer retained, it can then
+// reconnect and resume by block hash.
+func (c *wsClient) streamChainEvents(stream *chainEventStream, quit chan struct{}) {
+	for {
+		events, err := stream.next(quit)
+		if err != nil {
+			log.Warnf("Disconnecting websocket client %s: %v", c.addr, err)
+			c.Disconnect()
+			return
+		}
+		if events == nil {
+			return
+		}
+		for i := range events {
+			ntfn := btcjson.NewChainEventNtfn(events[i])
+			marshalled, err := btcjson.MarshalCmd(nil, ntfn)
+			if err != nil {
+				log.Errorf("Failed to marshal chain event: %v", err)
+				continue
+			}
+			if err := c.QueueNotification(marshalled); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// stopChainEvents stops streaming the chain event log to the client.
+func (c *wsClient) stopChainEvents() {
+	c.Lock()
+	if c.chainEventsQuit != nil {
+		close(c.chainEventsQuit)
+		c.chainEventsQuit = nil
+	}
+	c.Unlock()
+}
+
 // handleSession implements the session command extension for websocket
 // connections.
 func handleSession(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
@@ -1825,6 +1990,20 @@ func handleNotifyNewTransactions(wsc *wsClient, icmd interface{}) (interface{},
 	return nil, nil
 }
 
+// handleNotifyMempoolEvents implements the notifymempoolevents command
+// extension for websocket connections.
+func handleNotifyMempoolEvents(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
+	wsc.server.ntfnMgr.RegisterMempoolEvents(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyMempoolEvents implements the stopnotifymempoolevents command
+// extension for websocket connections.
+func handleStopNotifyMempoolEvents(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
+	wsc.server.ntfnMgr.UnregisterMempoolEvents(wsc)
+	return nil, nil
+}
+
 // handleStopNotifyNewTransations implements the stopnotifynewtransactions
 // command extension for websocket connections.
 func handleStopNotifyNewTransactions(wsc *wsClient, icmd interface{}) (interface{}, er.R) {
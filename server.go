@@ -14,6 +14,8 @@ import (
 	"math"
 	mathrand "math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strconv"
@@ -58,17 +60,43 @@ const (
 	// required to be supported by outbound peers.
 	defaultRequiredServices = protocol.SFNodeNetwork
 
-	// defaultTargetOutbound is the default number of outbound peers to
-	// target. We are normalizing the Bitcoin Core in allowing 16 here,
-	// For Bitcoin Core latest Bitcoin Core, 14 connections are used for
-	// full relaying and 2 are used for "block only" "fast" connections,
-	// although we don't yet make such a distinction.
+	// defaultTargetOutbound is the default number of full-relay outbound
+	// peers to target.  As with Bitcoin Core, the block-relay-only
+	// connections configured with --blockrelayonlyconns are made in
+	// addition to these.
 	defaultTargetOutbound = 14
 
+	// feelerInterval is the interval between feeler connections which
+	// test whether addresses learned from the network are reachable.
+	feelerInterval = time.Minute * 2
+
+	// anchorsFilename is the name of the file in the data directory where
+	// the block-relay-only peers are saved on shutdown so they can be
+	// reconnected to on startup.
+	anchorsFilename = "anchors.json"
+
+	// banListFilename is the name of the file in the data directory where
+	// bans are saved so they persist across restarts.
+	banListFilename = "banlist.json"
+
+	// mempoolFilename is the name of the file in the data directory where
+	// the transactions of the mempool are saved on shutdown so they can be
+	// loaded on startup.
+	mempoolFilename = "mempool.dat"
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// v2TransportHintTimeout is how long whether an outbound peer supports
+	// the v2 transport is remembered, after which the services known to
+	// the address manager are consulted again.
+	v2TransportHintTimeout = time.Hour * 24
+
+	// maxV2TransportHints is the maximum number of addresses whose support
+	// of the v2 transport is remembered.
+	maxV2TransportHints = 1000
 )
 
 // simpleAddr implements the net.Addr interface with two struct fields
@@ -128,12 +156,11 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
 }
 
@@ -190,6 +217,7 @@ type server struct {
 	syncManager          *netsync.SyncManager
 	chain                *blockchain.BlockChain
 	txMemPool            *mempool.TxPool
+	mempoolPersist       *mempoolPersist
 	cpuMiner             *cpuminer.CPUMiner
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
@@ -205,7 +233,7 @@ type server struct {
 	db                   database.DB
 	timeSource           blockchain.MedianTimeSource
 	services             protocol.ServiceFlag
-	banMgr               banmgr.BanMgr
+	banMgr               *banmgr.BanMgr
 
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
@@ -231,13 +259,56 @@ type server struct {
 	// agentWhitelist is a list of whitelisted user agent substrings, no
 	// whitelisting will be applied if the list is empty or nil.
 	agentWhitelist []string
+
+	// evictionKey is a random secret used to hash the network groups of
+	// inbound peers when choosing one to evict.
+	evictionKey uint64
+
+	// uploadTarget tracks the bytes sent against the --maxuploadtarget
+	// setting.
+	uploadTarget *uploadTarget
+
+	// uploadRate limits the combined upload rate of all peers, it is nil
+	// if there is no limit.
+	uploadRate *tokenBucket
+
+	// msgStats accounts the traffic of all peers by message type.
+	msgStats *msgStats
+
+	// blockProcessTime is a histogram of the time taken to process blocks.
+	blockProcessTime *histogram
+
+	// metricsServer serves metrics to Prometheus, it is nil unless
+	// --metricslisten is set.
+	metricsServer *metricsServer
+
+	// tipNotifier runs the --blocknotify and --stewardnotify commands, it
+	// is nil unless one of them is set.
+	tipNotifier *tipNotifier
+
+	// webhooks delivers chain and mempool events to the --webhook URLs,
+	// it is nil unless one is set.
+	webhooks *webhookManager
+
+	// chainEvents is the ordered log of blocks connected to and
+	// disconnected from the main chain streamed to RPC clients.
+	chainEvents *chainEventLog
+
+	// v2TransportHints records, by address, whether outbound peers are
+	// known to support the v2 transport.  It is learned from the services
+	// advertised in their version message and from handshakes which found
+	// them using the v1 transport.
+	v2TransportHints    map[string]v2TransportHint
+	v2TransportHintsMtx sync.Mutex
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
 // the blockmanager.
 type serverPeer struct {
 	// The following variables must only be used atomically
-	feeFilter int64
+	feeFilter     int64
+	lastBlockTime int64 // Unix time the peer last relayed a new block.
+	lastTxTime    int64 // Unix time the peer last relayed a new tx.
 
 	*peer.Peer
 
@@ -248,10 +319,11 @@ type serverPeer struct {
 	relayMtx       sync.Mutex
 	disableRelayTx bool
 	sentAddrs      bool
-	isWhitelisted  bool
+	permissions    netPermissions
 	filter         *bloom.Filter
 	addressesMtx   sync.RWMutex
 	knownAddresses map[string]struct{}
+	msgStats       *msgStats
 	quit           chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
@@ -266,12 +338,41 @@ func newServerPeer(s *server, isPersistent bool) *serverPeer {
 		persistent:     isPersistent,
 		filter:         bloom.LoadFilter(nil),
 		knownAddresses: make(map[string]struct{}),
+		msgStats:       newMsgStats(),
 		quit:           make(chan struct{}),
 		txProcessed:    make(chan struct{}, 1),
 		blockProcessed: make(chan struct{}, 1),
 	}
 }
 
+// connType returns the type of the outbound connection to the peer.  Inbound
+// peers are always full-relay.
+func (sp *serverPeer) connType() connmgr.ConnType {
+	if sp.connReq == nil {
+		return connmgr.ConnFullRelay
+	}
+	return sp.connReq.Type
+}
+
+// isBlockRelayOnly returns whether transactions and addresses must not be
+// relayed to or accepted from the peer.
+func (sp *serverPeer) isBlockRelayOnly() bool {
+	return sp.connType() == connmgr.ConnBlockRelayOnly
+}
+
+// hasPermission returns whether the peer has been granted the permission by
+// the whitelist or whitebind options.
+func (sp *serverPeer) hasPermission(perm netPermissions) bool {
+	return sp.permissions&perm == perm
+}
+
+// blocksOnly returns whether transactions from the peer are ignored because
+// blocksonly mode is enabled and the peer has not been granted the relay
+// permission.
+func (sp *serverPeer) blocksOnly() bool {
+	return cfg.BlocksOnly && !sp.hasPermission(permRelay)
+}
+
 // newestBlock returns the current best block hash and height using the format
 // required by the configuration for the peer package.
 func (sp *serverPeer) newestBlock() (*chainhash.Hash, int32, er.R) {
@@ -342,6 +443,10 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
 // the score is above the ban threshold, the peer will be banned and
 // disconnected.
 func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
+	if sp.hasPermission(permNoBan) {
+		log.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
+		return
+	}
 	if sp.server.banMgr.AddBanScore(sp.Addr(), persistent, transient, reason) {
 		sp.server.BanPeer(sp)
 		sp.Disconnect()
@@ -374,6 +479,10 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) *wire.MsgRej
 	if !cfg.SimNet && !isInbound {
 		addrManager.SetServices(remoteAddr, msg.Services)
 	}
+	if !isInbound {
+		sp.server.setV2TransportHint(sp.Addr(),
+			hasServices(msg.Services, protocol.SFNodeP2PV2))
+	}
 
 	// Ignore peers that have a protcol version that is too old.  The peer
 	// negotiation logic will disconnect it after this callback returns.
@@ -434,8 +543,10 @@ func (sp *serverPeer) OnVerAck(_ *peer.Peer, _ *wire.MsgVerAck) {
 // bloom filter loaded, the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// Only allow mempool requests if the server has bloom filtering
-	// enabled.
-	if sp.server.services&protocol.SFNodeBloom != protocol.SFNodeBloom {
+	// enabled or the peer has been granted the mempool permission.
+	if sp.server.services&protocol.SFNodeBloom != protocol.SFNodeBloom &&
+		!sp.hasPermission(permMempool) {
+
 		log.Debugf("peer %v sent mempool request with bloom "+
 			"filtering disabled -- disconnecting", sp)
 		sp.Disconnect()
@@ -445,8 +556,11 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 	// A decaying ban score increase is applied to prevent flooding.
 	// The ban score accumulates and passes the ban threshold if a burst of
 	// mempool messages comes from a peer. The score decays each minute to
-	// half of its value.
-	sp.addBanScore(0, 33, "mempool")
+	// half of its value.  Peers with the mempool permission may request it
+	// as often as they like.
+	if !sp.hasPermission(permMempool) {
+		sp.addBanScore(0, 33, "mempool")
+	}
 
 	// Generate inventory message with the available transactions in the
 	// transaction memory pool.  Limit it to the max allowed inventory
@@ -481,11 +595,17 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
 // handler this does not serialize all transactions through a single thread
 // transactions don't rely on the previous one in a linear fashion like blocks.
 func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
-	if cfg.BlocksOnly {
+	if sp.blocksOnly() {
 		log.Tracef("Ignoring tx %v from %v - blocksonly enabled",
 			msg.TxHash(), sp)
 		return
 	}
+	if sp.isBlockRelayOnly() {
+		log.Debugf("Block-relay-only peer %v sent a transaction -- "+
+			"disconnecting", sp)
+		sp.Disconnect()
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a btcutil.Tx which provides some convenience
@@ -499,8 +619,17 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 	// processed and known good or bad.  This helps prevent a malicious peer
 	// from queuing up a bunch of bad transactions before disconnecting (or
 	// being disconnected) and wasting memory.
-	sp.server.syncManager.QueueTx(tx, sp.Peer, sp.txProcessed)
+	txMemPool := sp.server.txMemPool
+	haveTx := txMemPool.HaveTransaction(tx.Hash())
+	sp.server.syncManager.QueueTx(tx, sp.Peer,
+		sp.hasPermission(permForceRelay), sp.txProcessed)
 	<-sp.txProcessed
+
+	// Remember when the peer last gave us a transaction we accepted so it
+	// can be protected from eviction.
+	if !haveTx && txMemPool.IsTransactionInPool(tx.Hash()) {
+		atomic.StoreInt64(&sp.lastTxTime, time.Now().Unix())
+	}
 }
 
 // OnBlock is invoked when a peer receives a block bitcoin message.  It
@@ -525,8 +654,17 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	// reference implementation processes blocks in the same
 	// thread and therefore blocks further messages until
 	// the bitcoin block has been fully processed.
+	haveBlock, _ := sp.server.chain.HaveBlock(block.Hash())
 	sp.server.syncManager.QueueBlock(block, sp.Peer, sp.blockProcessed)
 	<-sp.blockProcessed
+
+	// Remember when the peer last gave us a new block so it can be
+	// protected from eviction.
+	if !haveBlock {
+		if have, _ := sp.server.chain.HaveBlock(block.Hash()); have {
+			atomic.StoreInt64(&sp.lastBlockTime, time.Now().Unix())
+		}
+	}
 }
 
 // OnInv is invoked when a peer receives an inv bitcoin message and is
@@ -534,7 +672,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !sp.blocksOnly() && !sp.isBlockRelayOnly() {
 		if len(msg.InvList) > 0 {
 			sp.server.syncManager.QueueInv(msg, sp.Peer)
 		}
@@ -545,7 +683,8 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	for _, invVect := range msg.InvList {
 		if invVect.Type == wire.InvTypeTx {
 			log.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"blocksonly enabled or block-relay-only peer",
+				invVect.Hash, sp)
 			if sp.ProtocolVersion() >= protocol.BIP0037Version {
 				log.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
@@ -694,8 +833,9 @@ func (sp *serverPeer) OnGetBlocks(_ *peer.Peer, msg *wire.MsgGetBlocks) {
 // OnGetHeaders is invoked when a peer receives a getheaders bitcoin
 // message.
 func (sp *serverPeer) OnGetHeaders(_ *peer.Peer, msg *wire.MsgGetHeaders) {
-	// Ignore getheaders requests if not in sync.
-	if !sp.server.syncManager.IsCurrent() {
+	// Ignore getheaders requests if not in sync, unless the peer has been
+	// granted the download permission.
+	if !sp.server.syncManager.IsCurrent() && !sp.hasPermission(permDownload) {
 		return
 	}
 
@@ -1213,6 +1353,12 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Addresses are not relayed over block-relay-only connections.
+	if sp.isBlockRelayOnly() {
+		log.Debugf("Ignoring addr from block-relay-only peer %v", sp)
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < protocol.NetAddressTimeVersion {
 		return
@@ -1251,15 +1397,19 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 }
 
 // OnRead is invoked when a peer receives a message and it is used to update
-// the bytes received by the server.
+// the bytes received by the server, in total and by message type.
 func (sp *serverPeer) OnRead(_ *peer.Peer, bytesRead int, msg wire.Message, err er.R) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	sp.msgStats.addRecv(msg, bytesRead)
+	sp.server.msgStats.addRecv(msg, bytesRead)
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
-// the bytes sent by the server.
+// the bytes sent by the server, in total and by message type.
 func (sp *serverPeer) OnWrite(_ *peer.Peer, bytesWritten int, msg wire.Message, err er.R) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	sp.msgStats.addSent(msg, bytesWritten)
+	sp.server.msgStats.addSent(msg, bytesWritten)
 }
 
 // randomUint16Number returns a random uint16 in a specified input range.  Note
@@ -1328,6 +1478,11 @@ func (s *server) AnnounceNewTransactions(txns []*mempool.TxDesc) {
 	if s.rpcServer != nil {
 		s.rpcServer.NotifyNewTransactions(txns)
 	}
+
+	// Send webhooks for transactions paying to watched addresses.
+	if s.webhooks != nil {
+		s.webhooks.NotifyTransactions(txns)
+	}
 }
 
 // Transaction has one confirmation on the main chain. Now we can mark it as no
@@ -1406,6 +1561,18 @@ func (s *server) pushBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneChan cha
 		return err
 	}
 
+	// Do not serve historical blocks once the upload target is reached.
+	if s.historicalBlockLimited(sp, msgBlock.Header.Timestamp) {
+		log.Infof("Upload target reached, disconnecting peer %s "+
+			"which requested historical block %v", sp, hash)
+		sp.Disconnect()
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return er.Errorf("upload target reached")
+	}
+
 	// Once we have fetched data wait for any previous operation to finish.
 	if waitChan != nil {
 		<-waitChan
@@ -1464,6 +1631,18 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash,
 		return err
 	}
 
+	// Do not serve historical blocks once the upload target is reached.
+	if s.historicalBlockLimited(sp, blk.MsgBlock().Header.Timestamp) {
+		log.Infof("Upload target reached, disconnecting peer %s "+
+			"which requested historical block %v", sp, hash)
+		sp.Disconnect()
+
+		if doneChan != nil {
+			doneChan <- struct{}{}
+		}
+		return er.Errorf("upload target reached")
+	}
+
 	// Generate a merkle block by filtering the requested block according
 	// to the filter for the peer.
 	merkle, matchedTxIndices := bloom.NewMerkleBlock(blk, sp.filter)
@@ -1546,23 +1725,19 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		return false
 	}
 
-	// Disconnect banned peers.
-	host, _, err := net.SplitHostPort(sp.Addr())
-	if err != nil {
-		log.Debugf("can't split hostport %v", err)
+	// Disconnect banned peers unless they are protected from banning.
+	if !sp.hasPermission(permNoBan) && s.banMgr.IsBanned(sp.Addr()) {
 		sp.Disconnect()
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			log.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, time.Until(banEnd))
-			sp.Disconnect()
-			return false
-		}
 
-		log.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	// Feeler connections only test that the address is reachable, so mark
+	// it as good and disconnect.
+	if sp.connType() == connmgr.ConnFeeler {
+		log.Debugf("Feeler connection to %s succeeded", sp)
+		s.addrManager.Good(sp.NA())
+		sp.Disconnect()
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
@@ -1570,7 +1745,13 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// Rapid reconnect
 	sp.addBanScore(0, 10, "connect")
 
-	// Limit max number of total peers.
+	// Limit max number of total peers.  When full, a new inbound peer may
+	// take the place of the least useful existing inbound peer.
+	if state.Count() >= cfg.MaxPeers && sp.Inbound() &&
+		s.evictInboundPeer(state) {
+
+		log.Debugf("Evicted a peer to accept inbound peer %s", sp)
+	}
 	if state.Count() >= cfg.MaxPeers {
 		log.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
@@ -1607,8 +1788,10 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if !cfg.SimNet && !sp.Inbound() {
 		// Advertise the local address when the server accepts incoming
 		// connections and it believes itself to be close to the best
-		// known tip.
-		if !cfg.DisableListen && s.syncManager.IsCurrent() {
+		// known tip.  Addresses are never exchanged with
+		// block-relay-only peers.
+		blockRelayOnly := sp.isBlockRelayOnly()
+		if !cfg.DisableListen && !blockRelayOnly && s.syncManager.IsCurrent() {
 			// Get address that best matches.
 			lna := s.addrManager.LocalExternal.GetBest(sp.NA())
 			if addrutil.IsRoutable(lna) {
@@ -1622,7 +1805,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 		// more and the peer has a protocol version new enough to
 		// include a timestamp with addresses.
 		hasTimestamp := sp.ProtocolVersion() >= protocol.NetAddressTimeVersion
-		if s.addrManager.NeedMoreAddresses() && hasTimestamp {
+		if s.addrManager.NeedMoreAddresses() && hasTimestamp && !blockRelayOnly {
 			sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 		}
 
@@ -1649,11 +1832,26 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	// our connection manager about the disconnection. This can happen if we
 	// process a peer's `done` message before its `add`.
 	if !sp.Inbound() {
+		v2Failed := sp.V2HandshakeFailed()
+		if v2Failed {
+			// Remember that the peer uses v1 so the next attempt to
+			// this address, including the retry below, uses v1.
+			log.Debugf("%s is using the v1 transport, falling back to v1", sp)
+			s.setV2TransportHint(sp.Addr(), false)
+		}
+		connType := sp.connType()
 		if sp.persistent {
 			s.connManager.Disconnect(sp.connReq.ID())
 		} else {
 			s.connManager.Remove(sp.connReq.ID())
-			go s.connManager.NewConnReq()
+			if v2Failed {
+				go s.connManager.Connect(&connmgr.ConnReq{
+					Addr: sp.connReq.Addr,
+					Type: connType,
+				})
+			} else if connType != connmgr.ConnFeeler {
+				go s.connManager.NewConnReqWithType(connType)
+			}
 		}
 	}
 	if _, ok := list[sp.ID()]; ok {
@@ -1666,18 +1864,13 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 }
 
-// handleBanPeerMsg deals with banning peers.  It is invoked from the
+// handleBanPeerMsg deals with banning peers.  The ban itself is recorded by
+// the ban manager, so it only needs to be reported.  It is invoked from the
 // peerHandler goroutine.
 func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
-	host, _, err := net.SplitHostPort(sp.Addr())
-	if err != nil {
-		log.Debugf("can't split ban peer %s %v", sp.Addr(), err)
-		return
-	}
 	direction := directionString(sp.Inbound())
-	log.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+	log.Infof("Banned peer %s (%s) for %v", banmgr.TrimAddress(sp.Addr()),
+		direction, cfg.BanDuration)
 }
 
 func (s *server) sendInvMsgToPeer(sp *serverPeer, msg relayMsg) bool {
@@ -1706,8 +1899,8 @@ func (s *server) sendInvMsgToPeer(sp *serverPeer, msg relayMsg) bool {
 
 	if msg.invVect.Type == wire.InvTypeTx {
 		// Don't relay the transaction to the peer when it has
-		// transaction relaying disabled.
-		if sp.relayTxDisabled() {
+		// transaction relaying disabled or is block-relay-only.
+		if sp.relayTxDisabled() || sp.isBlockRelayOnly() {
 			return false
 		}
 
@@ -1970,21 +2163,90 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 		UserAgentComments: cfg.UserAgentComments,
 		ChainParams:       sp.server.chainParams,
 		Services:          sp.server.services,
-		DisableRelayTx:    cfg.BlocksOnly,
+		DisableRelayTx:    sp.blocksOnly(),
 		ProtocolVersion:   peer.MaxProtocolVersion,
 		TrickleInterval:   cfg.TrickleInterval,
+		V2Transport:       !cfg.NoV2Transport,
+	}
+}
+
+// v2TransportHint records whether an outbound peer supports the v2 transport.
+type v2TransportHint struct {
+	supported bool
+	expires   time.Time
+}
+
+// setV2TransportHint records whether the peer at addr supports the v2
+// transport.  When too many addresses are recorded, the expired hints are
+// dropped, or arbitrary ones if none has expired.
+//
+// This function is safe for concurrent access.
+func (s *server) setV2TransportHint(addr string, supported bool) {
+	now := time.Now()
+	s.v2TransportHintsMtx.Lock()
+	defer s.v2TransportHintsMtx.Unlock()
+
+	_, exists := s.v2TransportHints[addr]
+	if !exists && len(s.v2TransportHints) >= maxV2TransportHints {
+		for hintAddr, hint := range s.v2TransportHints {
+			if now.After(hint.expires) {
+				delete(s.v2TransportHints, hintAddr)
+			}
+		}
+		for hintAddr := range s.v2TransportHints {
+			if len(s.v2TransportHints) < maxV2TransportHints {
+				break
+			}
+			delete(s.v2TransportHints, hintAddr)
+		}
+	}
+	s.v2TransportHints[addr] = v2TransportHint{
+		supported: supported,
+		expires:   now.Add(v2TransportHintTimeout),
 	}
 }
 
+// useV2Transport returns whether an outbound connection to the passed peer
+// should attempt the v2 transport.  Addresses which were recently found using
+// the v1 transport or did not advertise support use v1, otherwise the services
+// known to the address manager are consulted.
+//
+// This function is safe for concurrent access.
+func (s *server) useV2Transport(addr net.Addr) bool {
+	if cfg.NoV2Transport {
+		return false
+	}
+	s.v2TransportHintsMtx.Lock()
+	hint, ok := s.v2TransportHints[addr.String()]
+	if ok && time.Now().After(hint.expires) {
+		delete(s.v2TransportHints, addr.String())
+		ok = false
+	}
+	s.v2TransportHintsMtx.Unlock()
+	if ok {
+		return hint.supported
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	services := s.addrManager.GetServices(wire.NewNetAddress(tcpAddr, 0))
+	return hasServices(services, protocol.SFNodeP2PV2)
+}
+
 // inboundPeerConnected is invoked by the connection manager when a new inbound
 // connection is established.  It initializes a new inbound server peer
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
 	sp := newServerPeer(s, false)
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
+	sp.permissions = peerPermissions(conn.RemoteAddr(), conn.LocalAddr())
+	if sp.permissions != 0 {
+		log.Debugf("Granting permissions %v to inbound peer %s",
+			sp.permissions, conn.RemoteAddr())
+	}
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
-	sp.AssociateConnection(conn)
+	sp.AssociateConnection(s.limitUpload(sp, conn))
 	go s.peerDoneHandler(sp)
 }
 
@@ -1995,7 +2257,14 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
-	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
+	sp.permissions = peerPermissions(conn.RemoteAddr(), nil)
+	peerCfg := newPeerConfig(sp)
+	peerCfg.V2Transport = s.useV2Transport(c.Addr)
+	if c.Type != connmgr.ConnFullRelay {
+		// Ask the peer not to announce transactions to us.
+		peerCfg.DisableRelayTx = true
+	}
+	p, err := peer.NewOutboundPeer(peerCfg, c.Addr.String())
 	if err != nil {
 		log.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
 		if c.Permanent {
@@ -2008,8 +2277,7 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	}
 	sp.Peer = p
 	sp.connReq = c
-	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
-	sp.AssociateConnection(conn)
+	sp.AssociateConnection(s.limitUpload(sp, conn))
 	go s.peerDoneHandler(sp)
 }
 
@@ -2081,7 +2349,6 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
@@ -2137,6 +2404,10 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Remember the block-relay-only peers so they can be
+			// reconnected to on the next start.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				log.Tracef("Shutdown peer %s", sp)
@@ -2216,6 +2487,7 @@ func (s *server) OutboundGroupCount(key string) int {
 // for the server.  It is safe for concurrent access.
 func (s *server) AddBytesSent(bytesSent uint64) {
 	atomic.AddUint64(&s.bytesSent, bytesSent)
+	s.uploadTarget.addBytes(bytesSent)
 }
 
 // AddBytesReceived adds the passed number of bytes to the total bytes received
@@ -2299,6 +2571,14 @@ cleanup:
 	s.wg.Done()
 }
 
+// handleMempoolEvent passes transactions added to or removed from the mempool
+// to the RPC server for its websocket clients.
+func (s *server) handleMempoolEvent(ev *mempool.Event) {
+	if s.rpcServer != nil {
+		s.rpcServer.ntfnMgr.NotifyMempoolEvent(ev)
+	}
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -2313,6 +2593,19 @@ func (s *server) Start() {
 	s.wg.Add(1)
 	go s.peerHandler()
 
+	// Load the transactions saved by the previous run into the mempool.
+	if cfg.NoPersistMempool {
+		atomic.StoreInt32(&s.mempoolPersist.loaded, 1)
+	} else {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			if _, _, err := s.mempoolPersist.load(s.quit); err != nil {
+				log.Warnf("Failed to load the mempool: %v", err)
+			}
+		}()
+	}
+
 	if s.nat != nil {
 		s.wg.Add(1)
 		go s.upnpUpdateThread()
@@ -2328,6 +2621,18 @@ func (s *server) Start() {
 		s.rpcServer.Start()
 	}
 
+	if s.metricsServer != nil {
+		s.metricsServer.Start()
+	}
+
+	if s.tipNotifier != nil {
+		s.tipNotifier.start(s.quit)
+	}
+
+	if s.webhooks != nil {
+		s.webhooks.Start()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
@@ -2353,6 +2658,20 @@ func (s *server) Stop() er.R {
 		s.rpcServer.Stop()
 	}
 
+	if s.metricsServer != nil {
+		s.metricsServer.Stop()
+	}
+
+	// Save the mempool unless it is still being loaded, which would lose
+	// the transactions which were not loaded yet.
+	if !cfg.NoPersistMempool {
+		if !s.mempoolPersist.isLoaded() {
+			log.Infof("Not saving the mempool as it was not loaded yet")
+		} else if _, err := s.mempoolPersist.save(); err != nil {
+			log.Errorf("Failed to save the mempool: %v", err)
+		}
+	}
+
 	// Save fee estimator state in the database.
 	s.db.Update(func(tx database.Tx) er.R {
 		metadata := tx.Metadata()
@@ -2363,6 +2682,17 @@ func (s *server) Stop() er.R {
 
 	// Signal the remaining goroutines to quit.
 	close(s.quit)
+
+	// Wait for a running notify command to finish.
+	if s.tipNotifier != nil {
+		s.tipNotifier.stop()
+	}
+
+	if s.webhooks != nil {
+		s.webhooks.Stop()
+	}
+
+	s.chainEvents.Close()
 	return nil
 }
 
@@ -2523,9 +2853,45 @@ func setupRPCListeners() ([]net.Listener, er.R) {
 		listeners = append(listeners, listener)
 	}
 
+	if cfg.RPCUnix != "" {
+		listener, err := listenRPCUnix(cfg.RPCUnix)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+
 	return listeners, nil
 }
 
+// listenRPCUnix listens for RPC connections on a Unix domain socket at path.
+// A socket left behind by an earlier run is replaced, and the new socket may
+// only be connected to by the owner of the process.
+func listenRPCUnix(path string) (net.Listener, er.R) {
+	if fi, errr := os.Lstat(path); errr == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, er.Errorf("RPC socket path %s exists and is "+
+				"not a socket", path)
+		}
+		if errr := os.Remove(path); errr != nil {
+			return nil, er.E(errr)
+		}
+	}
+	listener, errr := listenUnixPrivate(path)
+	if errr != nil {
+		return nil, er.E(errr)
+	}
+	if errr := os.Chmod(path, 0600); errr != nil {
+		listener.Close()
+		return nil, er.E(errr)
+	}
+	log.Infof("RPC server listening on %s", path)
+	return listener, nil
+}
+
 func (s *server) peerCount() int {
 	replyChan := make(chan []*serverPeer)
 	s.query <- getPeersMsg{reply: replyChan}
@@ -2547,6 +2913,9 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if cfg.NoCFilters {
 		services &^= protocol.SFNodeCF
 	}
+	if !cfg.NoV2Transport {
+		services |= protocol.SFNodeP2PV2
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, pktdLookup)
 
@@ -2574,6 +2943,16 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		DisableBanning: cfg.DisableBanning,
 		IpWhiteList:    []string{},
 		BanThreashold:  cfg.BanThreshold,
+		BanDuration:    cfg.BanDuration,
+		BanListFile:    filepath.Join(cfg.DataDir, banListFilename),
+	}
+
+	// The eviction key must not be predictable, or attackers could tell
+	// which network groups are protected from eviction.
+	var evictionKey uint64
+	errr := binary.Read(rand.Reader, binary.LittleEndian, &evictionKey)
+	if errr != nil {
+		return nil, er.E(errr)
 	}
 
 	s := server{
@@ -2598,7 +2977,16 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		cfCheckptCaches:      make(map[wire.FilterType][]cfHeaderKV),
 		agentBlacklist:       agentBlacklist,
 		agentWhitelist:       agentWhitelist,
-		banMgr:               *banmgr.New(&bmConfig),
+		banMgr:               banmgr.New(&bmConfig),
+		v2TransportHints:     make(map[string]v2TransportHint),
+		evictionKey:          evictionKey,
+		uploadTarget: newUploadTarget(cfg.MaxUploadTarget*1024*1024,
+			chainParams.TargetTimePerBlock),
+		msgStats:         newMsgStats(),
+		blockProcessTime: newHistogram(latencyBuckets),
+	}
+	if cfg.MaxUploadRate > 0 {
+		s.uploadRate = newTokenBucket(cfg.MaxUploadRate * 1024)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2660,6 +3048,15 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 	if err != nil {
 		return nil, err
 	}
+	s.tipNotifier = newTipNotifier(s.chain)
+	s.webhooks, err = newWebhookManager(s.chain, s.chainParams)
+	if err != nil {
+		return nil, err
+	}
+	s.chainEvents, err = newChainEventLog(s.chain, cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
 
 	// Search for a FeeEstimator state in the database. If none can be found
 	// or if it cannot be loaded, create a new one.
@@ -2702,6 +3099,12 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
 			RejectReplacement:    cfg.RejectReplacement,
+			MaxPoolSize:          int64(cfg.MaxMempool) * 1000000,
+			MaxTxAge:             time.Duration(cfg.MempoolExpiry) * time.Hour,
+			MaxAncestors:         cfg.LimitAncestorCount,
+			MaxAncestorSize:      int64(cfg.LimitAncestorSize) * 1000,
+			MaxDescendants:       cfg.LimitDescendantCount,
+			MaxDescendantSize:    int64(cfg.LimitDescendantSize) * 1000,
 		},
 		ChainParams:    chainParams,
 		FetchUtxoView:  s.chain.FetchUtxoView,
@@ -2715,8 +3118,10 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		HashCache:          s.hashCache,
 		AddrIndex:          s.addrIndex,
 		FeeEstimator:       s.feeEstimator,
+		Notify:             s.handleMempoolEvent,
 	}
 	s.txMemPool = mempool.New(&txC)
+	s.mempoolPersist = newMempoolPersist(s.txMemPool, cfg.DataDir)
 
 	s.syncManager, err = netsync.New(&netsync.Config{
 		PeerNotifier:       &s,
@@ -2726,6 +3131,7 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		DisableCheckpoints: cfg.DisableCheckpoints,
 		MaxPeers:           cfg.MaxPeers,
 		FeeEstimator:       s.feeEstimator,
+		BlockProcessed:     s.blockProcessTime.observeDuration,
 	})
 	if err != nil {
 		return nil, err
@@ -2838,15 +3244,29 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 	}
 
+	// Block-relay-only connections and feelers are only made to addresses
+	// from the address manager, so not when running in connect-only mode.
+	var anchors []net.Addr
+	var targetBlockRelayOnly uint32
+	var feelers time.Duration
+	if newAddressFunc != nil {
+		anchors = loadAnchors()
+		targetBlockRelayOnly = uint32(cfg.BlockRelayOnlyConns)
+		feelers = feelerInterval
+	}
+
 	// Create a connection manager.
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           pktdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       uint32(targetOutbound),
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Anchors:              anchors,
+		FeelerInterval:       feelers,
+		Dial:                 pktdDial,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
 	})
 	if err != nil {
 		return nil, err
@@ -2882,22 +3302,24 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}
 
 		s.rpcServer, err = newRPCServer(&rpcserverConfig{
-			Listeners:    rpcListeners,
-			StartupTime:  s.startupTime,
-			ConnMgr:      &rpcConnManager{&s},
-			SyncMgr:      &rpcSyncMgr{&s, s.syncManager},
-			TimeSource:   s.timeSource,
-			Chain:        s.chain,
-			ChainParams:  chainParams,
-			DB:           db,
-			TxMemPool:    s.txMemPool,
-			Generator:    blockTemplateGenerator,
-			CPUMiner:     s.cpuMiner,
-			TxIndexOrNil: s.txIndex,
-			AddrIndex:    s.addrIndex,
-			CfIndex:      s.cfIndex,
-			FeeEstimator: s.feeEstimator,
-			ServiceFlags: services,
+			Listeners:      rpcListeners,
+			StartupTime:    s.startupTime,
+			ConnMgr:        &rpcConnManager{&s},
+			SyncMgr:        &rpcSyncMgr{&s, s.syncManager},
+			TimeSource:     s.timeSource,
+			Chain:          s.chain,
+			ChainParams:    chainParams,
+			DB:             db,
+			TxMemPool:      s.txMemPool,
+			MempoolPersist: s.mempoolPersist,
+			Generator:      blockTemplateGenerator,
+			CPUMiner:       s.cpuMiner,
+			TxIndexOrNil:   s.txIndex,
+			AddrIndex:      s.addrIndex,
+			CfIndex:        s.cfIndex,
+			FeeEstimator:   s.feeEstimator,
+			ServiceFlags:   services,
+			ChainEvents:    s.chainEvents,
 		})
 		if err != nil {
 			return nil, err
@@ -2910,6 +3332,13 @@ func newServer(listenAddrs, agentBlacklist, agentWhitelist []string,
 		}()
 	}
 
+	if cfg.MetricsListen != "" {
+		s.metricsServer, err = newMetricsServer(&s, cfg.MetricsListen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &s, nil
 }
 
@@ -3074,32 +3503,6 @@ func addLocalAddress(addrMgr *addrmgr.AddrManager, addr string, services protoco
 	return nil
 }
 
-// isWhitelisted returns whether the IP address is included in the whitelisted
-// networks and IPs.
-func isWhitelisted(addr net.Addr) bool {
-	if len(cfg.whitelists) == 0 {
-		return false
-	}
-
-	host, _, err := net.SplitHostPort(addr.String())
-	if err != nil {
-		log.Warnf("Unable to SplitHostPort on '%s': %v", addr, err)
-		return false
-	}
-	ip := net.ParseIP(host)
-	if ip == nil {
-		log.Warnf("Unable to parse IP '%s'", addr)
-		return false
-	}
-
-	for _, ipnet := range cfg.whitelists {
-		if ipnet.Contains(ip) {
-			return true
-		}
-	}
-	return false
-}
-
 // checkpointSorter implements sort.Interface to allow a slice of checkpoints to
 // be sorted.
 type checkpointSorter []chaincfg.Checkpoint
@@ -12,6 +12,7 @@ import (
 
 	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
 	"github.com/pkt-cash/PKT-FullNode/pktconfig/version"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
 
 	"github.com/btcsuite/winsvc/eventlog"
 	"github.com/btcsuite/winsvc/mgr"
@@ -68,6 +69,7 @@ func (s *pktdService) Execute(args []string, r <-chan svc.ChangeRequest, changes
 	serverChan := make(chan *server)
 	go func() {
 		err := pktdMain(serverChan)
+		log.Close()
 		doneChan <- err
 	}()
 
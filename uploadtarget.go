@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/blockchain"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+)
+
+const (
+	// uploadTargetTimeframe is the length of the cycle the upload target
+	// applies to.
+	uploadTargetTimeframe = time.Hour * 24
+
+	// historicalBlockAge is the age after which a block is considered
+	// historical.  Historical blocks are not served to peers once the
+	// upload target is reached.
+	historicalBlockAge = time.Hour * 24 * 7
+)
+
+// uploadTarget tracks the bytes sent to peers within the current cycle so the
+// node can stay within the --maxuploadtarget setting.
+type uploadTarget struct {
+	mtx        sync.Mutex
+	target     uint64 // Bytes per cycle, 0 means no target.
+	buffer     uint64 // Bytes reserved for relaying new blocks.
+	cycleStart time.Time
+	cycleBytes uint64
+}
+
+// newUploadTarget returns an upload target allowing target bytes per cycle.
+// The bytes needed to relay a cycle's worth of new blocks, at one block every
+// blockInterval, are reserved so historical blocks can not use them up.
+func newUploadTarget(target uint64, blockInterval time.Duration) *uploadTarget {
+	buffer := uint64(uploadTargetTimeframe/blockInterval) *
+		blockchain.MaxBlockBaseSize
+	if target > 0 && target < buffer {
+		log.Warnf("The upload target of %d MiB is lower than the %d MiB "+
+			"needed to relay new blocks, historical blocks will not be "+
+			"served", target>>20, buffer>>20)
+	}
+	return &uploadTarget{
+		target:     target,
+		buffer:     buffer,
+		cycleStart: time.Now(),
+	}
+}
+
+// maybeStartCycle starts a new cycle if the current one has ended.  It MUST
+// be called with the lock held.
+func (u *uploadTarget) maybeStartCycle(now time.Time) {
+	if now.Sub(u.cycleStart) >= uploadTargetTimeframe {
+		u.cycleStart = now
+		u.cycleBytes = 0
+	}
+}
+
+// addBytes records bytes sent to a peer.
+func (u *uploadTarget) addBytes(n uint64) {
+	u.mtx.Lock()
+	u.maybeStartCycle(time.Now())
+	u.cycleBytes += n
+	u.mtx.Unlock()
+}
+
+// bytesLeft returns the bytes which may still be sent in the cycle.  It MUST
+// be called with the lock held.
+func (u *uploadTarget) bytesLeft() uint64 {
+	if u.cycleBytes >= u.target {
+		return 0
+	}
+	return u.target - u.cycleBytes
+}
+
+// reached returns whether the upload target has been reached.  When
+// historical is set, the bytes reserved for relaying new blocks are treated
+// as already used, so historical blocks stop being served before new blocks
+// would be affected.
+func (u *uploadTarget) reached(historical bool) bool {
+	if u.target == 0 {
+		return false
+	}
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.maybeStartCycle(time.Now())
+	left := u.bytesLeft()
+	if historical {
+		return left <= u.buffer
+	}
+	return left == 0
+}
+
+// uploadTargetStats is a snapshot of the state of the upload target.
+type uploadTargetStats struct {
+	Target          uint64
+	TargetReached   bool
+	ServeHistorical bool
+	BytesLeft       uint64
+	TimeLeft        time.Duration
+}
+
+// stats returns a snapshot of the state of the upload target.
+func (u *uploadTarget) stats() uploadTargetStats {
+	reached := u.reached(false)
+	serveHistorical := !u.reached(true)
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	stats := uploadTargetStats{
+		Target:          u.target,
+		TargetReached:   reached,
+		ServeHistorical: serveHistorical,
+	}
+	if u.target > 0 {
+		stats.BytesLeft = u.bytesLeft()
+		stats.TimeLeft = uploadTargetTimeframe - time.Since(u.cycleStart)
+	}
+	return stats
+}
+
+// tokenBucket limits a rate in bytes per second.  Bytes may be taken before
+// they are available, the caller then waits until the debt is paid off, which
+// keeps the long term rate at the limit no matter how large the writes are.
+type tokenBucket struct {
+	mtx    sync.Mutex
+	rate   float64 // Bytes per second.
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket returns a token bucket allowing rate bytes per second with
+// bursts of up to a second worth of bytes.
+func newTokenBucket(rate uint64) *tokenBucket {
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// take removes n bytes from the bucket and returns how long the caller must
+// wait before sending them.
+func (b *tokenBucket) take(n int) time.Duration {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+	b.tokens -= float64(n)
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// rateLimitedConn is a connection where writes are throttled by the token
+// buckets of the peer and of all peers combined.
+type rateLimitedConn struct {
+	net.Conn
+	buckets []*tokenBucket
+}
+
+// Write throttles the write according to the token buckets before passing it
+// to the underlying connection.
+func (c *rateLimitedConn) Write(b []byte) (int, error) {
+	var wait time.Duration
+	for _, bucket := range c.buckets {
+		if d := bucket.take(len(b)); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	return c.Conn.Write(b)
+}
+
+// limitUpload wraps the connection of the peer so writes respect the upload
+// rate limits, unless the peer has the download permission.
+func (s *server) limitUpload(sp *serverPeer, conn net.Conn) net.Conn {
+	if sp.hasPermission(permDownload) {
+		return conn
+	}
+	var buckets []*tokenBucket
+	if s.uploadRate != nil {
+		buckets = append(buckets, s.uploadRate)
+	}
+	if cfg.MaxPeerUploadRate > 0 {
+		buckets = append(buckets, newTokenBucket(cfg.MaxPeerUploadRate*1024))
+	}
+	if len(buckets) == 0 {
+		return conn
+	}
+	return &rateLimitedConn{Conn: conn, buckets: buckets}
+}
+
+// historicalBlockLimited returns whether a block with the given timestamp may
+// not be served to the peer because the upload target has been reached.
+func (s *server) historicalBlockLimited(sp *serverPeer, timestamp time.Time) bool {
+	if sp.hasPermission(permDownload) ||
+		time.Since(timestamp) < historicalBlockAge {
+
+		return false
+	}
+	return s.uploadTarget.reached(true)
+}
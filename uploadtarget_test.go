@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestUploadTarget tests that historical blocks stop being served before new
+// blocks once the upload target is approached.
+func TestUploadTarget(t *testing.T) {
+	// One block a day reserves a single maximum sized block.
+	u := newUploadTarget(3000000, uploadTargetTimeframe)
+	if u.reached(true) || u.reached(false) {
+		t.Fatalf("target reached before anything was sent")
+	}
+
+	u.addBytes(2000001)
+	if !u.reached(true) {
+		t.Errorf("historical limit not reached with the buffer in use")
+	}
+	if u.reached(false) {
+		t.Errorf("target reached before it was used up")
+	}
+
+	u.addBytes(999999)
+	if !u.reached(false) {
+		t.Errorf("target not reached after it was used up")
+	}
+	stats := u.stats()
+	if !stats.TargetReached || stats.ServeHistorical || stats.BytesLeft != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// A new cycle resets the target.
+	u.cycleStart = time.Now().Add(-uploadTargetTimeframe)
+	if u.reached(true) {
+		t.Errorf("historical limit reached in a new cycle")
+	}
+
+	// No target never limits.
+	u = newUploadTarget(0, uploadTargetTimeframe)
+	u.addBytes(1 << 40)
+	if u.reached(true) {
+		t.Errorf("limit reached without a target")
+	}
+}
+
+// TestTokenBucket tests that the token bucket allows a burst and then makes
+// callers wait for the bytes beyond it.
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(1000)
+	if d := b.take(1000); d != 0 {
+		t.Errorf("burst was delayed by %v", d)
+	}
+	d := b.take(500)
+	if d < 400*time.Millisecond || d > 500*time.Millisecond {
+		t.Errorf("unexpected delay %v for 500 bytes at 1000 bytes/s", d)
+	}
+}
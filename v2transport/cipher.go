@@ -0,0 +1,112 @@
+package v2transport
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+// rekeyInterval is the number of messages encrypted with one key before the
+// forward secure ciphers switch to a new key.
+const rekeyInterval = 224
+
+// fsChaCha20 is the forward secure ChaCha20 stream cipher used to encrypt the
+// length field of each packet.  The keystream is continuous across messages
+// and the key is replaced every rekeyInterval messages.
+type fsChaCha20 struct {
+	key          [32]byte
+	chunkCounter uint64
+	c            *chacha20.Cipher
+}
+
+func newFSChaCha20(key []byte) *fsChaCha20 {
+	f := &fsChaCha20{}
+	copy(f.key[:], key)
+	f.reset()
+	return f
+}
+
+// reset creates the underlying cipher for the current key and rekey epoch.
+func (f *fsChaCha20) reset() {
+	var nonce [chacha20.NonceSize]byte
+	binary.LittleEndian.PutUint64(nonce[4:], f.chunkCounter/rekeyInterval)
+	c, err := chacha20.NewUnauthenticatedCipher(f.key[:], nonce[:])
+	if err != nil {
+		// Only possible with an incorrect key or nonce size.
+		panic(err)
+	}
+	f.c = c
+}
+
+// crypt encrypts or decrypts a single chunk in place.
+func (f *fsChaCha20) crypt(chunk []byte) {
+	f.c.XORKeyStream(chunk, chunk)
+	if (f.chunkCounter+1)%rekeyInterval == 0 {
+		var newKey [32]byte
+		f.c.XORKeyStream(newKey[:], newKey[:])
+		f.key = newKey
+		f.chunkCounter++
+		f.reset()
+		return
+	}
+	f.chunkCounter++
+}
+
+// fsChaCha20Poly1305 is the forward secure AEAD used to encrypt the contents
+// of each packet.  The key is replaced every rekeyInterval packets.
+type fsChaCha20Poly1305 struct {
+	aead          cipher.AEAD
+	packetCounter uint64
+}
+
+func newFSChaCha20Poly1305(key []byte) *fsChaCha20Poly1305 {
+	aead, err := chacha20poly1305.New(key)
+	if err != nil {
+		// Only possible with an incorrect key size.
+		panic(err)
+	}
+	return &fsChaCha20Poly1305{aead: aead}
+}
+
+func (f *fsChaCha20Poly1305) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint32(nonce[:4], uint32(f.packetCounter%rekeyInterval))
+	binary.LittleEndian.PutUint64(nonce[4:], f.packetCounter/rekeyInterval)
+	return nonce
+}
+
+// advance moves on to the next packet, rekeying when the interval is reached.
+func (f *fsChaCha20Poly1305) advance(nonce []byte) {
+	if (f.packetCounter+1)%rekeyInterval == 0 {
+		copy(nonce[:4], []byte{0xff, 0xff, 0xff, 0xff})
+		newKey := f.aead.Seal(nil, nonce, make([]byte, 32), nil)[:32]
+		aead, err := chacha20poly1305.New(newKey)
+		if err != nil {
+			panic(err)
+		}
+		f.aead = aead
+	}
+	f.packetCounter++
+}
+
+// encrypt seals plaintext with the associated data aad.
+func (f *fsChaCha20Poly1305) encrypt(aad, plaintext []byte) []byte {
+	nonce := f.nonce()
+	out := f.aead.Seal(nil, nonce, plaintext, aad)
+	f.advance(nonce)
+	return out
+}
+
+// decrypt opens ciphertext with the associated data aad.
+func (f *fsChaCha20Poly1305) decrypt(aad, ciphertext []byte) ([]byte, er.R) {
+	nonce := f.nonce()
+	out, errr := f.aead.Open(nil, nonce, ciphertext, aad)
+	f.advance(nonce)
+	if errr != nil {
+		return nil, ErrDecrypt.New("", er.E(errr))
+	}
+	return out, nil
+}
@@ -0,0 +1,41 @@
+package v2transport
+
+import "github.com/pkt-cash/PKT-FullNode/btcutil/er"
+
+// Err identifies errors from the v2 transport.
+var Err er.ErrorType = er.NewErrorType("v2transport.Err")
+
+var (
+	// ErrV1Peer is returned from the responder side of the handshake when
+	// the remote peer started with an unencrypted v1 version message.  The
+	// bytes which were already consumed are available from V1Prefix so the
+	// connection can continue with the v1 protocol.
+	ErrV1Peer = Err.CodeWithDetail("ErrV1Peer",
+		"remote peer is using the v1 transport")
+
+	// ErrV1Responder is returned from the initiator side of the handshake
+	// when the remote peer appears to be using the v1 transport, because
+	// it closed the connection without answering our key or answered with
+	// a v1 version message.  The connection can't be used any further,
+	// but a new one using the v1 transport should succeed.
+	ErrV1Responder = Err.CodeWithDetail("ErrV1Responder",
+		"remote peer appears to be using the v1 transport")
+
+	// ErrGarbageTerminator indicates the garbage terminator of the remote
+	// peer was not found within the allowed amount of garbage.
+	ErrGarbageTerminator = Err.CodeWithDetail("ErrGarbageTerminator",
+		"garbage terminator not found")
+
+	// ErrDecrypt indicates a packet failed authentication.
+	ErrDecrypt = Err.CodeWithDetail("ErrDecrypt",
+		"packet authentication failed")
+
+	// ErrPacketTooLarge indicates a packet exceeds the maximum size.
+	ErrPacketTooLarge = Err.CodeWithDetail("ErrPacketTooLarge",
+		"packet too large")
+
+	// ErrMalformedPacket indicates the decrypted contents of a packet do
+	// not form a valid message.
+	ErrMalformedPacket = Err.CodeWithDetail("ErrMalformedPacket",
+		"malformed packet")
+)
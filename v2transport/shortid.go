@@ -0,0 +1,49 @@
+package v2transport
+
+import "github.com/pkt-cash/PKT-FullNode/wire"
+
+// shortIDs maps the one byte message type IDs defined by BIP0324 to commands.
+// Index 0 is reserved for messages which carry their full command name.
+// Some of the commands are not implemented by this node but are listed so the
+// numbering matches the specification.
+var shortIDs = [...]string{
+	1:  wire.CmdAddr,
+	2:  wire.CmdBlock,
+	3:  "blocktxn",
+	4:  "cmpctblock",
+	5:  wire.CmdFeeFilter,
+	6:  wire.CmdFilterAdd,
+	7:  wire.CmdFilterClear,
+	8:  wire.CmdFilterLoad,
+	9:  wire.CmdGetBlocks,
+	10: "getblocktxn",
+	11: wire.CmdGetData,
+	12: wire.CmdGetHeaders,
+	13: wire.CmdHeaders,
+	14: wire.CmdInv,
+	15: wire.CmdMemPool,
+	16: wire.CmdMerkleBlock,
+	17: wire.CmdNotFound,
+	18: wire.CmdPing,
+	19: wire.CmdPong,
+	20: "sendcmpct",
+	21: wire.CmdTx,
+	22: wire.CmdGetCFilters,
+	23: wire.CmdCFilter,
+	24: wire.CmdGetCFHeaders,
+	25: wire.CmdCFHeaders,
+	26: wire.CmdGetCFCheckpt,
+	27: wire.CmdCFCheckpt,
+	28: "addrv2",
+}
+
+// commandShortIDs is the reverse of shortIDs.
+var commandShortIDs = func() map[string]byte {
+	m := make(map[string]byte, len(shortIDs))
+	for id, cmd := range shortIDs {
+		if cmd != "" {
+			m[cmd] = byte(id)
+		}
+	}
+	return m
+}()
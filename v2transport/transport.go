@@ -0,0 +1,436 @@
+// Package v2transport implements the BIP0324 version 2 encrypted peer to peer
+// transport.
+//
+// A Transport performs the ElligatorSwift key exchange with the remote peer
+// and afterwards frames every message as an encrypted, authenticated packet.
+// Messages are read and written with the same signatures as the v1 functions
+// in the wire package so the peer can use either transport interchangeably.
+package v2transport
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+
+	"github.com/pkt-cash/PKT-FullNode/btcec"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/wire"
+	"github.com/pkt-cash/PKT-FullNode/wire/protocol"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// lengthFieldLen is the size of the encrypted length prefix.
+	lengthFieldLen = 3
+
+	// headerLen is the size of the header byte inside each packet.
+	headerLen = 1
+
+	// tagLen is the size of the Poly1305 authentication tag.
+	tagLen = 16
+
+	// ignoreBit marks a packet as a decoy which must be discarded.
+	ignoreBit = 0x80
+
+	// terminatorLen is the size of each garbage terminator.
+	terminatorLen = 16
+
+	// maxGarbageLen is the maximum amount of garbage either side may send
+	// before its garbage terminator.
+	maxGarbageLen = 4095
+
+	// maxContentsLen is the largest packet contents which will be
+	// accepted, a long command encoding followed by the largest payload.
+	maxContentsLen = 1 + wire.CommandSize + wire.MaxMessagePayload
+
+	// maxLengthField is the largest value the length field can encode.
+	maxLengthField = 1<<(8*lengthFieldLen) - 1
+)
+
+// Transport is one side of a v2 encrypted connection.  After a successful
+// Handshake, ReadMessage and WriteMessage may be called concurrently with
+// each other, but neither may be called concurrently with itself.
+type Transport struct {
+	net       protocol.BitcoinNet
+	initiator bool
+
+	privKey  *btcec.PrivateKey
+	ellSwift [btcec.EllSwiftPubKeyLen]byte
+
+	sendL *fsChaCha20
+	sendP *fsChaCha20Poly1305
+	recvL *fsChaCha20
+	recvP *fsChaCha20Poly1305
+
+	sendTerminator [terminatorLen]byte
+	recvTerminator [terminatorLen]byte
+	sessionID      [32]byte
+
+	v1Prefix []byte
+}
+
+// NewTransport returns a Transport for a connection on the given network.
+// The initiator is the side which opened the connection.
+func NewTransport(net protocol.BitcoinNet, initiator bool) (*Transport, er.R) {
+	privKey, ellSwift, err := btcec.EllSwiftCreate()
+	if err != nil {
+		return nil, err
+	}
+	return &Transport{
+		net:       net,
+		initiator: initiator,
+		privKey:   privKey,
+		ellSwift:  ellSwift,
+	}, nil
+}
+
+// SessionID returns the session identifier which both sides derive from the
+// key exchange.  It is only valid after a successful handshake.
+func (t *Transport) SessionID() []byte {
+	return t.sessionID[:]
+}
+
+// V1Prefix returns the bytes consumed from a peer which turned out to be using
+// the v1 transport, see ErrV1Peer.
+func (t *Transport) V1Prefix() []byte {
+	return t.v1Prefix
+}
+
+// v1Prefix returns the first bytes of a v1 version message on the network.
+func v1Prefix(net protocol.BitcoinNet) []byte {
+	var b [4 + wire.CommandSize]byte
+	binary.LittleEndian.PutUint32(b[:4], uint32(net))
+	copy(b[4:], wire.CmdVersion)
+	return b[:]
+}
+
+// taggedHash implements the BIP0340 tagged hash.
+func taggedHash(tag string, msgs ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, m := range msgs {
+		h.Write(m)
+	}
+	return h.Sum(nil)
+}
+
+// initCiphers derives all session keys from the ECDH with the remote key.
+func (t *Transport) initCiphers(theirs [btcec.EllSwiftPubKeyLen]byte) {
+	ecdhX := btcec.EllSwiftECDHXOnly(theirs, t.privKey)
+	initiatorKey, responderKey := t.ellSwift[:], theirs[:]
+	if !t.initiator {
+		initiatorKey, responderKey = theirs[:], t.ellSwift[:]
+	}
+	secret := taggedHash("bip324_ellswift_xonly_ecdh", initiatorKey,
+		responderKey, ecdhX[:])
+
+	var magic [4]byte
+	binary.LittleEndian.PutUint32(magic[:], uint32(t.net))
+	salt := append([]byte("bitcoin_v2_shared_secret"), magic[:]...)
+	prk := hkdf.Extract(sha256.New, secret, salt)
+	expand := func(label string, n int) []byte {
+		out := make([]byte, n)
+		if _, err := io.ReadFull(hkdf.Expand(sha256.New, prk, []byte(label)), out); err != nil {
+			// HKDF can produce far more than the few bytes needed.
+			panic(err)
+		}
+		return out
+	}
+
+	initL := newFSChaCha20(expand("initiator_L", 32))
+	initP := newFSChaCha20Poly1305(expand("initiator_P", 32))
+	respL := newFSChaCha20(expand("responder_L", 32))
+	respP := newFSChaCha20Poly1305(expand("responder_P", 32))
+	terminators := expand("garbage_terminators", 2*terminatorLen)
+	copy(t.sessionID[:], expand("session_id", 32))
+
+	if t.initiator {
+		t.sendL, t.sendP, t.recvL, t.recvP = initL, initP, respL, respP
+		copy(t.sendTerminator[:], terminators[:terminatorLen])
+		copy(t.recvTerminator[:], terminators[terminatorLen:])
+	} else {
+		t.sendL, t.sendP, t.recvL, t.recvP = respL, respP, initL, initP
+		copy(t.sendTerminator[:], terminators[terminatorLen:])
+		copy(t.recvTerminator[:], terminators[:terminatorLen])
+	}
+}
+
+// randomGarbage returns a random amount of random bytes to send before the
+// garbage terminator.
+func randomGarbage() ([]byte, er.R) {
+	var lb [2]byte
+	if _, errr := rand.Read(lb[:]); errr != nil {
+		return nil, er.E(errr)
+	}
+	garbage := make([]byte, int(binary.LittleEndian.Uint16(lb[:]))%(maxGarbageLen+1))
+	if _, errr := rand.Read(garbage); errr != nil {
+		return nil, er.E(errr)
+	}
+	return garbage, nil
+}
+
+// encryptPacket frames contents as a single encrypted packet.
+func (t *Transport) encryptPacket(contents, aad []byte, ignore bool) ([]byte, er.R) {
+	if len(contents) > maxLengthField {
+		return nil, ErrPacketTooLarge.New("", nil)
+	}
+	var l [lengthFieldLen]byte
+	l[0] = byte(len(contents))
+	l[1] = byte(len(contents) >> 8)
+	l[2] = byte(len(contents) >> 16)
+	t.sendL.crypt(l[:])
+
+	plain := make([]byte, headerLen+len(contents))
+	if ignore {
+		plain[0] = ignoreBit
+	}
+	copy(plain[headerLen:], contents)
+	return append(l[:], t.sendP.encrypt(aad, plain)...), nil
+}
+
+// readPacket reads and decrypts the next packet, returning its header byte and
+// contents along with the number of bytes read.
+func (t *Transport) readPacket(r io.Reader, aad []byte) (byte, []byte, int, er.R) {
+	var l [lengthFieldLen]byte
+	n, errr := io.ReadFull(r, l[:])
+	if errr != nil {
+		return 0, nil, n, er.E(errr)
+	}
+	t.recvL.crypt(l[:])
+	length := int(l[0]) | int(l[1])<<8 | int(l[2])<<16
+	if length > maxContentsLen {
+		return 0, nil, n, ErrPacketTooLarge.New("", nil)
+	}
+
+	buf := make([]byte, headerLen+length+tagLen)
+	nn, errr := io.ReadFull(r, buf)
+	n += nn
+	if errr != nil {
+		return 0, nil, n, er.E(errr)
+	}
+	plain, err := t.recvP.decrypt(aad, buf)
+	if err != nil {
+		return 0, nil, n, err
+	}
+	return plain[0], plain[headerLen:], n, nil
+}
+
+// asyncWrite writes b to w in the background so the handshake can proceed
+// with reading even on synchronous connections.
+func asyncWrite(w io.Writer, b []byte) chan er.R {
+	c := make(chan er.R, 1)
+	go func() {
+		_, errr := w.Write(b)
+		c <- er.E(errr)
+	}()
+	return c
+}
+
+// Handshake performs the key exchange on rw and returns the number of bytes
+// read and written.  On the responder side, ErrV1Peer is returned if the
+// remote peer is using the v1 transport, in which case nothing has been
+// written to rw.  On the initiator side, ErrV1Responder is returned if the
+// remote peer appears to be using the v1 transport.
+func (t *Transport) Handshake(rw io.ReadWriter) (int, int, er.R) {
+	var read, written int
+	garbage, err := randomGarbage()
+	if err != nil {
+		return read, written, err
+	}
+
+	var theirs [btcec.EllSwiftPubKeyLen]byte
+	var writes []chan er.R
+	var pending []int
+	if t.initiator {
+		first := append(append([]byte{}, t.ellSwift[:]...), garbage...)
+		writes = append(writes, asyncWrite(rw, first))
+		pending = append(pending, len(first))
+
+		// A v1 responder can't parse our key as a message header,
+		// so it drops the connection without sending anything.
+		n, errr := io.ReadFull(rw, theirs[:])
+		read += n
+		prefix := v1Prefix(t.net)
+		if n >= len(prefix) && bytes.Equal(theirs[:len(prefix)], prefix) {
+			return read, written, ErrV1Responder.Default()
+		}
+		if errr != nil {
+			if n == 0 && (errr == io.EOF ||
+				errors.Is(errr, syscall.ECONNRESET)) {
+
+				return read, written, ErrV1Responder.Default()
+			}
+			return read, written, er.E(errr)
+		}
+	} else {
+		prefix := v1Prefix(t.net)
+		n, errr := io.ReadFull(rw, theirs[:len(prefix)])
+		read += n
+		if errr != nil {
+			return read, written, er.E(errr)
+		}
+		if bytes.Equal(theirs[:len(prefix)], prefix) {
+			t.v1Prefix = append([]byte{}, prefix...)
+			return read, written, ErrV1Peer.Default()
+		}
+		n, errr = io.ReadFull(rw, theirs[len(prefix):])
+		read += n
+		if errr != nil {
+			return read, written, er.E(errr)
+		}
+	}
+	t.initCiphers(theirs)
+
+	// Send the garbage terminator followed by the version packet, which
+	// authenticates the garbage that was sent.
+	versionPacket, err := t.encryptPacket(nil, garbage, false)
+	if err != nil {
+		return read, written, err
+	}
+	second := append(append([]byte{}, t.sendTerminator[:]...), versionPacket...)
+	if t.initiator {
+		// Wait for the key to be sent so the writes are not reordered.
+		if err := <-writes[0]; err != nil {
+			return read, written, err
+		}
+		written += pending[0]
+		writes, pending = writes[1:], pending[1:]
+	} else {
+		second = append(append(append([]byte{}, t.ellSwift[:]...), garbage...), second...)
+	}
+	writes = append(writes, asyncWrite(rw, second))
+	pending = append(pending, len(second))
+
+	// Scan for the garbage terminator of the remote peer, one byte at a
+	// time so nothing past it is consumed.
+	var recvGarbage []byte
+	var b [1]byte
+	for {
+		n, errr := io.ReadFull(rw, b[:])
+		read += n
+		if errr != nil {
+			return read, written, er.E(errr)
+		}
+		recvGarbage = append(recvGarbage, b[0])
+		if len(recvGarbage) >= terminatorLen && bytes.Equal(
+			recvGarbage[len(recvGarbage)-terminatorLen:], t.recvTerminator[:]) {
+
+			recvGarbage = recvGarbage[:len(recvGarbage)-terminatorLen]
+			break
+		}
+		if len(recvGarbage) >= maxGarbageLen+terminatorLen {
+			return read, written, ErrGarbageTerminator.Default()
+		}
+	}
+
+	// The first packet authenticates the garbage, decoys may precede the
+	// version packet.
+	aad := recvGarbage
+	for {
+		header, _, n, err := t.readPacket(rw, aad)
+		read += n
+		if err != nil {
+			return read, written, err
+		}
+		aad = nil
+		if header&ignoreBit == 0 {
+			break
+		}
+	}
+
+	for i, c := range writes {
+		if err := <-c; err != nil {
+			return read, written, err
+		}
+		written += pending[i]
+	}
+	return read, written, nil
+}
+
+// WriteMessage writes msg to w as a single encrypted packet and returns the
+// number of bytes written.  It mirrors wire.WriteMessageWithEncodingN.
+func (t *Transport) WriteMessage(w io.Writer, msg wire.Message, pver uint32,
+	encoding wire.MessageEncoding) (int, er.R) {
+
+	cmd := msg.Command()
+	if len(cmd) > wire.CommandSize {
+		return 0, er.Errorf("command [%s] is too long [max %v]",
+			cmd, wire.CommandSize)
+	}
+	payload, err := wire.EncodeMessagePayload(msg, pver, encoding)
+	if err != nil {
+		return 0, err
+	}
+
+	var contents []byte
+	if id, ok := commandShortIDs[cmd]; ok {
+		contents = make([]byte, 1, 1+len(payload))
+		contents[0] = id
+	} else {
+		contents = make([]byte, 1+wire.CommandSize, 1+wire.CommandSize+len(payload))
+		copy(contents[1:], cmd)
+	}
+	contents = append(contents, payload...)
+
+	packet, err := t.encryptPacket(contents, nil, false)
+	if err != nil {
+		return 0, err
+	}
+	n, errr := w.Write(packet)
+	return n, er.E(errr)
+}
+
+// ReadMessage reads the next message from r, skipping decoy packets, and
+// returns the number of bytes read along with the message and its raw
+// payload.  It mirrors wire.ReadMessageWithEncodingN.
+func (t *Transport) ReadMessage(r io.Reader, pver uint32,
+	enc wire.MessageEncoding) (int, wire.Message, []byte, er.R) {
+
+	total := 0
+	for {
+		header, contents, n, err := t.readPacket(r, nil)
+		total += n
+		if err != nil {
+			return total, nil, nil, err
+		}
+		if header&ignoreBit != 0 {
+			continue
+		}
+		if len(contents) == 0 {
+			return total, nil, nil, ErrMalformedPacket.New("empty contents", nil)
+		}
+
+		var cmd string
+		var payload []byte
+		if contents[0] == 0 {
+			if len(contents) < 1+wire.CommandSize {
+				return total, nil, nil, ErrMalformedPacket.New(
+					"short command", nil)
+			}
+			cmd = strings.TrimRight(string(contents[1:1+wire.CommandSize]), "\x00")
+			payload = contents[1+wire.CommandSize:]
+		} else {
+			id := int(contents[0])
+			if id >= len(shortIDs) || shortIDs[id] == "" {
+				return total, nil, nil, ErrMalformedPacket.New(
+					fmt.Sprintf("unknown short id %d", id), nil)
+			}
+			cmd = shortIDs[id]
+			payload = contents[1:]
+		}
+
+		msg, err := wire.DecodeMessagePayload(cmd, payload, pver, enc)
+		if err != nil {
+			return total, nil, payload, err
+		}
+		return total, msg, payload, nil
+	}
+}
@@ -0,0 +1,144 @@
+package v2transport
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/wire"
+	"github.com/pkt-cash/PKT-FullNode/wire/protocol"
+)
+
+// handshakePair runs the handshake on both ends of a pipe.
+func handshakePair(t *testing.T) (*Transport, *Transport, net.Conn, net.Conn) {
+	c1, c2 := net.Pipe()
+	initiator, err := NewTransport(protocol.MainNet, true)
+	if err != nil {
+		t.Fatalf("NewTransport: %v", err)
+	}
+	responder, err := NewTransport(protocol.MainNet, false)
+	if err != nil {
+		t.Fatalf("NewTransport: %v", err)
+	}
+	errChan := make(chan er.R, 1)
+	go func() {
+		_, _, err := responder.Handshake(c2)
+		errChan <- err
+	}()
+	if _, _, err := initiator.Handshake(c1); err != nil {
+		t.Fatalf("initiator handshake: %v", err)
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("responder handshake: %v", err)
+	}
+	return initiator, responder, c1, c2
+}
+
+// TestHandshake ensures both sides agree on the session and can exchange
+// messages, including across several rekey intervals.
+func TestHandshake(t *testing.T) {
+	initiator, responder, c1, c2 := handshakePair(t)
+	defer c1.Close()
+	defer c2.Close()
+	if !bytes.Equal(initiator.SessionID(), responder.SessionID()) {
+		t.Fatalf("session id mismatch - initiator: %x, responder: %x",
+			initiator.SessionID(), responder.SessionID())
+	}
+
+	msgs := []wire.Message{
+		wire.NewMsgPing(1),
+		wire.NewMsgSendHeaders(),
+		wire.NewMsgPong(2),
+	}
+	for i := 0; i < rekeyInterval*2+5; i++ {
+		msg := msgs[i%len(msgs)]
+		go func() {
+			if _, err := initiator.WriteMessage(c1, msg, protocol.ProtocolVersion,
+				wire.BaseEncoding); err != nil {
+				t.Errorf("WriteMessage: %v", err)
+			}
+		}()
+		_, got, _, err := responder.ReadMessage(c2, protocol.ProtocolVersion,
+			wire.BaseEncoding)
+		if err != nil {
+			t.Fatalf("ReadMessage #%d: %v", i, err)
+		}
+		if got.Command() != msg.Command() {
+			t.Fatalf("message #%d - got: %s, want: %s", i,
+				got.Command(), msg.Command())
+		}
+	}
+}
+
+// TestV1Detection ensures the responder recognises a v1 peer.
+func TestV1Detection(t *testing.T) {
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	responder, err := NewTransport(protocol.MainNet, false)
+	if err != nil {
+		t.Fatalf("NewTransport: %v", err)
+	}
+	go func() {
+		ver := wire.NewMsgVersion(wire.NewNetAddressIPPort(nil, 0, 0),
+			wire.NewNetAddressIPPort(nil, 0, 0), 0, 0)
+		wire.WriteMessage(c1, ver, protocol.ProtocolVersion, protocol.MainNet)
+	}()
+	_, _, err = responder.Handshake(c2)
+	if !ErrV1Peer.Is(err) {
+		t.Fatalf("expected ErrV1Peer, got %v", err)
+	}
+	if !bytes.Equal(responder.V1Prefix(), v1Prefix(protocol.MainNet)) {
+		t.Fatalf("unexpected prefix %x", responder.V1Prefix())
+	}
+}
+
+// TestV1ResponderDetection ensures the initiator recognises a responder which
+// closes the connection without answering or answers with a v1 version
+// message, and only those.
+func TestV1ResponderDetection(t *testing.T) {
+	tests := []struct {
+		name    string
+		respond func(c net.Conn)
+		v1      bool
+	}{{
+		name:    "closed",
+		respond: func(c net.Conn) {},
+		v1:      true,
+	}, {
+		name: "version",
+		respond: func(c net.Conn) {
+			ver := wire.NewMsgVersion(wire.NewNetAddressIPPort(nil, 0, 0),
+				wire.NewNetAddressIPPort(nil, 0, 0), 0, 0)
+			wire.WriteMessage(c, ver, protocol.ProtocolVersion,
+				protocol.MainNet)
+		},
+		v1: true,
+	}, {
+		name: "truncated key",
+		respond: func(c net.Conn) {
+			c.Write(make([]byte, 10))
+		},
+		v1: false,
+	}}
+
+	for _, test := range tests {
+		c1, c2 := net.Pipe()
+		initiator, err := NewTransport(protocol.MainNet, true)
+		if err != nil {
+			t.Fatalf("NewTransport: %v", err)
+		}
+		go func(respond func(c net.Conn)) {
+			var key [64]byte
+			c2.Read(key[:])
+			respond(c2)
+			c2.Close()
+		}(test.respond)
+		_, _, err = initiator.Handshake(c1)
+		c1.Close()
+		if err == nil || ErrV1Responder.Is(err) != test.v1 {
+			t.Fatalf("%s: unexpected error %v", test.name, err)
+		}
+	}
+}
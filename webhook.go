@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkt-cash/PKT-FullNode/blockchain"
+	"github.com/pkt-cash/PKT-FullNode/btcutil"
+	"github.com/pkt-cash/PKT-FullNode/btcutil/er"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg"
+	"github.com/pkt-cash/PKT-FullNode/chaincfg/chainhash"
+	"github.com/pkt-cash/PKT-FullNode/mempool"
+	"github.com/pkt-cash/PKT-FullNode/pktlog/log"
+	"github.com/pkt-cash/PKT-FullNode/txscript"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+const (
+	// webhookDirname is the directory in the data directory pending
+	// webhook deliveries are kept in.
+	webhookDirname = "webhooks"
+
+	// webhookMaxPending is the number of deliveries which may be pending
+	// for an endpoint, the oldest ones are dropped beyond it.
+	webhookMaxPending = 10000
+
+	// webhookMaxAge is how long a delivery is retried before it is dropped.
+	webhookMaxAge = 24 * time.Hour
+
+	// webhookMinBackoff and webhookMaxBackoff bound the time waited before
+	// retrying a failed delivery, it doubles with each failure.
+	webhookMinBackoff = time.Second
+	webhookMaxBackoff = 10 * time.Minute
+
+	// webhookTimeout is the time an endpoint has to answer a delivery.
+	webhookTimeout = 15 * time.Second
+
+	// webhookSignatureHeader carries the HMAC-SHA256 of the request body,
+	// keyed with --webhooksecret.
+	webhookSignatureHeader = "X-Pktd-Signature"
+)
+
+// Webhook event types.
+const (
+	webhookBlockConnected    = "blockconnected"
+	webhookBlockDisconnected = "blockdisconnected"
+	webhookReorg             = "reorg"
+	webhookSteward           = "steward"
+	webhookMempoolTx         = "mempooltx"
+)
+
+// webhookEvents are all the webhook event types.
+var webhookEvents = []string{
+	webhookBlockConnected,
+	webhookBlockDisconnected,
+	webhookReorg,
+	webhookSteward,
+	webhookMempoolTx,
+}
+
+// webhookEntry is a webhook endpoint parsed from the --webhook option.
+type webhookEntry struct {
+	url string
+
+	// redacted is the url with any password masked, which is what is
+	// logged so credentials of endpoints do not end up in the log.
+	redacted string
+
+	// events are the events delivered to the endpoint, nil for all.
+	events map[string]struct{}
+}
+
+// splitWebhook splits a webhook in the form [event,...@]<url> into its events,
+// empty when there are none, and its url.
+func splitWebhook(s string) (string, string) {
+	if at := strings.Index(s, "@"); at >= 0 && !strings.Contains(s[:at], "://") {
+		return s[:at], s[at+1:]
+	}
+	return "", s
+}
+
+// redactWebhook returns the passed webhook with any password of its url masked
+// so it can be shown in errors.  The url is left out entirely when it can't be
+// parsed, since it may still hold a password.
+func redactWebhook(s string) string {
+	events, rawURL := splitWebhook(s)
+	redacted := "<invalid url>"
+	if u, errr := url.Parse(rawURL); errr == nil {
+		redacted = u.Redacted()
+	}
+	if events != "" {
+		return events + "@" + redacted
+	}
+	return redacted
+}
+
+// parseWebhook parses a webhook in the form [event,...@]<url>.
+func parseWebhook(s string) (webhookEntry, er.R) {
+	var entry webhookEntry
+	events, rawURL := splitWebhook(s)
+	if events != "" {
+		entry.events = make(map[string]struct{})
+		for _, event := range strings.Split(events, ",") {
+			known := false
+			for _, e := range webhookEvents {
+				if e == event {
+					known = true
+					break
+				}
+			}
+			if !known {
+				return entry, er.Errorf("unknown webhook event [%s]", event)
+			}
+			entry.events[event] = struct{}{}
+		}
+	}
+	u, errr := url.Parse(rawURL)
+	if errr != nil {
+		// The error quotes the url, which may hold a password.
+		var urlErr *url.Error
+		if errors.As(errr, &urlErr) {
+			errr = urlErr.Err
+		}
+		return entry, er.Errorf("webhook url is invalid: %v", errr)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return entry, er.Errorf("webhook url [%s] is not an http or https url",
+			u.Redacted())
+	}
+	entry.url = rawURL
+	entry.redacted = u.Redacted()
+	return entry, nil
+}
+
+// webhookPayload is the body POSTed to webhook endpoints.
+type webhookPayload struct {
+	Event string      `json:"event"`
+	Time  int64       `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// webhookBlock is the data of the blockconnected and blockdisconnected events.
+type webhookBlock struct {
+	Hash     string `json:"hash"`
+	Height   int32  `json:"height"`
+	PrevHash string `json:"prevhash"`
+	Time     int64  `json:"time"`
+}
+
+// webhookReorgData is the data of the reorg event, it is sent when the first
+// block of the new branch is connected.
+type webhookReorgData struct {
+	OldTip       string `json:"oldtip"`
+	ForkHash     string `json:"forkhash"`
+	ForkHeight   int32  `json:"forkheight"`
+	Disconnected int    `json:"disconnected"`
+}
+
+// webhookStewardData is the data of the steward event.
+type webhookStewardData struct {
+	Script string `json:"script"`
+	Height int32  `json:"height"`
+}
+
+// webhookMempoolTxData is the data of the mempooltx event.
+type webhookMempoolTxData struct {
+	TxID      string   `json:"txid"`
+	Addresses []string `json:"addresses"`
+}
+
+// webhookDelivery is a payload waiting to be delivered to an endpoint.  It is
+// stored in its own file so it survives restarts.
+type webhookDelivery struct {
+	Seq     uint64 `json:"seq"`
+	Event   string `json:"event"`
+	Created int64  `json:"created"`
+	Body    []byte `json:"body"`
+}
+
+// webhookEndpoint delivers payloads to a single URL, in order.
+type webhookEndpoint struct {
+	entry webhookEntry
+	dir   string
+
+	mtx     sync.Mutex
+	pending []*webhookDelivery
+	nextSeq uint64
+	wake    chan struct{}
+}
+
+// newWebhookEndpoint returns the endpoint for entry with the deliveries which
+// were pending when the node last shut down.
+func newWebhookEndpoint(entry webhookEntry, baseDir string) (*webhookEndpoint, er.R) {
+	urlHash := sha256.Sum256([]byte(entry.url))
+	e := &webhookEndpoint{
+		entry: entry,
+		dir:   filepath.Join(baseDir, hex.EncodeToString(urlHash[:8])),
+		wake:  make(chan struct{}, 1),
+	}
+	if errr := os.MkdirAll(e.dir, 0700); errr != nil {
+		return nil, er.E(errr)
+	}
+	if err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// deliveryFile returns the path of the file of the delivery with seq.
+func (e *webhookEndpoint) deliveryFile(seq uint64) string {
+	return filepath.Join(e.dir, strconv.FormatUint(seq, 10)+".json")
+}
+
+// load reads the pending deliveries from disk.
+func (e *webhookEndpoint) load() er.R {
+	entries, errr := os.ReadDir(e.dir)
+	if errr != nil {
+		return er.E(errr)
+	}
+	for _, de := range entries {
+		name := de.Name()
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		path := filepath.Join(e.dir, name)
+		b, errr := os.ReadFile(path)
+		if errr != nil {
+			return er.E(errr)
+		}
+		var d webhookDelivery
+		if errr := jsoniter.Unmarshal(b, &d); errr != nil {
+			log.Warnf("Removing unreadable webhook delivery %s: %v", path, errr)
+			os.Remove(path)
+			continue
+		}
+		e.pending = append(e.pending, &d)
+		if d.Seq >= e.nextSeq {
+			e.nextSeq = d.Seq + 1
+		}
+	}
+	sort.Slice(e.pending, func(i, j int) bool {
+		return e.pending[i].Seq < e.pending[j].Seq
+	})
+	if len(e.pending) > 0 {
+		log.Infof("Loaded %d pending deliveries for webhook %s",
+			len(e.pending), e.entry.redacted)
+	}
+	return nil
+}
+
+// wants returns whether the endpoint subscribed to event.
+func (e *webhookEndpoint) wants(event string) bool {
+	if e.entry.events == nil {
+		return true
+	}
+	_, ok := e.entry.events[event]
+	return ok
+}
+
+// enqueue stores a delivery of body and queues it.
+func (e *webhookEndpoint) enqueue(event string, body []byte) {
+	e.mtx.Lock()
+	d := &webhookDelivery{
+		Seq:     e.nextSeq,
+		Event:   event,
+		Created: time.Now().Unix(),
+		Body:    body,
+	}
+	e.nextSeq++
+	if err := e.store(d); err != nil {
+		log.Warnf("Failed to store webhook delivery for %s, it will not "+
+			"survive a restart: %v", e.entry.redacted, err)
+	}
+	e.pending = append(e.pending, d)
+	if len(e.pending) > webhookMaxPending {
+		dropped := e.pending[0]
+		e.pending = e.pending[1:]
+		os.Remove(e.deliveryFile(dropped.Seq))
+		log.Warnf("Dropping webhook delivery %d for %s, too many are pending",
+			dropped.Seq, e.entry.redacted)
+	}
+	e.mtx.Unlock()
+
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// store writes a delivery to its file.
+func (e *webhookEndpoint) store(d *webhookDelivery) er.R {
+	b, errr := jsoniter.Marshal(d)
+	if errr != nil {
+		return er.E(errr)
+	}
+	path := e.deliveryFile(d.Seq)
+	tmp := path + ".tmp"
+	if errr := os.WriteFile(tmp, b, 0600); errr != nil {
+		return er.E(errr)
+	}
+	return er.E(os.Rename(tmp, path))
+}
+
+// head returns the oldest pending delivery, or nil.
+func (e *webhookEndpoint) head() *webhookDelivery {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if len(e.pending) == 0 {
+		return nil
+	}
+	return e.pending[0]
+}
+
+// remove removes a delivery which was delivered or given up on.
+func (e *webhookEndpoint) remove(d *webhookDelivery) {
+	e.mtx.Lock()
+	if len(e.pending) > 0 && e.pending[0] == d {
+		e.pending = e.pending[1:]
+	}
+	e.mtx.Unlock()
+	if errr := os.Remove(e.deliveryFile(d.Seq)); errr != nil && !os.IsNotExist(errr) {
+		log.Warnf("Failed to remove webhook delivery %d for %s: %v",
+			d.Seq, e.entry.redacted, errr)
+	}
+}
+
+// webhookSignature returns the value of the signature header for body.
+func webhookSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// post delivers d to the endpoint.
+func (e *webhookEndpoint) post(client *http.Client, secret []byte, d *webhookDelivery) er.R {
+	req, errr := http.NewRequest("POST", e.entry.url, bytes.NewReader(d.Body))
+	if errr != nil {
+		return er.E(errr)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Pktd-Event", d.Event)
+	req.Header.Set("X-Pktd-Delivery", strconv.FormatUint(d.Seq, 10))
+	req.Header.Set(webhookSignatureHeader, webhookSignature(secret, d.Body))
+	resp, errr := client.Do(req)
+	if errr != nil {
+		return er.E(errr)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return er.Errorf("endpoint answered %s", resp.Status)
+	}
+	return nil
+}
+
+// deliverHandler delivers the pending deliveries in order, retrying failed
+// ones with exponential backoff until they expire.  It must be run as a
+// goroutine.
+func (e *webhookEndpoint) deliverHandler(m *webhookManager) {
+	defer m.wg.Done()
+	backoff := webhookMinBackoff
+	for {
+		d := e.head()
+		if d == nil {
+			select {
+			case <-e.wake:
+				continue
+			case <-m.quit:
+				return
+			}
+		}
+
+		if time.Since(time.Unix(d.Created, 0)) > webhookMaxAge {
+			log.Warnf("Dropping webhook delivery %d for %s, it could not "+
+				"be delivered for %v", d.Seq, e.entry.redacted, webhookMaxAge)
+			e.remove(d)
+			continue
+		}
+
+		err := e.post(m.client, m.secret, d)
+		if err == nil {
+			log.Debugf("Delivered webhook %s %d to %s", d.Event, d.Seq,
+				e.entry.redacted)
+			e.remove(d)
+			backoff = webhookMinBackoff
+			continue
+		}
+		log.Debugf("Webhook delivery %d to %s failed, retrying in %v: %v",
+			d.Seq, e.entry.redacted, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-m.quit:
+			return
+		}
+		backoff *= 2
+		if backoff > webhookMaxBackoff {
+			backoff = webhookMaxBackoff
+		}
+	}
+}
+
+// webhookManager turns chain and mempool events into webhook deliveries.
+type webhookManager struct {
+	chain     *blockchain.BlockChain
+	params    *chaincfg.Params
+	endpoints []*webhookEndpoint
+	watched   map[string]struct{}
+	secret    []byte
+	client    *http.Client
+	quit      chan struct{}
+	wg        sync.WaitGroup
+
+	// The following fields track reorgs and steward changes, they are only
+	// accessed from chain notification callbacks.
+	disconnected int
+	oldTip       *chainhash.Hash
+	lastSteward  string
+}
+
+// newWebhookManager returns a webhook manager for the --webhook options, or
+// nil if there are none.
+func newWebhookManager(chain *blockchain.BlockChain, params *chaincfg.Params) (*webhookManager, er.R) {
+	if len(cfg.webhooks) == 0 {
+		return nil, nil
+	}
+	m := &webhookManager{
+		chain:   chain,
+		params:  params,
+		watched: cfg.webhookAddrs,
+		secret:  []byte(cfg.WebhookSecret),
+		client:  &http.Client{Timeout: webhookTimeout},
+		quit:    make(chan struct{}),
+	}
+	baseDir := filepath.Join(cfg.DataDir, webhookDirname)
+	for _, entry := range cfg.webhooks {
+		e, err := newWebhookEndpoint(entry, baseDir)
+		if err != nil {
+			return nil, err
+		}
+		m.endpoints = append(m.endpoints, e)
+	}
+	m.lastSteward = hex.EncodeToString(chain.BestSnapshot().Elect.NetworkSteward)
+	chain.Subscribe(m.handleBlockchainNotification)
+	return m, nil
+}
+
+// Start begins delivering webhooks.
+func (m *webhookManager) Start() {
+	for _, e := range m.endpoints {
+		m.wg.Add(1)
+		go e.deliverHandler(m)
+	}
+}
+
+// Stop stops delivering webhooks, pending deliveries stay on disk.
+func (m *webhookManager) Stop() {
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// send queues an event for delivery to the endpoints which subscribed to it.
+func (m *webhookManager) send(event string, data interface{}) {
+	body, errr := jsoniter.Marshal(&webhookPayload{
+		Event: event,
+		Time:  time.Now().Unix(),
+		Data:  data,
+	})
+	if errr != nil {
+		log.Errorf("Failed to encode webhook %s: %v", event, errr)
+		return
+	}
+	for _, e := range m.endpoints {
+		if e.wants(event) {
+			e.enqueue(event, body)
+		}
+	}
+}
+
+// blockData returns the data of a block event.
+func blockData(block *btcutil.Block) *webhookBlock {
+	header := &block.MsgBlock().Header
+	return &webhookBlock{
+		Hash:     block.Hash().String(),
+		Height:   block.Height(),
+		PrevHash: header.PrevBlock.String(),
+		Time:     header.Timestamp.Unix(),
+	}
+}
+
+// handleBlockchainNotification sends the block, reorg and steward events once
+// the chain is current.
+func (m *webhookManager) handleBlockchainNotification(n *blockchain.Notification) {
+	block, ok := n.Data.(*btcutil.Block)
+	if !ok {
+		return
+	}
+	// Nothing is sent while the chain is syncing so the initial download
+	// does not queue a delivery for every block, but reorgs and the network
+	// steward are still tracked.
+	current := m.chain.IsCurrent()
+	switch n.Type {
+	case blockchain.NTBlockConnected:
+		if m.disconnected > 0 && current {
+			m.send(webhookReorg, &webhookReorgData{
+				OldTip:       m.oldTip.String(),
+				ForkHash:     block.MsgBlock().Header.PrevBlock.String(),
+				ForkHeight:   block.Height() - 1,
+				Disconnected: m.disconnected,
+			})
+		}
+		m.disconnected = 0
+		m.oldTip = nil
+		if current {
+			m.send(webhookBlockConnected, blockData(block))
+		}
+
+	case blockchain.NTBlockDisconnected:
+		if m.disconnected == 0 {
+			m.oldTip = block.Hash()
+		}
+		m.disconnected++
+		if current {
+			m.send(webhookBlockDisconnected, blockData(block))
+		}
+
+	default:
+		return
+	}
+
+	best := m.chain.BestSnapshot()
+	steward := hex.EncodeToString(best.Elect.NetworkSteward)
+	if steward != m.lastSteward {
+		m.lastSteward = steward
+		if current {
+			m.send(webhookSteward, &webhookStewardData{
+				Script: steward,
+				Height: best.Height,
+			})
+		}
+	}
+}
+
+// NotifyTransactions sends the mempooltx event for accepted transactions which
+// pay to a watched address.
+func (m *webhookManager) NotifyTransactions(txns []*mempool.TxDesc) {
+	if len(m.watched) == 0 {
+		return
+	}
+	for _, txD := range txns {
+		var matched []string
+		seen := make(map[string]struct{})
+		for _, txOut := range txD.Tx.MsgTx().TxOut {
+			_, addrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, m.params)
+			for _, addr := range addrs {
+				encoded := addr.EncodeAddress()
+				if _, ok := m.watched[encoded]; !ok {
+					continue
+				}
+				if _, ok := seen[encoded]; ok {
+					continue
+				}
+				seen[encoded] = struct{}{}
+				matched = append(matched, encoded)
+			}
+		}
+		if len(matched) > 0 {
+			m.send(webhookMempoolTx, &webhookMempoolTxData{
+				TxID:      txD.Tx.Hash().String(),
+				Addresses: matched,
+			})
+		}
+	}
+}
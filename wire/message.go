@@ -296,31 +296,12 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	copy(command[:], cmd)
 
 	// Encode the message payload.
-	var bw bytes.Buffer
-	err := msg.BtcEncode(&bw, pver, encoding)
+	payload, err := EncodeMessagePayload(msg, pver, encoding)
 	if err != nil {
 		return totalBytes, err
 	}
-	payload := bw.Bytes()
 	lenp := len(payload)
 
-	// Enforce maximum overall message payload.
-	if lenp > MaxMessagePayload {
-		str := fmt.Sprintf("message payload is too large - encoded "+
-			"%d bytes, but maximum message payload is %d bytes",
-			lenp, MaxMessagePayload)
-		return totalBytes, messageError("WriteMessage", str)
-	}
-
-	// Enforce maximum message payload based on the message type.
-	mpl := msg.MaxPayloadLength(pver)
-	if uint32(lenp) > mpl {
-		str := fmt.Sprintf("message payload is too large - encoded "+
-			"%d bytes, but maximum message payload size for "+
-			"messages of type [%s] is %d.", lenp, cmd, mpl)
-		return totalBytes, messageError("WriteMessage", str)
-	}
-
 	// Create header for the message.
 	hdr := messageHeader{}
 	hdr.magic = btcnet
@@ -347,6 +328,74 @@ func WriteMessageWithEncodingN(w io.Writer, msg Message, pver uint32,
 	return totalBytes, er.E(errr)
 }
 
+// EncodeMessagePayload serializes the payload of a bitcoin Message, without
+// any header, and enforces both the overall and the per-message-type payload
+// limits.  It is used by transports which frame messages differently from the
+// original protocol, such as the BIP0324 v2 transport.
+func EncodeMessagePayload(msg Message, pver uint32, encoding MessageEncoding) ([]byte, er.R) {
+	var bw bytes.Buffer
+	err := msg.BtcEncode(&bw, pver, encoding)
+	if err != nil {
+		return nil, err
+	}
+	payload := bw.Bytes()
+	lenp := len(payload)
+
+	// Enforce maximum overall message payload.
+	if lenp > MaxMessagePayload {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload is %d bytes",
+			lenp, MaxMessagePayload)
+		return nil, messageError("WriteMessage", str)
+	}
+
+	// Enforce maximum message payload based on the message type.
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(lenp) > mpl {
+		str := fmt.Sprintf("message payload is too large - encoded "+
+			"%d bytes, but maximum message payload size for "+
+			"messages of type [%s] is %d.", lenp, msg.Command(), mpl)
+		return nil, messageError("WriteMessage", str)
+	}
+
+	return payload, nil
+}
+
+// DecodeMessagePayload creates a Message of the type specified by command and
+// deserializes it from payload, which must not include a message header.  The
+// payload limits for the message type are enforced before decoding.
+func DecodeMessagePayload(command string, payload []byte, pver uint32,
+	enc MessageEncoding) (Message, er.R) {
+
+	// Check for malformed commands.
+	if !utf8.ValidString(command) {
+		str := fmt.Sprintf("invalid command %v", []byte(command))
+		return nil, messageError("ReadMessage", str)
+	}
+
+	// Create struct of appropriate message type based on the command.
+	msg, err := makeEmptyMessage(command)
+	if err != nil {
+		return nil, messageError("ReadMessage", err.String())
+	}
+
+	mpl := msg.MaxPayloadLength(pver)
+	if uint32(len(payload)) > mpl {
+		str := fmt.Sprintf("payload exceeds max length - message "+
+			"is %v bytes, but max payload size for messages of "+
+			"type [%v] is %v.", len(payload), command, mpl)
+		return nil, messageError("ReadMessage", str)
+	}
+
+	// NOTE: This must be a *bytes.Buffer since the MsgVersion BtcDecode
+	// function requires it.
+	err = msg.BtcDecode(bytes.NewBuffer(payload), pver, enc)
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
 // ReadMessageWithEncodingN reads, validates, and parses the next bitcoin Message
 // from r for the provided protocol version and bitcoin network.  It returns the
 // number of bytes read in addition to the parsed Message and raw bytes which
@@ -88,6 +88,10 @@ const (
 	// software.
 	SFNode2X
 
+	// SFNodeP2PV2 is a flag used to indicate a peer supports the BIP0324
+	// v2 encrypted transport protocol.
+	SFNodeP2PV2 ServiceFlag = 1 << 11
+
 	// SFTrusted is not a service flag, it is used internally for addresses
 	// whose source is a DNS seed or manual entry, to distinguish them from
 	// nodes whose source is another node.
@@ -104,6 +108,7 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeBit5:    "SFNodeBit5",
 	SFNodeCF:      "SFNodeCF",
 	SFNode2X:      "SFNode2X",
+	SFNodeP2PV2:   "SFNodeP2PV2",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -117,6 +122,7 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeBit5,
 	SFNodeCF,
 	SFNode2X,
+	SFNodeP2PV2,
 }
 
 // String returns the ServiceFlag in human-readable form.
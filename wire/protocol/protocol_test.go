@@ -25,7 +25,8 @@ func TestServiceFlagStringer(t *testing.T) {
 		{protocol.SFNodeBit5, "SFNodeBit5"},
 		{protocol.SFNodeCF, "SFNodeCF"},
 		{protocol.SFNode2X, "SFNode2X"},
-		{0xffffffff, "SFNodeNetwork|SFNodeGetUTXO|SFNodeBloom|SFNodeWitness|SFNodeXthin|SFNodeBit5|SFNodeCF|SFNode2X|0xffffff00"},
+		{protocol.SFNodeP2PV2, "SFNodeP2PV2"},
+		{0xffffffff, "SFNodeNetwork|SFNodeGetUTXO|SFNodeBloom|SFNodeWitness|SFNodeXthin|SFNodeBit5|SFNodeCF|SFNode2X|SFNodeP2PV2|0xfffff700"},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -308,6 +308,30 @@ var (
 	ErrTxExistsInChain = mkError(Err.CodeWithDetail("ErrTxExistsInChain",
 		"transaction already exists (in chain)"),
 		"txn-already-known")
+
+	// ErrTooManyAncestors transaction has more unconfirmed ancestors than
+	// the mempool allows
+	ErrTooManyAncestors = mkError(Err.CodeWithDetail("ErrTooManyAncestors",
+		"transaction has too many unconfirmed ancestors"),
+		"too-many-ancestors")
+
+	// ErrAncestorsTooLarge transaction and its unconfirmed ancestors are
+	// larger than the mempool allows
+	ErrAncestorsTooLarge = mkError(Err.CodeWithDetail("ErrAncestorsTooLarge",
+		"transaction and its unconfirmed ancestors are too large"),
+		"ancestors-too-large")
+
+	// ErrTooManyDescendants transaction would give an unconfirmed ancestor
+	// more descendants than the mempool allows
+	ErrTooManyDescendants = mkError(Err.CodeWithDetail("ErrTooManyDescendants",
+		"an unconfirmed ancestor would have too many descendants"),
+		"too-many-descendants")
+
+	// ErrDescendantsTooLarge transaction would make an unconfirmed ancestor
+	// and its descendants larger than the mempool allows
+	ErrDescendantsTooLarge = mkError(Err.CodeWithDetail("ErrDescendantsTooLarge",
+		"an unconfirmed ancestor and its descendants would be too large"),
+		"descendants-too-large")
 )
 
 // These errors map directly to the rejection codes and are used by the
@@ -402,6 +426,14 @@ func ExtractRejectCode(e er.R) (rejCode wire.RejectCode, errString string, found
 		return
 
 	case ErrOrphanTransactionTooBig:
+		fallthrough
+	case ErrTooManyAncestors:
+		fallthrough
+	case ErrAncestorsTooLarge:
+		fallthrough
+	case ErrTooManyDescendants:
+		fallthrough
+	case ErrDescendantsTooLarge:
 		rejCode = wire.RejectNonstandard
 		return
 